@@ -1,30 +1,290 @@
 package config
 
 import (
-	"flag"
-	"os"
-	"strconv"
+	"errors"
+	"fmt"
+	"log/slog"
+	"slices"
+	"strings"
+	"time"
+)
+
+// Типы хранилища событий
+const (
+	StorageMemory = "memory"
+	StorageFile   = "file"
+	StorageWAL    = "wal"
+)
+
+// Форматы журнала
+const (
+	LogFormatJSON = "json"
+	LogFormatText = "text"
+)
+
+// Способы доставки напоминаний
+const (
+	NotifierLog     = "log"
+	NotifierWebhook = "webhook"
+	NotifierSMTP    = "smtp"
 )
 
 // Config содержит настройки приложения
 type Config struct {
-	Port int
+	Port      int            `yaml:"port"`
+	CalDAV    bool           `yaml:"caldav"`
+	Storage   StorageConfig  `yaml:"storage"`
+	Server    ServerConfig   `yaml:"server"`
+	Log       LogConfig      `yaml:"log"`
+	Auth      AuthConfig     `yaml:"auth"`
+	CORS      CORSConfig     `yaml:"cors"`
+	Reminders ReminderConfig `yaml:"reminders"`
+	Webhooks  WebhookConfig  `yaml:"webhooks"`
+	Trash     TrashConfig    `yaml:"trash"`
+	Limits    LimitsConfig   `yaml:"limits"`
+}
+
+// StorageConfig настройки хранилища событий
+type StorageConfig struct {
+	Type string `yaml:"type"`
+	// Path файл хранилища (снимок для хранилища с журналом)
+	Path string `yaml:"path"`
+	// WALCompactEvery число записей журнала, после которого он сворачивается в снимок
+	WALCompactEvery int `yaml:"wal_compact_every"`
+}
+
+// ServerConfig настройки HTTP-сервера
+type ServerConfig struct {
+	ReadTimeout       time.Duration `yaml:"read_timeout"`
+	ReadHeaderTimeout time.Duration `yaml:"read_header_timeout"`
+	WriteTimeout      time.Duration `yaml:"write_timeout"`
+	IdleTimeout       time.Duration `yaml:"idle_timeout"`
+	// ShutdownTimeout время на завершение текущих запросов при остановке
+	ShutdownTimeout time.Duration `yaml:"shutdown_timeout"`
+}
+
+// LogConfig настройки журнала приложения
+type LogConfig struct {
+	// Level минимальный уровень: debug, info, warn или error
+	Level string `yaml:"level"`
+	// Format формат записей: json или text
+	Format string `yaml:"format"`
+}
+
+// AuthConfig настройки аутентификации
+type AuthConfig struct {
+	Enabled bool `yaml:"enabled"`
+	// Secret ключ подписи токенов HS256
+	Secret string `yaml:"secret"`
+	// APIKeys API-ключи в виде "userID:key,userID:key"
+	APIKeys  string        `yaml:"api_keys"`
+	TokenTTL time.Duration `yaml:"token_ttl"`
+	// IssueTokenFor если задан, приложение печатает токен этого пользователя
+	// и завершается; задается только флагом
+	IssueTokenFor int `yaml:"-"`
+}
+
+// CORSConfig настройки доступа к API из браузера с других доменов.
+// Без разрешенных источников заголовки CORS не отправляются.
+type CORSConfig struct {
+	// AllowedOrigins источники вида https://example.com или "*" для любых
+	AllowedOrigins []string      `yaml:"allowed_origins"`
+	AllowedMethods []string      `yaml:"allowed_methods"`
+	AllowedHeaders []string      `yaml:"allowed_headers"`
+	MaxAge         time.Duration `yaml:"max_age"`
+}
+
+// ReminderConfig настройки фоновой доставки напоминаний
+type ReminderConfig struct {
+	Enabled    bool          `yaml:"enabled"`
+	Interval   time.Duration `yaml:"interval"`
+	MaxDelay   time.Duration `yaml:"max_delay"`
+	Notifier   string        `yaml:"notifier"`
+	WebhookURL string        `yaml:"webhook_url"`
+	SMTPAddr   string        `yaml:"smtp_addr"`
+	SMTPFrom   string        `yaml:"smtp_from"`
+	SMTPTo     []string      `yaml:"smtp_to"`
+}
+
+// WebhookConfig настройки доставки изменений событий на webhook
+type WebhookConfig struct {
+	Enabled bool `yaml:"enabled"`
+	// Timeout время ожидания ответа на одну попытку
+	Timeout     time.Duration `yaml:"timeout"`
+	MaxAttempts int           `yaml:"max_attempts"`
+	// Backoff пауза перед повтором, удваивается с каждой попыткой до MaxBackoff
+	Backoff    time.Duration `yaml:"backoff"`
+	MaxBackoff time.Duration `yaml:"max_backoff"`
+	// Workers сколько запросов отправляется одновременно
+	Workers int `yaml:"workers"`
+	// AllowPrivate разрешает webhook на внутренние адреса (loopback,
+	// частные сети); по умолчанию они запрещены
+	AllowPrivate bool `yaml:"allow_private"`
+}
+
+// TrashConfig настройки корзины удаленных событий
+type TrashConfig struct {
+	// Retention сколько удаленное событие хранится в корзине
+	Retention time.Duration `yaml:"retention"`
+	// PurgeInterval как часто из корзины удаляются события с истекшим сроком
+	PurgeInterval time.Duration `yaml:"purge_interval"`
 }
 
-// Load загружает конфигурацию из переменных окружения и флагов
-func Load() *Config {
-	var port int
-	flag.IntVar(&port, "port", 8080, "server port")
-	flag.Parse()
+// LimitsConfig ограничения запросов к API
+type LimitsConfig struct {
+	// Requests сколько запросов пользователь или, без аутентификации,
+	// адрес может делать за Interval; 0 отключает ограничение
+	Requests int           `yaml:"requests"`
+	Interval time.Duration `yaml:"interval"`
+	// Burst сколько запросов подряд можно сделать после простоя
+	Burst int `yaml:"burst"`
+	// AddressRequests и AddressBurst ограничивают запросы с одного адреса
+	// до аутентификации, в том числе с неверными токенами; 0 отключает
+	// ограничение
+	AddressRequests int `yaml:"address_requests"`
+	AddressBurst    int `yaml:"address_burst"`
+	// TrustedProxies сколько доверенных прокси стоит перед сервисом;
+	// адрес клиента берется из X-Forwarded-For с учетом их числа, 0 —
+	// заголовок не используется
+	TrustedProxies int `yaml:"trusted_proxies"`
+	// MaxRange наибольшая длина диапазона from–to в выборке событий
+	MaxRange time.Duration `yaml:"max_range"`
+	// MaxBodyBytes наибольший размер тела запроса в байтах
+	MaxBodyBytes int `yaml:"max_body_bytes"`
+}
+
+// Default возвращает настройки по умолчанию
+func Default() *Config {
+	return &Config{
+		Port: 8080,
+		Storage: StorageConfig{
+			Type:            StorageMemory,
+			Path:            "events.json",
+			WALCompactEvery: 1000,
+		},
+		Server: ServerConfig{
+			ReadTimeout:       15 * time.Second,
+			ReadHeaderTimeout: 5 * time.Second,
+			WriteTimeout:      30 * time.Second,
+			IdleTimeout:       60 * time.Second,
+			ShutdownTimeout:   15 * time.Second,
+		},
+		Log: LogConfig{Level: "info", Format: LogFormatJSON},
+		// Аутентификация включается явно вместе с секретом или ключами
+		Auth: AuthConfig{
+			TokenTTL: 24 * time.Hour,
+		},
+		CORS: CORSConfig{
+			AllowedMethods: []string{"GET", "POST", "PUT", "PATCH", "DELETE"},
+			AllowedHeaders: []string{"Authorization", "X-API-Key", "Content-Type", "If-Match", "X-Request-ID", "Last-Event-ID"},
+			MaxAge:         10 * time.Minute,
+		},
+		Reminders: ReminderConfig{
+			Interval: 30 * time.Second,
+			MaxDelay: 24 * time.Hour,
+			Notifier: NotifierLog,
+			SMTPAddr: "localhost:25",
+			SMTPFrom: "calendar@localhost",
+		},
+		Webhooks: WebhookConfig{
+			Enabled:     true,
+			Timeout:     10 * time.Second,
+			MaxAttempts: 5,
+			Backoff:     time.Second,
+			MaxBackoff:  5 * time.Minute,
+			Workers:     4,
+		},
+		Trash: TrashConfig{
+			Retention:     30 * 24 * time.Hour,
+			PurgeInterval: time.Hour,
+		},
+		Limits: LimitsConfig{
+			Requests:        20,
+			Interval:        time.Second,
+			Burst:           40,
+			AddressRequests: 100,
+			AddressBurst:    200,
+			MaxRange:        2 * 366 * 24 * time.Hour,
+			MaxBodyBytes:    1 << 20,
+		},
+	}
+}
 
-	// Проверяем переменную окружения
-	if envPort := os.Getenv("SERVER_PORT"); envPort != "" {
-		if parsedPort, err := strconv.Atoi(envPort); err == nil {
-			port = parsedPort
+// LogLevel возвращает уровень журнала; уровень проверяется в Validate
+func (c *Config) LogLevel() slog.Level {
+	var level slog.Level
+	_ = level.UnmarshalText([]byte(c.Log.Level))
+	return level
+}
+
+// Validate проверяет согласованность настроек и возвращает все найденные
+// ошибки сразу; каждая ошибка начинается с ключа настройки в файле
+func (c *Config) Validate() error {
+	var errs []error
+	check := func(ok bool, key, format string, args ...any) {
+		if !ok {
+			errs = append(errs, fmt.Errorf("%s: %s", key, fmt.Sprintf(format, args...)))
 		}
 	}
 
-	return &Config{
-		Port: port,
+	check(c.Port > 0 && c.Port <= 65535, "port", "must be between 1 and 65535, got %d", c.Port)
+
+	storageTypes := []string{StorageMemory, StorageFile, StorageWAL}
+	check(slices.Contains(storageTypes, c.Storage.Type), "storage.type", "must be one of %s, got %q", strings.Join(storageTypes, ", "), c.Storage.Type)
+	check(c.Storage.Type == StorageMemory || c.Storage.Path != "", "storage.path", "is required for the %s backend", c.Storage.Type)
+	check(c.Storage.WALCompactEvery > 0, "storage.wal_compact_every", "must be positive, got %d", c.Storage.WALCompactEvery)
+
+	check(c.Server.ReadTimeout >= 0, "server.read_timeout", "must not be negative")
+	check(c.Server.ReadHeaderTimeout >= 0, "server.read_header_timeout", "must not be negative")
+	check(c.Server.WriteTimeout >= 0, "server.write_timeout", "must not be negative")
+	check(c.Server.IdleTimeout >= 0, "server.idle_timeout", "must not be negative")
+	check(c.Server.ShutdownTimeout > 0, "server.shutdown_timeout", "must be positive")
+
+	var level slog.Level
+	check(level.UnmarshalText([]byte(c.Log.Level)) == nil, "log.level", "must be one of debug, info, warn, error, got %q", c.Log.Level)
+	check(c.Log.Format == LogFormatJSON || c.Log.Format == LogFormatText, "log.format", "must be %s or %s, got %q", LogFormatJSON, LogFormatText, c.Log.Format)
+
+	check(!c.Auth.Enabled || c.Auth.Secret != "" || c.Auth.APIKeys != "", "auth", "secret or API keys are required when authentication is enabled: set CALENDAR_AUTH_SECRET or CALENDAR_AUTH_API_KEYS, or disable authentication")
+	check(c.Auth.TokenTTL > 0, "auth.token_ttl", "must be positive")
+
+	for _, origin := range c.CORS.AllowedOrigins {
+		check(origin == "*" || strings.HasPrefix(origin, "http://") || strings.HasPrefix(origin, "https://"),
+			"cors.allowed_origins", "origin %q must be \"*\" or start with http:// or https://", origin)
+	}
+	check(c.CORS.MaxAge >= 0, "cors.max_age", "must not be negative")
+
+	if c.Reminders.Enabled {
+		notifiers := []string{NotifierLog, NotifierWebhook, NotifierSMTP}
+		check(c.Reminders.Interval > 0, "reminders.interval", "must be positive")
+		check(slices.Contains(notifiers, c.Reminders.Notifier), "reminders.notifier", "must be one of %s, got %q", strings.Join(notifiers, ", "), c.Reminders.Notifier)
+		check(c.Reminders.Notifier != NotifierWebhook || c.Reminders.WebhookURL != "", "reminders.webhook_url", "is required for the webhook notifier")
+		check(c.Reminders.Notifier != NotifierSMTP || len(c.Reminders.SMTPTo) > 0, "reminders.smtp_to", "at least one recipient is required for the smtp notifier")
+	}
+
+	if c.Webhooks.Enabled {
+		check(c.Webhooks.Timeout > 0, "webhooks.timeout", "must be positive")
+		check(c.Webhooks.MaxAttempts > 0, "webhooks.max_attempts", "must be positive, got %d", c.Webhooks.MaxAttempts)
+		check(c.Webhooks.Backoff > 0, "webhooks.backoff", "must be positive")
+		check(c.Webhooks.MaxBackoff >= c.Webhooks.Backoff, "webhooks.max_backoff", "must not be less than webhooks.backoff")
+		check(c.Webhooks.Workers > 0, "webhooks.workers", "must be positive, got %d", c.Webhooks.Workers)
 	}
+
+	check(c.Trash.Retention > 0, "trash.retention", "must be positive")
+	check(c.Trash.PurgeInterval > 0, "trash.purge_interval", "must be positive")
+
+	check(c.Limits.Requests >= 0, "limits.requests", "must not be negative, got %d", c.Limits.Requests)
+	if c.Limits.Requests > 0 {
+		check(c.Limits.Interval > 0, "limits.interval", "must be positive")
+		check(c.Limits.Burst > 0, "limits.burst", "must be positive, got %d", c.Limits.Burst)
+	}
+	check(c.Limits.AddressRequests >= 0, "limits.address_requests", "must not be negative, got %d", c.Limits.AddressRequests)
+	if c.Limits.AddressRequests > 0 {
+		check(c.Limits.Interval > 0, "limits.interval", "must be positive")
+		check(c.Limits.AddressBurst > 0, "limits.address_burst", "must be positive, got %d", c.Limits.AddressBurst)
+	}
+	check(c.Limits.TrustedProxies >= 0, "limits.trusted_proxies", "must not be negative, got %d", c.Limits.TrustedProxies)
+	check(c.Limits.MaxRange > 0, "limits.max_range", "must be positive")
+	check(c.Limits.MaxBodyBytes > 0, "limits.max_body_bytes", "must be positive, got %d", c.Limits.MaxBodyBytes)
+
+	return errors.Join(errs...)
 }
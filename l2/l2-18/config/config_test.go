@@ -0,0 +1,172 @@
+package config
+
+import (
+	"errors"
+	"flag"
+	"os"
+	"path/filepath"
+	"slices"
+	"strings"
+	"testing"
+	"time"
+)
+
+func writeConfig(t *testing.T, content string) string {
+	t.Helper()
+	path := filepath.Join(t.TempDir(), "config.yaml")
+	if err := os.WriteFile(path, []byte(content), 0644); err != nil {
+		t.Fatal(err)
+	}
+	return path
+}
+
+func TestLoad_Precedence(t *testing.T) {
+	path := writeConfig(t, `
+port: 9000
+storage:
+  type: file
+  path: /var/lib/calendar/events.json
+server:
+  read_timeout: 5s
+  write_timeout: 10s
+log:
+  level: debug
+auth:
+  enabled: false
+cors:
+  allowed_origins: [https://app.example.com]
+`)
+	t.Setenv("CALENDAR_CONFIG", path)
+	t.Setenv("CALENDAR_SERVER_WRITE_TIMEOUT", "20s")
+	t.Setenv("SERVER_PORT", "9100")
+	t.Setenv("CALENDAR_PORT", "9200")
+
+	cfg, err := Load([]string{"-port", "9300", "-storage", "wal", "-caldav"})
+	if err != nil {
+		t.Fatal("Load() error:", err)
+	}
+
+	tests := []struct {
+		name string
+		got  any
+		want any
+	}{
+		{"default", cfg.Server.IdleTimeout, 60 * time.Second},
+		{"file", cfg.Server.ReadTimeout, 5 * time.Second},
+		{"file list", cfg.CORS.AllowedOrigins[0], "https://app.example.com"},
+		{"env over file", cfg.Server.WriteTimeout, 20 * time.Second},
+		{"flag over env", cfg.Port, 9300},
+		{"flag over file", cfg.Storage.Type, StorageWAL},
+		{"file kept without flag", cfg.Storage.Path, "/var/lib/calendar/events.json"},
+		{"bool flag", cfg.CalDAV, true},
+		{"log level", cfg.LogLevel().String(), "DEBUG"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if tt.got != tt.want {
+				t.Errorf("got %v, want %v", tt.got, tt.want)
+			}
+		})
+	}
+}
+
+func TestLoad_Defaults(t *testing.T) {
+	// Запуск без настроек работает, как до появления аутентификации
+	cfg, err := Load(nil)
+	if err != nil {
+		t.Fatal("Load() error:", err)
+	}
+	if cfg.Auth.Enabled {
+		t.Error("authentication is enabled by default")
+	}
+	if !slices.Contains(cfg.CORS.AllowedHeaders, "X-API-Key") {
+		t.Errorf("CORS allowed headers %q do not include X-API-Key", cfg.CORS.AllowedHeaders)
+	}
+}
+
+func TestLoad_LegacyEnv(t *testing.T) {
+	t.Setenv("AUTH_ENABLED", "true")
+	t.Setenv("AUTH_SECRET", "legacy-secret")
+	t.Setenv("REMINDER_SMTP_TO", "a@example.com, b@example.com")
+
+	cfg, err := Load(nil)
+	if err != nil {
+		t.Fatal("Load() error:", err)
+	}
+	if !cfg.Auth.Enabled || cfg.Auth.Secret != "legacy-secret" {
+		t.Error("AUTH_ENABLED and AUTH_SECRET were ignored")
+	}
+	if !slices.Equal(cfg.Reminders.SMTPTo, []string{"a@example.com", "b@example.com"}) {
+		t.Errorf("SMTPTo = %q", cfg.Reminders.SMTPTo)
+	}
+}
+
+func TestLoad_Errors(t *testing.T) {
+	tests := []struct {
+		name    string
+		file    string
+		env     map[string]string
+		args    []string
+		wantErr []string
+	}{
+		{
+			name:    "unknown file key",
+			file:    "storage:\n  tpye: file\n",
+			wantErr: []string{"tpye"},
+		},
+		{
+			name:    "invalid env value",
+			env:     map[string]string{"CALENDAR_SERVER_READ_TIMEOUT": "soon"},
+			wantErr: []string{"CALENDAR_SERVER_READ_TIMEOUT", `invalid duration "soon"`},
+		},
+		{
+			name:    "invalid flag value",
+			args:    []string{"-port", "http"},
+			wantErr: []string{"-port", `invalid number "http"`},
+		},
+		{
+			name: "all validation errors at once",
+			args: []string{"-port", "0", "-storage", "sql", "-log-level", "verbose", "-auth", "-cors-origins", "example.com"},
+			wantErr: []string{
+				"port: must be between 1 and 65535",
+				`storage.type: must be one of memory, file, wal, got "sql"`,
+				"log.level:",
+				"auth: secret or API keys are required",
+				`cors.allowed_origins: origin "example.com"`,
+			},
+		},
+		{
+			name:    "reminders need recipients",
+			args:    []string{"-auth=false", "-reminders", "-reminder-notifier", "smtp"},
+			wantErr: []string{"reminders.smtp_to"},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			for key, value := range tt.env {
+				t.Setenv(key, value)
+			}
+			args := tt.args
+			if tt.file != "" {
+				args = append([]string{"-config", writeConfig(t, tt.file)}, args...)
+			}
+
+			_, err := Load(args)
+			if err == nil {
+				t.Fatal("Load() succeeded, want error")
+			}
+			for _, want := range tt.wantErr {
+				if !strings.Contains(err.Error(), want) {
+					t.Errorf("Load() error = %q, want it to mention %q", err, want)
+				}
+			}
+		})
+	}
+}
+
+func TestLoad_Help(t *testing.T) {
+	if _, err := Load([]string{"-h"}); !errors.Is(err, flag.ErrHelp) {
+		t.Errorf("Load(-h) error = %v, want flag.ErrHelp", err)
+	}
+}
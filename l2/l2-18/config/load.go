@@ -0,0 +1,230 @@
+package config
+
+import (
+	"bytes"
+	"errors"
+	"flag"
+	"fmt"
+	"io"
+	"os"
+	"strconv"
+	"strings"
+	"time"
+
+	"gopkg.in/yaml.v3"
+)
+
+// EnvPrefix префикс переменных окружения с настройками
+const EnvPrefix = "CALENDAR_"
+
+// setting настройка, которую можно задать переменной окружения и флагом.
+// Имя переменной — EnvPrefix и ключ в файле в верхнем регистре
+// с подчеркиваниями вместо точек: server.read_timeout -> CALENDAR_SERVER_READ_TIMEOUT.
+type setting struct {
+	key   string // ключ в файле; пустой — настройка задается только флагом
+	flag  string
+	usage string
+	// legacy прежнее имя переменной без префикса, читается с меньшим приоритетом
+	legacy string
+	field  func(c *Config) any // указатель на поле настройки
+}
+
+// settings таблица настроек, доступных из окружения и флагов
+var settings = []setting{
+	{"port", "port", "server port", "SERVER_PORT", func(c *Config) any { return &c.Port }},
+	{"caldav", "caldav", "serve user calendars over CalDAV under /caldav/", "CALDAV_ENABLED", func(c *Config) any { return &c.CalDAV }},
+
+	{"storage.type", "storage", "event storage backend (memory, file or wal)", "STORAGE_TYPE", func(c *Config) any { return &c.Storage.Type }},
+	{"storage.path", "storage-path", "path to the storage file (snapshot for the wal backend)", "STORAGE_PATH", func(c *Config) any { return &c.Storage.Path }},
+	{"storage.wal_compact_every", "wal-compact-every", "compact the write-ahead log into a snapshot after this many records", "WAL_COMPACT_EVERY", func(c *Config) any { return &c.Storage.WALCompactEvery }},
+
+	{"server.read_timeout", "read-timeout", "maximum time to read a request including the body", "SERVER_READ_TIMEOUT", func(c *Config) any { return &c.Server.ReadTimeout }},
+	{"server.read_header_timeout", "read-header-timeout", "maximum time to read request headers", "SERVER_READ_HEADER_TIMEOUT", func(c *Config) any { return &c.Server.ReadHeaderTimeout }},
+	{"server.write_timeout", "write-timeout", "maximum time to write a response", "SERVER_WRITE_TIMEOUT", func(c *Config) any { return &c.Server.WriteTimeout }},
+	{"server.idle_timeout", "idle-timeout", "how long to keep idle keep-alive connections", "SERVER_IDLE_TIMEOUT", func(c *Config) any { return &c.Server.IdleTimeout }},
+	{"server.shutdown_timeout", "shutdown-timeout", "how long to wait for in-flight requests on shutdown", "SERVER_SHUTDOWN_TIMEOUT", func(c *Config) any { return &c.Server.ShutdownTimeout }},
+
+	{"log.level", "log-level", "minimum log level (debug, info, warn or error)", "", func(c *Config) any { return &c.Log.Level }},
+	{"log.format", "log-format", "log record format (json or text)", "", func(c *Config) any { return &c.Log.Format }},
+
+	{"auth.enabled", "auth", "require a token or API key and take the user from it", "AUTH_ENABLED", func(c *Config) any { return &c.Auth.Enabled }},
+	{"auth.secret", "auth-secret", "secret key for signing HS256 tokens", "AUTH_SECRET", func(c *Config) any { return &c.Auth.Secret }},
+	{"auth.api_keys", "api-keys", "comma-separated API keys as userID:key", "API_KEYS", func(c *Config) any { return &c.Auth.APIKeys }},
+	{"auth.token_ttl", "token-ttl", "lifetime of issued tokens", "TOKEN_TTL", func(c *Config) any { return &c.Auth.TokenTTL }},
+	{"", "issue-token", "print a signed token for the given user ID and exit", "", func(c *Config) any { return &c.Auth.IssueTokenFor }},
+
+	{"cors.allowed_origins", "cors-origins", "comma-separated origins allowed to call the API from a browser, or *", "", func(c *Config) any { return &c.CORS.AllowedOrigins }},
+	{"cors.allowed_methods", "cors-methods", "comma-separated methods allowed in cross-origin requests", "", func(c *Config) any { return &c.CORS.AllowedMethods }},
+	{"cors.allowed_headers", "cors-headers", "comma-separated headers allowed in cross-origin requests", "", func(c *Config) any { return &c.CORS.AllowedHeaders }},
+	{"cors.max_age", "cors-max-age", "how long browsers may cache preflight responses", "", func(c *Config) any { return &c.CORS.MaxAge }},
+
+	{"reminders.enabled", "reminders", "deliver event reminders in the background", "REMINDERS_ENABLED", func(c *Config) any { return &c.Reminders.Enabled }},
+	{"reminders.interval", "reminder-interval", "how often to look for due reminders", "REMINDER_INTERVAL", func(c *Config) any { return &c.Reminders.Interval }},
+	{"reminders.max_delay", "reminder-max-delay", "drop reminders that are late by more than this after downtime", "", func(c *Config) any { return &c.Reminders.MaxDelay }},
+	{"reminders.notifier", "reminder-notifier", "reminder delivery method (log, webhook or smtp)", "REMINDER_NOTIFIER", func(c *Config) any { return &c.Reminders.Notifier }},
+	{"reminders.webhook_url", "reminder-webhook-url", "URL to POST reminders to", "REMINDER_WEBHOOK_URL", func(c *Config) any { return &c.Reminders.WebhookURL }},
+	{"reminders.smtp_addr", "reminder-smtp-addr", "SMTP relay address", "REMINDER_SMTP_ADDR", func(c *Config) any { return &c.Reminders.SMTPAddr }},
+	{"reminders.smtp_from", "reminder-smtp-from", "sender address of reminder mails", "", func(c *Config) any { return &c.Reminders.SMTPFrom }},
+	{"reminders.smtp_to", "reminder-smtp-to", "comma-separated recipients of reminder mails", "REMINDER_SMTP_TO", func(c *Config) any { return &c.Reminders.SMTPTo }},
+
+	{"webhooks.enabled", "webhooks", "deliver event changes to registered webhooks", "", func(c *Config) any { return &c.Webhooks.Enabled }},
+	{"webhooks.timeout", "webhook-timeout", "how long to wait for a webhook response", "", func(c *Config) any { return &c.Webhooks.Timeout }},
+	{"webhooks.max_attempts", "webhook-max-attempts", "delivery attempts before a change goes to the dead-letter list", "", func(c *Config) any { return &c.Webhooks.MaxAttempts }},
+	{"webhooks.backoff", "webhook-backoff", "pause before the first retry, doubled for each next one", "", func(c *Config) any { return &c.Webhooks.Backoff }},
+	{"webhooks.max_backoff", "webhook-max-backoff", "longest pause between retries", "", func(c *Config) any { return &c.Webhooks.MaxBackoff }},
+	{"webhooks.workers", "webhook-workers", "how many webhook requests are sent concurrently", "", func(c *Config) any { return &c.Webhooks.Workers }},
+	{"webhooks.allow_private", "webhook-allow-private", "allow webhooks to loopback, link-local and private addresses", "", func(c *Config) any { return &c.Webhooks.AllowPrivate }},
+
+	{"trash.retention", "trash-retention", "how long deleted events are kept in the trash", "TRASH_RETENTION", func(c *Config) any { return &c.Trash.Retention }},
+	{"trash.purge_interval", "trash-purge-interval", "how often expired events are purged from the trash", "", func(c *Config) any { return &c.Trash.PurgeInterval }},
+
+	{"limits.requests", "rate-limit", "requests a user or address may make per limits interval, 0 to disable", "", func(c *Config) any { return &c.Limits.Requests }},
+	{"limits.interval", "rate-limit-interval", "interval the rate limit is counted over", "", func(c *Config) any { return &c.Limits.Interval }},
+	{"limits.burst", "rate-limit-burst", "requests allowed in a row after a pause", "", func(c *Config) any { return &c.Limits.Burst }},
+	{"limits.address_requests", "address-rate-limit", "requests an address may make per limits interval before authentication, 0 to disable", "", func(c *Config) any { return &c.Limits.AddressRequests }},
+	{"limits.address_burst", "address-rate-limit-burst", "requests an address may make in a row after a pause", "", func(c *Config) any { return &c.Limits.AddressBurst }},
+	{"limits.trusted_proxies", "trusted-proxies", "number of proxies in front of the server whose X-Forwarded-For entries are trusted, 0 to ignore the header", "", func(c *Config) any { return &c.Limits.TrustedProxies }},
+	{"limits.max_range", "max-range", "longest from-to range of an event query", "", func(c *Config) any { return &c.Limits.MaxRange }},
+	{"limits.max_body_bytes", "max-body-bytes", "largest accepted request body in bytes", "", func(c *Config) any { return &c.Limits.MaxBodyBytes }},
+}
+
+// env возвращает имя переменной окружения настройки
+func (s setting) env() string {
+	if s.key == "" {
+		return ""
+	}
+	return EnvPrefix + strings.ToUpper(strings.ReplaceAll(s.key, ".", "_"))
+}
+
+// Load собирает конфигурацию из слоев по возрастанию приоритета:
+// значения по умолчанию, YAML-файл (флаг -config или CALENDAR_CONFIG),
+// переменные окружения и флаги командной строки, после чего проверяет ее.
+// Для -h возвращает flag.ErrHelp.
+func Load(args []string) (*Config, error) {
+	cfg := Default()
+
+	fs := flag.NewFlagSet("calendar", flag.ContinueOnError)
+	configPath := fs.String("config", os.Getenv(EnvPrefix+"CONFIG"), "path to a YAML configuration file")
+
+	// Флаги применяются после файла и окружения, поэтому пока запоминаются
+	var flagValues []func(c *Config) error
+	for _, s := range settings {
+		usage := fmt.Sprintf("%s (default %s)", s.usage, formatValue(s.field(cfg)))
+		if env := s.env(); env != "" {
+			usage += "; env " + env
+		}
+		set := func(value string) error {
+			flagValues = append(flagValues, func(c *Config) error {
+				return parseValue(s.field(c), value)
+			})
+			return parseValue(s.field(Default()), value)
+		}
+		if _, ok := s.field(cfg).(*bool); ok {
+			fs.BoolFunc(s.flag, usage, set)
+		} else {
+			fs.Func(s.flag, usage, set)
+		}
+	}
+	if err := fs.Parse(args); err != nil {
+		return nil, err
+	}
+
+	if *configPath != "" {
+		if err := loadFile(cfg, *configPath); err != nil {
+			return nil, err
+		}
+	}
+
+	for _, s := range settings {
+		for _, env := range []string{s.legacy, s.env()} {
+			value, ok := os.LookupEnv(env)
+			if env == "" || !ok {
+				continue
+			}
+			if err := parseValue(s.field(cfg), value); err != nil {
+				return nil, fmt.Errorf("%s: %v", env, err)
+			}
+		}
+	}
+
+	for _, apply := range flagValues {
+		if err := apply(cfg); err != nil {
+			return nil, err
+		}
+	}
+
+	if err := cfg.Validate(); err != nil {
+		return nil, fmt.Errorf("invalid configuration:\n%w", err)
+	}
+	return cfg, nil
+}
+
+// loadFile накладывает на конфигурацию значения из YAML-файла.
+// Неизвестные ключи считаются ошибкой, чтобы опечатки не терялись молча.
+func loadFile(cfg *Config, path string) error {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return fmt.Errorf("failed to read config file: %v", err)
+	}
+
+	decoder := yaml.NewDecoder(bytes.NewReader(data))
+	decoder.KnownFields(true)
+	if err := decoder.Decode(cfg); err != nil && !errors.Is(err, io.EOF) {
+		return fmt.Errorf("invalid config file %s: %v", path, err)
+	}
+	return nil
+}
+
+// parseValue разбирает строковое значение в поле по указателю
+func parseValue(field any, value string) error {
+	switch p := field.(type) {
+	case *string:
+		*p = value
+	case *int:
+		n, err := strconv.Atoi(value)
+		if err != nil {
+			return fmt.Errorf("invalid number %q", value)
+		}
+		*p = n
+	case *bool:
+		b, err := strconv.ParseBool(value)
+		if err != nil {
+			return fmt.Errorf("invalid boolean %q", value)
+		}
+		*p = b
+	case *time.Duration:
+		d, err := time.ParseDuration(value)
+		if err != nil {
+			return fmt.Errorf("invalid duration %q", value)
+		}
+		*p = d
+	case *[]string:
+		*p = nil
+		for _, item := range strings.Split(value, ",") {
+			if item = strings.TrimSpace(item); item != "" {
+				*p = append(*p, item)
+			}
+		}
+	default:
+		return fmt.Errorf("unsupported setting type %T", field)
+	}
+	return nil
+}
+
+// formatValue форматирует значение поля для справки по флагам
+func formatValue(field any) string {
+	switch p := field.(type) {
+	case *string:
+		return strconv.Quote(*p)
+	case *[]string:
+		return strconv.Quote(strings.Join(*p, ","))
+	case *int:
+		return strconv.Itoa(*p)
+	case *bool:
+		return strconv.FormatBool(*p)
+	case *time.Duration:
+		return p.String()
+	default:
+		return fmt.Sprint(field)
+	}
+}
@@ -1,3 +1,5 @@
 module l2-18
 
 go 1.24
+
+require gopkg.in/yaml.v3 v3.0.1
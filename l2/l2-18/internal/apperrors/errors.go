@@ -0,0 +1,131 @@
+package apperrors
+
+import (
+	"errors"
+	"fmt"
+	"sort"
+	"strings"
+)
+
+// Виды ошибок приложения. Слои оборачивают их через %w, а обработчики
+// выбирают код ответа с помощью errors.Is.
+var (
+	ErrNotFound   = errors.New("not found")
+	ErrForbidden  = errors.New("forbidden")
+	ErrConflict   = errors.New("conflict")
+	ErrValidation = errors.New("validation failed")
+)
+
+// kindError ошибка с собственным текстом, относящаяся к одному из видов
+type kindError struct {
+	kind error
+	msg  string
+}
+
+// Error возвращает текст ошибки
+func (e *kindError) Error() string {
+	return e.msg
+}
+
+// Unwrap возвращает вид ошибки для errors.Is
+func (e *kindError) Unwrap() error {
+	return e.kind
+}
+
+// Errorf создает ошибку вида kind с текстом по формату
+func Errorf(kind error, format string, args ...interface{}) error {
+	return &kindError{kind: kind, msg: fmt.Sprintf(format, args...)}
+}
+
+// ValidationError ошибка проверки запроса с сообщениями по отдельным полям
+type ValidationError struct {
+	Fields map[string]string
+}
+
+// Field создает ошибку проверки одного поля
+func Field(field, format string, args ...interface{}) error {
+	v := &ValidationError{}
+	v.Add(field, format, args...)
+	return v
+}
+
+// Add добавляет сообщение для поля. Первое сообщение для поля сохраняется.
+func (e *ValidationError) Add(field, format string, args ...interface{}) {
+	if e.Fields == nil {
+		e.Fields = make(map[string]string)
+	}
+	if _, exists := e.Fields[field]; !exists {
+		e.Fields[field] = fmt.Sprintf(format, args...)
+	}
+}
+
+// Err возвращает ошибку, если в ней есть сообщения, иначе nil
+func (e *ValidationError) Err() error {
+	if len(e.Fields) == 0 {
+		return nil
+	}
+	return e
+}
+
+// Error возвращает сообщения полей в порядке их имен
+func (e *ValidationError) Error() string {
+	fields := make([]string, 0, len(e.Fields))
+	for field := range e.Fields {
+		fields = append(fields, field)
+	}
+	sort.Strings(fields)
+
+	messages := make([]string, 0, len(fields))
+	for _, field := range fields {
+		messages = append(messages, e.Fields[field])
+	}
+	return strings.Join(messages, "; ")
+}
+
+// Unwrap относит ошибку к виду ErrValidation
+func (e *ValidationError) Unwrap() error {
+	return ErrValidation
+}
+
+// FieldErrors возвращает сообщения по полям, если err содержит ValidationError
+func FieldErrors(err error) map[string]string {
+	var v *ValidationError
+	if errors.As(err, &v) {
+		return v.Fields
+	}
+	return nil
+}
+
+// Машиночитаемые коды ошибок в ответах API
+const (
+	CodeNotFound         = "not_found"
+	CodeForbidden        = "forbidden"
+	CodeConflict         = "conflict"
+	CodeValidation       = "validation_failed"
+	CodeBadRequest       = "bad_request"
+	CodeAuth             = "unauthorized"
+	CodeInternal         = "internal"
+	CodeMethodNotAllowed = "method_not_allowed"
+
+	CodeScheduleConflict     = "schedule_conflict"
+	CodePreconditionRequired = "precondition_required"
+	CodePreconditionFailed   = "precondition_failed"
+	CodeTooLarge             = "request_too_large"
+	CodeRateLimited          = "rate_limited"
+)
+
+// Code возвращает машиночитаемый код ошибки по ее виду
+func Code(err error) string {
+	switch {
+	case errors.Is(err, ErrNotFound):
+		return CodeNotFound
+	case errors.Is(err, ErrForbidden):
+		return CodeForbidden
+	case errors.Is(err, ErrConflict):
+		return CodeConflict
+	case errors.Is(err, ErrValidation):
+		return CodeValidation
+	default:
+		return CodeInternal
+	}
+}
@@ -0,0 +1,46 @@
+package apperrors
+
+import (
+	"errors"
+	"fmt"
+	"testing"
+)
+
+func TestErrors_Kinds(t *testing.T) {
+	v := &ValidationError{}
+	if v.Err() != nil {
+		t.Fatal("empty ValidationError should not be an error")
+	}
+	v.Add("title", "title is required")
+	v.Add("date", "date or start is required")
+	v.Add("title", "ignored second message")
+
+	tests := []struct {
+		name     string
+		err      error
+		wantCode string
+		wantMsg  string
+	}{
+		{"not found", fmt.Errorf("failed to update event: %w", Errorf(ErrNotFound, "event with ID %d not found", 5)), CodeNotFound, "failed to update event: event with ID 5 not found"},
+		{"forbidden", Errorf(ErrForbidden, "event does not belong to user"), CodeForbidden, "event does not belong to user"},
+		{"validation", fmt.Errorf("failed to create event: %w", v.Err()), CodeValidation, "failed to create event: date or start is required; title is required"},
+		{"single field", Field("rrule", "invalid recurrence rule"), CodeValidation, "invalid recurrence rule"},
+		{"untyped", errors.New("disk is full"), CodeInternal, "disk is full"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := Code(tt.err); got != tt.wantCode {
+				t.Errorf("Code() = %q, want %q", got, tt.wantCode)
+			}
+			if tt.err.Error() != tt.wantMsg {
+				t.Errorf("Error() = %q, want %q", tt.err.Error(), tt.wantMsg)
+			}
+		})
+	}
+
+	fields := FieldErrors(fmt.Errorf("wrapped: %w", v))
+	if len(fields) != 2 || fields["title"] != "title is required" {
+		t.Errorf("FieldErrors() = %v", fields)
+	}
+}
@@ -0,0 +1,104 @@
+package auth
+
+import (
+	"context"
+	"crypto/sha256"
+	"errors"
+	"fmt"
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// ErrNoCredentials запрос не содержит учетных данных
+var ErrNoCredentials = errors.New("authentication required")
+
+// Authenticator определяет пользователя по учетным данным запроса:
+// подписанному токену (Authorization: Bearer) или API-ключу
+// (заголовок X-API-Key). Для CalDAV-клиентов токен или ключ можно
+// передать паролем в Basic-аутентификации, имя пользователя не используется.
+type Authenticator struct {
+	secret  []byte
+	apiKeys map[[sha256.Size]byte]int // хеш ключа -> userID
+	now     func() time.Time
+}
+
+// NewAuthenticator создает аутентификатор с ключом подписи токенов secret
+// и API-ключами apiKeys (ключ -> userID)
+func NewAuthenticator(secret string, apiKeys map[string]int) *Authenticator {
+	a := &Authenticator{
+		secret:  []byte(secret),
+		apiKeys: make(map[[sha256.Size]byte]int, len(apiKeys)),
+		now:     time.Now,
+	}
+	// Храним хеши, чтобы поиск ключа не зависел по времени от его содержимого
+	for key, userID := range apiKeys {
+		a.apiKeys[sha256.Sum256([]byte(key))] = userID
+	}
+	return a
+}
+
+// ParseAPIKeys разбирает список API-ключей вида "userID:key,userID:key"
+func ParseAPIKeys(s string) (map[string]int, error) {
+	keys := make(map[string]int)
+	for _, entry := range strings.Split(s, ",") {
+		entry = strings.TrimSpace(entry)
+		if entry == "" {
+			continue
+		}
+		user, key, ok := strings.Cut(entry, ":")
+		if !ok || key == "" {
+			return nil, fmt.Errorf("invalid API key entry %q, want userID:key", entry)
+		}
+		userID, err := strconv.Atoi(user)
+		if err != nil || userID <= 0 {
+			return nil, fmt.Errorf("invalid user ID in API key entry %q", entry)
+		}
+		keys[key] = userID
+	}
+	return keys, nil
+}
+
+// Authenticate возвращает пользователя, которому принадлежат учетные данные запроса
+func (a *Authenticator) Authenticate(r *http.Request) (int, error) {
+	if key := r.Header.Get("X-API-Key"); key != "" {
+		return a.apiKey(key)
+	}
+
+	if _, password, ok := r.BasicAuth(); ok {
+		if userID, err := a.apiKey(password); err == nil {
+			return userID, nil
+		}
+		return a.ParseToken(password)
+	}
+
+	scheme, token, ok := strings.Cut(r.Header.Get("Authorization"), " ")
+	if !ok || !strings.EqualFold(scheme, "Bearer") {
+		return 0, ErrNoCredentials
+	}
+	return a.ParseToken(strings.TrimSpace(token))
+}
+
+// apiKey возвращает владельца API-ключа
+func (a *Authenticator) apiKey(key string) (int, error) {
+	userID, ok := a.apiKeys[sha256.Sum256([]byte(key))]
+	if !ok {
+		return 0, fmt.Errorf("invalid API key")
+	}
+	return userID, nil
+}
+
+// userKey ключ пользователя в контексте запроса
+type userKey struct{}
+
+// WithUser возвращает контекст с аутентифицированным пользователем
+func WithUser(ctx context.Context, userID int) context.Context {
+	return context.WithValue(ctx, userKey{}, userID)
+}
+
+// UserID возвращает аутентифицированного пользователя из контекста
+func UserID(ctx context.Context) (int, bool) {
+	userID, ok := ctx.Value(userKey{}).(int)
+	return userID, ok
+}
@@ -0,0 +1,99 @@
+package auth
+
+import (
+	"encoding/base64"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestAuthenticator_Tokens(t *testing.T) {
+	a := NewAuthenticator("secret", nil)
+	now := time.Date(2024, 1, 8, 9, 0, 0, 0, time.UTC)
+	a.now = func() time.Time { return now }
+
+	token, err := a.IssueToken(42, time.Hour)
+	if err != nil {
+		t.Fatal("IssueToken() error:", err)
+	}
+
+	userID, err := a.ParseToken(token)
+	if err != nil || userID != 42 {
+		t.Fatalf("ParseToken() = %d, %v, want 42", userID, err)
+	}
+
+	parts := strings.Split(token, ".")
+	noneHeader := base64.RawURLEncoding.EncodeToString([]byte(`{"alg":"none","typ":"JWT"}`))
+	otherKey, _ := NewAuthenticator("other", nil).IssueToken(42, time.Hour)
+	otherTyp := base64.RawURLEncoding.EncodeToString([]byte(`{"alg":"HS256","typ":"JWE"}`)) + "." + parts[1]
+	otherTyp += "." + a.sign(otherTyp)
+
+	tests := []struct {
+		name  string
+		token string
+	}{
+		{"malformed", "not-a-token"},
+		{"tampered payload", parts[0] + "." + base64.RawURLEncoding.EncodeToString([]byte(`{"sub":"1","exp":9999999999}`)) + "." + parts[2]},
+		{"alg none", noneHeader + "." + parts[1] + "."},
+		{"other key", otherKey},
+		{"typ not JWT", otherTyp},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if _, err := a.ParseToken(tt.token); err == nil {
+				t.Error("ParseToken() should reject token")
+			}
+		})
+	}
+
+	now = now.Add(2 * time.Hour)
+	if _, err := a.ParseToken(token); err == nil {
+		t.Error("ParseToken() should reject expired token")
+	}
+}
+
+func TestAuthenticator_Authenticate(t *testing.T) {
+	keys, err := ParseAPIKeys("7:script-key, 8:caldav-key")
+	if err != nil {
+		t.Fatal("ParseAPIKeys() error:", err)
+	}
+	a := NewAuthenticator("secret", keys)
+	token, _ := a.IssueToken(5, time.Hour)
+
+	tests := []struct {
+		name    string
+		headers map[string]string
+		basic   []string
+		want    int
+		wantErr bool
+	}{
+		{name: "bearer token", headers: map[string]string{"Authorization": "Bearer " + token}, want: 5},
+		{name: "api key", headers: map[string]string{"X-API-Key": "script-key"}, want: 7},
+		{name: "basic with api key", basic: []string{"anyone", "caldav-key"}, want: 8},
+		{name: "basic with token", basic: []string{"anyone", token}, want: 5},
+		{name: "unknown api key", headers: map[string]string{"X-API-Key": "guess"}, wantErr: true},
+		{name: "no credentials", wantErr: true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			r := httptest.NewRequest("GET", "/events_for_day", nil)
+			for k, v := range tt.headers {
+				r.Header.Set(k, v)
+			}
+			if tt.basic != nil {
+				r.SetBasicAuth(tt.basic[0], tt.basic[1])
+			}
+
+			got, err := a.Authenticate(r)
+			if (err != nil) != tt.wantErr || got != tt.want {
+				t.Errorf("Authenticate() = %d, %v, want %d (error %v)", got, err, tt.want, tt.wantErr)
+			}
+		})
+	}
+
+	if _, err := ParseAPIKeys("nouser"); err == nil {
+		t.Error("ParseAPIKeys() should reject entry without user ID")
+	}
+}
@@ -0,0 +1,110 @@
+package auth
+
+import (
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// ErrInvalidToken токен поврежден, подписан другим ключом или истек
+var ErrInvalidToken = errors.New("invalid token")
+
+// jwtHeader заголовок токена: поддерживается только HS256
+var jwtHeader = base64.RawURLEncoding.EncodeToString([]byte(`{"alg":"HS256","typ":"JWT"}`))
+
+// claims полезная нагрузка токена. Пользователь хранится в sub строкой, как требует RFC 7519.
+type claims struct {
+	Subject   string `json:"sub"`
+	IssuedAt  int64  `json:"iat"`
+	ExpiresAt int64  `json:"exp"`
+}
+
+// IssueToken выпускает токен пользователя со сроком действия ttl
+func (a *Authenticator) IssueToken(userID int, ttl time.Duration) (string, error) {
+	if len(a.secret) == 0 {
+		return "", fmt.Errorf("token secret is not configured")
+	}
+	if userID <= 0 {
+		return "", fmt.Errorf("invalid user ID")
+	}
+
+	now := a.now()
+	payload, err := json.Marshal(claims{
+		Subject:   strconv.Itoa(userID),
+		IssuedAt:  now.Unix(),
+		ExpiresAt: now.Add(ttl).Unix(),
+	})
+	if err != nil {
+		return "", err
+	}
+
+	signingInput := jwtHeader + "." + base64.RawURLEncoding.EncodeToString(payload)
+	return signingInput + "." + a.sign(signingInput), nil
+}
+
+// ParseToken проверяет подпись и срок действия токена и возвращает пользователя
+func (a *Authenticator) ParseToken(token string) (int, error) {
+	if len(a.secret) == 0 {
+		return 0, ErrInvalidToken
+	}
+
+	parts := strings.Split(token, ".")
+	if len(parts) != 3 {
+		return 0, ErrInvalidToken
+	}
+
+	// Алгоритм проверяется явно, чтобы нельзя было подменить его (например, на none),
+	// а тип, если указан, должен быть JWT
+	header, err := base64.RawURLEncoding.DecodeString(parts[0])
+	if err != nil {
+		return 0, ErrInvalidToken
+	}
+	var h struct {
+		Alg string `json:"alg"`
+		Typ string `json:"typ"`
+	}
+	if err := json.Unmarshal(header, &h); err != nil || h.Alg != "HS256" || (h.Typ != "" && h.Typ != "JWT") {
+		return 0, ErrInvalidToken
+	}
+
+	signature, err := base64.RawURLEncoding.DecodeString(parts[2])
+	if err != nil {
+		return 0, ErrInvalidToken
+	}
+	expected, _ := base64.RawURLEncoding.DecodeString(a.sign(parts[0] + "." + parts[1]))
+	if !hmac.Equal(signature, expected) {
+		return 0, ErrInvalidToken
+	}
+
+	payload, err := base64.RawURLEncoding.DecodeString(parts[1])
+	if err != nil {
+		return 0, ErrInvalidToken
+	}
+	var c claims
+	if err := json.Unmarshal(payload, &c); err != nil {
+		return 0, ErrInvalidToken
+	}
+	if c.ExpiresAt == 0 || !a.now().Before(time.Unix(c.ExpiresAt, 0)) {
+		return 0, ErrInvalidToken
+	}
+
+	userID, err := strconv.Atoi(c.Subject)
+	if err != nil || userID <= 0 {
+		return 0, ErrInvalidToken
+	}
+
+	return userID, nil
+}
+
+// sign возвращает подпись HMAC-SHA256 в base64url
+func (a *Authenticator) sign(signingInput string) string {
+	mac := hmac.New(sha256.New, a.secret)
+	mac.Write([]byte(signingInput))
+	return base64.RawURLEncoding.EncodeToString(mac.Sum(nil))
+}
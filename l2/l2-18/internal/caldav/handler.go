@@ -0,0 +1,578 @@
+package caldav
+
+import (
+	"bytes"
+	"encoding/xml"
+	"errors"
+	"fmt"
+	"io"
+	"l2-18/internal/apperrors"
+	"l2-18/internal/auth"
+	"l2-18/internal/ical"
+	"l2-18/internal/models"
+	"l2-18/internal/service"
+	"log/slog"
+	"net/http"
+	"net/url"
+	"slices"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// maxBodySize ограничение размера тела запроса
+const maxBodySize = 1 << 20
+
+// timeRangeLayout формат границ time-range
+const timeRangeLayout = "20060102T150405Z"
+
+// Handler обслуживает календари пользователей по протоколу CalDAV (RFC 4791).
+// Каждый пользователь — коллекция {prefix}{userID}/, каждый объект календаря
+// (событие или серия с измененными экземплярами) — ресурс {UID}.ics в ней.
+type Handler struct {
+	service *service.EventService
+	prefix  string
+}
+
+// NewHandler создает обработчик CalDAV, обслуживающий пути под prefix
+func NewHandler(service *service.EventService, prefix string) *Handler {
+	if !strings.HasSuffix(prefix, "/") {
+		prefix += "/"
+	}
+	return &Handler{service: service, prefix: prefix}
+}
+
+// ServeHTTP реализует интерфейс http.Handler
+func (h *Handler) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	if r.Method == http.MethodOptions {
+		h.options(w)
+		return
+	}
+
+	userID, uid, err := h.parsePath(r.URL.Path)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusNotFound)
+		return
+	}
+
+	// При включенной аутентификации доступен только собственный календарь
+	if authUser, ok := auth.UserID(r.Context()); ok && authUser != userID {
+		http.Error(w, "access to another user's calendar is forbidden", http.StatusForbidden)
+		return
+	}
+
+	switch {
+	case r.Method == "PROPFIND":
+		h.propfind(w, r, userID, uid)
+	case r.Method == "REPORT" && uid == "":
+		h.report(w, r, userID)
+	case (r.Method == http.MethodGet || r.Method == http.MethodHead) && uid != "":
+		h.get(w, r, userID, uid)
+	case r.Method == http.MethodPut && uid != "":
+		h.put(w, r, userID, uid)
+	case r.Method == http.MethodDelete && uid != "":
+		h.delete(w, r, userID, uid)
+	default:
+		http.Error(w, "method not allowed", http.StatusMethodNotAllowed)
+	}
+}
+
+// options сообщает о поддержке CalDAV
+func (h *Handler) options(w http.ResponseWriter) {
+	w.Header().Set("DAV", "1, 3, calendar-access")
+	w.Header().Set("Allow", "OPTIONS, GET, HEAD, PUT, DELETE, PROPFIND, REPORT")
+	w.WriteHeader(http.StatusOK)
+}
+
+// propfind возвращает свойства коллекции пользователя или объекта календаря
+func (h *Handler) propfind(w http.ResponseWriter, r *http.Request, userID int, uid string) {
+	req, err := parsePropfind(r.Body)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+
+	ms := newMultistatus()
+
+	if uid != "" {
+		object, err := h.service.GetEventObject(userID, uid)
+		if err != nil {
+			writeError(w, r, err)
+			return
+		}
+		found, missing := h.objectProps(object, req)
+		ms.response(h.objectHref(userID, uid), found, missing)
+		ms.write(w)
+		return
+	}
+
+	objects, err := h.service.ListEventObjects(userID, time.Time{}, time.Time{})
+	if err != nil {
+		writeError(w, r, err)
+		return
+	}
+
+	found, missing := h.collectionProps(userID, objects, req)
+	ms.response(h.collectionHref(userID), found, missing)
+
+	if r.Header.Get("Depth") != "0" {
+		for _, object := range objects {
+			found, missing := h.objectProps(object, req)
+			ms.response(h.objectHref(userID, object[0].UID), found, missing)
+		}
+	}
+
+	ms.write(w)
+}
+
+// report обрабатывает calendar-query и calendar-multiget
+func (h *Handler) report(w http.ResponseWriter, r *http.Request, userID int) {
+	var req reportRequest
+	if err := xml.NewDecoder(io.LimitReader(r.Body, maxBodySize)).Decode(&req); err != nil {
+		http.Error(w, fmt.Sprintf("invalid report body: %v", err), http.StatusBadRequest)
+		return
+	}
+
+	props := &propfindRequest{Prop: req.Prop}
+	if req.Prop == nil {
+		props.AllProp = &struct{}{}
+	}
+
+	ms := newMultistatus()
+
+	switch {
+	case req.XMLName.Space == nsCalDAV && req.XMLName.Local == "calendar-query":
+		var from, to time.Time
+		if req.Filter != nil {
+			if tr := req.Filter.CompFilter.eventTimeRange(); tr != nil {
+				var err error
+				if from, to, err = parseTimeRange(tr); err != nil {
+					http.Error(w, err.Error(), http.StatusBadRequest)
+					return
+				}
+			}
+		}
+
+		objects, err := h.service.ListEventObjects(userID, from, to)
+		if err != nil {
+			writeError(w, r, err)
+			return
+		}
+		for _, object := range objects {
+			found, missing := h.objectProps(object, props)
+			ms.response(h.objectHref(userID, object[0].UID), found, missing)
+		}
+
+	case req.XMLName.Space == nsCalDAV && req.XMLName.Local == "calendar-multiget":
+		for _, href := range req.Hrefs {
+			hrefUser, uid, err := h.parsePath(hrefPath(href))
+			if err != nil || hrefUser != userID || uid == "" {
+				ms.status(href, http.StatusNotFound)
+				continue
+			}
+			object, err := h.service.GetEventObject(userID, uid)
+			if err != nil {
+				ms.status(href, errorStatus(err))
+				continue
+			}
+			found, missing := h.objectProps(object, props)
+			ms.response(href, found, missing)
+		}
+
+	default:
+		http.Error(w, "unsupported report", http.StatusForbidden)
+		return
+	}
+
+	ms.write(w)
+}
+
+// get отдает объект календаря в формате iCalendar
+func (h *Handler) get(w http.ResponseWriter, r *http.Request, userID int, uid string) {
+	object, err := h.service.GetEventObject(userID, uid)
+	if err != nil {
+		writeError(w, r, err)
+		return
+	}
+
+	data, err := encodeObject(object)
+	if err != nil {
+		http.Error(w, "failed to encode calendar", http.StatusInternalServerError)
+		return
+	}
+
+	w.Header().Set("Content-Type", "text/calendar; charset=utf-8")
+	w.Header().Set("ETag", objectETag(object))
+	w.Header().Set("Last-Modified", lastModified(object).UTC().Format(http.TimeFormat))
+	w.WriteHeader(http.StatusOK)
+	if r.Method != http.MethodHead {
+		_, _ = w.Write(data)
+	}
+}
+
+// put создает или заменяет объект календаря
+func (h *Handler) put(w http.ResponseWriter, r *http.Request, userID int, uid string) {
+	existing, err := h.service.GetEventObject(userID, uid)
+	if err != nil && !errors.Is(err, apperrors.ErrNotFound) {
+		writeError(w, r, err)
+		return
+	}
+	exists := err == nil
+	if !checkPreconditions(r, existing, exists) {
+		http.Error(w, "precondition failed", http.StatusPreconditionFailed)
+		return
+	}
+
+	loc, err := h.service.ResolveLocation(userID, "")
+	if err != nil {
+		writeError(w, r, err)
+		return
+	}
+
+	components, err := ical.Decode(io.LimitReader(r.Body, maxBodySize), loc)
+	if err != nil {
+		http.Error(w, fmt.Sprintf("invalid calendar data: %v", err), http.StatusBadRequest)
+		return
+	}
+
+	versions := expectedVersions(r, existing)
+	created, err := h.service.PutEventObject(userID, uid, components, versions)
+	if err != nil {
+		writeWriteError(w, r, err, versions)
+		return
+	}
+
+	if object, err := h.service.GetEventObject(userID, uid); err == nil {
+		w.Header().Set("ETag", objectETag(object))
+	}
+	if created {
+		w.WriteHeader(http.StatusCreated)
+	} else {
+		w.WriteHeader(http.StatusNoContent)
+	}
+}
+
+// delete удаляет объект календаря
+func (h *Handler) delete(w http.ResponseWriter, r *http.Request, userID int, uid string) {
+	existing, err := h.service.GetEventObject(userID, uid)
+	if err != nil {
+		writeError(w, r, err)
+		return
+	}
+	if !checkPreconditions(r, existing, true) {
+		http.Error(w, "precondition failed", http.StatusPreconditionFailed)
+		return
+	}
+
+	versions := expectedVersions(r, existing)
+	if err := h.service.DeleteEventObject(userID, uid, versions); err != nil {
+		writeWriteError(w, r, err, versions)
+		return
+	}
+
+	w.WriteHeader(http.StatusNoContent)
+}
+
+// errorStatus возвращает код ответа для ошибки сервиса по ее виду
+func errorStatus(err error) int {
+	switch {
+	case errors.Is(err, apperrors.ErrForbidden):
+		return http.StatusForbidden
+	case errors.Is(err, apperrors.ErrNotFound):
+		return http.StatusNotFound
+	case errors.Is(err, apperrors.ErrConflict):
+		return http.StatusConflict
+	case errors.Is(err, apperrors.ErrValidation):
+		return http.StatusBadRequest
+	default:
+		return http.StatusInternalServerError
+	}
+}
+
+// writeError отправляет ответ с ошибкой сервиса. Текст внутренних ошибок
+// не раскрывается клиенту, а пишется в лог.
+func writeError(w http.ResponseWriter, r *http.Request, err error) {
+	status := errorStatus(err)
+	if status == http.StatusInternalServerError {
+		slog.ErrorContext(r.Context(), "internal error", "error", err)
+		http.Error(w, "internal server error", status)
+		return
+	}
+	http.Error(w, err.Error(), status)
+}
+
+// writeWriteError отправляет ответ с ошибкой изменения объекта. Конфликт
+// версий при заданном If-Match означает, что объект изменился после
+// проверки предусловий.
+func writeWriteError(w http.ResponseWriter, r *http.Request, err error, versions map[int]int) {
+	if versions != nil && errors.Is(err, apperrors.ErrConflict) {
+		http.Error(w, "precondition failed", http.StatusPreconditionFailed)
+		return
+	}
+	writeError(w, r, err)
+}
+
+// collectionProps вычисляет свойства коллекции пользователя
+func (h *Handler) collectionProps(userID int, objects [][]*models.Event, req *propfindRequest) ([]propValue, []xml.Name) {
+	href := hrefElement(h.collectionHref(userID))
+
+	available := []propValue{
+		{propName(nsDAV, "resourcetype"), "<d:collection/><c:calendar/>"},
+		{propName(nsDAV, "displayname"), escape(fmt.Sprintf("Calendar of user %d", userID))},
+		{propName(nsDAV, "current-user-principal"), href},
+		{propName(nsDAV, "principal-URL"), href},
+		{propName(nsDAV, "owner"), href},
+		{propName(nsCalDAV, "calendar-home-set"), href},
+		{propName(nsCalDAV, "supported-calendar-component-set"), `<c:comp name="VEVENT"/>`},
+		{propName(nsDAV, "supported-report-set"),
+			"<d:supported-report><d:report><c:calendar-query/></d:report></d:supported-report>" +
+				"<d:supported-report><d:report><c:calendar-multiget/></d:report></d:supported-report>"},
+		{propName(nsCS, "getctag"), escape(collectionCTag(objects))},
+	}
+
+	return selectProps(available, req, nil)
+}
+
+// objectProps вычисляет свойства объекта календаря
+func (h *Handler) objectProps(object []*models.Event, req *propfindRequest) ([]propValue, []xml.Name) {
+	available := []propValue{
+		{propName(nsDAV, "resourcetype"), ""},
+		{propName(nsDAV, "getetag"), escape(objectETag(object))},
+		{propName(nsDAV, "getcontenttype"), "text/calendar; charset=utf-8; component=VEVENT"},
+		{propName(nsDAV, "getlastmodified"), lastModified(object).UTC().Format(http.TimeFormat)},
+	}
+
+	// calendar-data дорого вычислять и не входит в allprop
+	calendarData := propName(nsCalDAV, "calendar-data")
+	lazy := map[xml.Name]func() (string, bool){
+		calendarData: func() (string, bool) {
+			data, err := encodeObject(object)
+			if err != nil {
+				return "", false
+			}
+			return escape(string(data)), true
+		},
+	}
+
+	return selectProps(available, req, lazy)
+}
+
+// selectProps отбирает запрошенные свойства. Для allprop возвращаются все
+// свойства из available, ленивые свойства — только по явному запросу.
+func selectProps(available []propValue, req *propfindRequest, lazy map[xml.Name]func() (string, bool)) ([]propValue, []xml.Name) {
+	if req.Prop == nil {
+		if req.PropName != nil {
+			names := make([]propValue, 0, len(available))
+			for _, p := range available {
+				names = append(names, propValue{name: p.name})
+			}
+			return names, nil
+		}
+		return available, nil
+	}
+
+	var found []propValue
+	var missing []xml.Name
+	for _, name := range req.Prop.names() {
+		if compute, ok := lazy[name]; ok {
+			if inner, ok := compute(); ok {
+				found = append(found, propValue{name, inner})
+				continue
+			}
+		}
+
+		matched := false
+		for _, p := range available {
+			if p.name == name {
+				found = append(found, p)
+				matched = true
+				break
+			}
+		}
+		if !matched {
+			missing = append(missing, name)
+		}
+	}
+
+	return found, missing
+}
+
+// parsePath разбирает путь вида {prefix}{userID}/ или {prefix}{userID}/{UID}.ics
+func (h *Handler) parsePath(path string) (int, string, error) {
+	rest, ok := strings.CutPrefix(path, h.prefix)
+	if !ok {
+		return 0, "", fmt.Errorf("path is outside of calendar root")
+	}
+
+	userPart, resource, _ := strings.Cut(rest, "/")
+	userID, err := strconv.Atoi(userPart)
+	if err != nil || userID <= 0 {
+		return 0, "", fmt.Errorf("calendar user not found")
+	}
+
+	if resource == "" {
+		return userID, "", nil
+	}
+
+	name, ok := strings.CutSuffix(resource, ".ics")
+	if !ok || strings.Contains(name, "/") {
+		return 0, "", fmt.Errorf("calendar resource not found")
+	}
+	uid, err := url.PathUnescape(name)
+	if err != nil || uid == "" {
+		return 0, "", fmt.Errorf("calendar resource not found")
+	}
+
+	return userID, uid, nil
+}
+
+// collectionHref путь коллекции пользователя
+func (h *Handler) collectionHref(userID int) string {
+	return fmt.Sprintf("%s%d/", h.prefix, userID)
+}
+
+// objectHref путь объекта календаря
+func (h *Handler) objectHref(userID int, uid string) string {
+	return h.collectionHref(userID) + url.PathEscape(uid) + ".ics"
+}
+
+// parsePropfind читает тело PROPFIND, пустое тело означает allprop
+func parsePropfind(body io.Reader) (*propfindRequest, error) {
+	data, err := io.ReadAll(io.LimitReader(body, maxBodySize))
+	if err != nil {
+		return nil, err
+	}
+
+	req := &propfindRequest{}
+	if len(bytes.TrimSpace(data)) == 0 {
+		req.AllProp = &struct{}{}
+		return req, nil
+	}
+
+	if err := xml.Unmarshal(data, req); err != nil {
+		return nil, fmt.Errorf("invalid propfind body: %v", err)
+	}
+	if req.Prop == nil && req.PropName == nil {
+		req.AllProp = &struct{}{}
+	}
+
+	return req, nil
+}
+
+// parseTimeRange разбирает границы time-range, пустые границы не ограничивают выборку
+func parseTimeRange(tr *timeRange) (time.Time, time.Time, error) {
+	var from, to time.Time
+	var err error
+
+	if tr.Start != "" {
+		if from, err = time.Parse(timeRangeLayout, tr.Start); err != nil {
+			return time.Time{}, time.Time{}, fmt.Errorf("invalid time-range start: %v", err)
+		}
+	}
+	if tr.End != "" {
+		if to, err = time.Parse(timeRangeLayout, tr.End); err != nil {
+			return time.Time{}, time.Time{}, fmt.Errorf("invalid time-range end: %v", err)
+		}
+	}
+
+	return from, to, nil
+}
+
+// hrefPath возвращает путь из href, который может быть абсолютным URL
+func hrefPath(href string) string {
+	u, err := url.Parse(href)
+	if err != nil {
+		return href
+	}
+	return u.Path
+}
+
+// checkPreconditions проверяет заголовки If-Match и If-None-Match
+func checkPreconditions(r *http.Request, object []*models.Event, exists bool) bool {
+	if match := r.Header.Get("If-Match"); match != "" {
+		if !exists {
+			return false
+		}
+		if match != "*" && !etagListContains(match, objectETag(object)) {
+			return false
+		}
+	}
+
+	if noneMatch := r.Header.Get("If-None-Match"); noneMatch != "" && exists {
+		if noneMatch == "*" || etagListContains(noneMatch, objectETag(object)) {
+			return false
+		}
+	}
+
+	return true
+}
+
+// etagListContains проверяет, есть ли etag в списке заголовка
+func etagListContains(header, etag string) bool {
+	for _, candidate := range strings.Split(header, ",") {
+		candidate = strings.TrimPrefix(strings.TrimSpace(candidate), "W/")
+		if candidate == etag {
+			return true
+		}
+	}
+	return false
+}
+
+// encodeObject кодирует объект календаря в iCalendar
+func encodeObject(object []*models.Event) ([]byte, error) {
+	var buf bytes.Buffer
+	if err := ical.Encode(&buf, object); err != nil {
+		return nil, err
+	}
+	return buf.Bytes(), nil
+}
+
+// lastModified возвращает время последнего изменения объекта
+func lastModified(object []*models.Event) time.Time {
+	var latest time.Time
+	for _, event := range object {
+		if event.UpdatedAt.After(latest) {
+			latest = event.UpdatedAt
+		}
+	}
+	return latest
+}
+
+// objectETag вычисляет ETag объекта по ID и версиям его компонентов.
+// Версии проверяются хранилищем при записи, поэтому проверка If-Match
+// атомарна с изменением.
+func objectETag(object []*models.Event) string {
+	parts := make([]string, 0, len(object))
+	for _, event := range object {
+		parts = append(parts, strconv.Itoa(event.ID)+"."+strconv.Itoa(event.Version))
+	}
+	slices.Sort(parts)
+	return `"` + strings.Join(parts, "-") + `"`
+}
+
+// expectedVersions возвращает версии компонентов объекта, с которыми
+// совпал If-Match, или nil, если запрос не требует конкретной версии
+func expectedVersions(r *http.Request, object []*models.Event) map[int]int {
+	if match := r.Header.Get("If-Match"); match == "" || match == "*" {
+		return nil
+	}
+	versions := make(map[int]int, len(object))
+	for _, event := range object {
+		versions[event.ID] = event.Version
+	}
+	return versions
+}
+
+// collectionCTag вычисляет ctag коллекции: меняется при любом изменении,
+// создании или удалении объекта
+func collectionCTag(objects [][]*models.Event) string {
+	var latest time.Time
+	count := 0
+	for _, object := range objects {
+		if modified := lastModified(object); modified.After(latest) {
+			latest = modified
+		}
+		count += len(object)
+	}
+	return strconv.FormatInt(latest.UnixNano(), 36) + "-" + strconv.Itoa(count)
+}
@@ -0,0 +1,171 @@
+package caldav
+
+import (
+	"errors"
+	"fmt"
+	"io"
+	"l2-18/internal/models"
+	"l2-18/internal/service"
+	"l2-18/internal/storage"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+)
+
+const standup = "BEGIN:VCALENDAR\r\n" +
+	"VERSION:2.0\r\n" +
+	"PRODID:-//Test//EN\r\n" +
+	"BEGIN:VEVENT\r\n" +
+	"UID:standup-1\r\n" +
+	"DTSTART:20240108T090000Z\r\n" +
+	"DTEND:20240108T091500Z\r\n" +
+	"SUMMARY:Standup\r\n" +
+	"RRULE:FREQ=DAILY;COUNT=5\r\n" +
+	"END:VEVENT\r\n" +
+	"END:VCALENDAR\r\n"
+
+func do(t *testing.T, h http.Handler, method, path, body string, headers map[string]string) *httptest.ResponseRecorder {
+	t.Helper()
+	req := httptest.NewRequest(method, path, strings.NewReader(body))
+	for k, v := range headers {
+		req.Header.Set(k, v)
+	}
+	rec := httptest.NewRecorder()
+	h.ServeHTTP(rec, req)
+	return rec
+}
+
+func TestHandler_Lifecycle(t *testing.T) {
+	h := NewHandler(service.NewEventService(storage.NewInMemoryEventStorage()), "/caldav/")
+
+	// Создание объекта
+	rec := do(t, h, http.MethodPut, "/caldav/1/standup-1.ics", standup, map[string]string{"If-None-Match": "*"})
+	if rec.Code != http.StatusCreated {
+		t.Fatalf("PUT status = %d, body %s", rec.Code, rec.Body)
+	}
+	etag := rec.Header().Get("ETag")
+	if etag == "" {
+		t.Fatal("PUT did not return ETag")
+	}
+
+	// Повторное создание с If-None-Match: * запрещено
+	rec = do(t, h, http.MethodPut, "/caldav/1/standup-1.ics", standup, map[string]string{"If-None-Match": "*"})
+	if rec.Code != http.StatusPreconditionFailed {
+		t.Errorf("second PUT status = %d, want 412", rec.Code)
+	}
+
+	// Список коллекции
+	rec = do(t, h, "PROPFIND", "/caldav/1/", `<?xml version="1.0"?>
+<d:propfind xmlns:d="DAV:" xmlns:cs="http://calendarserver.org/ns/">
+  <d:prop><d:resourcetype/><d:getetag/><cs:getctag/><d:unknown/></d:prop>
+</d:propfind>`, map[string]string{"Depth": "1"})
+	if rec.Code != http.StatusMultiStatus {
+		t.Fatalf("PROPFIND status = %d", rec.Code)
+	}
+	body := rec.Body.String()
+	for _, want := range []string{"<c:calendar/>", "/caldav/1/standup-1.ics", escape(etag), "404 Not Found"} {
+		if !strings.Contains(body, want) {
+			t.Errorf("PROPFIND response does not contain %q:\n%s", want, body)
+		}
+	}
+
+	// calendar-query по диапазону с экземпляром серии
+	query := `<?xml version="1.0"?>
+<c:calendar-query xmlns:d="DAV:" xmlns:c="urn:ietf:params:xml:ns:caldav">
+  <d:prop><d:getetag/><c:calendar-data/></d:prop>
+  <c:filter><c:comp-filter name="VCALENDAR"><c:comp-filter name="VEVENT">
+    <c:time-range start="%s" end="%s"/>
+  </c:comp-filter></c:comp-filter></c:filter>
+</c:calendar-query>`
+	rec = do(t, h, "REPORT", "/caldav/1/", fmt.Sprintf(query, "20240110T000000Z", "20240111T000000Z"), nil)
+	if rec.Code != http.StatusMultiStatus || !strings.Contains(rec.Body.String(), "SUMMARY:Standup") {
+		t.Errorf("REPORT in range = %d:\n%s", rec.Code, rec.Body)
+	}
+	rec = do(t, h, "REPORT", "/caldav/1/", fmt.Sprintf(query, "20240201T000000Z", "20240301T000000Z"), nil)
+	if strings.Contains(rec.Body.String(), "standup-1.ics") {
+		t.Errorf("REPORT out of range returned the series:\n%s", rec.Body)
+	}
+
+	// Чтение ресурса
+	rec = do(t, h, http.MethodGet, "/caldav/1/standup-1.ics", "", nil)
+	data, _ := io.ReadAll(rec.Body)
+	if rec.Code != http.StatusOK || rec.Header().Get("ETag") != etag || !strings.Contains(string(data), "RRULE:FREQ=DAILY;COUNT=5") {
+		t.Errorf("GET = %d, ETag %q:\n%s", rec.Code, rec.Header().Get("ETag"), data)
+	}
+
+	// Обновление с устаревшим ETag
+	rec = do(t, h, http.MethodPut, "/caldav/1/standup-1.ics", standup, map[string]string{"If-Match": `"stale"`})
+	if rec.Code != http.StatusPreconditionFailed {
+		t.Errorf("PUT with stale If-Match status = %d, want 412", rec.Code)
+	}
+
+	// Обновление с актуальным ETag
+	updated := strings.Replace(standup, "SUMMARY:Standup", "SUMMARY:Daily standup", 1)
+	rec = do(t, h, http.MethodPut, "/caldav/1/standup-1.ics", updated, map[string]string{"If-Match": etag})
+	if rec.Code != http.StatusNoContent {
+		t.Errorf("PUT with current If-Match status = %d, body %s", rec.Code, rec.Body)
+	}
+	if rec.Header().Get("ETag") == etag {
+		t.Error("PUT did not change ETag")
+	}
+
+	// Удаление по ETag до обновления
+	rec = do(t, h, http.MethodDelete, "/caldav/1/standup-1.ics", "", map[string]string{"If-Match": etag})
+	if rec.Code != http.StatusPreconditionFailed {
+		t.Errorf("DELETE with stale If-Match status = %d, want 412", rec.Code)
+	}
+
+	// Другой пользователь не видит объект
+	rec = do(t, h, http.MethodGet, "/caldav/2/standup-1.ics", "", nil)
+	if rec.Code != http.StatusNotFound {
+		t.Errorf("GET of another user's resource status = %d, want 404", rec.Code)
+	}
+
+	// Удаление
+	rec = do(t, h, http.MethodDelete, "/caldav/1/standup-1.ics", "", nil)
+	if rec.Code != http.StatusNoContent {
+		t.Errorf("DELETE status = %d", rec.Code)
+	}
+	rec = do(t, h, http.MethodGet, "/caldav/1/standup-1.ics", "", nil)
+	if rec.Code != http.StatusNotFound {
+		t.Errorf("GET after DELETE status = %d, want 404", rec.Code)
+	}
+}
+
+// brokenStorage хранилище, которое не может сохранять события
+type brokenStorage struct {
+	*storage.InMemoryEventStorage
+}
+
+func (brokenStorage) Create(*models.Event) error {
+	return errors.New("disk is full")
+}
+
+func TestHandler_StatusCodes(t *testing.T) {
+	twoMasters := strings.Replace(standup, "END:VCALENDAR", strings.Join(strings.Split(standup, "\r\n")[3:10], "\r\n")+"\r\nEND:VCALENDAR", 1)
+
+	tests := []struct {
+		name    string
+		strg    storage.EventStorage
+		method  string
+		path    string
+		body    string
+		want    int
+		wantMsg string
+	}{
+		{"get missing", storage.NewInMemoryEventStorage(), http.MethodGet, "/caldav/1/missing.ics", "", http.StatusNotFound, "not found"},
+		{"delete missing", storage.NewInMemoryEventStorage(), http.MethodDelete, "/caldav/1/missing.ics", "", http.StatusNotFound, "not found"},
+		{"put invalid object", storage.NewInMemoryEventStorage(), http.MethodPut, "/caldav/1/standup-1.ics", twoMasters, http.StatusBadRequest, "master event"},
+		{"put storage failure", brokenStorage{storage.NewInMemoryEventStorage()}, http.MethodPut, "/caldav/1/standup-1.ics", standup, http.StatusInternalServerError, "internal server error"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			h := NewHandler(service.NewEventService(tt.strg), "/caldav/")
+			rec := do(t, h, tt.method, tt.path, tt.body, nil)
+			if rec.Code != tt.want || !strings.Contains(rec.Body.String(), tt.wantMsg) {
+				t.Errorf("%s %s = %d %q, want %d with %q", tt.method, tt.path, rec.Code, rec.Body, tt.want, tt.wantMsg)
+			}
+		})
+	}
+}
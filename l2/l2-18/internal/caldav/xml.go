@@ -0,0 +1,179 @@
+package caldav
+
+import (
+	"bytes"
+	"encoding/xml"
+	"fmt"
+	"net/http"
+	"strings"
+)
+
+// Пространства имен XML, используемые в ответах
+const (
+	nsDAV    = "DAV:"
+	nsCalDAV = "urn:ietf:params:xml:ns:caldav"
+	nsCS     = "http://calendarserver.org/ns/"
+)
+
+// prefixes префиксы, объявленные в корне multistatus
+var prefixes = map[string]string{
+	nsDAV:    "d",
+	nsCalDAV: "c",
+	nsCS:     "cs",
+}
+
+// propName возвращает полное имя свойства
+func propName(space, local string) xml.Name {
+	return xml.Name{Space: space, Local: local}
+}
+
+// propValue найденное свойство с готовым XML-содержимым
+type propValue struct {
+	name  xml.Name
+	inner string
+}
+
+// propfindRequest тело PROPFIND
+type propfindRequest struct {
+	XMLName  xml.Name   `xml:"DAV: propfind"`
+	AllProp  *struct{}  `xml:"DAV: allprop"`
+	PropName *struct{}  `xml:"DAV: propname"`
+	Prop     *propNames `xml:"DAV: prop"`
+}
+
+// propNames список запрошенных свойств
+type propNames struct {
+	Names []struct {
+		XMLName xml.Name
+	} `xml:",any"`
+}
+
+// names возвращает имена запрошенных свойств
+func (p *propNames) names() []xml.Name {
+	if p == nil {
+		return nil
+	}
+	result := make([]xml.Name, 0, len(p.Names))
+	for _, n := range p.Names {
+		result = append(result, n.XMLName)
+	}
+	return result
+}
+
+// reportRequest тело REPORT calendar-query или calendar-multiget
+type reportRequest struct {
+	XMLName xml.Name
+	Prop    *propNames `xml:"DAV: prop"`
+	Hrefs   []string   `xml:"DAV: href"`
+	Filter  *struct {
+		CompFilter compFilter `xml:"urn:ietf:params:xml:ns:caldav comp-filter"`
+	} `xml:"urn:ietf:params:xml:ns:caldav filter"`
+}
+
+// compFilter фильтр компонентов calendar-query
+type compFilter struct {
+	Name        string       `xml:"name,attr"`
+	TimeRange   *timeRange   `xml:"urn:ietf:params:xml:ns:caldav time-range"`
+	CompFilters []compFilter `xml:"urn:ietf:params:xml:ns:caldav comp-filter"`
+}
+
+// timeRange ограничение по времени в формате UTC iCalendar
+type timeRange struct {
+	Start string `xml:"start,attr"`
+	End   string `xml:"end,attr"`
+}
+
+// eventTimeRange находит time-range фильтра VCALENDAR/VEVENT
+func (f *compFilter) eventTimeRange() *timeRange {
+	if !strings.EqualFold(f.Name, "VCALENDAR") {
+		return nil
+	}
+	for _, sub := range f.CompFilters {
+		if strings.EqualFold(sub.Name, "VEVENT") {
+			return sub.TimeRange
+		}
+	}
+	return nil
+}
+
+// multistatus формирует ответ 207 Multi-Status
+type multistatus struct {
+	buf bytes.Buffer
+}
+
+// newMultistatus начинает документ multistatus
+func newMultistatus() *multistatus {
+	m := &multistatus{}
+	m.buf.WriteString(xml.Header)
+	m.buf.WriteString(`<d:multistatus xmlns:d="DAV:" xmlns:c="` + nsCalDAV + `" xmlns:cs="` + nsCS + `">`)
+	return m
+}
+
+// response добавляет ответ для ресурса с найденными и отсутствующими свойствами
+func (m *multistatus) response(href string, found []propValue, missing []xml.Name) {
+	m.buf.WriteString("<d:response><d:href>")
+	xml.EscapeText(&m.buf, []byte(href))
+	m.buf.WriteString("</d:href>")
+
+	if len(found) > 0 || len(missing) == 0 {
+		m.buf.WriteString("<d:propstat><d:prop>")
+		for _, p := range found {
+			m.element(p.name, p.inner)
+		}
+		m.buf.WriteString("</d:prop><d:status>HTTP/1.1 200 OK</d:status></d:propstat>")
+	}
+	if len(missing) > 0 {
+		m.buf.WriteString("<d:propstat><d:prop>")
+		for _, name := range missing {
+			m.element(name, "")
+		}
+		m.buf.WriteString("</d:prop><d:status>HTTP/1.1 404 Not Found</d:status></d:propstat>")
+	}
+
+	m.buf.WriteString("</d:response>")
+}
+
+// status добавляет ответ для ресурса без свойств, например 404 в multiget
+func (m *multistatus) status(href string, code int) {
+	m.buf.WriteString("<d:response><d:href>")
+	xml.EscapeText(&m.buf, []byte(href))
+	fmt.Fprintf(&m.buf, "</d:href><d:status>HTTP/1.1 %d %s</d:status></d:response>", code, http.StatusText(code))
+}
+
+// element записывает элемент с префиксом пространства имен
+func (m *multistatus) element(name xml.Name, inner string) {
+	tag := name.Local
+	decl := ""
+	if prefix, ok := prefixes[name.Space]; ok {
+		tag = prefix + ":" + name.Local
+	} else if name.Space != "" {
+		tag = "x:" + name.Local
+		decl = ` xmlns:x="` + escape(name.Space) + `"`
+	}
+
+	if inner == "" {
+		m.buf.WriteString("<" + tag + decl + "/>")
+		return
+	}
+	m.buf.WriteString("<" + tag + decl + ">" + inner + "</" + tag + ">")
+}
+
+// write отправляет документ клиенту
+func (m *multistatus) write(w http.ResponseWriter) {
+	m.buf.WriteString("</d:multistatus>")
+	w.Header().Set("Content-Type", "application/xml; charset=utf-8")
+	w.WriteHeader(http.StatusMultiStatus)
+	_, _ = w.Write(m.buf.Bytes())
+}
+
+// escape экранирует текст для вставки в XML
+func escape(s string) string {
+	var b bytes.Buffer
+	xml.EscapeText(&b, []byte(s))
+	return b.String()
+}
+
+// hrefElement возвращает элемент d:href
+func hrefElement(href string) string {
+	return "<d:href>" + escape(href) + "</d:href>"
+}
@@ -0,0 +1,154 @@
+// Package feed шина изменений событий внутри приложения: сервис публикует
+// изменения, а лента SSE и рассылка webhook подписываются на них
+package feed
+
+import (
+	"errors"
+	"l2-18/internal/models"
+	"sync"
+	"time"
+)
+
+// DefaultHistorySize сколько последних изменений шина хранит для подписчиков,
+// которые продолжают ленту после обрыва
+const DefaultHistorySize = 1000
+
+// ErrClosed шина закрыта и не принимает подписчиков
+var ErrClosed = errors.New("change feed is closed")
+
+// Bus рассылает изменения подписчикам. Публикация не блокируется:
+// подписчик, который не успевает забирать изменения и переполнил буфер,
+// отключается и может подписаться снова с последнего полученного Seq.
+type Bus struct {
+	mu      sync.Mutex
+	seq     int64
+	history []models.Change // последние изменения по возрастанию Seq
+	size    int
+	subs    map[*Subscription]struct{}
+	closed  bool
+	now     func() time.Time
+}
+
+// Subscription подписка на изменения. Канал C закрывается, когда подписка
+// закрыта, шина закрыта или подписчик отстал.
+type Subscription struct {
+	C       <-chan models.Change
+	ch      chan models.Change
+	filter  func(models.Change) bool
+	bus     *Bus
+	dropped bool
+}
+
+// NewBus создает шину, которая хранит historySize последних изменений;
+// historySize <= 0 означает DefaultHistorySize
+func NewBus(historySize int) *Bus {
+	if historySize <= 0 {
+		historySize = DefaultHistorySize
+	}
+	return &Bus{
+		size: historySize,
+		subs: make(map[*Subscription]struct{}),
+		now:  time.Now,
+	}
+}
+
+// Publish присваивает изменению очередной Seq и время и рассылает его
+// подписчикам, фильтр которых его пропускает
+func (b *Bus) Publish(change models.Change) models.Change {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	b.seq++
+	change.Seq = b.seq
+	change.Time = b.now().UTC()
+
+	if len(b.history) == b.size {
+		b.history = append(b.history[:0], b.history[1:]...)
+	}
+	b.history = append(b.history, change)
+
+	for sub := range b.subs {
+		if sub.filter != nil && !sub.filter(change) {
+			continue
+		}
+		select {
+		case sub.ch <- change:
+		default:
+			sub.dropped = true
+			b.unsubscribe(sub)
+		}
+	}
+
+	return change
+}
+
+// Subscribe подписывается на изменения, которые пропускает filter
+// (nil — на все). Сначала в канал попадают сохраненные изменения с Seq
+// больше after, затем новые; buffer — размер очереди сверх них.
+func (b *Bus) Subscribe(after int64, filter func(models.Change) bool, buffer int) (*Subscription, error) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	if b.closed {
+		return nil, ErrClosed
+	}
+
+	var missed []models.Change
+	for _, change := range b.history {
+		if change.Seq > after && (filter == nil || filter(change)) {
+			missed = append(missed, change)
+		}
+	}
+
+	ch := make(chan models.Change, buffer+len(missed))
+	for _, change := range missed {
+		ch <- change
+	}
+	sub := &Subscription{C: ch, ch: ch, filter: filter, bus: b}
+	b.subs[sub] = struct{}{}
+
+	return sub, nil
+}
+
+// Seq возвращает номер последнего опубликованного изменения
+func (b *Bus) Seq() int64 {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	return b.seq
+}
+
+// Close закрывает все подписки и перестает принимать новые
+func (b *Bus) Close() {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	b.closed = true
+	for sub := range b.subs {
+		b.unsubscribe(sub)
+	}
+}
+
+// unsubscribe закрывает подписку без блокировки
+func (b *Bus) unsubscribe(sub *Subscription) {
+	if _, ok := b.subs[sub]; ok {
+		delete(b.subs, sub)
+		close(sub.ch)
+	}
+}
+
+// Close отменяет подписку
+func (s *Subscription) Close() {
+	s.bus.mu.Lock()
+	defer s.bus.mu.Unlock()
+
+	s.bus.unsubscribe(s)
+}
+
+// Dropped сообщает, что подписка закрыта из-за того, что подписчик отстал
+func (s *Subscription) Dropped() bool {
+	s.bus.mu.Lock()
+	defer s.bus.mu.Unlock()
+
+	return s.dropped
+}
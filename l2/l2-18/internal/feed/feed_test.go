@@ -0,0 +1,94 @@
+package feed
+
+import (
+	"errors"
+	"l2-18/internal/models"
+	"testing"
+)
+
+// drain возвращает Seq изменений, уже лежащих в канале подписки
+func drain(sub *Subscription) []int64 {
+	var seqs []int64
+	for {
+		select {
+		case change, ok := <-sub.C:
+			if !ok {
+				return seqs
+			}
+			seqs = append(seqs, change.Seq)
+		default:
+			return seqs
+		}
+	}
+}
+
+func equalSeqs(a, b []int64) bool {
+	if len(a) != len(b) {
+		return false
+	}
+	for i := range a {
+		if a[i] != b[i] {
+			return false
+		}
+	}
+	return true
+}
+
+func TestBus(t *testing.T) {
+	bus := NewBus(3)
+	forUser := func(userID int) func(models.Change) bool {
+		return func(c models.Change) bool { return c.VisibleTo(userID) }
+	}
+	publish := func(recipients ...int) {
+		bus.Publish(models.Change{Type: models.ChangeCreated, Event: &models.Event{}, Recipients: recipients})
+	}
+
+	all, err := bus.Subscribe(0, nil, 10)
+	if err != nil {
+		t.Fatal("Subscribe() error:", err)
+	}
+	user2, _ := bus.Subscribe(0, forUser(2), 10)
+	slow, _ := bus.Subscribe(0, nil, 1)
+
+	publish(1)
+	publish(1, 2)
+	publish(3)
+	publish(2)
+
+	if got := drain(all); !equalSeqs(got, []int64{1, 2, 3, 4}) {
+		t.Errorf("all changes = %v, want [1 2 3 4]", got)
+	}
+	if got := drain(user2); !equalSeqs(got, []int64{2, 4}) {
+		t.Errorf("changes for user 2 = %v, want [2 4]", got)
+	}
+	if got := drain(slow); !equalSeqs(got, []int64{1}) || !slow.Dropped() {
+		t.Errorf("slow subscriber got %v, dropped %v; want [1] and dropped", got, slow.Dropped())
+	}
+
+	// Продолжение ленты: хранятся только 3 последних изменения
+	resumed, _ := bus.Subscribe(2, nil, 10)
+	if got := drain(resumed); !equalSeqs(got, []int64{3, 4}) {
+		t.Errorf("resumed after 2 = %v, want [3 4]", got)
+	}
+	old, _ := bus.Subscribe(0, nil, 10)
+	if got := drain(old); !equalSeqs(got, []int64{2, 3, 4}) {
+		t.Errorf("resumed after 0 = %v, want the kept history [2 3 4]", got)
+	}
+
+	user2.Close()
+	publish(2)
+	if got := drain(user2); len(got) != 0 {
+		t.Errorf("closed subscription got %v", got)
+	}
+
+	bus.Close()
+	if _, ok := <-all.C; !ok {
+		t.Fatal("change published before Close() is lost")
+	}
+	if _, ok := <-all.C; ok {
+		t.Error("subscription is open after bus Close()")
+	}
+	if _, err := bus.Subscribe(0, nil, 1); !errors.Is(err, ErrClosed) {
+		t.Errorf("Subscribe() after Close() error = %v, want ErrClosed", err)
+	}
+}
@@ -0,0 +1,78 @@
+package handler
+
+import (
+	"errors"
+	"l2-18/internal/apperrors"
+	"l2-18/internal/models"
+	"l2-18/internal/service"
+	"net/http"
+)
+
+// applyBatch обработчик POST /v2/users/{id}/events/batch. Если пакет
+// выполнен, возвращает 200 с результатами всех операций; иначе — код ответа
+// первой неудачной операции, ошибки неудачных операций и 424 для остальных.
+func (h *V2Handler) applyBatch(w http.ResponseWriter, r *http.Request) {
+	ownerID, userID, err := calendarTarget(r)
+	if err != nil {
+		writeError(w, r, err)
+		return
+	}
+
+	var req models.BatchRequest
+	if err := decodeJSON(r, &req); err != nil {
+		writeBadRequest(w, err)
+		return
+	}
+	if req.UserID != 0 && req.UserID != userID {
+		writeError(w, r, errUserMismatch)
+		return
+	}
+	if req.CalendarID != 0 && req.CalendarID != ownerID {
+		writeError(w, r, apperrors.Field("calendar_id", "calendar ID in body does not match the path"))
+		return
+	}
+	req.UserID, req.CalendarID = userID, ownerID
+
+	events, err := h.service.ApplyBatch(&req)
+	var batchErr *service.BatchError
+	if errors.As(err, &batchErr) {
+		writeBatchError(w, r, batchErr, len(req.Operations))
+		return
+	}
+	if err != nil {
+		writeError(w, r, err)
+		return
+	}
+
+	results := make([]models.BatchItemResult, len(events))
+	for i, event := range events {
+		results[i] = models.BatchItemResult{Index: i, Status: http.StatusOK, Event: event}
+		switch {
+		case req.Operations[i].Create != nil:
+			results[i].Status = http.StatusCreated
+		case req.Operations[i].Delete != nil:
+			results[i].Status = http.StatusNoContent
+		}
+	}
+
+	writeJSON(w, http.StatusOK, models.BatchResult{Applied: true, Results: results})
+}
+
+// writeBatchError отправляет результаты невыполненного пакета из n операций
+func writeBatchError(w http.ResponseWriter, r *http.Request, batchErr *service.BatchError, n int) {
+	status := 0
+	results := make([]models.BatchItemResult, n)
+	for i := range results {
+		results[i] = models.BatchItemResult{Index: i, Status: http.StatusFailedDependency}
+		if err, failed := batchErr.Errors[i]; failed {
+			results[i].Status = errorStatus(err)
+			resp := errorResponse(r.Context(), err, results[i].Status)
+			results[i].Error = &resp
+			if status == 0 {
+				status = results[i].Status
+			}
+		}
+	}
+
+	writeJSON(w, status, models.BatchResult{Results: results})
+}
@@ -2,15 +2,22 @@ package handler
 
 import (
 	"encoding/json"
+	"errors"
 	"fmt"
+	"l2-18/internal/apperrors"
+	"l2-18/internal/auth"
 	"l2-18/internal/models"
 	"l2-18/internal/service"
 	"net/http"
 	"net/url"
 	"strconv"
+	"strings"
 	"time"
 )
 
+// errUserMismatch user_id запроса не совпадает с аутентифицированным пользователем
+var errUserMismatch = errors.New("user_id does not match authenticated user")
+
 // EventHandler обработчик HTTP запросов для событий
 type EventHandler struct {
 	service *service.EventService
@@ -30,13 +37,13 @@ func (h *EventHandler) CreateEvent(w http.ResponseWriter, r *http.Request) {
 
 	req, err := h.parseCreateEventRequest(r)
 	if err != nil {
-		h.sendError(w, err.Error(), http.StatusBadRequest)
+		h.sendRequestError(w, err)
 		return
 	}
 
 	event, err := h.service.CreateEvent(req)
 	if err != nil {
-		h.sendError(w, err.Error(), http.StatusBadRequest)
+		h.sendServiceError(w, r, err)
 		return
 	}
 
@@ -52,17 +59,13 @@ func (h *EventHandler) UpdateEvent(w http.ResponseWriter, r *http.Request) {
 
 	req, err := h.parseUpdateEventRequest(r)
 	if err != nil {
-		h.sendError(w, err.Error(), http.StatusBadRequest)
+		h.sendRequestError(w, err)
 		return
 	}
 
 	event, err := h.service.UpdateEvent(req)
 	if err != nil {
-		if isBusinessLogicError(err) {
-			h.sendError(w, err.Error(), http.StatusServiceUnavailable)
-		} else {
-			h.sendError(w, err.Error(), http.StatusBadRequest)
-		}
+		h.sendEventError(w, r, err, req.ID, req.UserID)
 		return
 	}
 
@@ -78,17 +81,13 @@ func (h *EventHandler) DeleteEvent(w http.ResponseWriter, r *http.Request) {
 
 	req, err := h.parseDeleteEventRequest(r)
 	if err != nil {
-		h.sendError(w, err.Error(), http.StatusBadRequest)
+		h.sendRequestError(w, err)
 		return
 	}
 
 	err = h.service.DeleteEvent(req)
 	if err != nil {
-		if isBusinessLogicError(err) {
-			h.sendError(w, err.Error(), http.StatusServiceUnavailable)
-		} else {
-			h.sendError(w, err.Error(), http.StatusBadRequest)
-		}
+		h.sendEventError(w, r, err, req.ID, req.UserID)
 		return
 	}
 
@@ -104,13 +103,13 @@ func (h *EventHandler) GetEventsForDay(w http.ResponseWriter, r *http.Request) {
 
 	userID, date, err := h.parseGetEventsRequest(r)
 	if err != nil {
-		h.sendError(w, err.Error(), http.StatusBadRequest)
+		h.sendRequestError(w, err)
 		return
 	}
 
 	events, err := h.service.GetEventsForDay(userID, date)
 	if err != nil {
-		h.sendError(w, err.Error(), http.StatusBadRequest)
+		h.sendServiceError(w, r, err)
 		return
 	}
 
@@ -126,13 +125,13 @@ func (h *EventHandler) GetEventsForWeek(w http.ResponseWriter, r *http.Request)
 
 	userID, date, err := h.parseGetEventsRequest(r)
 	if err != nil {
-		h.sendError(w, err.Error(), http.StatusBadRequest)
+		h.sendRequestError(w, err)
 		return
 	}
 
 	events, err := h.service.GetEventsForWeek(userID, date)
 	if err != nil {
-		h.sendError(w, err.Error(), http.StatusBadRequest)
+		h.sendServiceError(w, r, err)
 		return
 	}
 
@@ -148,28 +147,54 @@ func (h *EventHandler) GetEventsForMonth(w http.ResponseWriter, r *http.Request)
 
 	userID, date, err := h.parseGetEventsRequest(r)
 	if err != nil {
-		h.sendError(w, err.Error(), http.StatusBadRequest)
+		h.sendRequestError(w, err)
 		return
 	}
 
 	events, err := h.service.GetEventsForMonth(userID, date)
 	if err != nil {
-		h.sendError(w, err.Error(), http.StatusBadRequest)
+		h.sendServiceError(w, r, err)
 		return
 	}
 
 	h.sendSuccess(w, "events retrieved successfully", events)
 }
 
+// SetUserTimeZone обработчик установки часового пояса пользователя
+func (h *EventHandler) SetUserTimeZone(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodPost {
+		h.sendError(w, "method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+
+	req, err := h.parseSetTimeZoneRequest(r)
+	if err != nil {
+		h.sendRequestError(w, err)
+		return
+	}
+
+	if err := h.service.SetUserTimeZone(req); err != nil {
+		h.sendServiceError(w, r, err)
+		return
+	}
+
+	h.sendSuccess(w, "time zone updated successfully", nil)
+}
+
 // parseCreateEventRequest парсит запрос на создание события
 func (h *EventHandler) parseCreateEventRequest(r *http.Request) (*models.CreateEventRequest, error) {
 	contentType := r.Header.Get("Content-Type")
 
 	if contentType == "application/json" {
 		var req models.CreateEventRequest
-		if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		if err := decodeJSON(r, &req); err != nil {
 			return nil, err
 		}
+		userID, err := requestUser(r, req.UserID)
+		if err != nil {
+			return nil, err
+		}
+		req.UserID = userID
 		return &req, nil
 	}
 
@@ -178,16 +203,52 @@ func (h *EventHandler) parseCreateEventRequest(r *http.Request) (*models.CreateE
 		return nil, err
 	}
 
-	userID, err := strconv.Atoi(r.FormValue("user_id"))
+	userID, err := formUserID(r, r.FormValue("user_id"))
+	if err != nil {
+		return nil, err
+	}
+
+	allDay, err := parseFormBool(r.FormValue("all_day"))
+	if err != nil {
+		return nil, err
+	}
+
+	reminders, err := parseFormInts(r.FormValue("reminders"))
+	if err != nil {
+		return nil, err
+	}
+
+	attendees, err := parseFormInts(r.FormValue("attendees"))
+	if err != nil {
+		return nil, err
+	}
+
+	calendarID, err := parseFormInt(r.FormValue("calendar_id"))
+	if err != nil {
+		return nil, err
+	}
+
+	checkConflicts, err := parseFormBool(r.FormValue("check_conflicts"))
 	if err != nil {
 		return nil, err
 	}
 
 	return &models.CreateEventRequest{
-		UserID:      userID,
-		Date:        r.FormValue("date"),
-		Title:       r.FormValue("title"),
-		Description: r.FormValue("description"),
+		UserID:         userID,
+		CalendarID:     calendarID,
+		CheckConflicts: checkConflicts,
+		Date:           r.FormValue("date"),
+		Start:          r.FormValue("start"),
+		End:            r.FormValue("end"),
+		Duration:       r.FormValue("duration"),
+		AllDay:         allDay,
+		TimeZone:       r.FormValue("time_zone"),
+		Title:          r.FormValue("title"),
+		Description:    r.FormValue("description"),
+		RRule:          r.FormValue("rrule"),
+		Reminders:      reminders,
+		Attendees:      attendees,
+		Tags:           parseFormList(r.FormValue("tags")),
 	}, nil
 }
 
@@ -197,9 +258,14 @@ func (h *EventHandler) parseUpdateEventRequest(r *http.Request) (*models.UpdateE
 
 	if contentType == "application/json" {
 		var req models.UpdateEventRequest
-		if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		if err := decodeJSON(r, &req); err != nil {
+			return nil, err
+		}
+		userID, err := requestUser(r, req.UserID)
+		if err != nil {
 			return nil, err
 		}
+		req.UserID = userID
 		return &req, nil
 	}
 
@@ -213,17 +279,54 @@ func (h *EventHandler) parseUpdateEventRequest(r *http.Request) (*models.UpdateE
 		return nil, err
 	}
 
-	userID, err := strconv.Atoi(r.FormValue("user_id"))
+	userID, err := formUserID(r, r.FormValue("user_id"))
+	if err != nil {
+		return nil, err
+	}
+
+	allDay, err := parseFormBool(r.FormValue("all_day"))
+	if err != nil {
+		return nil, err
+	}
+
+	reminders, err := parseFormInts(r.FormValue("reminders"))
+	if err != nil {
+		return nil, err
+	}
+
+	attendees, err := parseFormInts(r.FormValue("attendees"))
+	if err != nil {
+		return nil, err
+	}
+
+	checkConflicts, err := parseFormBool(r.FormValue("check_conflicts"))
+	if err != nil {
+		return nil, err
+	}
+
+	version, err := parseFormInt(r.FormValue("version"))
 	if err != nil {
 		return nil, err
 	}
 
 	return &models.UpdateEventRequest{
-		ID:          id,
-		UserID:      userID,
-		Date:        r.FormValue("date"),
-		Title:       r.FormValue("title"),
-		Description: r.FormValue("description"),
+		ID:             id,
+		UserID:         userID,
+		Date:           r.FormValue("date"),
+		Start:          r.FormValue("start"),
+		End:            r.FormValue("end"),
+		Duration:       r.FormValue("duration"),
+		AllDay:         allDay,
+		TimeZone:       r.FormValue("time_zone"),
+		Title:          r.FormValue("title"),
+		Description:    r.FormValue("description"),
+		RRule:          r.FormValue("rrule"),
+		Reminders:      reminders,
+		Attendees:      attendees,
+		Tags:           parseFormList(r.FormValue("tags")),
+		CheckConflicts: checkConflicts,
+		Version:        version,
+		OccurrenceDate: r.FormValue("occurrence_date"),
 	}, nil
 }
 
@@ -233,9 +336,14 @@ func (h *EventHandler) parseDeleteEventRequest(r *http.Request) (*models.DeleteE
 
 	if contentType == "application/json" {
 		var req models.DeleteEventRequest
-		if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		if err := decodeJSON(r, &req); err != nil {
 			return nil, err
 		}
+		userID, err := requestUser(r, req.UserID)
+		if err != nil {
+			return nil, err
+		}
+		req.UserID = userID
 		return &req, nil
 	}
 
@@ -249,14 +357,21 @@ func (h *EventHandler) parseDeleteEventRequest(r *http.Request) (*models.DeleteE
 		return nil, err
 	}
 
-	userID, err := strconv.Atoi(r.FormValue("user_id"))
+	userID, err := formUserID(r, r.FormValue("user_id"))
+	if err != nil {
+		return nil, err
+	}
+
+	version, err := parseFormInt(r.FormValue("version"))
 	if err != nil {
 		return nil, err
 	}
 
 	return &models.DeleteEventRequest{
-		ID:     id,
-		UserID: userID,
+		ID:             id,
+		UserID:         userID,
+		Version:        version,
+		OccurrenceDate: r.FormValue("occurrence_date"),
 	}, nil
 }
 
@@ -267,7 +382,7 @@ func (h *EventHandler) parseGetEventsRequest(r *http.Request) (int, time.Time, e
 		return 0, time.Time{}, err
 	}
 
-	userID, err := strconv.Atoi(values.Get("user_id"))
+	userID, err := formUserID(r, values.Get("user_id"))
 	if err != nil {
 		return 0, time.Time{}, err
 	}
@@ -277,7 +392,14 @@ func (h *EventHandler) parseGetEventsRequest(r *http.Request) (int, time.Time, e
 		return 0, time.Time{}, fmt.Errorf("date parameter is required")
 	}
 
-	date, err := time.Parse("2006-01-02", dateStr)
+	// Границы дня, недели и месяца считаются в часовом поясе из параметра tz
+	// или в часовом поясе пользователя
+	loc, err := h.service.ResolveLocation(userID, values.Get("tz"))
+	if err != nil {
+		return 0, time.Time{}, err
+	}
+
+	date, err := time.ParseInLocation("2006-01-02", dateStr, loc)
 	if err != nil {
 		return 0, time.Time{}, err
 	}
@@ -285,6 +407,147 @@ func (h *EventHandler) parseGetEventsRequest(r *http.Request) (int, time.Time, e
 	return userID, date, nil
 }
 
+// parseSetTimeZoneRequest парсит запрос на установку часового пояса
+func (h *EventHandler) parseSetTimeZoneRequest(r *http.Request) (*models.SetTimeZoneRequest, error) {
+	contentType := r.Header.Get("Content-Type")
+
+	if contentType == "application/json" {
+		var req models.SetTimeZoneRequest
+		if err := decodeJSON(r, &req); err != nil {
+			return nil, err
+		}
+		userID, err := requestUser(r, req.UserID)
+		if err != nil {
+			return nil, err
+		}
+		req.UserID = userID
+		return &req, nil
+	}
+
+	// Парсим как form data
+	if err := r.ParseForm(); err != nil {
+		return nil, err
+	}
+
+	userID, err := formUserID(r, r.FormValue("user_id"))
+	if err != nil {
+		return nil, err
+	}
+
+	return &models.SetTimeZoneRequest{
+		UserID:   userID,
+		TimeZone: r.FormValue("time_zone"),
+	}, nil
+}
+
+// formUserID разбирает параметр user_id и сверяет его с аутентифицированным
+// пользователем. При включенной аутентификации параметр можно не передавать.
+func formUserID(r *http.Request, value string) (int, error) {
+	supplied := 0
+	if value != "" {
+		n, err := strconv.Atoi(value)
+		if err != nil {
+			return 0, err
+		}
+		supplied = n
+	}
+	return requestUser(r, supplied)
+}
+
+// requestUser возвращает пользователя запроса: аутентифицированного, если
+// аутентификация включена, иначе переданного клиентом
+func requestUser(r *http.Request, supplied int) (int, error) {
+	userID, ok := auth.UserID(r.Context())
+	if !ok {
+		return supplied, nil
+	}
+	if supplied != 0 && supplied != userID {
+		return 0, errUserMismatch
+	}
+	return userID, nil
+}
+
+// parseFormBool разбирает необязательный булев параметр формы
+func parseFormBool(value string) (bool, error) {
+	if value == "" {
+		return false, nil
+	}
+	return strconv.ParseBool(value)
+}
+
+// parseFormInts разбирает необязательный список чисел через запятую, например "10,60"
+func parseFormInts(value string) ([]int, error) {
+	if strings.TrimSpace(value) == "" {
+		return nil, nil
+	}
+
+	var result []int
+	for _, part := range strings.Split(value, ",") {
+		n, err := strconv.Atoi(strings.TrimSpace(part))
+		if err != nil {
+			return nil, err
+		}
+		result = append(result, n)
+	}
+	return result, nil
+}
+
+// parseFormList разбирает список строк через запятую
+func parseFormList(value string) []string {
+	if strings.TrimSpace(value) == "" {
+		return nil
+	}
+	return strings.Split(value, ",")
+}
+
+// parseFormInt разбирает необязательный числовой параметр формы
+func parseFormInt(value string) (int, error) {
+	if value == "" {
+		return 0, nil
+	}
+	return strconv.Atoi(value)
+}
+
+// sendRequestError отправляет ответ с ошибкой разбора запроса
+func (h *EventHandler) sendRequestError(w http.ResponseWriter, err error) {
+	if errors.Is(err, errUserMismatch) {
+		h.sendError(w, err.Error(), http.StatusForbidden)
+		return
+	}
+	h.sendError(w, err.Error(), bodyStatus(err))
+}
+
+// sendEventError отправляет ответ с ошибкой изменения события.
+// Если версия устарела, отвечает 409 с текущим состоянием события.
+// Пересечение с другими событиями — тоже 409, но со списком этих событий.
+func (h *EventHandler) sendEventError(w http.ResponseWriter, r *http.Request, err error, id, userID int) {
+	if isVersionConflict(err) {
+		writeConflict(w, h.service, err, id, userID, http.StatusConflict, apperrors.CodeConflict)
+		return
+	}
+	h.sendServiceError(w, r, err)
+}
+
+// sendServiceError отправляет ответ с ошибкой сервиса. Старые маршруты
+// отвечают 400 на ошибки входных данных, 409 на конфликт версий и 503
+// на остальные ошибки бизнес-логики (событие не найдено, чужое событие).
+func (h *EventHandler) sendServiceError(w http.ResponseWriter, r *http.Request, err error) {
+	status := http.StatusInternalServerError
+	switch {
+	case errors.Is(err, errUserMismatch):
+		status = http.StatusForbidden
+	case errors.Is(err, apperrors.ErrValidation):
+		status = http.StatusBadRequest
+	case errors.Is(err, apperrors.ErrConflict):
+		status = http.StatusConflict
+	case errors.Is(err, apperrors.ErrNotFound),
+		errors.Is(err, apperrors.ErrForbidden):
+		status = http.StatusServiceUnavailable
+	}
+
+	writeJSON(w, status, errorResponse(r.Context(), err, status))
+}
+
 // sendSuccess отправляет успешный ответ
 func (h *EventHandler) sendSuccess(w http.ResponseWriter, message string, data interface{}) {
 	w.Header().Set("Content-Type", "application/json")
@@ -308,6 +571,7 @@ func (h *EventHandler) sendError(w http.ResponseWriter, message string, statusCo
 
 	response := models.APIResponse{
 		Error: message,
+		Code:  errorCode(statusCode),
 	}
 
 	if err := json.NewEncoder(w).Encode(response); err != nil {
@@ -315,12 +579,3 @@ func (h *EventHandler) sendError(w http.ResponseWriter, message string, statusCo
 		return
 	}
 }
-
-// isBusinessLogicError определяет, является ли ошибка бизнес-логической
-func isBusinessLogicError(err error) bool {
-	errMsg := err.Error()
-	return errMsg == "event with ID not found" ||
-		errMsg == "event does not belong to user" ||
-		errMsg == "failed to delete event: event with ID not found" ||
-		errMsg == "failed to update event: event with ID not found"
-}
@@ -0,0 +1,63 @@
+package handler
+
+import (
+	"encoding/json"
+	"l2-18/internal/models"
+	"l2-18/internal/service"
+	"l2-18/internal/storage"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+)
+
+func TestEventHandler_ErrorResponses(t *testing.T) {
+	h := NewEventHandler(service.NewEventService(storage.NewInMemoryEventStorage()))
+
+	post := func(handle http.HandlerFunc, body string) (int, models.APIResponse) {
+		req := httptest.NewRequest(http.MethodPost, "/", strings.NewReader(body))
+		req.Header.Set("Content-Type", "application/json")
+		rec := httptest.NewRecorder()
+		handle(rec, req)
+
+		var resp models.APIResponse
+		_ = json.Unmarshal(rec.Body.Bytes(), &resp)
+		return rec.Code, resp
+	}
+
+	// Тело ограничивается так же, как middleware.BodyLimit
+	limited := func(w http.ResponseWriter, r *http.Request) {
+		r.Body = http.MaxBytesReader(w, r.Body, 16)
+		h.CreateEvent(w, r)
+	}
+
+	tests := []struct {
+		name       string
+		handle     http.HandlerFunc
+		body       string
+		wantStatus int
+		wantCode   string
+		wantFields []string
+	}{
+		{"validation", h.CreateEvent, `{"user_id":1}`, http.StatusBadRequest, "validation_failed", []string{"title", "date"}},
+		{"malformed", h.CreateEvent, `{`, http.StatusBadRequest, "bad_request", nil},
+		{"unknown field", h.CreateEvent, `{"user_id":1,"date":"2024-01-08","titel":"x"}`, http.StatusBadRequest, "bad_request", nil},
+		{"too large", limited, `{"user_id":1,"date":"2024-01-08","title":"x"}`, http.StatusRequestEntityTooLarge, "request_too_large", nil},
+		{"update missing", h.UpdateEvent, `{"id":7,"user_id":1,"date":"2024-01-08","title":"x"}`, http.StatusServiceUnavailable, "not_found", nil},
+		{"delete missing", h.DeleteEvent, `{"id":7,"user_id":1}`, http.StatusServiceUnavailable, "not_found", nil},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			status, resp := post(tt.handle, tt.body)
+			if status != tt.wantStatus || resp.Code != tt.wantCode {
+				t.Errorf("status = %d, code %q, want %d %q (%s)", status, resp.Code, tt.wantStatus, tt.wantCode, resp.Error)
+			}
+			for _, field := range tt.wantFields {
+				if resp.Fields[field] == "" {
+					t.Errorf("response has no message for field %q: %v", field, resp.Fields)
+				}
+			}
+		})
+	}
+}
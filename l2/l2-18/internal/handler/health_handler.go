@@ -0,0 +1,56 @@
+package handler
+
+import (
+	"l2-18/internal/service"
+	"net/http"
+	"sync/atomic"
+)
+
+// healthStatus ответ проверок живости и готовности
+type healthStatus struct {
+	Status string `json:"status"`
+	Error  string `json:"error,omitempty"`
+}
+
+// HealthHandler обработчик проверок живости и готовности для оркестратора.
+// Проверки не требуют аутентификации.
+type HealthHandler struct {
+	service  *service.EventService
+	draining atomic.Bool
+}
+
+// NewHealthHandler создает обработчик проверок
+func NewHealthHandler(service *service.EventService) *HealthHandler {
+	return &HealthHandler{service: service}
+}
+
+// Register регистрирует /healthz и /readyz
+func (h *HealthHandler) Register(mux *http.ServeMux) {
+	mux.HandleFunc("GET /healthz", h.Live)
+	mux.HandleFunc("GET /readyz", h.Ready)
+}
+
+// Drain снимает готовность на время остановки сервера,
+// чтобы балансировщик перестал направлять на него запросы
+func (h *HealthHandler) Drain() {
+	h.draining.Store(true)
+}
+
+// Live обработчик GET /healthz: процесс жив и обслуживает запросы
+func (h *HealthHandler) Live(w http.ResponseWriter, r *http.Request) {
+	writeJSON(w, http.StatusOK, healthStatus{Status: "ok"})
+}
+
+// Ready обработчик GET /readyz: сервер не останавливается и хранилище
+// может сохранять изменения
+func (h *HealthHandler) Ready(w http.ResponseWriter, r *http.Request) {
+	if h.draining.Load() {
+		writeJSON(w, http.StatusServiceUnavailable, healthStatus{Status: "unavailable", Error: "server is shutting down"})
+		return
+	}
+	if err := h.service.CheckHealth(); err != nil {
+		writeJSON(w, http.StatusServiceUnavailable, healthStatus{Status: "unavailable", Error: err.Error()})
+		return
+	}
+	writeJSON(w, http.StatusOK, healthStatus{Status: "ok"})
+}
@@ -0,0 +1,48 @@
+package handler
+
+import (
+	"l2-18/internal/service"
+	"l2-18/internal/storage"
+	"net/http"
+	"net/http/httptest"
+	"path/filepath"
+	"testing"
+)
+
+func TestHealthHandler(t *testing.T) {
+	strg, err := storage.NewWALEventStorage(filepath.Join(t.TempDir(), "events.json"), 0)
+	if err != nil {
+		t.Fatal("NewWALEventStorage() error:", err)
+	}
+	health := NewHealthHandler(service.NewEventService(strg))
+	mux := http.NewServeMux()
+	health.Register(mux)
+
+	get := func(path string) int {
+		rec := httptest.NewRecorder()
+		mux.ServeHTTP(rec, httptest.NewRequest(http.MethodGet, path, nil))
+		return rec.Code
+	}
+
+	tests := []struct {
+		name       string
+		prepare    func()
+		path       string
+		wantStatus int
+	}{
+		{"live", func() {}, "/healthz", http.StatusOK},
+		{"ready", func() {}, "/readyz", http.StatusOK},
+		{"storage closed", func() { strg.Close() }, "/readyz", http.StatusServiceUnavailable},
+		{"live with storage closed", func() {}, "/healthz", http.StatusOK},
+		{"draining", health.Drain, "/readyz", http.StatusServiceUnavailable},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			tt.prepare()
+			if got := get(tt.path); got != tt.wantStatus {
+				t.Errorf("GET %s = %d, want %d", tt.path, got, tt.wantStatus)
+			}
+		})
+	}
+}
@@ -0,0 +1,151 @@
+package handler
+
+import (
+	"l2-18/internal/apperrors"
+	"l2-18/internal/models"
+	"net/http"
+	"strconv"
+)
+
+// EventHistory обработчик просмотра истории изменений события
+// GET /events/{id}/history
+func (h *EventHandler) EventHistory(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodGet {
+		h.sendError(w, "method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+
+	id, userID, err := eventTarget(r)
+	if err != nil {
+		h.sendRequestError(w, err)
+		return
+	}
+
+	history, err := h.service.GetHistory(id, userID)
+	if err != nil {
+		h.sendServiceError(w, r, err)
+		return
+	}
+
+	h.sendSuccess(w, "history retrieved successfully", history)
+}
+
+// RevertEvent обработчик возврата события к записи его истории
+func (h *EventHandler) RevertEvent(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodPost {
+		h.sendError(w, "method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+
+	req, err := h.parseRevertEventRequest(r)
+	if err != nil {
+		h.sendRequestError(w, err)
+		return
+	}
+
+	event, err := h.service.RevertEvent(req)
+	if err != nil {
+		h.sendEventError(w, r, err, req.ID, req.UserID)
+		return
+	}
+
+	h.sendSuccess(w, "event reverted successfully", event)
+}
+
+// parseRevertEventRequest парсит запрос на возврат события к записи истории
+func (h *EventHandler) parseRevertEventRequest(r *http.Request) (*models.RevertEventRequest, error) {
+	if r.Header.Get("Content-Type") == "application/json" {
+		var req models.RevertEventRequest
+		if err := decodeJSON(r, &req); err != nil {
+			return nil, err
+		}
+		userID, err := requestUser(r, req.UserID)
+		if err != nil {
+			return nil, err
+		}
+		req.UserID = userID
+		return &req, nil
+	}
+
+	// Парсим как form data
+	if err := r.ParseForm(); err != nil {
+		return nil, err
+	}
+
+	id, err := strconv.Atoi(r.FormValue("id"))
+	if err != nil {
+		return nil, err
+	}
+
+	userID, err := formUserID(r, r.FormValue("user_id"))
+	if err != nil {
+		return nil, err
+	}
+
+	revision, err := strconv.Atoi(r.FormValue("revision"))
+	if err != nil {
+		return nil, err
+	}
+
+	version := 0
+	if v := r.FormValue("version"); v != "" {
+		if version, err = strconv.Atoi(v); err != nil {
+			return nil, err
+		}
+	}
+
+	return &models.RevertEventRequest{ID: id, UserID: userID, Revision: revision, Version: version}, nil
+}
+
+// getHistory обработчик GET /v2/events/{id}/history
+func (h *V2Handler) getHistory(w http.ResponseWriter, r *http.Request) {
+	id, userID, err := eventTarget(r)
+	if err != nil {
+		writeError(w, r, err)
+		return
+	}
+
+	history, err := h.service.GetHistory(id, userID)
+	if err != nil {
+		writeError(w, r, err)
+		return
+	}
+
+	writeJSON(w, http.StatusOK, history)
+}
+
+// revertEvent обработчик POST /v2/events/{id}/history/{revision}/revert
+func (h *V2Handler) revertEvent(w http.ResponseWriter, r *http.Request) {
+	id, userID, err := eventTarget(r)
+	if err != nil {
+		writeError(w, r, err)
+		return
+	}
+	revision, err := strconv.Atoi(r.PathValue("revision"))
+	if err != nil || revision <= 0 {
+		writeError(w, r, apperrors.Errorf(apperrors.ErrNotFound, "revision %q not found", r.PathValue("revision")))
+		return
+	}
+
+	var queryVersion int
+	if v := r.URL.Query().Get("version"); v != "" {
+		if queryVersion, err = strconv.Atoi(v); err != nil {
+			writeError(w, r, apperrors.Field("version", "invalid version"))
+			return
+		}
+	}
+	version, fromHeader, err := precondition(r, h.service, id, userID, queryVersion)
+	if err != nil {
+		h.writeEventError(w, r, err, id, userID, fromHeader)
+		return
+	}
+
+	event, err := h.service.RevertEvent(&models.RevertEventRequest{ID: id, UserID: userID, Revision: revision, Version: version})
+	if err != nil {
+		h.writeEventError(w, r, err, id, userID, fromHeader)
+		return
+	}
+
+	w.Header().Set("ETag", etag(event))
+	writeJSON(w, http.StatusOK, event)
+}
@@ -0,0 +1,123 @@
+package handler
+
+import (
+	"fmt"
+	"io"
+	"l2-18/internal/ical"
+	"net/http"
+	"strings"
+	"time"
+)
+
+// ExportICS обработчик выгрузки событий в формате iCalendar.
+// Параметры: user_id (при аутентификации необязателен), необязательные from и to (даты, to включительно) и tz.
+func (h *EventHandler) ExportICS(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodGet {
+		h.sendError(w, "method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+
+	values := r.URL.Query()
+
+	userID, err := formUserID(r, values.Get("user_id"))
+	if err != nil {
+		h.sendRequestError(w, err)
+		return
+	}
+
+	loc, err := h.service.ResolveLocation(userID, values.Get("tz"))
+	if err != nil {
+		h.sendServiceError(w, r, err)
+		return
+	}
+
+	var from, to time.Time
+	if fromStr := values.Get("from"); fromStr != "" {
+		if from, err = time.ParseInLocation("2006-01-02", fromStr, loc); err != nil {
+			h.sendError(w, err.Error(), http.StatusBadRequest)
+			return
+		}
+	}
+
+	if toStr := values.Get("to"); toStr != "" {
+		if to, err = time.ParseInLocation("2006-01-02", toStr, loc); err != nil {
+			h.sendError(w, err.Error(), http.StatusBadRequest)
+			return
+		}
+		to = to.AddDate(0, 0, 1)
+	}
+
+	events, err := h.service.ExportEvents(userID, from, to)
+	if err != nil {
+		h.sendServiceError(w, r, err)
+		return
+	}
+
+	w.Header().Set("Content-Type", "text/calendar; charset=utf-8")
+	w.Header().Set("Content-Disposition", fmt.Sprintf(`attachment; filename="calendar-%d.ics"`, userID))
+	if err := ical.Encode(w, events); err != nil {
+		http.Error(w, "failed to encode calendar", http.StatusInternalServerError)
+		return
+	}
+}
+
+// ImportICS обработчик загрузки событий из файла iCalendar.
+// Файл передается телом запроса (text/calendar) или полем file формы multipart,
+// пользователь — параметром user_id.
+func (h *EventHandler) ImportICS(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodPost {
+		h.sendError(w, "method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+
+	body, err := h.openCalendarFile(r)
+	if err != nil {
+		h.sendError(w, err.Error(), bodyStatus(err))
+		return
+	}
+	defer body.Close()
+
+	userID, err := formUserID(r, r.FormValue("user_id"))
+	if err != nil {
+		h.sendRequestError(w, err)
+		return
+	}
+
+	// Время без часового пояса считается заданным в часовом поясе пользователя
+	loc, err := h.service.ResolveLocation(userID, r.FormValue("tz"))
+	if err != nil {
+		h.sendServiceError(w, r, err)
+		return
+	}
+
+	events, err := ical.Decode(body, loc)
+	if err != nil {
+		h.sendError(w, fmt.Sprintf("invalid calendar file: %v", err), bodyStatus(err))
+		return
+	}
+
+	result, err := h.service.ImportEvents(userID, events)
+	if err != nil {
+		h.sendServiceError(w, r, err)
+		return
+	}
+
+	h.sendSuccess(w, "events imported successfully", result)
+}
+
+// openCalendarFile возвращает содержимое загруженного файла iCalendar
+func (h *EventHandler) openCalendarFile(r *http.Request) (io.ReadCloser, error) {
+	contentType := r.Header.Get("Content-Type")
+	if strings.HasPrefix(contentType, "multipart/form-data") {
+		if err := r.ParseMultipartForm(10 << 20); err != nil {
+			return nil, err
+		}
+		file, _, err := r.FormFile("file")
+		if err != nil {
+			return nil, err
+		}
+		return file, nil
+	}
+
+	return r.Body, nil
+}
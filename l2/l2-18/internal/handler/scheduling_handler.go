@@ -0,0 +1,141 @@
+package handler
+
+import (
+	"l2-18/internal/apperrors"
+	"l2-18/internal/service"
+	"net/http"
+	"strconv"
+	"time"
+)
+
+// FreeBusy обработчик запроса занятости пользователей
+func (h *EventHandler) FreeBusy(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodGet {
+		h.sendError(w, "method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+
+	users, from, to, err := parseScheduleQuery(h.service, r)
+	if err != nil {
+		h.sendRequestError(w, err)
+		return
+	}
+
+	result, err := h.service.FreeBusy(users, from, to)
+	if err != nil {
+		h.sendServiceError(w, r, err)
+		return
+	}
+
+	h.sendSuccess(w, "free/busy retrieved successfully", result)
+}
+
+// NextFreeSlot обработчик поиска ближайшего свободного времени
+func (h *EventHandler) NextFreeSlot(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodGet {
+		h.sendError(w, "method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+
+	users, from, to, err := parseScheduleQuery(h.service, r)
+	if err != nil {
+		h.sendRequestError(w, err)
+		return
+	}
+	duration, err := parseSlotMinutes(r)
+	if err != nil {
+		h.sendRequestError(w, err)
+		return
+	}
+
+	slot, err := h.service.NextFreeSlot(users, duration, from, to)
+	if err != nil {
+		h.sendServiceError(w, r, err)
+		return
+	}
+
+	h.sendSuccess(w, "free slot found", slot)
+}
+
+// freeBusy обработчик GET /v2/freebusy
+func (h *V2Handler) freeBusy(w http.ResponseWriter, r *http.Request) {
+	users, from, to, err := parseScheduleQuery(h.service, r)
+	if err != nil {
+		writeError(w, r, err)
+		return
+	}
+
+	result, err := h.service.FreeBusy(users, from, to)
+	if err != nil {
+		writeError(w, r, err)
+		return
+	}
+
+	writeJSON(w, http.StatusOK, result)
+}
+
+// nextFreeSlot обработчик GET /v2/freebusy/next-slot
+func (h *V2Handler) nextFreeSlot(w http.ResponseWriter, r *http.Request) {
+	users, from, to, err := parseScheduleQuery(h.service, r)
+	if err != nil {
+		writeError(w, r, err)
+		return
+	}
+	duration, err := parseSlotMinutes(r)
+	if err != nil {
+		writeError(w, r, err)
+		return
+	}
+
+	slot, err := h.service.NextFreeSlot(users, duration, from, to)
+	if err != nil {
+		writeError(w, r, err)
+		return
+	}
+
+	writeJSON(w, http.StatusOK, slot)
+}
+
+// parseScheduleQuery разбирает пользователей (users=1,2; по умолчанию —
+// пользователь запроса) и окно [from, to) запроса занятости. Без from
+// окно начинается с текущей минуты.
+func parseScheduleQuery(svc *service.EventService, r *http.Request) ([]int, time.Time, time.Time, error) {
+	query := r.URL.Query()
+
+	userID, err := formUserID(r, query.Get("user_id"))
+	if err != nil {
+		return nil, time.Time{}, time.Time{}, err
+	}
+
+	users, err := parseFormInts(query.Get("users"))
+	if err != nil {
+		return nil, time.Time{}, time.Time{}, apperrors.Field("users", "invalid users list: %v", err)
+	}
+	if len(users) == 0 && userID > 0 {
+		users = []int{userID}
+	}
+
+	loc, err := svc.ResolveLocation(userID, query.Get("tz"))
+	if err != nil {
+		return nil, time.Time{}, time.Time{}, err
+	}
+
+	from, to, err := parseRange(query.Get("from"), query.Get("to"), loc)
+	if err != nil {
+		return nil, time.Time{}, time.Time{}, err
+	}
+	if query.Get("from") == "" {
+		from = time.Now().In(loc).Truncate(time.Minute)
+	}
+
+	return users, from, to, nil
+}
+
+// parseSlotMinutes разбирает длительность искомого промежутка в минутах
+func parseSlotMinutes(r *http.Request) (time.Duration, error) {
+	minutes, err := strconv.Atoi(r.URL.Query().Get("minutes"))
+	if err != nil || minutes <= 0 {
+		return 0, apperrors.Field("minutes", "minutes must be a positive number")
+	}
+	return time.Duration(minutes) * time.Minute, nil
+}
@@ -0,0 +1,100 @@
+package handler
+
+import (
+	"l2-18/internal/apperrors"
+	"l2-18/internal/models"
+	"l2-18/internal/service"
+	"net/http"
+)
+
+// SearchEvents обработчик поиска событий
+func (h *EventHandler) SearchEvents(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodGet {
+		h.sendError(w, "method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+
+	userID, err := formUserID(r, r.URL.Query().Get("user_id"))
+	if err != nil {
+		h.sendRequestError(w, err)
+		return
+	}
+
+	req, err := parseSearchQuery(h.service, r, userID)
+	if err != nil {
+		h.sendRequestError(w, err)
+		return
+	}
+
+	result, err := h.service.SearchEvents(req)
+	if err != nil {
+		h.sendServiceError(w, r, err)
+		return
+	}
+
+	h.sendSuccess(w, "events found", result)
+}
+
+// searchEvents обработчик GET /v2/users/{id}/search
+func (h *V2Handler) searchEvents(w http.ResponseWriter, r *http.Request) {
+	userID, err := pathUser(r)
+	if err != nil {
+		writeError(w, r, err)
+		return
+	}
+
+	req, err := parseSearchQuery(h.service, r, userID)
+	if err != nil {
+		writeError(w, r, err)
+		return
+	}
+
+	result, err := h.service.SearchEvents(req)
+	if err != nil {
+		writeError(w, r, err)
+		return
+	}
+	if result.Events == nil {
+		result.Events = []*models.Event{}
+	}
+
+	writeJSON(w, http.StatusOK, result)
+}
+
+// parseSearchQuery разбирает параметры поиска. Диапазон дат необязателен:
+// он применяется, только если задан from или to.
+func parseSearchQuery(svc *service.EventService, r *http.Request, userID int) (*models.SearchRequest, error) {
+	query := r.URL.Query()
+
+	attendees, err := parseFormInts(query.Get("attendees"))
+	if err != nil {
+		return nil, apperrors.Field("attendees", "invalid attendees list: %v", err)
+	}
+
+	limit, err := parseFormInt(query.Get("limit"))
+	if err != nil {
+		return nil, apperrors.Field("limit", "invalid limit: %v", err)
+	}
+
+	req := &models.SearchRequest{
+		UserID:    userID,
+		Query:     query.Get("q"),
+		Tags:      parseFormList(query.Get("tags")),
+		Attendees: attendees,
+		Sort:      query.Get("sort"),
+		Limit:     limit,
+		Cursor:    query.Get("cursor"),
+	}
+
+	if query.Get("from") != "" || query.Get("to") != "" {
+		loc, err := svc.ResolveLocation(userID, query.Get("tz"))
+		if err != nil {
+			return nil, err
+		}
+		if req.From, req.To, err = parseRange(query.Get("from"), query.Get("to"), loc); err != nil {
+			return nil, err
+		}
+	}
+
+	return req, nil
+}
@@ -0,0 +1,264 @@
+package handler
+
+import (
+	"l2-18/internal/apperrors"
+	"l2-18/internal/models"
+	"net/http"
+	"strconv"
+)
+
+// RespondToEvent обработчик ответа участника на приглашение
+func (h *EventHandler) RespondToEvent(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodPost {
+		h.sendError(w, "method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+
+	req, err := h.parseRSVPRequest(r)
+	if err != nil {
+		h.sendRequestError(w, err)
+		return
+	}
+
+	event, err := h.service.RespondToEvent(req)
+	if err != nil {
+		h.sendEventError(w, r, err, req.ID, req.UserID)
+		return
+	}
+
+	h.sendSuccess(w, "response saved successfully", event)
+}
+
+// ShareCalendar обработчик открытия доступа к календарю
+func (h *EventHandler) ShareCalendar(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodPost {
+		h.sendError(w, "method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+
+	req, err := h.parseShareCalendarRequest(r)
+	if err != nil {
+		h.sendRequestError(w, err)
+		return
+	}
+
+	share, err := h.service.ShareCalendar(req)
+	if err != nil {
+		h.sendServiceError(w, r, err)
+		return
+	}
+
+	h.sendSuccess(w, "calendar shared successfully", share)
+}
+
+// UnshareCalendar обработчик закрытия доступа к календарю
+func (h *EventHandler) UnshareCalendar(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodPost {
+		h.sendError(w, "method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+
+	req, err := h.parseShareCalendarRequest(r)
+	if err != nil {
+		h.sendRequestError(w, err)
+		return
+	}
+
+	if err := h.service.UnshareCalendar(req.UserID, req.ShareWith); err != nil {
+		h.sendServiceError(w, r, err)
+		return
+	}
+
+	h.sendSuccess(w, "calendar unshared successfully", nil)
+}
+
+// parseRSVPRequest парсит ответ на приглашение
+func (h *EventHandler) parseRSVPRequest(r *http.Request) (*models.RSVPRequest, error) {
+	if r.Header.Get("Content-Type") == "application/json" {
+		var req models.RSVPRequest
+		if err := decodeJSON(r, &req); err != nil {
+			return nil, err
+		}
+		userID, err := requestUser(r, req.UserID)
+		if err != nil {
+			return nil, err
+		}
+		req.UserID = userID
+		return &req, nil
+	}
+
+	// Парсим как form data
+	if err := r.ParseForm(); err != nil {
+		return nil, err
+	}
+
+	id, err := strconv.Atoi(r.FormValue("id"))
+	if err != nil {
+		return nil, err
+	}
+
+	userID, err := formUserID(r, r.FormValue("user_id"))
+	if err != nil {
+		return nil, err
+	}
+
+	return &models.RSVPRequest{
+		ID:     id,
+		UserID: userID,
+		Status: r.FormValue("status"),
+	}, nil
+}
+
+// parseShareCalendarRequest парсит запрос на открытие или закрытие доступа к календарю
+func (h *EventHandler) parseShareCalendarRequest(r *http.Request) (*models.ShareCalendarRequest, error) {
+	if r.Header.Get("Content-Type") == "application/json" {
+		var req models.ShareCalendarRequest
+		if err := decodeJSON(r, &req); err != nil {
+			return nil, err
+		}
+		userID, err := requestUser(r, req.UserID)
+		if err != nil {
+			return nil, err
+		}
+		req.UserID = userID
+		return &req, nil
+	}
+
+	// Парсим как form data
+	if err := r.ParseForm(); err != nil {
+		return nil, err
+	}
+
+	userID, err := formUserID(r, r.FormValue("user_id"))
+	if err != nil {
+		return nil, err
+	}
+
+	shareWith, err := strconv.Atoi(r.FormValue("share_with"))
+	if err != nil {
+		return nil, err
+	}
+
+	return &models.ShareCalendarRequest{
+		UserID:     userID,
+		ShareWith:  shareWith,
+		Permission: r.FormValue("permission"),
+	}, nil
+}
+
+// respondToEvent обработчик POST /v2/events/{id}/rsvp
+func (h *V2Handler) respondToEvent(w http.ResponseWriter, r *http.Request) {
+	id, userID, err := eventTarget(r)
+	if err != nil {
+		writeError(w, r, err)
+		return
+	}
+
+	var req models.RSVPRequest
+	if err := decodeJSON(r, &req); err != nil {
+		writeBadRequest(w, err)
+		return
+	}
+	req.ID, req.UserID = id, userID
+
+	event, err := h.service.RespondToEvent(&req)
+	if err != nil {
+		h.writeEventError(w, r, err, id, userID, false)
+		return
+	}
+
+	w.Header().Set("ETag", etag(event))
+	writeJSON(w, http.StatusOK, event)
+}
+
+// listShares обработчик GET /v2/users/{id}/shares
+func (h *V2Handler) listShares(w http.ResponseWriter, r *http.Request) {
+	userID, err := pathUser(r)
+	if err != nil {
+		writeError(w, r, err)
+		return
+	}
+
+	shares, err := h.service.GetCalendarShares(userID)
+	if err != nil {
+		writeError(w, r, err)
+		return
+	}
+
+	writeJSON(w, http.StatusOK, shareList(shares))
+}
+
+// shareCalendar обработчик PUT /v2/users/{id}/shares/{user}
+func (h *V2Handler) shareCalendar(w http.ResponseWriter, r *http.Request) {
+	userID, err := pathUser(r)
+	if err != nil {
+		writeError(w, r, err)
+		return
+	}
+	shareWith, err := strconv.Atoi(r.PathValue("user"))
+	if err != nil {
+		writeError(w, r, apperrors.Field("share_with", "invalid user to share with"))
+		return
+	}
+
+	var req models.ShareCalendarRequest
+	if err := decodeJSON(r, &req); err != nil {
+		writeBadRequest(w, err)
+		return
+	}
+	req.UserID, req.ShareWith = userID, shareWith
+
+	share, err := h.service.ShareCalendar(&req)
+	if err != nil {
+		writeError(w, r, err)
+		return
+	}
+
+	writeJSON(w, http.StatusOK, share)
+}
+
+// unshareCalendar обработчик DELETE /v2/users/{id}/shares/{user}
+func (h *V2Handler) unshareCalendar(w http.ResponseWriter, r *http.Request) {
+	userID, err := pathUser(r)
+	if err != nil {
+		writeError(w, r, err)
+		return
+	}
+	shareWith, err := strconv.Atoi(r.PathValue("user"))
+	if err != nil {
+		writeError(w, r, apperrors.Field("share_with", "invalid user to share with"))
+		return
+	}
+
+	if err := h.service.UnshareCalendar(userID, shareWith); err != nil {
+		writeError(w, r, err)
+		return
+	}
+
+	w.WriteHeader(http.StatusNoContent)
+}
+
+// listSharedCalendars обработчик GET /v2/users/{id}/shared-calendars
+func (h *V2Handler) listSharedCalendars(w http.ResponseWriter, r *http.Request) {
+	userID, err := pathUser(r)
+	if err != nil {
+		writeError(w, r, err)
+		return
+	}
+
+	shares, err := h.service.GetSharedCalendars(userID)
+	if err != nil {
+		writeError(w, r, err)
+		return
+	}
+
+	writeJSON(w, http.StatusOK, shareList(shares))
+}
+
+// shareList оборачивает доступы в ответ, пустой список кодируется как []
+func shareList(shares []models.Share) models.ShareList {
+	if shares == nil {
+		shares = []models.Share{}
+	}
+	return models.ShareList{Shares: shares}
+}
@@ -0,0 +1,85 @@
+package handler
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"strconv"
+	"time"
+)
+
+// streamHeartbeat как часто в ленту пишется комментарий, чтобы прокси
+// и клиенты не закрывали простаивающее соединение
+const streamHeartbeat = 15 * time.Second
+
+// StreamEvents обработчик ленты изменений событий пользователя
+// в формате Server-Sent Events. Каждое изменение — событие SSE с типом
+// изменения и его Seq в id; после обрыва клиент передает последний
+// полученный id в заголовке Last-Event-ID и получает пропущенные
+// изменения, если они еще хранятся.
+func (h *EventHandler) StreamEvents(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodGet {
+		h.sendError(w, "method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+
+	userID, err := formUserID(r, r.URL.Query().Get("user_id"))
+	if err != nil {
+		h.sendRequestError(w, err)
+		return
+	}
+
+	var after int64
+	if id := r.Header.Get("Last-Event-ID"); id != "" {
+		if after, err = strconv.ParseInt(id, 10, 64); err != nil {
+			h.sendRequestError(w, fmt.Errorf("invalid Last-Event-ID %q", id))
+			return
+		}
+	}
+
+	sub, err := h.service.SubscribeChanges(userID, after)
+	if err != nil {
+		h.sendServiceError(w, r, err)
+		return
+	}
+	defer sub.Close()
+
+	// Лента открыта дольше, чем WriteTimeout сервера
+	rc := http.NewResponseController(w)
+	_ = rc.SetWriteDeadline(time.Time{})
+
+	w.Header().Set("Content-Type", "text/event-stream")
+	w.Header().Set("Cache-Control", "no-cache")
+	w.Header().Set("X-Accel-Buffering", "no")
+	w.WriteHeader(http.StatusOK)
+	fmt.Fprint(w, ": connected\n\n")
+	if err := rc.Flush(); err != nil {
+		return
+	}
+
+	heartbeat := time.NewTicker(streamHeartbeat)
+	defer heartbeat.Stop()
+
+	for {
+		select {
+		case <-r.Context().Done():
+			return
+		case <-heartbeat.C:
+			fmt.Fprint(w, ": ping\n\n")
+		case change, ok := <-sub.C:
+			if !ok {
+				// Подписчик отстал или сервер останавливается: клиент
+				// переподключится и продолжит с последнего id
+				return
+			}
+			data, err := json.Marshal(change)
+			if err != nil {
+				return
+			}
+			fmt.Fprintf(w, "id: %d\nevent: %s\ndata: %s\n\n", change.Seq, change.Type, data)
+		}
+		if err := rc.Flush(); err != nil {
+			return
+		}
+	}
+}
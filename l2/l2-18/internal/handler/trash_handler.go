@@ -0,0 +1,155 @@
+package handler
+
+import (
+	"l2-18/internal/apperrors"
+	"l2-18/internal/models"
+	"net/http"
+	"strconv"
+)
+
+// Trash обработчик просмотра корзины пользователя
+func (h *EventHandler) Trash(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodGet {
+		h.sendError(w, "method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+
+	userID, err := formUserID(r, r.URL.Query().Get("user_id"))
+	if err != nil {
+		h.sendRequestError(w, err)
+		return
+	}
+
+	trashed, err := h.service.GetTrash(userID)
+	if err != nil {
+		h.sendServiceError(w, r, err)
+		return
+	}
+	if trashed == nil {
+		trashed = []models.TrashedEvent{}
+	}
+
+	h.sendSuccess(w, "trash retrieved successfully", models.TrashList{Events: trashed})
+}
+
+// RestoreEvent обработчик восстановления события из корзины
+func (h *EventHandler) RestoreEvent(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodPost {
+		h.sendError(w, "method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+
+	req, err := h.parseRestoreEventRequest(r)
+	if err != nil {
+		h.sendRequestError(w, err)
+		return
+	}
+
+	event, err := h.service.RestoreEvent(req)
+	if err != nil {
+		h.sendServiceError(w, r, err)
+		return
+	}
+
+	h.sendSuccess(w, "event restored successfully", event)
+}
+
+// parseRestoreEventRequest парсит запрос на восстановление события
+func (h *EventHandler) parseRestoreEventRequest(r *http.Request) (*models.RestoreEventRequest, error) {
+	if r.Header.Get("Content-Type") == "application/json" {
+		var req models.RestoreEventRequest
+		if err := decodeJSON(r, &req); err != nil {
+			return nil, err
+		}
+		userID, err := requestUser(r, req.UserID)
+		if err != nil {
+			return nil, err
+		}
+		req.UserID = userID
+		return &req, nil
+	}
+
+	// Парсим как form data
+	if err := r.ParseForm(); err != nil {
+		return nil, err
+	}
+
+	id, err := strconv.Atoi(r.FormValue("id"))
+	if err != nil {
+		return nil, err
+	}
+
+	userID, err := formUserID(r, r.FormValue("user_id"))
+	if err != nil {
+		return nil, err
+	}
+
+	return &models.RestoreEventRequest{ID: id, UserID: userID}, nil
+}
+
+// listTrash обработчик GET /v2/users/{id}/trash
+func (h *V2Handler) listTrash(w http.ResponseWriter, r *http.Request) {
+	userID, err := pathUser(r)
+	if err != nil {
+		writeError(w, r, err)
+		return
+	}
+
+	trashed, err := h.service.GetTrash(userID)
+	if err != nil {
+		writeError(w, r, err)
+		return
+	}
+	if trashed == nil {
+		trashed = []models.TrashedEvent{}
+	}
+
+	writeJSON(w, http.StatusOK, models.TrashList{Events: trashed})
+}
+
+// restoreEvent обработчик POST /v2/users/{id}/trash/{event}/restore
+func (h *V2Handler) restoreEvent(w http.ResponseWriter, r *http.Request) {
+	userID, id, err := trashTarget(r)
+	if err != nil {
+		writeError(w, r, err)
+		return
+	}
+
+	event, err := h.service.RestoreEvent(&models.RestoreEventRequest{ID: id, UserID: userID})
+	if err != nil {
+		writeError(w, r, err)
+		return
+	}
+
+	w.Header().Set("ETag", etag(event))
+	writeJSON(w, http.StatusOK, event)
+}
+
+// purgeEvent обработчик DELETE /v2/users/{id}/trash/{event}
+func (h *V2Handler) purgeEvent(w http.ResponseWriter, r *http.Request) {
+	userID, id, err := trashTarget(r)
+	if err != nil {
+		writeError(w, r, err)
+		return
+	}
+
+	if err := h.service.PurgeEvent(id, userID); err != nil {
+		writeError(w, r, err)
+		return
+	}
+
+	w.WriteHeader(http.StatusNoContent)
+}
+
+// trashTarget возвращает пользователя и событие из пути /v2/users/{id}/trash/{event}
+func trashTarget(r *http.Request) (int, int, error) {
+	userID, err := pathUser(r)
+	if err != nil {
+		return 0, 0, err
+	}
+	id, err := strconv.Atoi(r.PathValue("event"))
+	if err != nil || id <= 0 {
+		return 0, 0, apperrors.Errorf(apperrors.ErrNotFound, "event %q is not in the trash", r.PathValue("event"))
+	}
+	return userID, id, nil
+}
@@ -0,0 +1,881 @@
+package handler
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"l2-18/internal/apperrors"
+	"l2-18/internal/auth"
+	"l2-18/internal/models"
+	"l2-18/internal/openapi"
+	"l2-18/internal/service"
+	"log/slog"
+	"net/http"
+	"slices"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// V2Handler обработчик REST API v2: ресурсы адресуются путем, действие
+// задается методом HTTP, а код ответа определяется видом ошибки
+type V2Handler struct {
+	service *service.EventService
+}
+
+// NewV2Handler создает обработчик API v2
+func NewV2Handler(service *service.EventService) *V2Handler {
+	return &V2Handler{service: service}
+}
+
+// route маршрут API v2 вместе с его описанием для OpenAPI
+type route struct {
+	openapi.Operation
+	handle http.HandlerFunc
+}
+
+// Register регистрирует маршруты API v2 и документ OpenAPI /v2/openapi.json
+func (h *V2Handler) Register(mux *http.ServeMux) {
+	routes := h.routes()
+	for _, rt := range routes {
+		mux.HandleFunc(rt.Method+" "+rt.Path, rt.handle)
+	}
+
+	operations := make([]openapi.Operation, 0, len(routes))
+	for _, rt := range routes {
+		operations = append(operations, rt.Operation)
+	}
+	doc := openapi.Document("Calendar API", "2.0", operations, models.APIResponse{})
+
+	mux.HandleFunc("GET /v2/openapi.json", func(w http.ResponseWriter, r *http.Request) {
+		writeJSON(w, http.StatusOK, doc)
+	})
+}
+
+// routes таблица маршрутов API v2
+func (h *V2Handler) routes() []route {
+	userQuery := openapi.Param{Name: "user_id", Description: "owner of the event when authentication is disabled"}
+	scheduleQuery := []openapi.Param{
+		{Name: "users", Description: "comma-separated user IDs (default: the requesting user)"},
+		{Name: "from", Description: "range start, RFC 3339 or date (default: now)"},
+		{Name: "to", Description: "range end, RFC 3339 or inclusive date (default: one month after today)"},
+		{Name: "tz", Description: "IANA time zone for dates"},
+	}
+	ifMatch := openapi.Param{Name: "If-Match", In: "header", Description: "ETag of the event the change is based on; required unless version is given"}
+
+	return []route{
+		{
+			Operation: openapi.Operation{
+				Method:  http.MethodGet,
+				Path:    "/v2/users/{id}/events",
+				Summary: "List events of a calendar in a time range, expanding recurring series; the own calendar includes invitations",
+				Query: []openapi.Param{
+					{Name: "user_id", Description: "reading user when authentication is disabled (default: calendar owner)"},
+					{Name: "from", Description: "range start, RFC 3339 or date (default: today)"},
+					{Name: "to", Description: "range end, RFC 3339 or inclusive date (default: one month after from); the range may not exceed the configured maximum, two years by default"},
+					{Name: "tz", Description: "IANA time zone for dates and returned times"},
+				},
+				Response: models.EventList{},
+				Status:   http.StatusOK,
+				Errors:   []int{http.StatusForbidden, http.StatusUnprocessableEntity},
+			},
+			handle: h.listEvents,
+		},
+		{
+			Operation: openapi.Operation{
+				Method:   http.MethodPost,
+				Path:     "/v2/users/{id}/events",
+				Summary:  "Create an event in an own calendar or in a calendar shared for writing",
+				Query:    []openapi.Param{{Name: "user_id", Description: "creating user when authentication is disabled (default: calendar owner)"}},
+				Request:  models.CreateEventRequest{},
+				Response: models.Event{},
+				Status:   http.StatusCreated,
+				Errors:   []int{http.StatusBadRequest, http.StatusForbidden, http.StatusUnprocessableEntity},
+			},
+			handle: h.createEvent,
+		},
+		{
+			Operation: openapi.Operation{
+				Method:   http.MethodPost,
+				Path:     "/v2/users/{id}/events/batch",
+				Summary:  "Create, update and delete events in one request; either all operations are applied or none",
+				Query:    []openapi.Param{{Name: "user_id", Description: "acting user when authentication is disabled (default: calendar owner)"}},
+				Request:  models.BatchRequest{},
+				Response: models.BatchResult{},
+				Status:   http.StatusOK,
+				Errors:   []int{http.StatusBadRequest, http.StatusForbidden, http.StatusNotFound, http.StatusConflict, http.StatusUnprocessableEntity},
+			},
+			handle: h.applyBatch,
+		},
+		{
+			Operation: openapi.Operation{
+				Method:   http.MethodGet,
+				Path:     "/v2/events/{id}",
+				Summary:  "Get an event",
+				Query:    []openapi.Param{userQuery},
+				Response: models.Event{},
+				Status:   http.StatusOK,
+				Errors:   []int{http.StatusForbidden, http.StatusNotFound},
+			},
+			handle: h.getEvent,
+		},
+		{
+			Operation: openapi.Operation{
+				Method:   http.MethodPut,
+				Path:     "/v2/events/{id}",
+				Summary:  "Replace an event or, with occurrence_date, one occurrence of a series",
+				Query:    []openapi.Param{userQuery, ifMatch},
+				Request:  models.UpdateEventRequest{},
+				Response: models.Event{},
+				Status:   http.StatusOK,
+				Errors:   []int{http.StatusBadRequest, http.StatusForbidden, http.StatusNotFound, http.StatusConflict, http.StatusPreconditionFailed, http.StatusUnprocessableEntity, http.StatusPreconditionRequired},
+			},
+			handle: h.replaceEvent,
+		},
+		{
+			Operation: openapi.Operation{
+				Method:   http.MethodPatch,
+				Path:     "/v2/events/{id}",
+				Summary:  "Update selected fields of an event",
+				Query:    []openapi.Param{userQuery, ifMatch},
+				Request:  models.PatchEventRequest{},
+				Response: models.Event{},
+				Status:   http.StatusOK,
+				Errors:   []int{http.StatusBadRequest, http.StatusForbidden, http.StatusNotFound, http.StatusConflict, http.StatusPreconditionFailed, http.StatusUnprocessableEntity, http.StatusPreconditionRequired},
+			},
+			handle: h.patchEvent,
+		},
+		{
+			Operation: openapi.Operation{
+				Method:  http.MethodDelete,
+				Path:    "/v2/events/{id}",
+				Summary: "Delete an event or one occurrence of a series",
+				Query: []openapi.Param{
+					userQuery,
+					ifMatch,
+					{Name: "version", Description: "expected event version, alternative to If-Match"},
+					{Name: "occurrence_date", Description: "delete only this occurrence, RFC 3339 or date"},
+				},
+				Status: http.StatusNoContent,
+				Errors: []int{http.StatusForbidden, http.StatusNotFound, http.StatusConflict, http.StatusPreconditionFailed, http.StatusUnprocessableEntity, http.StatusPreconditionRequired},
+			},
+			handle: h.deleteEvent,
+		},
+		{
+			Operation: openapi.Operation{
+				Method:   http.MethodGet,
+				Path:     "/v2/events/{id}/history",
+				Summary:  "Get the history of changes of an event: who changed which fields and when",
+				Query:    []openapi.Param{userQuery},
+				Response: models.History{},
+				Status:   http.StatusOK,
+				Errors:   []int{http.StatusForbidden, http.StatusNotFound},
+			},
+			handle: h.getHistory,
+		},
+		{
+			Operation: openapi.Operation{
+				Method:  http.MethodPost,
+				Path:    "/v2/events/{id}/history/{revision}/revert",
+				Summary: "Return the fields of an event to their state after a revision of its history",
+				Query: []openapi.Param{
+					userQuery,
+					ifMatch,
+					{Name: "version", Description: "expected event version, alternative to If-Match"},
+				},
+				Response: models.Event{},
+				Status:   http.StatusOK,
+				Errors:   []int{http.StatusForbidden, http.StatusNotFound, http.StatusConflict, http.StatusPreconditionFailed, http.StatusUnprocessableEntity, http.StatusPreconditionRequired},
+			},
+			handle: h.revertEvent,
+		},
+		{
+			Operation: openapi.Operation{
+				Method:   http.MethodPost,
+				Path:     "/v2/events/{id}/rsvp",
+				Summary:  "Accept, decline or tentatively accept an invitation",
+				Query:    []openapi.Param{userQuery},
+				Request:  models.RSVPRequest{},
+				Response: models.Event{},
+				Status:   http.StatusOK,
+				Errors:   []int{http.StatusBadRequest, http.StatusForbidden, http.StatusNotFound, http.StatusConflict, http.StatusUnprocessableEntity},
+			},
+			handle: h.respondToEvent,
+		},
+		{
+			Operation: openapi.Operation{
+				Method:   http.MethodGet,
+				Path:     "/v2/users/{id}/shares",
+				Summary:  "List users the calendar is shared with",
+				Response: models.ShareList{},
+				Status:   http.StatusOK,
+				Errors:   []int{http.StatusForbidden},
+			},
+			handle: h.listShares,
+		},
+		{
+			Operation: openapi.Operation{
+				Method:   http.MethodPut,
+				Path:     "/v2/users/{id}/shares/{user}",
+				Summary:  "Share the calendar with a user for reading or writing",
+				Request:  models.ShareCalendarRequest{},
+				Response: models.Share{},
+				Status:   http.StatusOK,
+				Errors:   []int{http.StatusBadRequest, http.StatusForbidden, http.StatusUnprocessableEntity},
+			},
+			handle: h.shareCalendar,
+		},
+		{
+			Operation: openapi.Operation{
+				Method:  http.MethodDelete,
+				Path:    "/v2/users/{id}/shares/{user}",
+				Summary: "Stop sharing the calendar with a user",
+				Status:  http.StatusNoContent,
+				Errors:  []int{http.StatusForbidden, http.StatusNotFound, http.StatusUnprocessableEntity},
+			},
+			handle: h.unshareCalendar,
+		},
+		{
+			Operation: openapi.Operation{
+				Method:   http.MethodGet,
+				Path:     "/v2/users/{id}/shared-calendars",
+				Summary:  "List calendars of other users shared with the user",
+				Response: models.ShareList{},
+				Status:   http.StatusOK,
+				Errors:   []int{http.StatusForbidden},
+			},
+			handle: h.listSharedCalendars,
+		},
+		{
+			Operation: openapi.Operation{
+				Method:   http.MethodGet,
+				Path:     "/v2/users/{id}/webhooks",
+				Summary:  "List webhooks notified about changes of the user's events and invitations",
+				Response: models.WebhookList{},
+				Status:   http.StatusOK,
+				Errors:   []int{http.StatusForbidden},
+			},
+			handle: h.listWebhooks,
+		},
+		{
+			Operation: openapi.Operation{
+				Method:   http.MethodPost,
+				Path:     "/v2/users/{id}/webhooks",
+				Summary:  "Register a webhook; changes are POSTed with an HMAC-SHA256 signature by the returned secret",
+				Request:  models.CreateWebhookRequest{},
+				Response: models.Webhook{},
+				Status:   http.StatusCreated,
+				Errors:   []int{http.StatusBadRequest, http.StatusForbidden, http.StatusUnprocessableEntity},
+			},
+			handle: h.createWebhook,
+		},
+		{
+			Operation: openapi.Operation{
+				Method:  http.MethodDelete,
+				Path:    "/v2/users/{id}/webhooks/{webhook}",
+				Summary: "Delete a webhook",
+				Status:  http.StatusNoContent,
+				Errors:  []int{http.StatusForbidden, http.StatusNotFound},
+			},
+			handle: h.deleteWebhook,
+		},
+		{
+			Operation: openapi.Operation{
+				Method:   http.MethodGet,
+				Path:     "/v2/users/{id}/webhooks/dead-letters",
+				Summary:  "List changes that could not be delivered to the user's webhooks",
+				Response: models.DeadLetterList{},
+				Status:   http.StatusOK,
+				Errors:   []int{http.StatusForbidden},
+			},
+			handle: h.listDeadLetters,
+		},
+		{
+			Operation: openapi.Operation{
+				Method:   http.MethodGet,
+				Path:     "/v2/users/{id}/trash",
+				Summary:  "List deleted events of the user, newest first, with the time each is purged",
+				Response: models.TrashList{},
+				Status:   http.StatusOK,
+				Errors:   []int{http.StatusForbidden},
+			},
+			handle: h.listTrash,
+		},
+		{
+			Operation: openapi.Operation{
+				Method:   http.MethodPost,
+				Path:     "/v2/users/{id}/trash/{event}/restore",
+				Summary:  "Restore a deleted event; a series is restored with the occurrences deleted together with it",
+				Response: models.Event{},
+				Status:   http.StatusOK,
+				Errors:   []int{http.StatusForbidden, http.StatusNotFound, http.StatusConflict},
+			},
+			handle: h.restoreEvent,
+		},
+		{
+			Operation: openapi.Operation{
+				Method:  http.MethodDelete,
+				Path:    "/v2/users/{id}/trash/{event}",
+				Summary: "Delete an event from the trash permanently",
+				Status:  http.StatusNoContent,
+				Errors:  []int{http.StatusForbidden, http.StatusNotFound},
+			},
+			handle: h.purgeEvent,
+		},
+		{
+			Operation: openapi.Operation{
+				Method:  http.MethodGet,
+				Path:    "/v2/users/{id}/search",
+				Summary: "Search own events and invitations by words of title and description, tags and attendees",
+				Query: []openapi.Param{
+					{Name: "q", Description: "words to find; each matches the beginning of a word, case-insensitively"},
+					{Name: "tags", Description: "comma-separated tags the events must all have"},
+					{Name: "attendees", Description: "comma-separated user IDs the events must all include"},
+					{Name: "from", Description: "range start, RFC 3339 or date; with a range recurring series are expanded"},
+					{Name: "to", Description: "range end, RFC 3339 or inclusive date (default: one month after from)"},
+					{Name: "tz", Description: "IANA time zone for dates"},
+					{Name: "sort", Description: "start (default), title or updated; a leading minus reverses the order"},
+					{Name: "limit", Description: "page size, up to 200 (default: 50)"},
+					{Name: "cursor", Description: "next_cursor of the previous page"},
+				},
+				Response: models.SearchResult{},
+				Status:   http.StatusOK,
+				Errors:   []int{http.StatusForbidden, http.StatusUnprocessableEntity},
+			},
+			handle: h.searchEvents,
+		},
+		{
+			Operation: openapi.Operation{
+				Method:   http.MethodGet,
+				Path:     "/v2/freebusy",
+				Summary:  "Busy time of users in a time range, without event details",
+				Query:    scheduleQuery,
+				Response: models.FreeBusyList{},
+				Status:   http.StatusOK,
+				Errors:   []int{http.StatusForbidden, http.StatusUnprocessableEntity},
+			},
+			handle: h.freeBusy,
+		},
+		{
+			Operation: openapi.Operation{
+				Method:  http.MethodGet,
+				Path:    "/v2/freebusy/next-slot",
+				Summary: "Find the earliest time range of the given length when all users are free",
+				Query: append(slices.Clone(scheduleQuery),
+					openapi.Param{Name: "minutes", Description: "length of the slot in minutes", Required: true}),
+				Response: models.Interval{},
+				Status:   http.StatusOK,
+				Errors:   []int{http.StatusForbidden, http.StatusNotFound, http.StatusUnprocessableEntity},
+			},
+			handle: h.nextFreeSlot,
+		},
+	}
+}
+
+// listEvents обработчик GET /v2/users/{id}/events
+func (h *V2Handler) listEvents(w http.ResponseWriter, r *http.Request) {
+	ownerID, userID, err := calendarTarget(r)
+	if err != nil {
+		writeError(w, r, err)
+		return
+	}
+
+	query := r.URL.Query()
+	loc, err := h.service.ResolveLocation(userID, query.Get("tz"))
+	if err != nil {
+		writeError(w, r, err)
+		return
+	}
+
+	from, to, err := parseRange(query.Get("from"), query.Get("to"), loc)
+	if err != nil {
+		writeError(w, r, err)
+		return
+	}
+
+	events, err := h.service.GetCalendarEvents(userID, ownerID, from, to)
+	if err != nil {
+		writeError(w, r, err)
+		return
+	}
+	if events == nil {
+		events = []*models.Event{}
+	}
+
+	writeJSON(w, http.StatusOK, models.EventList{Events: events})
+}
+
+// createEvent обработчик POST /v2/users/{id}/events
+func (h *V2Handler) createEvent(w http.ResponseWriter, r *http.Request) {
+	ownerID, userID, err := calendarTarget(r)
+	if err != nil {
+		writeError(w, r, err)
+		return
+	}
+
+	var req models.CreateEventRequest
+	if err := decodeJSON(r, &req); err != nil {
+		writeBadRequest(w, err)
+		return
+	}
+	if req.UserID != 0 && req.UserID != userID {
+		writeError(w, r, errUserMismatch)
+		return
+	}
+	if req.CalendarID != 0 && req.CalendarID != ownerID {
+		writeError(w, r, apperrors.Field("calendar_id", "calendar ID in body does not match the path"))
+		return
+	}
+	req.UserID, req.CalendarID = userID, ownerID
+
+	event, err := h.service.CreateEvent(&req)
+	if err != nil {
+		writeError(w, r, err)
+		return
+	}
+
+	w.Header().Set("Location", fmt.Sprintf("/v2/events/%d", event.ID))
+	w.Header().Set("ETag", etag(event))
+	writeJSON(w, http.StatusCreated, event)
+}
+
+// getEvent обработчик GET /v2/events/{id}
+func (h *V2Handler) getEvent(w http.ResponseWriter, r *http.Request) {
+	id, userID, err := eventTarget(r)
+	if err != nil {
+		writeError(w, r, err)
+		return
+	}
+
+	event, err := h.service.GetEvent(id, userID)
+	if err != nil {
+		writeError(w, r, err)
+		return
+	}
+
+	w.Header().Set("ETag", etag(event))
+	writeJSON(w, http.StatusOK, event)
+}
+
+// replaceEvent обработчик PUT /v2/events/{id}
+func (h *V2Handler) replaceEvent(w http.ResponseWriter, r *http.Request) {
+	id, userID, err := eventTarget(r)
+	if err != nil {
+		writeError(w, r, err)
+		return
+	}
+
+	var req models.UpdateEventRequest
+	if err := decodeJSON(r, &req); err != nil {
+		writeBadRequest(w, err)
+		return
+	}
+	if req.ID != 0 && req.ID != id {
+		writeError(w, r, apperrors.Field("id", "event ID in body does not match the path"))
+		return
+	}
+	if req.UserID != 0 && req.UserID != userID {
+		writeError(w, r, errUserMismatch)
+		return
+	}
+	req.ID, req.UserID = id, userID
+
+	version, fromHeader, err := precondition(r, h.service, id, userID, req.Version)
+	if err != nil {
+		h.writeEventError(w, r, err, id, userID, fromHeader)
+		return
+	}
+	req.Version = version
+
+	event, err := h.service.UpdateEvent(&req)
+	if err != nil {
+		h.writeEventError(w, r, err, id, userID, fromHeader)
+		return
+	}
+
+	w.Header().Set("ETag", etag(event))
+	writeJSON(w, http.StatusOK, event)
+}
+
+// patchEvent обработчик PATCH /v2/events/{id}
+func (h *V2Handler) patchEvent(w http.ResponseWriter, r *http.Request) {
+	id, userID, err := eventTarget(r)
+	if err != nil {
+		writeError(w, r, err)
+		return
+	}
+
+	var patch models.PatchEventRequest
+	if err := decodeJSON(r, &patch); err != nil {
+		writeBadRequest(w, err)
+		return
+	}
+
+	version, fromHeader, err := precondition(r, h.service, id, userID, patch.Version)
+	if err != nil {
+		h.writeEventError(w, r, err, id, userID, fromHeader)
+		return
+	}
+	patch.Version = version
+
+	event, err := h.service.PatchEvent(id, userID, &patch)
+	if err != nil {
+		h.writeEventError(w, r, err, id, userID, fromHeader)
+		return
+	}
+
+	w.Header().Set("ETag", etag(event))
+	writeJSON(w, http.StatusOK, event)
+}
+
+// deleteEvent обработчик DELETE /v2/events/{id}
+func (h *V2Handler) deleteEvent(w http.ResponseWriter, r *http.Request) {
+	id, userID, err := eventTarget(r)
+	if err != nil {
+		writeError(w, r, err)
+		return
+	}
+
+	query := r.URL.Query()
+	var queryVersion int
+	if v := query.Get("version"); v != "" {
+		if queryVersion, err = strconv.Atoi(v); err != nil {
+			writeError(w, r, apperrors.Field("version", "invalid version"))
+			return
+		}
+	}
+
+	version, fromHeader, err := precondition(r, h.service, id, userID, queryVersion)
+	if err != nil {
+		h.writeEventError(w, r, err, id, userID, fromHeader)
+		return
+	}
+
+	err = h.service.DeleteEvent(&models.DeleteEventRequest{
+		ID:             id,
+		UserID:         userID,
+		Version:        version,
+		OccurrenceDate: query.Get("occurrence_date"),
+	})
+	if err != nil {
+		h.writeEventError(w, r, err, id, userID, fromHeader)
+		return
+	}
+
+	w.WriteHeader(http.StatusNoContent)
+}
+
+// errPreconditionRequired изменение события без указания версии
+var errPreconditionRequired = errors.New("If-Match header or version is required")
+
+// precondition возвращает версию события id, на которой основано изменение:
+// из If-Match или из параметра version. Второе значение сообщает, что
+// версия взята из If-Match. If-Match: * разрешает изменение любой версии.
+// Если в If-Match перечислено несколько версий, выбирается текущая; если
+// ни один тег не может совпасть, возвращается конфликт версий.
+func precondition(r *http.Request, svc *service.EventService, id, userID, version int) (int, bool, error) {
+	header := strings.TrimSpace(strings.Join(r.Header.Values("If-Match"), ", "))
+	switch {
+	case header == "" && version == 0:
+		return 0, false, errPreconditionRequired
+	case header == "":
+		return version, false, nil
+	}
+
+	versions, wildcard, err := parseIfMatch(header)
+	switch {
+	case err != nil:
+		return 0, false, err
+	case wildcard:
+		return version, true, nil
+	case version != 0:
+		if !slices.Contains(versions, version) {
+			return 0, false, apperrors.Field("version", "version does not match If-Match")
+		}
+		return version, true, nil
+	case len(versions) == 1:
+		return versions[0], true, nil
+	}
+
+	if len(versions) > 1 {
+		current, err := svc.GetEvent(id, userID)
+		if err != nil {
+			return 0, false, err
+		}
+		if slices.Contains(versions, current.Version) {
+			return current.Version, true, nil
+		}
+	}
+	return 0, true, apperrors.Errorf(apperrors.ErrConflict, "If-Match %s does not match the current version of event %d", header, id)
+}
+
+// parseIfMatch разбирает If-Match по RFC 9110: "*" или список тегов через
+// запятую. Возвращает версии из сильных тегов; wildcard сообщает о "*".
+// Слабые теги и теги, не являющиеся версией, при сильном сравнении
+// ни с чем не совпадают и пропускаются.
+func parseIfMatch(header string) (versions []int, wildcard bool, err error) {
+	if header == "*" {
+		return nil, true, nil
+	}
+
+	malformed := apperrors.Field("If-Match", "invalid ETag %s", header)
+	tags := 0
+	for rest := header; ; {
+		rest = strings.TrimLeft(rest, " \t,")
+		if rest == "" {
+			break
+		}
+
+		weak := strings.HasPrefix(rest, "W/")
+		rest = strings.TrimPrefix(rest, "W/")
+		if !strings.HasPrefix(rest, `"`) {
+			return nil, false, malformed
+		}
+		end := strings.IndexByte(rest[1:], '"')
+		if end < 0 {
+			return nil, false, malformed
+		}
+		tag := rest[1 : end+1]
+		rest = strings.TrimLeft(rest[end+2:], " \t")
+		if rest != "" && rest[0] != ',' {
+			return nil, false, malformed
+		}
+
+		tags++
+		if version, err := strconv.Atoi(tag); err == nil && !weak {
+			versions = append(versions, version)
+		}
+	}
+	if tags == 0 {
+		return nil, false, malformed
+	}
+
+	return versions, false, nil
+}
+
+// writeEventError отправляет ответ с ошибкой изменения события. При конфликте
+// версий ответ содержит текущее состояние события: 412, если версия
+// пришла в If-Match, и 409, если в теле запроса.
+func (h *V2Handler) writeEventError(w http.ResponseWriter, r *http.Request, err error, id, userID int, fromHeader bool) {
+	if !isVersionConflict(err) {
+		writeError(w, r, err)
+		return
+	}
+
+	status, code := http.StatusConflict, apperrors.CodeConflict
+	if fromHeader {
+		status, code = http.StatusPreconditionFailed, apperrors.CodePreconditionFailed
+	}
+	writeConflict(w, h.service, err, id, userID, status, code)
+}
+
+// isVersionConflict сообщает, что событие изменилось с версии, на которой
+// основан запрос (в отличие от пересечения с другими событиями)
+func isVersionConflict(err error) bool {
+	var schedule *service.ScheduleConflictError
+	return errors.Is(err, apperrors.ErrConflict) && !errors.As(err, &schedule)
+}
+
+// writeConflict отправляет ответ о конфликте версий вместе с текущим состоянием события
+func writeConflict(w http.ResponseWriter, svc *service.EventService, err error, id, userID, status int, code string) {
+	resp := models.APIResponse{Error: err.Error(), Code: code}
+	if current, getErr := svc.GetEvent(id, userID); getErr == nil {
+		w.Header().Set("ETag", etag(current))
+		resp.Data = current
+	}
+	writeJSON(w, status, resp)
+}
+
+// etag возвращает ETag события по его версии
+func etag(event *models.Event) string {
+	return fmt.Sprintf(`"%d"`, event.Version)
+}
+
+// pathUser возвращает пользователя из пути /v2/users/{id}/...
+func pathUser(r *http.Request) (int, error) {
+	userID, err := strconv.Atoi(r.PathValue("id"))
+	if err != nil || userID <= 0 {
+		return 0, apperrors.Errorf(apperrors.ErrNotFound, "user %q not found", r.PathValue("id"))
+	}
+	return requestUser(r, userID)
+}
+
+// calendarTarget возвращает владельца календаря из пути /v2/users/{id}/...
+// и пользователя запроса, который может работать с чужим календарем
+// при открытом доступе
+func calendarTarget(r *http.Request) (int, int, error) {
+	ownerID, err := strconv.Atoi(r.PathValue("id"))
+	if err != nil || ownerID <= 0 {
+		return 0, 0, apperrors.Errorf(apperrors.ErrNotFound, "user %q not found", r.PathValue("id"))
+	}
+
+	if _, ok := auth.UserID(r.Context()); ok {
+		userID, err := requestUser(r, 0)
+		return ownerID, userID, err
+	}
+
+	userID := ownerID
+	if value := r.URL.Query().Get("user_id"); value != "" {
+		if userID, err = strconv.Atoi(value); err != nil {
+			return 0, 0, apperrors.Field("user_id", "invalid user ID")
+		}
+	}
+	return ownerID, userID, nil
+}
+
+// eventTarget возвращает событие из пути /v2/events/{id} и пользователя запроса
+func eventTarget(r *http.Request) (int, int, error) {
+	id, err := strconv.Atoi(r.PathValue("id"))
+	if err != nil || id <= 0 {
+		return 0, 0, apperrors.Errorf(apperrors.ErrNotFound, "event %q not found", r.PathValue("id"))
+	}
+
+	userID, err := formUserID(r, r.URL.Query().Get("user_id"))
+	if err != nil {
+		return 0, 0, err
+	}
+
+	return id, userID, nil
+}
+
+// parseRange разбирает границы выборки. Дата в to включается целиком.
+func parseRange(fromStr, toStr string, loc *time.Location) (time.Time, time.Time, error) {
+	now := time.Now().In(loc)
+	from := time.Date(now.Year(), now.Month(), now.Day(), 0, 0, 0, 0, loc)
+	if fromStr != "" {
+		parsed, _, err := parseBound(fromStr, loc)
+		if err != nil {
+			return time.Time{}, time.Time{}, apperrors.Field("from", "invalid from: %v", err)
+		}
+		from = parsed
+	}
+
+	to := from.AddDate(0, 1, 0)
+	if toStr != "" {
+		parsed, isDate, err := parseBound(toStr, loc)
+		if err != nil {
+			return time.Time{}, time.Time{}, apperrors.Field("to", "invalid to: %v", err)
+		}
+		to = parsed
+		if isDate {
+			to = to.AddDate(0, 0, 1)
+		}
+	}
+
+	return from, to, nil
+}
+
+// parseBound разбирает момент в RFC 3339 или дату; второе значение сообщает, что это дата
+func parseBound(s string, loc *time.Location) (time.Time, bool, error) {
+	if t, err := time.Parse(time.RFC3339, s); err == nil {
+		return t.In(loc), false, nil
+	}
+	t, err := time.ParseInLocation("2006-01-02", s, loc)
+	return t, true, err
+}
+
+// errorStatus возвращает код ответа для ошибки по ее виду
+func errorStatus(err error) int {
+	switch {
+	case errors.Is(err, errUserMismatch), errors.Is(err, apperrors.ErrForbidden):
+		return http.StatusForbidden
+	case errors.Is(err, errPreconditionRequired):
+		return http.StatusPreconditionRequired
+	case errors.Is(err, apperrors.ErrNotFound):
+		return http.StatusNotFound
+	case errors.Is(err, apperrors.ErrConflict):
+		return http.StatusConflict
+	case errors.Is(err, apperrors.ErrValidation):
+		return http.StatusUnprocessableEntity
+	default:
+		return http.StatusInternalServerError
+	}
+}
+
+// writeError отправляет ответ с ошибкой. Текст внутренних ошибок
+// не раскрывается клиенту, а пишется в лог.
+func writeError(w http.ResponseWriter, r *http.Request, err error) {
+	status := errorStatus(err)
+	writeJSON(w, status, errorResponse(r.Context(), err, status))
+}
+
+// writeBadRequest отправляет ответ о некорректном или слишком большом теле запроса
+func writeBadRequest(w http.ResponseWriter, err error) {
+	status := bodyStatus(err)
+	writeJSON(w, status, models.APIResponse{
+		Error: fmt.Sprintf("invalid request body: %v", err),
+		Code:  errorCode(status),
+	})
+}
+
+// errorResponse формирует тело ответа с ошибкой: текст, машиночитаемый код,
+// сообщения по полям для ошибок проверки и пересекающиеся события
+// для конфликтов расписания
+func errorResponse(ctx context.Context, err error, status int) models.APIResponse {
+	if status == http.StatusInternalServerError {
+		slog.ErrorContext(ctx, "internal error", "error", err)
+		return models.APIResponse{Error: "internal server error", Code: apperrors.CodeInternal}
+	}
+
+	code := apperrors.Code(err)
+	switch {
+	case errors.Is(err, errUserMismatch):
+		code = apperrors.CodeForbidden
+	case errors.Is(err, errPreconditionRequired):
+		code = apperrors.CodePreconditionRequired
+	}
+
+	resp := models.APIResponse{
+		Error:  err.Error(),
+		Code:   code,
+		Fields: apperrors.FieldErrors(err),
+	}
+
+	var schedule *service.ScheduleConflictError
+	if errors.As(err, &schedule) {
+		resp.Code = apperrors.CodeScheduleConflict
+		resp.Data = models.EventList{Events: schedule.Events}
+	}
+
+	return resp
+}
+
+// errorCode возвращает машиночитаемый код ошибки по коду ответа
+func errorCode(status int) string {
+	switch status {
+	case http.StatusBadRequest:
+		return apperrors.CodeBadRequest
+	case http.StatusUnauthorized:
+		return apperrors.CodeAuth
+	case http.StatusForbidden:
+		return apperrors.CodeForbidden
+	case http.StatusNotFound:
+		return apperrors.CodeNotFound
+	case http.StatusConflict:
+		return apperrors.CodeConflict
+	case http.StatusUnprocessableEntity:
+		return apperrors.CodeValidation
+	case http.StatusMethodNotAllowed:
+		return apperrors.CodeMethodNotAllowed
+	case http.StatusPreconditionFailed:
+		return apperrors.CodePreconditionFailed
+	case http.StatusPreconditionRequired:
+		return apperrors.CodePreconditionRequired
+	case http.StatusRequestEntityTooLarge:
+		return apperrors.CodeTooLarge
+	case http.StatusTooManyRequests:
+		return apperrors.CodeRateLimited
+	default:
+		return apperrors.CodeInternal
+	}
+}
+
+// writeJSON отправляет значение в формате JSON
+func writeJSON(w http.ResponseWriter, status int, v interface{}) {
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(status)
+	if err := json.NewEncoder(w).Encode(v); err != nil {
+		slog.Error("failed to encode response", "error", err)
+	}
+}
@@ -0,0 +1,485 @@
+package handler
+
+import (
+	"encoding/json"
+	"l2-18/internal/auth"
+	"l2-18/internal/models"
+	"l2-18/internal/service"
+	"l2-18/internal/storage"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+)
+
+func TestV2Handler_StatusCodes(t *testing.T) {
+	mux := http.NewServeMux()
+	NewV2Handler(service.NewEventService(storage.NewInMemoryEventStorage())).Register(mux)
+
+	// Все запросы выполняются от имени пользователя 1
+	do := func(method, path, body, ifMatch string) *httptest.ResponseRecorder {
+		req := httptest.NewRequest(method, path, strings.NewReader(body))
+		req = req.WithContext(auth.WithUser(req.Context(), 1))
+		if ifMatch != "" {
+			req.Header.Set("If-Match", ifMatch)
+		}
+		rec := httptest.NewRecorder()
+		mux.ServeHTTP(rec, req)
+		return rec
+	}
+
+	rec := do(http.MethodPost, "/v2/users/1/events", `{"start":"2024-01-08T09:00:00Z","duration":"30m","title":"Standup"}`, "")
+	if rec.Code != http.StatusCreated || rec.Header().Get("Location") != "/v2/events/1" || rec.Header().Get("ETag") != `"1"` {
+		t.Fatalf("POST status = %d, Location %q, ETag %q, body %s",
+			rec.Code, rec.Header().Get("Location"), rec.Header().Get("ETag"), rec.Body)
+	}
+
+	tests := []struct {
+		name       string
+		method     string
+		path       string
+		body       string
+		ifMatch    string
+		wantStatus int
+	}{
+		{"get", http.MethodGet, "/v2/events/1", "", "", http.StatusOK},
+		{"get missing", http.MethodGet, "/v2/events/42", "", "", http.StatusNotFound},
+		{"list", http.MethodGet, "/v2/users/1/events?from=2024-01-08&to=2024-01-08", "", "", http.StatusOK},
+		{"list too long range", http.MethodGet, "/v2/users/1/events?from=2000-01-01&to=9999-12-31", "", "", http.StatusUnprocessableEntity},
+		{"list other user", http.MethodGet, "/v2/users/2/events", "", "", http.StatusForbidden},
+		{"create without title", http.MethodPost, "/v2/users/1/events", `{"date":"2024-01-08"}`, "", http.StatusUnprocessableEntity},
+		{"create malformed", http.MethodPost, "/v2/users/1/events", `{`, "", http.StatusBadRequest},
+		{"create unknown field", http.MethodPost, "/v2/users/1/events", `{"date":"2024-01-08","title":"x","tittle":"x"}`, "", http.StatusBadRequest},
+		{"create trailing data", http.MethodPost, "/v2/users/1/events", `{"date":"2024-01-08","title":"x"} {}`, "", http.StatusBadRequest},
+		{"patch without version", http.MethodPatch, "/v2/events/1", `{"title":"Daily standup"}`, "", http.StatusPreconditionRequired},
+		{"patch", http.MethodPatch, "/v2/events/1", `{"title":"Daily standup"}`, `"1"`, http.StatusOK},
+		{"patch stale if-match", http.MethodPatch, "/v2/events/1", `{"title":"Standup"}`, `"1"`, http.StatusPreconditionFailed},
+		// Слабый тег и чужой тег не совпадают при сильном сравнении
+		{"patch weak if-match", http.MethodPatch, "/v2/events/1", `{"title":"Standup"}`, `W/"2"`, http.StatusPreconditionFailed},
+		{"patch foreign if-match", http.MethodPatch, "/v2/events/1", `{"title":"Standup"}`, `"abc"`, http.StatusPreconditionFailed},
+		{"patch stale if-match list", http.MethodPatch, "/v2/events/1", `{"title":"Standup"}`, `"1", W/"2"`, http.StatusPreconditionFailed},
+		{"patch if-match list", http.MethodPatch, "/v2/events/1", `{"title":"Standup"}`, `"1", "2"`, http.StatusOK},
+		{"patch unterminated if-match", http.MethodPatch, "/v2/events/1", `{"title":"Standup"}`, `"3`, http.StatusUnprocessableEntity},
+		{"put stale version", http.MethodPut, "/v2/events/1", `{"date":"2024-01-08","title":"x","version":1}`, "", http.StatusConflict},
+		{"put malformed if-match", http.MethodPut, "/v2/events/1", `{"date":"2024-01-08","title":"x"}`, "2", http.StatusUnprocessableEntity},
+		{"put invalid rrule", http.MethodPut, "/v2/events/1", `{"date":"2024-01-08","title":"x","rrule":"FREQ=SOMETIMES"}`, "*", http.StatusUnprocessableEntity},
+		{"wrong method", http.MethodPost, "/v2/events/1", "", "", http.StatusMethodNotAllowed},
+		{"delete without version", http.MethodDelete, "/v2/events/1", "", "", http.StatusPreconditionRequired},
+		{"delete stale version", http.MethodDelete, "/v2/events/1?version=1", "", "", http.StatusConflict},
+		{"delete", http.MethodDelete, "/v2/events/1", "", `W/"1", "3"`, http.StatusNoContent},
+		{"delete again", http.MethodDelete, "/v2/events/1", "", "*", http.StatusNotFound},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			rec := do(tt.method, tt.path, tt.body, tt.ifMatch)
+			if rec.Code != tt.wantStatus {
+				t.Errorf("%s %s status = %d, want %d, body %s", tt.method, tt.path, rec.Code, tt.wantStatus, rec.Body)
+			}
+		})
+	}
+
+	// Слишком длинный диапазон отклоняется с ошибкой поля to
+	if rec := do(http.MethodGet, "/v2/users/1/events?from=2000-01-01&to=9999-12-31", "", ""); !strings.Contains(rec.Body.String(), `"to"`) {
+		t.Errorf("too long range body %s has no error for field to", rec.Body)
+	}
+
+	// PATCH сохраняет непереданные поля
+	do(http.MethodPost, "/v2/users/1/events", `{"start":"2024-01-09T09:00:00Z","duration":"30m","title":"Review"}`, "")
+	rec = do(http.MethodPatch, "/v2/events/2", `{"start":"2024-01-09T11:00:00Z"}`, `"1"`)
+	var patched struct {
+		Title string
+		Start string
+		End   string
+	}
+	_ = json.Unmarshal(rec.Body.Bytes(), &patched)
+	if patched.Title != "Review" || patched.End != "2024-01-09T11:30:00Z" {
+		t.Errorf("PATCH = %+v, want title kept and duration preserved", patched)
+	}
+
+	// Ответ на устаревшую версию содержит текущее состояние события
+	rec = do(http.MethodPatch, "/v2/events/2", `{"title":"Retro"}`, `"1"`)
+	var stale struct {
+		Code string
+		Data struct {
+			Title   string
+			Version int
+		}
+	}
+	_ = json.Unmarshal(rec.Body.Bytes(), &stale)
+	if stale.Code != "precondition_failed" || stale.Data.Title != "Review" || stale.Data.Version != 2 || rec.Header().Get("ETag") != `"2"` {
+		t.Errorf("stale PATCH = %+v, ETag %q, want current event at version 2", stale, rec.Header().Get("ETag"))
+	}
+
+	rec = do(http.MethodGet, "/v2/openapi.json", "", "")
+	var doc struct {
+		Paths map[string]map[string]interface{} `json:"paths"`
+	}
+	if err := json.Unmarshal(rec.Body.Bytes(), &doc); err != nil {
+		t.Fatal("openapi.json is not valid JSON:", err)
+	}
+	if _, ok := doc.Paths["/v2/events/{id}"]["patch"]; !ok {
+		t.Errorf("openapi.json does not describe PATCH /v2/events/{id}")
+	}
+}
+
+func TestV2Handler_Sharing(t *testing.T) {
+	mux := http.NewServeMux()
+	NewV2Handler(service.NewEventService(storage.NewInMemoryEventStorage())).Register(mux)
+
+	do := func(userID int, method, path, body string) *httptest.ResponseRecorder {
+		req := httptest.NewRequest(method, path, strings.NewReader(body))
+		req = req.WithContext(auth.WithUser(req.Context(), userID))
+		rec := httptest.NewRecorder()
+		mux.ServeHTTP(rec, req)
+		return rec
+	}
+
+	rec := do(1, http.MethodPost, "/v2/users/1/events", `{"start":"2024-01-08T10:00:00Z","duration":"1h","title":"Planning","attendees":[2]}`)
+	if rec.Code != http.StatusCreated {
+		t.Fatalf("POST status = %d, body %s", rec.Code, rec.Body)
+	}
+
+	tests := []struct {
+		name       string
+		userID     int
+		method     string
+		path       string
+		body       string
+		wantStatus int
+	}{
+		{"attendee reads invitation", 2, http.MethodGet, "/v2/events/1", "", http.StatusOK},
+		{"attendee accepts", 2, http.MethodPost, "/v2/events/1/rsvp", `{"status":"accepted"}`, http.StatusOK},
+		{"stranger cannot respond", 3, http.MethodPost, "/v2/events/1/rsvp", `{"status":"accepted"}`, http.StatusForbidden},
+		{"calendar not shared", 3, http.MethodGet, "/v2/users/1/events?from=2024-01-08", "", http.StatusForbidden},
+		{"share for reading", 1, http.MethodPut, "/v2/users/1/shares/3", `{"permission":"read"}`, http.StatusOK},
+		{"share invalid permission", 1, http.MethodPut, "/v2/users/1/shares/3", `{"permission":"admin"}`, http.StatusUnprocessableEntity},
+		{"share someone else's calendar", 3, http.MethodPut, "/v2/users/1/shares/3", `{"permission":"write"}`, http.StatusForbidden},
+		{"read shared calendar", 3, http.MethodGet, "/v2/users/1/events?from=2024-01-08", "", http.StatusOK},
+		{"create with read access", 3, http.MethodPost, "/v2/users/1/events", `{"date":"2024-01-09","title":"Offsite"}`, http.StatusForbidden},
+		{"share for writing", 1, http.MethodPut, "/v2/users/1/shares/3", `{"permission":"write"}`, http.StatusOK},
+		{"create with write access", 3, http.MethodPost, "/v2/users/1/events", `{"date":"2024-01-09","title":"Offsite"}`, http.StatusCreated},
+		{"list shared calendars", 3, http.MethodGet, "/v2/users/3/shared-calendars", "", http.StatusOK},
+		{"unshare", 1, http.MethodDelete, "/v2/users/1/shares/3", "", http.StatusNoContent},
+		{"unshare again", 1, http.MethodDelete, "/v2/users/1/shares/3", "", http.StatusNotFound},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			rec := do(tt.userID, tt.method, tt.path, tt.body)
+			if rec.Code != tt.wantStatus {
+				t.Errorf("%s %s as user %d status = %d, want %d, body %s", tt.method, tt.path, tt.userID, rec.Code, tt.wantStatus, rec.Body)
+			}
+		})
+	}
+
+	rec = do(2, http.MethodGet, "/v2/users/2/events?from=2024-01-08&to=2024-01-08", "")
+	var list struct {
+		Events []struct {
+			Title     string
+			Attendees []struct{ Status string }
+		}
+	}
+	_ = json.Unmarshal(rec.Body.Bytes(), &list)
+	if len(list.Events) != 1 || list.Events[0].Attendees[0].Status != "accepted" {
+		t.Errorf("attendee events = %+v, want accepted invitation", list.Events)
+	}
+}
+
+func TestV2Handler_Scheduling(t *testing.T) {
+	mux := http.NewServeMux()
+	NewV2Handler(service.NewEventService(storage.NewInMemoryEventStorage())).Register(mux)
+
+	do := func(method, path, body string) *httptest.ResponseRecorder {
+		req := httptest.NewRequest(method, path, strings.NewReader(body))
+		req = req.WithContext(auth.WithUser(req.Context(), 1))
+		rec := httptest.NewRecorder()
+		mux.ServeHTTP(rec, req)
+		return rec
+	}
+
+	do(http.MethodPost, "/v2/users/1/events", `{"start":"2024-01-08T09:00:00Z","duration":"1h","title":"Standup"}`)
+
+	rec := do(http.MethodPost, "/v2/users/1/events", `{"start":"2024-01-08T09:30:00Z","duration":"1h","title":"Sync","check_conflicts":true}`)
+	var conflict struct {
+		Code string
+		Data models.EventList
+	}
+	_ = json.Unmarshal(rec.Body.Bytes(), &conflict)
+	if rec.Code != http.StatusConflict || conflict.Code != "schedule_conflict" || len(conflict.Data.Events) != 1 {
+		t.Errorf("conflicting POST = %d %s, want 409 with the overlapping event", rec.Code, rec.Body)
+	}
+
+	tests := []struct {
+		name       string
+		path       string
+		wantStatus int
+		wantBody   string
+	}{
+		{"free/busy", "/v2/freebusy?users=1,2&from=2024-01-08&to=2024-01-08", http.StatusOK, `"busy":[{"start":"2024-01-08T09:00:00Z","end":"2024-01-08T10:00:00Z"}]`},
+		{"free/busy invalid users", "/v2/freebusy?users=1,x", http.StatusUnprocessableEntity, `"users"`},
+		{"next slot", "/v2/freebusy/next-slot?from=2024-01-08T09:00:00Z&to=2024-01-08&minutes=30", http.StatusOK, `"start":"2024-01-08T10:00:00Z"`},
+		{"next slot without minutes", "/v2/freebusy/next-slot?from=2024-01-08", http.StatusUnprocessableEntity, `"minutes"`},
+		{"no free slot", "/v2/freebusy/next-slot?from=2024-01-08T09:00:00Z&to=2024-01-08T10:00:00Z&minutes=30", http.StatusNotFound, `"not_found"`},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			rec := do(http.MethodGet, tt.path, "")
+			if rec.Code != tt.wantStatus || !strings.Contains(rec.Body.String(), tt.wantBody) {
+				t.Errorf("GET %s = %d %s, want %d with %s", tt.path, rec.Code, rec.Body, tt.wantStatus, tt.wantBody)
+			}
+		})
+	}
+}
+
+func TestV2Handler_Search(t *testing.T) {
+	mux := http.NewServeMux()
+	NewV2Handler(service.NewEventService(storage.NewInMemoryEventStorage())).Register(mux)
+
+	do := func(method, path, body string) *httptest.ResponseRecorder {
+		req := httptest.NewRequest(method, path, strings.NewReader(body))
+		req = req.WithContext(auth.WithUser(req.Context(), 1))
+		rec := httptest.NewRecorder()
+		mux.ServeHTTP(rec, req)
+		return rec
+	}
+
+	do(http.MethodPost, "/v2/users/1/events", `{"start":"2024-01-08T09:00:00Z","duration":"1h","title":"Планирование","tags":["работа"]}`)
+	do(http.MethodPost, "/v2/users/1/events", `{"start":"2024-01-09T09:00:00Z","duration":"1h","title":"Планерка"}`)
+
+	tests := []struct {
+		name       string
+		path       string
+		wantStatus int
+		wantBody   string
+	}{
+		{"query", "/v2/users/1/search?q=%D0%BF%D0%BB%D0%B0%D0%BD&limit=1", http.StatusOK, `"next_cursor"`},
+		{"tags", "/v2/users/1/search?tags=%D0%A0%D0%90%D0%91%D0%9E%D0%A2%D0%90", http.StatusOK, `"tags":["работа"]`},
+		{"range", "/v2/users/1/search?from=2024-01-09&to=2024-01-09", http.StatusOK, `"title":"Планерка"`},
+		{"no results", "/v2/users/1/search?q=demo", http.StatusOK, `"events":[]`},
+		{"invalid sort", "/v2/users/1/search?sort=rank", http.StatusUnprocessableEntity, `"sort"`},
+		{"other user", "/v2/users/2/search", http.StatusForbidden, `"forbidden"`},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			rec := do(http.MethodGet, tt.path, "")
+			if rec.Code != tt.wantStatus || !strings.Contains(rec.Body.String(), tt.wantBody) {
+				t.Errorf("GET %s = %d %s, want %d with %s", tt.path, rec.Code, rec.Body, tt.wantStatus, tt.wantBody)
+			}
+		})
+	}
+}
+
+func TestV2Handler_Webhooks(t *testing.T) {
+	mux := http.NewServeMux()
+	NewV2Handler(service.NewEventService(storage.NewInMemoryEventStorage())).Register(mux)
+
+	do := func(method, path, body string) *httptest.ResponseRecorder {
+		req := httptest.NewRequest(method, path, strings.NewReader(body))
+		req = req.WithContext(auth.WithUser(req.Context(), 1))
+		rec := httptest.NewRecorder()
+		mux.ServeHTTP(rec, req)
+		return rec
+	}
+
+	rec := do(http.MethodPost, "/v2/users/1/webhooks", `{"url":"https://example.com/hook","events":["event.created"]}`)
+	if rec.Code != http.StatusCreated || rec.Header().Get("Location") != "/v2/users/1/webhooks/1" || !strings.Contains(rec.Body.String(), `"secret"`) {
+		t.Fatalf("POST status = %d, Location %q, body %s", rec.Code, rec.Header().Get("Location"), rec.Body)
+	}
+
+	tests := []struct {
+		name       string
+		method     string
+		path       string
+		body       string
+		wantStatus int
+		wantBody   string
+	}{
+		{"list hides secret", http.MethodGet, "/v2/users/1/webhooks", "", http.StatusOK, `"url":"https://example.com/hook"`},
+		{"invalid url", http.MethodPost, "/v2/users/1/webhooks", `{"url":"ftp://example.com"}`, http.StatusUnprocessableEntity, `"url"`},
+		{"unknown event type", http.MethodPost, "/v2/users/1/webhooks", `{"url":"https://example.com","events":["event.moved"]}`, http.StatusUnprocessableEntity, `"events"`},
+		{"other user", http.MethodGet, "/v2/users/2/webhooks", "", http.StatusForbidden, `"forbidden"`},
+		{"dead letters", http.MethodGet, "/v2/users/1/webhooks/dead-letters", "", http.StatusOK, `"dead_letters":[]`},
+		{"delete", http.MethodDelete, "/v2/users/1/webhooks/1", "", http.StatusNoContent, ""},
+		{"delete missing", http.MethodDelete, "/v2/users/1/webhooks/1", "", http.StatusNotFound, `"not_found"`},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			rec := do(tt.method, tt.path, tt.body)
+			if rec.Code != tt.wantStatus || !strings.Contains(rec.Body.String(), tt.wantBody) {
+				t.Errorf("%s %s = %d %s, want %d with %s", tt.method, tt.path, rec.Code, rec.Body, tt.wantStatus, tt.wantBody)
+			}
+			if strings.Contains(rec.Body.String(), `"secret"`) {
+				t.Errorf("%s %s exposes the webhook secret: %s", tt.method, tt.path, rec.Body)
+			}
+		})
+	}
+}
+
+func TestV2Handler_Batch(t *testing.T) {
+	mux := http.NewServeMux()
+	NewV2Handler(service.NewEventService(storage.NewInMemoryEventStorage())).Register(mux)
+
+	do := func(path, body string) *httptest.ResponseRecorder {
+		req := httptest.NewRequest(http.MethodPost, path, strings.NewReader(body))
+		req = req.WithContext(auth.WithUser(req.Context(), 1))
+		rec := httptest.NewRecorder()
+		mux.ServeHTTP(rec, req)
+		return rec
+	}
+
+	do("/v2/users/1/events", `{"start":"2024-01-08T09:00:00Z","duration":"30m","title":"Standup"}`)
+
+	tests := []struct {
+		name       string
+		path       string
+		body       string
+		wantStatus int
+		wantBody   []string
+	}{
+		{
+			"invalid operation", "/v2/users/1/events/batch",
+			`{"operations":[{"create":{"date":"2024-01-09","title":"Review"}},{"create":{"date":"2024-01-10"}}]}`,
+			http.StatusUnprocessableEntity,
+			[]string{`"applied":false`, `{"index":0,"status":424}`, `"fields":{"title":"title is required"}`},
+		},
+		{
+			"stale version", "/v2/users/1/events/batch",
+			`{"operations":[{"create":{"date":"2024-01-09","title":"Review"}},{"delete":{"id":1,"version":3}}]}`,
+			http.StatusConflict,
+			[]string{`"index":1,"status":409`, `"code":"conflict"`},
+		},
+		{"empty", "/v2/users/1/events/batch", `{"operations":[]}`, http.StatusUnprocessableEntity, []string{`"operations"`}},
+		{"malformed", "/v2/users/1/events/batch", `{"operations":{}}`, http.StatusBadRequest, []string{`"bad_request"`}},
+		{"other user", "/v2/users/1/events/batch", `{"user_id":2,"operations":[{"delete":{"id":1}}]}`, http.StatusForbidden, []string{`"forbidden"`}},
+		{
+			"event changed twice", "/v2/users/1/events/batch",
+			`{"operations":[{"create":{"date":"2024-01-09","title":"Review"}},{"update":{"id":1,"start":"2024-01-08T10:00:00Z","duration":"30m","title":"Late standup","version":1}},{"delete":{"id":1}}]}`,
+			http.StatusConflict,
+			[]string{`"index":2,"status":409`},
+		},
+		{
+			"applied", "/v2/users/1/events/batch",
+			`{"operations":[{"create":{"date":"2024-01-09","title":"Review"}},{"update":{"id":1,"start":"2024-01-08T10:00:00Z","duration":"30m","title":"Late standup","version":1}}]}`,
+			http.StatusOK,
+			[]string{`"applied":true`, `"index":0,"status":201,"event":{"id":2,`, `"index":1,"status":200,"event":{"id":1,`},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			rec := do(tt.path, tt.body)
+			if rec.Code != tt.wantStatus {
+				t.Fatalf("POST %s = %d %s, want %d", tt.path, rec.Code, rec.Body, tt.wantStatus)
+			}
+			for _, want := range tt.wantBody {
+				if !strings.Contains(rec.Body.String(), want) {
+					t.Errorf("POST %s body %s does not contain %s", tt.path, rec.Body, want)
+				}
+			}
+		})
+	}
+}
+
+func TestV2Handler_Trash(t *testing.T) {
+	mux := http.NewServeMux()
+	NewV2Handler(service.NewEventService(storage.NewInMemoryEventStorage())).Register(mux)
+
+	do := func(method, path, body string) *httptest.ResponseRecorder {
+		req := httptest.NewRequest(method, path, strings.NewReader(body))
+		req = req.WithContext(auth.WithUser(req.Context(), 1))
+		rec := httptest.NewRecorder()
+		mux.ServeHTTP(rec, req)
+		return rec
+	}
+
+	for _, title := range []string{"Standup", "Review"} {
+		if rec := do(http.MethodPost, "/v2/users/1/events", `{"start":"2024-01-08T09:00:00Z","duration":"30m","title":"`+title+`"}`); rec.Code != http.StatusCreated {
+			t.Fatalf("POST status = %d, body %s", rec.Code, rec.Body)
+		}
+	}
+	for _, path := range []string{"/v2/events/1?version=1", "/v2/events/2?version=1"} {
+		if rec := do(http.MethodDelete, path, ""); rec.Code != http.StatusNoContent {
+			t.Fatalf("DELETE %s status = %d, body %s", path, rec.Code, rec.Body)
+		}
+	}
+
+	tests := []struct {
+		name       string
+		method     string
+		path       string
+		wantStatus int
+		wantBody   string
+	}{
+		{"list", http.MethodGet, "/v2/users/1/trash", http.StatusOK, `"purge_at"`},
+		{"other user", http.MethodGet, "/v2/users/2/trash", http.StatusForbidden, `"forbidden"`},
+		{"restore", http.MethodPost, "/v2/users/1/trash/1/restore", http.StatusOK, `"version":2`},
+		{"restore again", http.MethodPost, "/v2/users/1/trash/1/restore", http.StatusNotFound, `"not_found"`},
+		{"restored event", http.MethodGet, "/v2/events/1", http.StatusOK, `"title":"Standup"`},
+		{"invalid event", http.MethodDelete, "/v2/users/1/trash/abc", http.StatusNotFound, `"not_found"`},
+		{"purge", http.MethodDelete, "/v2/users/1/trash/2", http.StatusNoContent, ""},
+		{"purged", http.MethodPost, "/v2/users/1/trash/2/restore", http.StatusNotFound, `"not_found"`},
+		{"empty", http.MethodGet, "/v2/users/1/trash", http.StatusOK, `"events":[]`},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			rec := do(tt.method, tt.path, "")
+			if rec.Code != tt.wantStatus || !strings.Contains(rec.Body.String(), tt.wantBody) {
+				t.Errorf("%s %s = %d %s, want %d with %s", tt.method, tt.path, rec.Code, rec.Body, tt.wantStatus, tt.wantBody)
+			}
+		})
+	}
+}
+
+func TestV2Handler_History(t *testing.T) {
+	mux := http.NewServeMux()
+	NewV2Handler(service.NewEventService(storage.NewInMemoryEventStorage())).Register(mux)
+
+	do := func(method, path, body, ifMatch string) *httptest.ResponseRecorder {
+		req := httptest.NewRequest(method, path, strings.NewReader(body))
+		req = req.WithContext(auth.WithUser(req.Context(), 1))
+		if ifMatch != "" {
+			req.Header.Set("If-Match", ifMatch)
+		}
+		rec := httptest.NewRecorder()
+		mux.ServeHTTP(rec, req)
+		return rec
+	}
+
+	if rec := do(http.MethodPost, "/v2/users/1/events", `{"start":"2024-01-08T09:00:00Z","duration":"30m","title":"Standup"}`, ""); rec.Code != http.StatusCreated {
+		t.Fatalf("POST status = %d, body %s", rec.Code, rec.Body)
+	}
+	if rec := do(http.MethodPatch, "/v2/events/1", `{"title":"Daily"}`, `"1"`); rec.Code != http.StatusOK {
+		t.Fatalf("PATCH status = %d, body %s", rec.Code, rec.Body)
+	}
+
+	tests := []struct {
+		name       string
+		method     string
+		path       string
+		ifMatch    string
+		wantStatus int
+		wantBody   string
+	}{
+		{"history", http.MethodGet, "/v2/events/1/history", "", http.StatusOK, `"old":"Standup","new":"Daily"`},
+		{"missing event", http.MethodGet, "/v2/events/9/history", "", http.StatusNotFound, `"not_found"`},
+		{"revert without version", http.MethodPost, "/v2/events/1/history/1/revert", "", http.StatusPreconditionRequired, ""},
+		{"revert stale", http.MethodPost, "/v2/events/1/history/1/revert", `"1"`, http.StatusPreconditionFailed, `"version":2`},
+		{"revert missing revision", http.MethodPost, "/v2/events/1/history/7/revert", `"2"`, http.StatusNotFound, `"not_found"`},
+		{"revert", http.MethodPost, "/v2/events/1/history/1/revert", `"2"`, http.StatusOK, `"title":"Standup"`},
+		{"revert recorded", http.MethodGet, "/v2/events/1/history", "", http.StatusOK, `"reverted_to":1`},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			rec := do(tt.method, tt.path, "", tt.ifMatch)
+			if rec.Code != tt.wantStatus || !strings.Contains(rec.Body.String(), tt.wantBody) {
+				t.Errorf("%s %s = %d %s, want %d with %s", tt.method, tt.path, rec.Code, rec.Body, tt.wantStatus, tt.wantBody)
+			}
+		})
+	}
+}
@@ -0,0 +1,99 @@
+package handler
+
+import (
+	"fmt"
+	"l2-18/internal/apperrors"
+	"l2-18/internal/models"
+	"net/http"
+	"strconv"
+)
+
+// listWebhooks обработчик GET /v2/users/{id}/webhooks
+func (h *V2Handler) listWebhooks(w http.ResponseWriter, r *http.Request) {
+	userID, err := pathUser(r)
+	if err != nil {
+		writeError(w, r, err)
+		return
+	}
+
+	webhooks, err := h.service.GetWebhooks(userID)
+	if err != nil {
+		writeError(w, r, err)
+		return
+	}
+	if webhooks == nil {
+		webhooks = []models.Webhook{}
+	}
+
+	writeJSON(w, http.StatusOK, models.WebhookList{Webhooks: webhooks})
+}
+
+// createWebhook обработчик POST /v2/users/{id}/webhooks
+func (h *V2Handler) createWebhook(w http.ResponseWriter, r *http.Request) {
+	userID, err := pathUser(r)
+	if err != nil {
+		writeError(w, r, err)
+		return
+	}
+
+	var req models.CreateWebhookRequest
+	if err := decodeJSON(r, &req); err != nil {
+		writeBadRequest(w, err)
+		return
+	}
+	if req.UserID != 0 && req.UserID != userID {
+		writeError(w, r, errUserMismatch)
+		return
+	}
+	req.UserID = userID
+
+	webhook, err := h.service.RegisterWebhook(&req)
+	if err != nil {
+		writeError(w, r, err)
+		return
+	}
+
+	w.Header().Set("Location", fmt.Sprintf("/v2/users/%d/webhooks/%d", userID, webhook.ID))
+	writeJSON(w, http.StatusCreated, webhook)
+}
+
+// deleteWebhook обработчик DELETE /v2/users/{id}/webhooks/{webhook}
+func (h *V2Handler) deleteWebhook(w http.ResponseWriter, r *http.Request) {
+	userID, err := pathUser(r)
+	if err != nil {
+		writeError(w, r, err)
+		return
+	}
+	id, err := strconv.Atoi(r.PathValue("webhook"))
+	if err != nil || id <= 0 {
+		writeError(w, r, apperrors.Errorf(apperrors.ErrNotFound, "webhook %q not found", r.PathValue("webhook")))
+		return
+	}
+
+	if err := h.service.DeleteWebhook(userID, id); err != nil {
+		writeError(w, r, err)
+		return
+	}
+
+	w.WriteHeader(http.StatusNoContent)
+}
+
+// listDeadLetters обработчик GET /v2/users/{id}/webhooks/dead-letters
+func (h *V2Handler) listDeadLetters(w http.ResponseWriter, r *http.Request) {
+	userID, err := pathUser(r)
+	if err != nil {
+		writeError(w, r, err)
+		return
+	}
+
+	letters, err := h.service.GetDeadLetters(userID)
+	if err != nil {
+		writeError(w, r, err)
+		return
+	}
+	if letters == nil {
+		letters = []models.DeadLetter{}
+	}
+
+	writeJSON(w, http.StatusOK, models.DeadLetterList{DeadLetters: letters})
+}
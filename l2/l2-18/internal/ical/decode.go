@@ -0,0 +1,362 @@
+package ical
+
+import (
+	"bufio"
+	"fmt"
+	"io"
+	"l2-18/internal/models"
+	"regexp"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// property строка содержимого iCalendar: имя, параметры и значение
+type property struct {
+	name   string
+	params map[string]string
+	value  string
+}
+
+// durationPattern формат DURATION из RFC 5545, например P1D или PT1H30M
+var durationPattern = regexp.MustCompile(`^([+-])?P(?:(\d+)W)?(?:(\d+)D)?(?:T(?:(\d+)H)?(?:(\d+)M)?(?:(\d+)S)?)?$`)
+
+// Decode читает события VEVENT из VCALENDAR. Время без часового пояса
+// (floating) и время с неизвестным TZID интерпретируется в часовом поясе loc.
+// У измененных экземпляров серий заполнено RecurrenceID, а UID совпадает с UID серии.
+func Decode(r io.Reader, loc *time.Location) ([]*models.Event, error) {
+	lines, err := unfold(r)
+	if err != nil {
+		return nil, err
+	}
+
+	var events []*models.Event
+	var current []property
+	depth := 0 // вложенность компонентов внутри VEVENT (например, VALARM)
+	inEvent := false
+	inAlarm := false
+
+	for i, line := range lines {
+		prop, err := parseLine(line)
+		if err != nil {
+			return nil, fmt.Errorf("line %d: %v", i+1, err)
+		}
+
+		switch {
+		case prop.name == "BEGIN" && strings.EqualFold(prop.value, "VEVENT"):
+			if inEvent {
+				return nil, fmt.Errorf("line %d: nested VEVENT", i+1)
+			}
+			inEvent = true
+			current = nil
+		case prop.name == "END" && strings.EqualFold(prop.value, "VEVENT"):
+			if !inEvent {
+				return nil, fmt.Errorf("line %d: unexpected END:VEVENT", i+1)
+			}
+			event, err := buildEvent(current, loc)
+			if err != nil {
+				return nil, err
+			}
+			events = append(events, event)
+			inEvent = false
+		case !inEvent:
+			// Свойства календаря и VTIMEZONE пропускаем
+		case prop.name == "BEGIN":
+			depth++
+			inAlarm = depth == 1 && strings.EqualFold(prop.value, "VALARM")
+		case prop.name == "END":
+			depth--
+			inAlarm = false
+		case depth == 0:
+			current = append(current, prop)
+		case inAlarm && prop.name == "TRIGGER":
+			// Из VALARM берем только смещение напоминания
+			current = append(current, prop)
+		}
+	}
+
+	if inEvent {
+		return nil, fmt.Errorf("unterminated VEVENT")
+	}
+
+	return events, nil
+}
+
+// buildEvent собирает событие из свойств VEVENT
+func buildEvent(props []property, loc *time.Location) (*models.Event, error) {
+	event := &models.Event{TimeZone: loc.String()}
+	var duration *time.Duration
+	var hasEnd bool
+
+	for _, prop := range props {
+		switch prop.name {
+		case "UID":
+			event.UID = prop.value
+		case "SUMMARY":
+			event.Title = unescapeText(prop.value)
+		case "DESCRIPTION":
+			event.Description = unescapeText(prop.value)
+		case "DTSTART":
+			start, allDay, tz, err := parseTime(prop, loc)
+			if err != nil {
+				return nil, fmt.Errorf("invalid DTSTART: %v", err)
+			}
+			event.Start = start
+			event.AllDay = allDay
+			event.TimeZone = tz
+		case "DTEND":
+			end, _, _, err := parseTime(prop, loc)
+			if err != nil {
+				return nil, fmt.Errorf("invalid DTEND: %v", err)
+			}
+			event.End = end
+			hasEnd = true
+		case "DURATION":
+			d, err := parseDuration(prop.value)
+			if err != nil {
+				return nil, fmt.Errorf("invalid DURATION: %v", err)
+			}
+			duration = &d
+		case "CATEGORIES":
+			for _, value := range splitList(prop.value) {
+				event.Tags = append(event.Tags, unescapeText(value))
+			}
+		case "RRULE":
+			event.RRule = prop.value
+		case "EXDATE":
+			for _, value := range strings.Split(prop.value, ",") {
+				ex, _, _, err := parseTime(property{name: prop.name, params: prop.params, value: value}, loc)
+				if err != nil {
+					return nil, fmt.Errorf("invalid EXDATE: %v", err)
+				}
+				event.ExDates = append(event.ExDates, ex)
+			}
+		case "RECURRENCE-ID":
+			rid, _, _, err := parseTime(prop, loc)
+			if err != nil {
+				return nil, fmt.Errorf("invalid RECURRENCE-ID: %v", err)
+			}
+			event.RecurrenceID = &rid
+		case "TRIGGER":
+			if minutes, ok := parseTrigger(prop); ok {
+				event.Reminders = append(event.Reminders, minutes)
+			}
+		}
+	}
+
+	if event.UID == "" {
+		return nil, fmt.Errorf("VEVENT without UID")
+	}
+	if event.Start.IsZero() {
+		return nil, fmt.Errorf("VEVENT %s without DTSTART", event.UID)
+	}
+
+	switch {
+	case hasEnd:
+	case duration != nil:
+		event.End = event.Start.Add(*duration)
+	case event.AllDay:
+		// Согласно RFC 5545 событие-дата без DTEND длится один день
+		event.End = event.Start.AddDate(0, 0, 1)
+	default:
+		event.End = event.Start
+	}
+	if event.End.Before(event.Start) {
+		return nil, fmt.Errorf("VEVENT %s ends before it starts", event.UID)
+	}
+	event.Date = time.Date(event.Start.Year(), event.Start.Month(), event.Start.Day(), 0, 0, 0, 0, event.Start.Location())
+
+	return event, nil
+}
+
+// parseTime разбирает значение DATE или DATE-TIME с учетом TZID.
+// Возвращает момент времени, признак даты без времени и имя часового пояса.
+func parseTime(prop property, loc *time.Location) (time.Time, bool, string, error) {
+	value := strings.TrimSpace(prop.value)
+
+	if strings.EqualFold(prop.params["VALUE"], "DATE") || len(value) == len(dateLayout) {
+		if tzLoc, ok := tzidLocation(prop); ok {
+			loc = tzLoc
+		}
+		t, err := time.ParseInLocation(dateLayout, value, loc)
+		return t, true, loc.String(), err
+	}
+
+	if strings.HasSuffix(value, "Z") {
+		t, err := time.Parse(utcLayout, value)
+		return t, false, time.UTC.String(), err
+	}
+
+	if tzLoc, ok := tzidLocation(prop); ok {
+		loc = tzLoc
+	}
+	t, err := time.ParseInLocation(dateTimeLayout, value, loc)
+	return t, false, loc.String(), err
+}
+
+// tzidLocation загружает часовой пояс из параметра TZID, если он известен
+func tzidLocation(prop property) (*time.Location, bool) {
+	tzid := strings.TrimPrefix(prop.params["TZID"], "/")
+	if tzid == "" {
+		return nil, false
+	}
+	loc, err := time.LoadLocation(tzid)
+	if err != nil {
+		return nil, false
+	}
+	return loc, true
+}
+
+// parseDuration разбирает DURATION, например P1D или PT1H30M
+func parseDuration(s string) (time.Duration, error) {
+	m := durationPattern.FindStringSubmatch(strings.TrimSpace(s))
+	if m == nil || s == "P" || s == "PT" {
+		return 0, fmt.Errorf("malformed duration %q", s)
+	}
+
+	units := []time.Duration{7 * 24 * time.Hour, 24 * time.Hour, time.Hour, time.Minute, time.Second}
+	var d time.Duration
+	for i, unit := range units {
+		if m[i+2] == "" {
+			continue
+		}
+		n, err := strconv.Atoi(m[i+2])
+		if err != nil {
+			return 0, err
+		}
+		d += time.Duration(n) * unit
+	}
+	if m[1] == "-" {
+		d = -d
+	}
+
+	return d, nil
+}
+
+// parseTrigger возвращает смещение напоминания в минутах до начала события.
+// Абсолютное время, отсчет от конца события и срабатывание после начала
+// не поддерживаются и пропускаются.
+func parseTrigger(prop property) (int, bool) {
+	if strings.EqualFold(prop.params["VALUE"], "DATE-TIME") || strings.EqualFold(prop.params["RELATED"], "END") {
+		return 0, false
+	}
+
+	d, err := parseDuration(prop.value)
+	if err != nil || d > 0 {
+		return 0, false
+	}
+
+	return int(-d / time.Minute), true
+}
+
+// unfold читает строки, склеивая перенесенные (начинающиеся с пробела или табуляции)
+func unfold(r io.Reader) ([]string, error) {
+	scanner := bufio.NewScanner(r)
+	scanner.Buffer(make([]byte, 0, 64*1024), 1024*1024)
+
+	var lines []string
+	for scanner.Scan() {
+		line := strings.TrimRight(scanner.Text(), "\r")
+		if line == "" {
+			continue
+		}
+		if (line[0] == ' ' || line[0] == '\t') && len(lines) > 0 {
+			lines[len(lines)-1] += line[1:]
+			continue
+		}
+		lines = append(lines, line)
+	}
+	if err := scanner.Err(); err != nil {
+		return nil, err
+	}
+
+	return lines, nil
+}
+
+// parseLine разбирает строку вида NAME;PARAM=VALUE:значение
+func parseLine(line string) (property, error) {
+	prop := property{params: make(map[string]string)}
+
+	// Ищем двоеточие, отделяющее значение, вне кавычек в параметрах
+	inQuotes := false
+	colon := -1
+	for i, r := range line {
+		if r == '"' {
+			inQuotes = !inQuotes
+		}
+		if r == ':' && !inQuotes {
+			colon = i
+			break
+		}
+	}
+	if colon < 0 {
+		return prop, fmt.Errorf("missing ':' in %q", line)
+	}
+
+	head := line[:colon]
+	prop.value = line[colon+1:]
+
+	parts := splitParams(head)
+	prop.name = strings.ToUpper(parts[0])
+	for _, param := range parts[1:] {
+		key, value, ok := strings.Cut(param, "=")
+		if !ok {
+			return prop, fmt.Errorf("invalid parameter %q", param)
+		}
+		prop.params[strings.ToUpper(key)] = strings.Trim(value, `"`)
+	}
+
+	return prop, nil
+}
+
+// splitParams делит имя и параметры по ';' вне кавычек
+func splitParams(s string) []string {
+	var parts []string
+	inQuotes := false
+	start := 0
+	for i, r := range s {
+		switch {
+		case r == '"':
+			inQuotes = !inQuotes
+		case r == ';' && !inQuotes:
+			parts = append(parts, s[start:i])
+			start = i + 1
+		}
+	}
+	return append(parts, s[start:])
+}
+
+// splitList делит список значений TEXT по неэкранированным запятым
+func splitList(s string) []string {
+	var parts []string
+	start := 0
+	for i := 0; i < len(s); i++ {
+		switch s[i] {
+		case '\\':
+			i++
+		case ',':
+			parts = append(parts, s[start:i])
+			start = i + 1
+		}
+	}
+	return append(parts, s[start:])
+}
+
+// unescapeText снимает экранирование значения типа TEXT
+func unescapeText(s string) string {
+	var b strings.Builder
+	for i := 0; i < len(s); i++ {
+		if s[i] == '\\' && i+1 < len(s) {
+			i++
+			switch s[i] {
+			case 'n', 'N':
+				b.WriteByte('\n')
+			default:
+				b.WriteByte(s[i])
+			}
+			continue
+		}
+		b.WriteByte(s[i])
+	}
+	return b.String()
+}
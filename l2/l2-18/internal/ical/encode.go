@@ -0,0 +1,167 @@
+package ical
+
+import (
+	"bufio"
+	"fmt"
+	"io"
+	"l2-18/internal/models"
+	"strings"
+	"time"
+)
+
+// Форматы дат iCalendar
+const (
+	dateLayout     = "20060102"
+	dateTimeLayout = "20060102T150405"
+	utcLayout      = "20060102T150405Z"
+)
+
+// prodID идентификатор продукта в VCALENDAR
+const prodID = "-//l2-18//Calendar//RU"
+
+// maxLineOctets максимальная длина строки до переноса согласно RFC 5545
+const maxLineOctets = 75
+
+// Encode записывает события как VCALENDAR. Время событий с часовым поясом
+// выводится с параметром TZID в виде имени IANA, события на весь день — как даты.
+func Encode(w io.Writer, events []*models.Event) error {
+	bw := bufio.NewWriter(w)
+	enc := &encoder{w: bw}
+
+	enc.line("BEGIN:VCALENDAR")
+	enc.line("VERSION:2.0")
+	enc.line("PRODID:" + prodID)
+	enc.line("CALSCALE:GREGORIAN")
+	for _, event := range events {
+		enc.event(event)
+	}
+	enc.line("END:VCALENDAR")
+
+	if enc.err != nil {
+		return enc.err
+	}
+	return bw.Flush()
+}
+
+// encoder пишет строки iCalendar, запоминая первую ошибку
+type encoder struct {
+	w   *bufio.Writer
+	err error
+}
+
+// event записывает один VEVENT
+func (e *encoder) event(event *models.Event) {
+	e.line("BEGIN:VEVENT")
+	e.line("UID:" + EventUID(event))
+	e.line("DTSTAMP:" + event.UpdatedAt.UTC().Format(utcLayout))
+	if event.RecurrenceID != nil {
+		e.line("RECURRENCE-ID" + formatTime(event, *event.RecurrenceID))
+	}
+	e.line("DTSTART" + formatTime(event, event.Start))
+	e.line("DTEND" + formatTime(event, event.End))
+	e.line("SUMMARY:" + escapeText(event.Title))
+	if event.Description != "" {
+		e.line("DESCRIPTION:" + escapeText(event.Description))
+	}
+	if len(event.Tags) > 0 {
+		values := make([]string, 0, len(event.Tags))
+		for _, tag := range event.Tags {
+			values = append(values, escapeText(tag))
+		}
+		e.line("CATEGORIES:" + strings.Join(values, ","))
+	}
+	if event.RRule != "" {
+		e.line("RRULE:" + event.RRule)
+	}
+	if len(event.ExDates) > 0 {
+		values := make([]string, 0, len(event.ExDates))
+		var params string
+		for _, ex := range event.ExDates {
+			formatted := formatTime(event, ex)
+			params, _, _ = strings.Cut(formatted, ":")
+			values = append(values, formatted[len(params)+1:])
+		}
+		e.line("EXDATE" + params + ":" + strings.Join(values, ","))
+	}
+	if !event.CreatedAt.IsZero() {
+		e.line("CREATED:" + event.CreatedAt.UTC().Format(utcLayout))
+	}
+	if !event.UpdatedAt.IsZero() {
+		e.line("LAST-MODIFIED:" + event.UpdatedAt.UTC().Format(utcLayout))
+	}
+	for _, minutes := range event.Reminders {
+		e.line("BEGIN:VALARM")
+		e.line("ACTION:DISPLAY")
+		e.line("DESCRIPTION:" + escapeText(event.Title))
+		e.line(fmt.Sprintf("TRIGGER:-PT%dM", minutes))
+		e.line("END:VALARM")
+	}
+	e.line("END:VEVENT")
+}
+
+// line записывает строку с переносом длинных строк и CRLF
+func (e *encoder) line(s string) {
+	if e.err != nil {
+		return
+	}
+
+	var b strings.Builder
+	octets := 0
+	for _, r := range s {
+		size := len(string(r))
+		if octets+size > maxLineOctets {
+			b.WriteString("\r\n ")
+			octets = 1
+		}
+		b.WriteRune(r)
+		octets += size
+	}
+	b.WriteString("\r\n")
+
+	_, e.err = e.w.WriteString(b.String())
+}
+
+// EventUID возвращает UID события для iCalendar, формируя его из ID,
+// если событие было создано до появления UID
+func EventUID(event *models.Event) string {
+	if event.UID != "" {
+		return event.UID
+	}
+	return fmt.Sprintf("event-%d@l2-18", event.ID)
+}
+
+// formatTime форматирует момент времени события вместе с параметрами
+// свойства, например ";VALUE=DATE:20240305" или ";TZID=Europe/Moscow:20240305T100000"
+func formatTime(event *models.Event, t time.Time) string {
+	switch {
+	case event.AllDay:
+		return ";VALUE=DATE:" + t.In(location(event.TimeZone)).Format(dateLayout)
+	case event.TimeZone != "" && event.TimeZone != "UTC":
+		return ";TZID=" + event.TimeZone + ":" + t.In(location(event.TimeZone)).Format(dateTimeLayout)
+	default:
+		return ":" + t.UTC().Format(utcLayout)
+	}
+}
+
+// escapeText экранирует значение типа TEXT
+func escapeText(s string) string {
+	return strings.NewReplacer(
+		`\`, `\\`,
+		";", `\;`,
+		",", `\,`,
+		"\r\n", `\n`,
+		"\n", `\n`,
+	).Replace(s)
+}
+
+// location загружает часовой пояс по имени, по умолчанию UTC
+func location(tz string) *time.Location {
+	if tz == "" {
+		return time.UTC
+	}
+	loc, err := time.LoadLocation(tz)
+	if err != nil {
+		return time.UTC
+	}
+	return loc
+}
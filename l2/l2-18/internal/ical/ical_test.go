@@ -0,0 +1,155 @@
+package ical
+
+import (
+	"bytes"
+	"l2-18/internal/models"
+	"slices"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestEncodeDecode_RoundTrip(t *testing.T) {
+	moscow, err := time.LoadLocation("Europe/Moscow")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	seriesStart := time.Date(2024, 1, 1, 0, 0, 0, 0, time.UTC)
+	rid := time.Date(2024, 1, 8, 0, 0, 0, 0, time.UTC)
+	meetingStart := time.Date(2024, 3, 5, 10, 0, 0, 0, moscow)
+
+	events := []*models.Event{
+		{
+			UID:         "standup@test",
+			Start:       seriesStart,
+			End:         seriesStart.AddDate(0, 0, 1),
+			AllDay:      true,
+			TimeZone:    "UTC",
+			Title:       "Standup",
+			RRule:       "FREQ=WEEKLY;BYDAY=MO",
+			ExDates:     []time.Time{rid},
+			Description: "Daily sync; bring notes, please",
+		},
+		{
+			UID:          "standup@test",
+			Start:        rid.AddDate(0, 0, 1),
+			End:          rid.AddDate(0, 0, 2),
+			AllDay:       true,
+			TimeZone:     "UTC",
+			Title:        "Moved standup",
+			RecurrenceID: &rid,
+		},
+		{
+			UID:         "meeting@test",
+			Start:       meetingStart,
+			End:         meetingStart.Add(90 * time.Minute),
+			TimeZone:    "Europe/Moscow",
+			Title:       "Планирование квартала",
+			Description: strings.Repeat("Очень длинное описание встречи. ", 5) + "\nВторая строка",
+			Reminders:   []int{10, 60},
+			Tags:        []string{"работа", "Q1, планы"},
+		},
+	}
+
+	var buf bytes.Buffer
+	if err := Encode(&buf, events); err != nil {
+		t.Fatal("Encode() error:", err)
+	}
+
+	for _, line := range strings.Split(buf.String(), "\r\n") {
+		if len(line) > maxLineOctets {
+			t.Errorf("Encode() produced line longer than %d octets: %q", maxLineOctets, line)
+		}
+	}
+
+	decoded, err := Decode(&buf, time.UTC)
+	if err != nil {
+		t.Fatal("Decode() error:", err)
+	}
+	if len(decoded) != len(events) {
+		t.Fatalf("Decode() got %d events, want %d", len(decoded), len(events))
+	}
+
+	for i, want := range events {
+		got := decoded[i]
+		if got.UID != want.UID || got.Title != want.Title || got.Description != want.Description {
+			t.Errorf("event %d: got %q/%q/%q, want %q/%q/%q", i, got.UID, got.Title, got.Description, want.UID, want.Title, want.Description)
+		}
+		if !got.Start.Equal(want.Start) || !got.End.Equal(want.End) || got.AllDay != want.AllDay {
+			t.Errorf("event %d: got %v-%v (all day %v), want %v-%v (all day %v)", i, got.Start, got.End, got.AllDay, want.Start, want.End, want.AllDay)
+		}
+		if got.TimeZone != want.TimeZone {
+			t.Errorf("event %d: TimeZone = %q, want %q", i, got.TimeZone, want.TimeZone)
+		}
+		if got.RRule != want.RRule || len(got.ExDates) != len(want.ExDates) {
+			t.Errorf("event %d: recurrence = %q %v, want %q %v", i, got.RRule, got.ExDates, want.RRule, want.ExDates)
+		}
+		if !slices.Equal(got.Reminders, want.Reminders) {
+			t.Errorf("event %d: Reminders = %v, want %v", i, got.Reminders, want.Reminders)
+		}
+		if !slices.Equal(got.Tags, want.Tags) {
+			t.Errorf("event %d: Tags = %q, want %q", i, got.Tags, want.Tags)
+		}
+		if (got.RecurrenceID == nil) != (want.RecurrenceID == nil) ||
+			(got.RecurrenceID != nil && !got.RecurrenceID.Equal(*want.RecurrenceID)) {
+			t.Errorf("event %d: RecurrenceID = %v, want %v", i, got.RecurrenceID, want.RecurrenceID)
+		}
+	}
+}
+
+func TestDecode_ThirdPartyCalendar(t *testing.T) {
+	input := strings.Join([]string{
+		"BEGIN:VCALENDAR",
+		"VERSION:2.0",
+		"PRODID:-//Example//EN",
+		"BEGIN:VTIMEZONE",
+		"TZID:Europe/Berlin",
+		"BEGIN:STANDARD",
+		"DTSTART:19701025T030000",
+		"END:STANDARD",
+		"END:VTIMEZONE",
+		"BEGIN:VEVENT",
+		"UID:abc-123",
+		"DTSTART;TZID=Europe/Berlin:20240401T090000",
+		"DURATION:PT45M",
+		"SUMMARY:Weekly review\\, team A",
+		"DESCRIPTION:Long description that was folded",
+		"  across two lines",
+		"BEGIN:VALARM",
+		"TRIGGER:-PT15M",
+		"DESCRIPTION:Reminder",
+		"END:VALARM",
+		"END:VEVENT",
+		"END:VCALENDAR",
+	}, "\r\n")
+
+	events, err := Decode(strings.NewReader(input), time.UTC)
+	if err != nil {
+		t.Fatal("Decode() error:", err)
+	}
+	if len(events) != 1 {
+		t.Fatalf("Decode() got %d events, want 1", len(events))
+	}
+
+	event := events[0]
+	if event.Title != "Weekly review, team A" {
+		t.Errorf("Title = %q", event.Title)
+	}
+	if event.Description != "Long description that was folded across two lines" {
+		t.Errorf("Description = %q", event.Description)
+	}
+	if event.TimeZone != "Europe/Berlin" || event.Start.UTC().Hour() != 7 {
+		t.Errorf("Start = %v in %q, want 09:00 Europe/Berlin", event.Start, event.TimeZone)
+	}
+	if event.Duration() != 45*time.Minute {
+		t.Errorf("Duration() = %v, want 45m", event.Duration())
+	}
+	if !slices.Equal(event.Reminders, []int{15}) {
+		t.Errorf("Reminders = %v, want [15]", event.Reminders)
+	}
+
+	if _, err := Decode(strings.NewReader("BEGIN:VEVENT\r\nSUMMARY:x\r\nEND:VEVENT"), time.UTC); err == nil {
+		t.Error("Decode() should reject VEVENT without UID")
+	}
+}
@@ -0,0 +1,93 @@
+// Package logging связывает структурный журнал slog с запросами:
+// идентификатор запроса из контекста добавляется к каждой записи,
+// а обработчики могут дополнять итоговую запись о запросе своими полями
+package logging
+
+import (
+	"context"
+	"crypto/rand"
+	"encoding/hex"
+	"log/slog"
+	"sync"
+)
+
+type requestKey struct{}
+
+// request состояние запроса в контексте
+type request struct {
+	id    string
+	mu    sync.Mutex
+	attrs []slog.Attr
+}
+
+// WithRequestID возвращает контекст запроса с идентификатором id
+func WithRequestID(ctx context.Context, id string) context.Context {
+	return context.WithValue(ctx, requestKey{}, &request{id: id})
+}
+
+// RequestID возвращает идентификатор запроса из контекста или пустую строку
+func RequestID(ctx context.Context) string {
+	if req, ok := ctx.Value(requestKey{}).(*request); ok {
+		return req.id
+	}
+	return ""
+}
+
+// NewRequestID создает случайный идентификатор запроса
+func NewRequestID() string {
+	b := make([]byte, 16)
+	_, _ = rand.Read(b)
+	return hex.EncodeToString(b)
+}
+
+// AddAttrs дополняет итоговую запись журнала о запросе полями attrs,
+// например пользователем после аутентификации. Вне запроса ничего не делает.
+func AddAttrs(ctx context.Context, attrs ...slog.Attr) {
+	req, ok := ctx.Value(requestKey{}).(*request)
+	if !ok {
+		return
+	}
+	req.mu.Lock()
+	defer req.mu.Unlock()
+	req.attrs = append(req.attrs, attrs...)
+}
+
+// Attrs возвращает поля, добавленные к записи о запросе
+func Attrs(ctx context.Context) []slog.Attr {
+	req, ok := ctx.Value(requestKey{}).(*request)
+	if !ok {
+		return nil
+	}
+	req.mu.Lock()
+	defer req.mu.Unlock()
+	return append([]slog.Attr(nil), req.attrs...)
+}
+
+// Handler обертка обработчика slog, которая добавляет к записям
+// идентификатор запроса из контекста в поле request_id
+type Handler struct {
+	slog.Handler
+}
+
+// NewHandler оборачивает обработчик slog
+func NewHandler(next slog.Handler) *Handler {
+	return &Handler{Handler: next}
+}
+
+// Handle добавляет идентификатор запроса и передает запись дальше
+func (h *Handler) Handle(ctx context.Context, record slog.Record) error {
+	if id := RequestID(ctx); id != "" {
+		record.AddAttrs(slog.String("request_id", id))
+	}
+	return h.Handler.Handle(ctx, record)
+}
+
+// WithAttrs возвращает обертку над обработчиком с полями attrs
+func (h *Handler) WithAttrs(attrs []slog.Attr) slog.Handler {
+	return &Handler{Handler: h.Handler.WithAttrs(attrs)}
+}
+
+// WithGroup возвращает обертку над обработчиком с группой name
+func (h *Handler) WithGroup(name string) slog.Handler {
+	return &Handler{Handler: h.Handler.WithGroup(name)}
+}
@@ -0,0 +1,167 @@
+// Package metrics собирает метрики сервиса и отдает их в текстовом
+// формате Prometheus (https://prometheus.io/docs/instrumenting/exposition_formats/)
+package metrics
+
+import (
+	"fmt"
+	"io"
+	"log/slog"
+	"net/http"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+)
+
+// Buckets верхние границы корзин гистограммы длительности запросов в секундах
+var Buckets = []float64{0.005, 0.01, 0.025, 0.05, 0.1, 0.25, 0.5, 1, 2.5, 5, 10}
+
+// Имена метрик запросов
+const (
+	requestsTotal   = "calendar_http_requests_total"
+	requestDuration = "calendar_http_request_duration_seconds"
+)
+
+// requestLabels метки метрик запросов. Route — шаблон маршрута,
+// а не путь, чтобы число рядов не зависело от идентификаторов в пути.
+type requestLabels struct {
+	Method string
+	Route  string
+	Status int
+}
+
+// histogram распределение длительностей запросов с одними метками
+type histogram struct {
+	counts []uint64 // counts[i] — число наблюдений не больше Buckets[i]
+	count  uint64
+	sum    float64
+}
+
+// gauge метрика, значение которой вычисляется при каждом чтении
+type gauge struct {
+	name  string
+	help  string
+	value func() (float64, error)
+}
+
+// Registry хранит метрики запросов и вычисляемые метрики
+type Registry struct {
+	mu       sync.Mutex
+	requests map[requestLabels]*histogram
+	gauges   []gauge
+}
+
+// NewRegistry создает пустой набор метрик
+func NewRegistry() *Registry {
+	return &Registry{requests: make(map[requestLabels]*histogram)}
+}
+
+// ObserveRequest учитывает завершенный запрос в счетчике и гистограмме
+func (r *Registry) ObserveRequest(method, route string, status int, duration time.Duration) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	labels := requestLabels{Method: method, Route: route, Status: status}
+	h, ok := r.requests[labels]
+	if !ok {
+		h = &histogram{counts: make([]uint64, len(Buckets))}
+		r.requests[labels] = h
+	}
+
+	seconds := duration.Seconds()
+	for i, bound := range Buckets {
+		if seconds <= bound {
+			h.counts[i]++
+		}
+	}
+	h.count++
+	h.sum += seconds
+}
+
+// GaugeFunc добавляет метрику, значение которой value вычисляет при каждом
+// чтении. Если value вернула ошибку, метрика в этот раз пропускается.
+func (r *Registry) GaugeFunc(name, help string, value func() (float64, error)) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	r.gauges = append(r.gauges, gauge{name: name, help: help, value: value})
+}
+
+// WriteTo записывает все метрики в текстовом формате Prometheus
+func (r *Registry) WriteTo(w io.Writer) (int64, error) {
+	r.mu.Lock()
+	labels := make([]requestLabels, 0, len(r.requests))
+	requests := make(map[requestLabels]histogram, len(r.requests))
+	for l, h := range r.requests {
+		labels = append(labels, l)
+		requests[l] = histogram{counts: append([]uint64(nil), h.counts...), count: h.count, sum: h.sum}
+	}
+	gauges := append([]gauge(nil), r.gauges...)
+	r.mu.Unlock()
+
+	sort.Slice(labels, func(i, j int) bool {
+		a, b := labels[i], labels[j]
+		if a.Route != b.Route {
+			return a.Route < b.Route
+		}
+		if a.Method != b.Method {
+			return a.Method < b.Method
+		}
+		return a.Status < b.Status
+	})
+
+	var b strings.Builder
+	fmt.Fprintf(&b, "# HELP %s Total number of HTTP requests.\n", requestsTotal)
+	fmt.Fprintf(&b, "# TYPE %s counter\n", requestsTotal)
+	for _, l := range labels {
+		fmt.Fprintf(&b, "%s{%s} %d\n", requestsTotal, l.format(), requests[l].count)
+	}
+
+	fmt.Fprintf(&b, "# HELP %s HTTP request latency in seconds.\n", requestDuration)
+	fmt.Fprintf(&b, "# TYPE %s histogram\n", requestDuration)
+	for _, l := range labels {
+		h := requests[l]
+		for i, bound := range Buckets {
+			fmt.Fprintf(&b, "%s_bucket{%s,le=\"%s\"} %d\n", requestDuration, l.format(), formatFloat(bound), h.counts[i])
+		}
+		fmt.Fprintf(&b, "%s_bucket{%s,le=\"+Inf\"} %d\n", requestDuration, l.format(), h.count)
+		fmt.Fprintf(&b, "%s_sum{%s} %s\n", requestDuration, l.format(), formatFloat(h.sum))
+		fmt.Fprintf(&b, "%s_count{%s} %d\n", requestDuration, l.format(), h.count)
+	}
+
+	for _, g := range gauges {
+		value, err := g.value()
+		if err != nil {
+			slog.Error("failed to collect metric", "metric", g.name, "error", err)
+			continue
+		}
+		fmt.Fprintf(&b, "# HELP %s %s\n", g.name, g.help)
+		fmt.Fprintf(&b, "# TYPE %s gauge\n", g.name)
+		fmt.Fprintf(&b, "%s %s\n", g.name, formatFloat(value))
+	}
+
+	n, err := io.WriteString(w, b.String())
+	return int64(n), err
+}
+
+// ServeHTTP отдает метрики по запросу Prometheus
+func (r *Registry) ServeHTTP(w http.ResponseWriter, req *http.Request) {
+	w.Header().Set("Content-Type", "text/plain; version=0.0.4; charset=utf-8")
+	_, _ = r.WriteTo(w)
+}
+
+// format возвращает метки в виде method="GET",route="...",status="200"
+func (l requestLabels) format() string {
+	return fmt.Sprintf(`method="%s",route="%s",status="%d"`, escape(l.Method), escape(l.Route), l.Status)
+}
+
+// escape экранирует значение метки
+func escape(s string) string {
+	return strings.NewReplacer(`\`, `\\`, `"`, `\"`, "\n", `\n`).Replace(s)
+}
+
+// formatFloat форматирует число без лишних знаков
+func formatFloat(f float64) string {
+	return strconv.FormatFloat(f, 'g', -1, 64)
+}
@@ -0,0 +1,48 @@
+package metrics
+
+import (
+	"errors"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestRegistry_WriteTo(t *testing.T) {
+	r := NewRegistry()
+	r.ObserveRequest("GET", "GET /v2/users/{id}/events", 200, 20*time.Millisecond)
+	r.ObserveRequest("GET", "GET /v2/users/{id}/events", 200, 3*time.Second)
+	r.ObserveRequest("POST", `/odd"route`, 500, time.Millisecond)
+	r.GaugeFunc("calendar_events", "Number of stored events.", func() (float64, error) { return 42, nil })
+	r.GaugeFunc("calendar_broken", "Fails to collect.", func() (float64, error) { return 0, errors.New("boom") })
+
+	var b strings.Builder
+	if _, err := r.WriteTo(&b); err != nil {
+		t.Fatal("WriteTo() error:", err)
+	}
+	out := b.String()
+
+	events := `method="GET",route="GET /v2/users/{id}/events",status="200"`
+	want := []string{
+		"# TYPE calendar_http_requests_total counter",
+		"calendar_http_requests_total{" + events + "} 2",
+		`calendar_http_requests_total{method="POST",route="/odd\"route",status="500"} 1`,
+		"# TYPE calendar_http_request_duration_seconds histogram",
+		"calendar_http_request_duration_seconds_bucket{" + events + `,le="0.01"} 0`,
+		"calendar_http_request_duration_seconds_bucket{" + events + `,le="0.025"} 1`,
+		"calendar_http_request_duration_seconds_bucket{" + events + `,le="2.5"} 1`,
+		"calendar_http_request_duration_seconds_bucket{" + events + `,le="5"} 2`,
+		"calendar_http_request_duration_seconds_bucket{" + events + `,le="+Inf"} 2`,
+		"calendar_http_request_duration_seconds_sum{" + events + "} 3.02",
+		"calendar_http_request_duration_seconds_count{" + events + "} 2",
+		"# TYPE calendar_events gauge",
+		"calendar_events 42",
+	}
+	for _, line := range want {
+		if !strings.Contains(out, line+"\n") {
+			t.Errorf("output has no line %q:\n%s", line, out)
+		}
+	}
+	if strings.Contains(out, "calendar_broken") {
+		t.Errorf("failed gauge is written:\n%s", out)
+	}
+}
@@ -0,0 +1,40 @@
+package middleware
+
+import (
+	"encoding/json"
+	"l2-18/internal/apperrors"
+	"l2-18/internal/auth"
+	"l2-18/internal/logging"
+	"l2-18/internal/models"
+	"log/slog"
+	"net/http"
+)
+
+// AuthMiddleware структура для middleware аутентификации
+type AuthMiddleware struct {
+	handler       http.Handler
+	authenticator *auth.Authenticator
+}
+
+// Auth создает middleware, которое пропускает только запросы с действительными
+// учетными данными и кладет пользователя в контекст запроса
+func Auth(authenticator *auth.Authenticator, next http.Handler) http.Handler {
+	return &AuthMiddleware{handler: next, authenticator: authenticator}
+}
+
+// ServeHTTP реализует интерфейс http.Handler
+func (a *AuthMiddleware) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	userID, err := a.authenticator.Authenticate(r)
+	if err != nil {
+		// Basic нужен CalDAV-клиентам, чтобы они запросили пароль
+		w.Header().Add("WWW-Authenticate", `Bearer realm="calendar"`)
+		w.Header().Add("WWW-Authenticate", `Basic realm="calendar", charset="UTF-8"`)
+		w.Header().Set("Content-Type", "application/json")
+		w.WriteHeader(http.StatusUnauthorized)
+		_ = json.NewEncoder(w).Encode(models.APIResponse{Error: err.Error(), Code: apperrors.CodeAuth})
+		return
+	}
+
+	logging.AddAttrs(r.Context(), slog.Int("user_id", userID))
+	a.handler.ServeHTTP(w, r.WithContext(auth.WithUser(r.Context(), userID)))
+}
@@ -0,0 +1,39 @@
+package middleware
+
+import (
+	"encoding/json"
+	"fmt"
+	"l2-18/internal/apperrors"
+	"l2-18/internal/models"
+	"net/http"
+)
+
+// BodyLimitMiddleware структура для middleware ограничения размера тела запроса
+type BodyLimitMiddleware struct {
+	handler  http.Handler
+	maxBytes int64
+}
+
+// BodyLimit создает middleware, которое ограничивает тело запроса maxBytes
+// байтами: запрос с большим Content-Length отклоняется сразу с 413,
+// а чтение тела без длины прерывается ошибкой *http.MaxBytesError
+// на первом байте сверх ограничения
+func BodyLimit(maxBytes int64, next http.Handler) http.Handler {
+	return &BodyLimitMiddleware{handler: next, maxBytes: maxBytes}
+}
+
+// ServeHTTP реализует интерфейс http.Handler
+func (b *BodyLimitMiddleware) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	if r.ContentLength > b.maxBytes {
+		w.Header().Set("Content-Type", "application/json")
+		w.WriteHeader(http.StatusRequestEntityTooLarge)
+		_ = json.NewEncoder(w).Encode(models.APIResponse{
+			Error: fmt.Sprintf("request body is larger than %d bytes", b.maxBytes),
+			Code:  apperrors.CodeTooLarge,
+		})
+		return
+	}
+
+	r.Body = http.MaxBytesReader(w, r.Body, b.maxBytes)
+	b.handler.ServeHTTP(w, r)
+}
@@ -0,0 +1,47 @@
+package middleware
+
+import (
+	"errors"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+)
+
+func TestBodyLimit(t *testing.T) {
+	var readErr error
+	handler := BodyLimit(8, http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		_, readErr = io.ReadAll(r.Body)
+	}))
+
+	tests := []struct {
+		name       string
+		body       string
+		chunked    bool
+		wantStatus int
+		wantErr    bool
+	}{
+		{"within limit", "12345678", false, http.StatusOK, false},
+		{"content length over limit", "123456789", false, http.StatusRequestEntityTooLarge, false},
+		// Без Content-Length тело обрывается при чтении
+		{"chunked over limit", "123456789", true, http.StatusOK, true},
+	}
+	for _, tt := range tests {
+		readErr = nil
+		req := httptest.NewRequest(http.MethodPost, "/create_event", strings.NewReader(tt.body))
+		if tt.chunked {
+			req.ContentLength = -1
+		}
+		rec := httptest.NewRecorder()
+		handler.ServeHTTP(rec, req)
+
+		var tooLarge *http.MaxBytesError
+		if rec.Code != tt.wantStatus || errors.As(readErr, &tooLarge) != tt.wantErr {
+			t.Errorf("%s: status %d, read error %v; want %d, error %v", tt.name, rec.Code, readErr, tt.wantStatus, tt.wantErr)
+		}
+		if rec.Code == http.StatusRequestEntityTooLarge && !strings.Contains(rec.Body.String(), `"code":"request_too_large"`) {
+			t.Errorf("%s: body %s has no request_too_large code", tt.name, rec.Body)
+		}
+	}
+}
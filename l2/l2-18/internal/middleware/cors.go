@@ -0,0 +1,62 @@
+package middleware
+
+import (
+	"net/http"
+	"slices"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// CORSOptions разрешения для запросов из браузера с других источников
+type CORSOptions struct {
+	// AllowedOrigins разрешенные источники; "*" разрешает любой
+	AllowedOrigins []string
+	AllowedMethods []string
+	AllowedHeaders []string
+	// MaxAge время, на которое браузер может запомнить ответ на предварительный запрос
+	MaxAge time.Duration
+}
+
+// CORSMiddleware структура для middleware CORS
+type CORSMiddleware struct {
+	handler http.Handler
+	options CORSOptions
+}
+
+// CORS создает middleware, которое добавляет заголовки CORS к ответам
+// на запросы с разрешенных источников и само отвечает на предварительные
+// запросы, чтобы они не требовали аутентификации
+func CORS(options CORSOptions, next http.Handler) http.Handler {
+	return &CORSMiddleware{handler: next, options: options}
+}
+
+// ServeHTTP реализует интерфейс http.Handler
+func (c *CORSMiddleware) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	origin := r.Header.Get("Origin")
+	if origin == "" || !c.allowed(origin) {
+		c.handler.ServeHTTP(w, r)
+		return
+	}
+
+	header := w.Header()
+	header.Add("Vary", "Origin")
+	header.Set("Access-Control-Allow-Origin", origin)
+	header.Set("Access-Control-Expose-Headers", "ETag, Location, "+RequestIDHeader)
+
+	// Предварительный запрос браузера перед запросом с другого источника
+	if r.Method == http.MethodOptions && r.Header.Get("Access-Control-Request-Method") != "" {
+		header.Set("Access-Control-Allow-Methods", strings.Join(c.options.AllowedMethods, ", "))
+		header.Set("Access-Control-Allow-Headers", strings.Join(c.options.AllowedHeaders, ", "))
+		header.Set("Access-Control-Max-Age", strconv.Itoa(int(c.options.MaxAge.Seconds())))
+		w.WriteHeader(http.StatusNoContent)
+		return
+	}
+
+	c.handler.ServeHTTP(w, r)
+}
+
+// allowed проверяет, разрешен ли источник
+func (c *CORSMiddleware) allowed(origin string) bool {
+	return slices.Contains(c.options.AllowedOrigins, "*") || slices.Contains(c.options.AllowedOrigins, origin)
+}
@@ -1,59 +1,112 @@
 package middleware
 
 import (
-	"log"
+	"l2-18/internal/logging"
+	"log/slog"
 	"net/http"
 	"time"
 )
 
+// RequestIDHeader заголовок с идентификатором запроса
+const RequestIDHeader = "X-Request-ID"
+
+// maxRequestIDLength наибольшая длина идентификатора, принимаемого от клиента
+const maxRequestIDLength = 128
+
 // LoggingMiddleware структура для middleware логирования
 type LoggingMiddleware struct {
 	handler http.Handler
+	logger  *slog.Logger
 }
 
-// Logging создает middleware для логирования запросов
-func Logging(next http.Handler) http.Handler {
-	return &LoggingMiddleware{handler: next}
+// Logging создает middleware, которое присваивает запросу идентификатор
+// (берет его из X-Request-ID или создает новый), кладет его в контекст
+// и заголовок ответа и пишет в журнал итоговую запись о запросе
+func Logging(logger *slog.Logger, next http.Handler) http.Handler {
+	return &LoggingMiddleware{handler: next, logger: logger}
 }
 
 // ServeHTTP реализует интерфейс http.Handler
 func (l *LoggingMiddleware) ServeHTTP(w http.ResponseWriter, r *http.Request) {
 	start := time.Now()
 
-	// Создаем обертку для ResponseWriter чтобы захватить статус код
+	id := r.Header.Get(RequestIDHeader)
+	if !validRequestID(id) {
+		id = logging.NewRequestID()
+	}
+	w.Header().Set(RequestIDHeader, id)
+	ctx := logging.WithRequestID(r.Context(), id)
+
+	// Создаем обертку для ResponseWriter чтобы захватить статус код и размер ответа
 	wrapped := &responseWriter{
 		ResponseWriter: w,
 		statusCode:     http.StatusOK,
 	}
 
 	// Выполняем запрос
-	l.handler.ServeHTTP(wrapped, r)
+	l.handler.ServeHTTP(wrapped, r.WithContext(ctx))
 
 	// Логируем информацию о запросе
-	duration := time.Since(start)
-	log.Printf("[%s] %s %s - %d - %v - %s",
-		start.Format("2006-01-02 15:04:05"),
-		r.Method,
-		r.URL.Path,
-		wrapped.statusCode,
-		duration,
-		r.RemoteAddr,
-	)
+	attrs := append([]slog.Attr{
+		slog.String("method", r.Method),
+		slog.String("path", r.URL.Path),
+		slog.Int("status", wrapped.statusCode),
+		slog.Float64("duration_ms", float64(time.Since(start).Microseconds())/1000),
+		slog.Int64("bytes", wrapped.bytes),
+		slog.String("remote_addr", r.RemoteAddr),
+	}, logging.Attrs(ctx)...)
+
+	level := slog.LevelInfo
+	if wrapped.statusCode >= http.StatusInternalServerError {
+		level = slog.LevelError
+	}
+	l.logger.LogAttrs(ctx, level, "request", attrs...)
+}
+
+// validRequestID проверяет идентификатор от клиента: непустой, не слишком
+// длинный и без символов, которые могут исказить журнал
+func validRequestID(id string) bool {
+	if id == "" || len(id) > maxRequestIDLength {
+		return false
+	}
+	for _, r := range id {
+		switch {
+		case r >= 'a' && r <= 'z', r >= 'A' && r <= 'Z', r >= '0' && r <= '9':
+		case r == '-', r == '_', r == '.', r == ':':
+		default:
+			return false
+		}
+	}
+	return true
 }
 
 // responseWriter обертка для http.ResponseWriter для захвата статус кода
+// и числа записанных байт
 type responseWriter struct {
 	http.ResponseWriter
-	statusCode int
+	statusCode  int
+	bytes       int64
+	wroteHeader bool
 }
 
 // WriteHeader перехватывает статус код
 func (w *responseWriter) WriteHeader(statusCode int) {
-	w.statusCode = statusCode
+	if !w.wroteHeader {
+		w.statusCode = statusCode
+		w.wroteHeader = true
+	}
 	w.ResponseWriter.WriteHeader(statusCode)
 }
 
-// Write записывает данные и устанавливает статус 200 если он не был установлен
+// Write записывает данные и считает их размер
 func (w *responseWriter) Write(data []byte) (int, error) {
-	return w.ResponseWriter.Write(data)
+	w.wroteHeader = true
+	n, err := w.ResponseWriter.Write(data)
+	w.bytes += int64(n)
+	return n, err
+}
+
+// Unwrap возвращает исходный ResponseWriter для http.ResponseController
+func (w *responseWriter) Unwrap() http.ResponseWriter {
+	return w.ResponseWriter
 }
@@ -0,0 +1,97 @@
+package middleware
+
+import (
+	"bytes"
+	"encoding/json"
+	"l2-18/internal/auth"
+	"l2-18/internal/logging"
+	"log/slog"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+)
+
+func TestLogging(t *testing.T) {
+	var buf bytes.Buffer
+	logger := slog.New(logging.NewHandler(slog.NewJSONHandler(&buf, nil)))
+
+	authenticator := auth.NewAuthenticator("", map[string]int{"key": 7})
+	var inner http.Handler = http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		logger.InfoContext(r.Context(), "inside handler")
+		w.WriteHeader(http.StatusCreated)
+		w.Write([]byte("hello"))
+	})
+	handler := Logging(logger, Auth(authenticator, inner))
+
+	tests := []struct {
+		name       string
+		requestID  string
+		apiKey     string
+		wantStatus int
+		wantID     string // пустая строка — идентификатор должен быть создан
+		wantUser   float64
+		wantBytes  float64
+		wantInner  bool
+	}{
+		{"propagated id", "abc-123", "key", http.StatusCreated, "abc-123", 7, 5, true},
+		{"generated id", "", "key", http.StatusCreated, "", 7, 5, true},
+		{"unsafe id replaced", "bad id\n", "key", http.StatusCreated, "", 7, 5, true},
+		{"unauthenticated", "", "", http.StatusUnauthorized, "", 0, -1, false},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			buf.Reset()
+			req := httptest.NewRequest(http.MethodGet, "/events", nil)
+			if tt.requestID != "" {
+				req.Header.Set(RequestIDHeader, tt.requestID)
+			}
+			if tt.apiKey != "" {
+				req.Header.Set("X-API-Key", tt.apiKey)
+			}
+			rec := httptest.NewRecorder()
+			handler.ServeHTTP(rec, req)
+
+			if rec.Code != tt.wantStatus {
+				t.Fatalf("status = %d, want %d", rec.Code, tt.wantStatus)
+			}
+			id := rec.Header().Get(RequestIDHeader)
+			if tt.wantID != "" && id != tt.wantID {
+				t.Errorf("%s = %q, want %q", RequestIDHeader, id, tt.wantID)
+			}
+			if tt.wantID == "" && (len(id) != 32 || id == tt.requestID) {
+				t.Errorf("%s = %q, want a generated id", RequestIDHeader, id)
+			}
+
+			var records []map[string]any
+			for _, line := range strings.Split(strings.TrimSpace(buf.String()), "\n") {
+				var record map[string]any
+				if err := json.Unmarshal([]byte(line), &record); err != nil {
+					t.Fatalf("invalid log line %q: %v", line, err)
+				}
+				if record["request_id"] != id {
+					t.Errorf("record %q request_id = %v, want %q", record["msg"], record["request_id"], id)
+				}
+				records = append(records, record)
+			}
+			if got := len(records) == 2; got != tt.wantInner {
+				t.Fatalf("got %d records, want handler record: %v", len(records), tt.wantInner)
+			}
+
+			last := records[len(records)-1]
+			if last["msg"] != "request" || last["method"] != "GET" || last["path"] != "/events" {
+				t.Errorf("request record = %v", last)
+			}
+			if last["status"] != float64(tt.wantStatus) {
+				t.Errorf("status = %v, want %d", last["status"], tt.wantStatus)
+			}
+			if tt.wantBytes >= 0 && last["bytes"] != tt.wantBytes {
+				t.Errorf("bytes = %v, want %v", last["bytes"], tt.wantBytes)
+			}
+			if user, ok := last["user_id"]; tt.wantUser == 0 && ok || tt.wantUser != 0 && user != tt.wantUser {
+				t.Errorf("user_id = %v, want %v", user, tt.wantUser)
+			}
+		})
+	}
+}
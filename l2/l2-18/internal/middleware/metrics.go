@@ -0,0 +1,57 @@
+package middleware
+
+import (
+	"l2-18/internal/metrics"
+	"net/http"
+	"time"
+)
+
+// unmatchedRoute метка маршрута для запросов, не подошедших ни к одному маршруту
+const unmatchedRoute = "unmatched"
+
+// MetricsMiddleware структура для middleware метрик запросов
+type MetricsMiddleware struct {
+	handler  http.Handler
+	registry *metrics.Registry
+	route    func(r *http.Request) string
+}
+
+// Metrics создает middleware, которое учитывает каждый запрос в метриках
+// по методу, шаблону маршрута и статусу ответа. route возвращает шаблон
+// маршрута запроса или пустую строку, если маршрут не найден.
+func Metrics(registry *metrics.Registry, route func(r *http.Request) string, next http.Handler) http.Handler {
+	return &MetricsMiddleware{handler: next, registry: registry, route: route}
+}
+
+// ServeHTTP реализует интерфейс http.Handler
+func (m *MetricsMiddleware) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	start := time.Now()
+	wrapped := &responseWriter{
+		ResponseWriter: w,
+		statusCode:     http.StatusOK,
+	}
+
+	m.handler.ServeHTTP(wrapped, r)
+
+	route := m.route(r)
+	if route == "" {
+		route = unmatchedRoute
+	}
+	m.registry.ObserveRequest(r.Method, route, wrapped.statusCode, time.Since(start))
+}
+
+// MuxRoute возвращает функцию, которая ищет шаблон маршрута запроса
+// во вложенных ServeMux: шаблон fallback внешнего означает, что запрос
+// передается следующему. Так корневой mux с проверками и "/" для API
+// дает шаблоны маршрутов API, а не "/".
+func MuxRoute(fallback string, muxes ...*http.ServeMux) func(r *http.Request) string {
+	return func(r *http.Request) string {
+		var pattern string
+		for _, mux := range muxes {
+			if _, pattern = mux.Handler(r); pattern != fallback {
+				return pattern
+			}
+		}
+		return pattern
+	}
+}
@@ -0,0 +1,50 @@
+package middleware
+
+import (
+	"l2-18/internal/metrics"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+)
+
+func TestMetrics(t *testing.T) {
+	api := http.NewServeMux()
+	api.HandleFunc("GET /v2/users/{id}/events", func(w http.ResponseWriter, r *http.Request) {})
+	api.HandleFunc("/create_event", func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusBadRequest)
+	})
+	root := http.NewServeMux()
+	root.HandleFunc("GET /healthz", func(w http.ResponseWriter, r *http.Request) {})
+	root.Handle("/", api)
+
+	registry := metrics.NewRegistry()
+	handler := Metrics(registry, MuxRoute("/", root, api), root)
+
+	tests := []struct {
+		method string
+		path   string
+		want   string
+	}{
+		{http.MethodGet, "/v2/users/1/events", `method="GET",route="GET /v2/users/{id}/events",status="200"`},
+		{http.MethodGet, "/v2/users/2/events", `method="GET",route="GET /v2/users/{id}/events",status="200"`},
+		{http.MethodPost, "/create_event", `method="POST",route="/create_event",status="400"`},
+		{http.MethodGet, "/healthz", `method="GET",route="GET /healthz",status="200"`},
+		{http.MethodGet, "/missing", `method="GET",route="unmatched",status="404"`},
+	}
+	for _, tt := range tests {
+		handler.ServeHTTP(httptest.NewRecorder(), httptest.NewRequest(tt.method, tt.path, nil))
+	}
+
+	var b strings.Builder
+	registry.WriteTo(&b)
+	for _, tt := range tests {
+		want := "calendar_http_requests_total{" + tt.want + "}"
+		if !strings.Contains(b.String(), want) {
+			t.Errorf("%s %s: output has no %s:\n%s", tt.method, tt.path, want, b.String())
+		}
+	}
+	if want := `calendar_http_requests_total{method="GET",route="GET /v2/users/{id}/events",status="200"} 2`; !strings.Contains(b.String(), want) {
+		t.Errorf("requests with different IDs are not aggregated by route:\n%s", b.String())
+	}
+}
@@ -0,0 +1,165 @@
+package middleware
+
+import (
+	"encoding/json"
+	"l2-18/internal/apperrors"
+	"l2-18/internal/auth"
+	"l2-18/internal/models"
+	"math"
+	"net"
+	"net/http"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+)
+
+// sweepInterval как часто из памяти убираются корзины неактивных клиентов
+const sweepInterval = time.Minute
+
+// RateLimitOptions ограничение частоты запросов одного клиента
+type RateLimitOptions struct {
+	// Requests и Interval задают равномерный темп: Requests запросов за Interval
+	Requests int
+	Interval time.Duration
+	// Burst сколько запросов подряд можно сделать после простоя
+	Burst int
+	// PerAddress считать запросы по адресу клиента, даже если пользователь
+	// известен
+	PerAddress bool
+	// TrustedProxies сколько доверенных прокси стоит перед сервисом. Каждый
+	// из них дописывает адрес своего клиента в конец X-Forwarded-For,
+	// поэтому адрес клиента — TrustedProxies-й справа; записи левее мог
+	// подставить сам клиент. 0 — заголовок не используется.
+	TrustedProxies int
+}
+
+// bucket корзина маркеров одного клиента
+type bucket struct {
+	tokens float64
+	last   time.Time
+}
+
+// RateLimitMiddleware структура для middleware ограничения частоты запросов
+type RateLimitMiddleware struct {
+	handler http.Handler
+	options RateLimitOptions
+	// rate сколько маркеров добавляется в секунду
+	rate      float64
+	mu        sync.Mutex
+	buckets   map[string]*bucket
+	lastSweep time.Time
+	now       func() time.Time
+}
+
+// RateLimit создает middleware, которое ограничивает частоту запросов
+// алгоритмом корзины маркеров: отдельно для каждого аутентифицированного
+// пользователя, а без аутентификации или с PerAddress — для каждого адреса
+// клиента. На запрос сверх ограничения отвечает 429 с заголовком
+// Retry-After. Пользователя middleware берет из контекста, поэтому
+// ставится после Auth; с PerAddress его можно поставить и до Auth.
+func RateLimit(options RateLimitOptions, next http.Handler) http.Handler {
+	return &RateLimitMiddleware{
+		handler: next,
+		options: options,
+		rate:    float64(options.Requests) / options.Interval.Seconds(),
+		buckets: make(map[string]*bucket),
+		now:     time.Now,
+	}
+}
+
+// ServeHTTP реализует интерфейс http.Handler
+func (l *RateLimitMiddleware) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	wait, ok := l.allow(l.client(r))
+	if !ok {
+		seconds := max(int(math.Ceil(wait.Seconds())), 1)
+		w.Header().Set("Retry-After", strconv.Itoa(seconds))
+		w.Header().Set("Content-Type", "application/json")
+		w.WriteHeader(http.StatusTooManyRequests)
+		_ = json.NewEncoder(w).Encode(models.APIResponse{
+			Error: "too many requests, retry in " + strconv.Itoa(seconds) + "s",
+			Code:  apperrors.CodeRateLimited,
+		})
+		return
+	}
+
+	l.handler.ServeHTTP(w, r)
+}
+
+// allow забирает маркер из корзины клиента. Если корзина пуста,
+// возвращает время до появления следующего маркера.
+func (l *RateLimitMiddleware) allow(client string) (time.Duration, bool) {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+
+	now := l.now()
+	l.sweep(now)
+
+	b, ok := l.buckets[client]
+	if !ok {
+		b = &bucket{tokens: float64(l.options.Burst), last: now}
+		l.buckets[client] = b
+	}
+	b.tokens = min(b.tokens+now.Sub(b.last).Seconds()*l.rate, float64(l.options.Burst))
+	b.last = now
+
+	if b.tokens < 1 {
+		return time.Duration((1 - b.tokens) / l.rate * float64(time.Second)), false
+	}
+	b.tokens--
+	return 0, true
+}
+
+// sweep убирает корзины, которые успели заполниться: для их клиентов
+// новая корзина ничем не отличается от старой
+func (l *RateLimitMiddleware) sweep(now time.Time) {
+	if now.Sub(l.lastSweep) < sweepInterval {
+		return
+	}
+	l.lastSweep = now
+
+	full := time.Duration(float64(l.options.Burst) / l.rate * float64(time.Second))
+	for client, b := range l.buckets {
+		if now.Sub(b.last) >= full {
+			delete(l.buckets, client)
+		}
+	}
+}
+
+// client возвращает ключ клиента: пользователя или адрес
+func (l *RateLimitMiddleware) client(r *http.Request) string {
+	if userID, ok := auth.UserID(r.Context()); ok && !l.options.PerAddress {
+		return "user:" + strconv.Itoa(userID)
+	}
+
+	if ip := l.forwardedFor(r); ip != "" {
+		return "ip:" + ip
+	}
+
+	host, _, err := net.SplitHostPort(r.RemoteAddr)
+	if err != nil {
+		host = r.RemoteAddr
+	}
+	return "ip:" + host
+}
+
+// forwardedFor возвращает адрес клиента, записанный ближайшим к нему
+// доверенным прокси, или пустую строку. Если записей меньше, чем прокси,
+// запрос прошел не через все из них и ни одной записи нельзя доверять:
+// тогда клиент определяется по адресу соединения.
+func (l *RateLimitMiddleware) forwardedFor(r *http.Request) string {
+	if l.options.TrustedProxies <= 0 {
+		return ""
+	}
+
+	var hops []string
+	for _, header := range r.Header.Values("X-Forwarded-For") {
+		for _, hop := range strings.Split(header, ",") {
+			hops = append(hops, strings.TrimSpace(hop))
+		}
+	}
+	if len(hops) < l.options.TrustedProxies {
+		return ""
+	}
+	return hops[len(hops)-l.options.TrustedProxies]
+}
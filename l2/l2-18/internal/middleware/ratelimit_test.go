@@ -0,0 +1,140 @@
+package middleware
+
+import (
+	"l2-18/internal/auth"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestRateLimit(t *testing.T) {
+	ok := http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {})
+	limiter := RateLimit(RateLimitOptions{Requests: 2, Interval: time.Second, Burst: 3}, ok).(*RateLimitMiddleware)
+	clock := time.Date(2024, 1, 8, 9, 0, 0, 0, time.UTC)
+	limiter.now = func() time.Time { return clock }
+
+	do := func(userID int, remoteAddr, forwardedFor string) *httptest.ResponseRecorder {
+		req := httptest.NewRequest(http.MethodGet, "/v2/users/1/events", nil)
+		req.RemoteAddr = remoteAddr
+		if forwardedFor != "" {
+			req.Header.Set("X-Forwarded-For", forwardedFor)
+		}
+		if userID != 0 {
+			req = req.WithContext(auth.WithUser(req.Context(), userID))
+		}
+		rec := httptest.NewRecorder()
+		limiter.ServeHTTP(rec, req)
+		return rec
+	}
+
+	tests := []struct {
+		name         string
+		advance      time.Duration
+		userID       int
+		remoteAddr   string
+		forwardedFor string
+		wantStatus   int
+		wantRetry    string
+	}{
+		{"burst 1", 0, 1, "10.0.0.1:1000", "", http.StatusOK, ""},
+		{"burst 2", 0, 1, "10.0.0.1:1000", "", http.StatusOK, ""},
+		{"burst 3", 0, 1, "10.0.0.1:1000", "", http.StatusOK, ""},
+		{"bucket empty", 0, 1, "10.0.0.1:1000", "", http.StatusTooManyRequests, "1"},
+		{"other user", 0, 2, "10.0.0.1:1000", "", http.StatusOK, ""},
+		{"refilled token", 500 * time.Millisecond, 1, "10.0.0.1:1000", "", http.StatusOK, ""},
+		{"empty again", 0, 1, "10.0.0.1:1000", "", http.StatusTooManyRequests, "1"},
+		{"address without user", 0, 0, "10.0.0.2:1000", "", http.StatusOK, ""},
+		// Без доверия к прокси X-Forwarded-For не меняет клиента
+		{"forwarded for is ignored", 0, 0, "10.0.0.2:2000", "192.0.2.7", http.StatusOK, ""},
+	}
+	for _, tt := range tests {
+		clock = clock.Add(tt.advance)
+		rec := do(tt.userID, tt.remoteAddr, tt.forwardedFor)
+		if rec.Code != tt.wantStatus || rec.Header().Get("Retry-After") != tt.wantRetry {
+			t.Errorf("%s: status %d, Retry-After %q; want %d, %q", tt.name, rec.Code, rec.Header().Get("Retry-After"), tt.wantStatus, tt.wantRetry)
+		}
+		if rec.Code == http.StatusTooManyRequests && !strings.Contains(rec.Body.String(), `"code":"rate_limited"`) {
+			t.Errorf("%s: body %s has no rate_limited code", tt.name, rec.Body)
+		}
+	}
+
+	// Корзины неактивных клиентов убираются из памяти
+	clock = clock.Add(sweepInterval)
+	do(3, "10.0.0.3:1000", "")
+	if len(limiter.buckets) != 1 {
+		t.Errorf("after sweep %d buckets are kept, want 1", len(limiter.buckets))
+	}
+}
+
+func TestRateLimit_ForwardedFor(t *testing.T) {
+	ok := http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {})
+
+	tests := []struct {
+		name    string
+		proxies int
+		// first и second X-Forwarded-For двух запросов подряд
+		first, second string
+		want          int
+	}{
+		{"same client", 1, "192.0.2.7", "192.0.2.7", http.StatusTooManyRequests},
+		{"clients behind one proxy", 1, "192.0.2.7", "192.0.2.8", http.StatusOK},
+		// Клиент не может выдать себя за другого, дописав адреса слева
+		{"spoofed left hops", 1, "198.51.100.1, 192.0.2.7", "198.51.100.2, 192.0.2.7", http.StatusTooManyRequests},
+		{"client behind two proxies", 2, "198.51.100.1, 192.0.2.7, 10.0.0.5", "198.51.100.2, 192.0.2.7, 10.0.0.6", http.StatusTooManyRequests},
+		{"clients behind two proxies", 2, "192.0.2.7, 10.0.0.5", "192.0.2.8, 10.0.0.5", http.StatusOK},
+		// При неполной цепочке прокси клиент определяется по адресу соединения
+		{"fewer hops than proxies", 3, "192.0.2.7", "192.0.2.8, 10.0.0.5", http.StatusTooManyRequests},
+		{"header ignored", 0, "192.0.2.7", "192.0.2.8", http.StatusTooManyRequests},
+	}
+	for _, tt := range tests {
+		limiter := RateLimit(RateLimitOptions{Requests: 1, Interval: time.Minute, Burst: 1, TrustedProxies: tt.proxies}, ok)
+		var got int
+		for _, forwardedFor := range []string{tt.first, tt.second} {
+			req := httptest.NewRequest(http.MethodGet, "/", nil)
+			req.RemoteAddr = "10.0.0.1:1000"
+			req.Header.Set("X-Forwarded-For", forwardedFor)
+			rec := httptest.NewRecorder()
+			limiter.ServeHTTP(rec, req)
+			got = rec.Code
+		}
+		if got != tt.want {
+			t.Errorf("%s: second request status = %d, want %d", tt.name, got, tt.want)
+		}
+	}
+}
+
+func TestRateLimit_PerAddress(t *testing.T) {
+	ok := http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {})
+	limiter := RateLimit(RateLimitOptions{Requests: 1, Interval: time.Minute, Burst: 2, PerAddress: true}, ok)
+
+	do := func(userID int, remoteAddr string) int {
+		req := httptest.NewRequest(http.MethodGet, "/", nil)
+		req.RemoteAddr = remoteAddr
+		if userID != 0 {
+			req = req.WithContext(auth.WithUser(req.Context(), userID))
+		}
+		rec := httptest.NewRecorder()
+		limiter.ServeHTTP(rec, req)
+		return rec.Code
+	}
+
+	// Разные пользователи с одного адреса делят одну корзину
+	tests := []struct {
+		name       string
+		userID     int
+		remoteAddr string
+		want       int
+	}{
+		{"first user", 1, "10.0.0.1:1000", http.StatusOK},
+		{"unauthenticated", 0, "10.0.0.1:2000", http.StatusOK},
+		{"second user", 2, "10.0.0.1:3000", http.StatusTooManyRequests},
+		{"other address", 2, "10.0.0.2:1000", http.StatusOK},
+	}
+	for _, tt := range tests {
+		if got := do(tt.userID, tt.remoteAddr); got != tt.want {
+			t.Errorf("%s: status = %d, want %d", tt.name, got, tt.want)
+		}
+	}
+}
@@ -0,0 +1,35 @@
+package models
+
+// BatchOperation операция пакета: задается ровно одно из полей.
+// Пользователь операций берется из пакета.
+type BatchOperation struct {
+	Create *CreateEventRequest `json:"create,omitempty"`
+	Update *UpdateEventRequest `json:"update,omitempty"`
+	Delete *DeleteEventRequest `json:"delete,omitempty"`
+}
+
+// BatchRequest пакет операций над событиями, выполняемый атомарно:
+// все операции или ни одной
+type BatchRequest struct {
+	UserID int `json:"user_id"`
+	// CalendarID календарь, в котором создаются события без своего calendar_id
+	CalendarID int              `json:"calendar_id,omitempty"`
+	Operations []BatchOperation `json:"operations"`
+}
+
+// BatchItemResult результат операции пакета
+type BatchItemResult struct {
+	Index int `json:"index"`
+	// Status код ответа, который получил бы отдельный запрос с операцией;
+	// 424, если операция верна, но пакет не выполнен из-за других операций
+	Status int `json:"status"`
+	// Event событие после создания или изменения
+	Event *Event       `json:"event,omitempty"`
+	Error *APIResponse `json:"error,omitempty"`
+}
+
+// BatchResult результаты операций пакета в порядке операций
+type BatchResult struct {
+	Applied bool              `json:"applied"`
+	Results []BatchItemResult `json:"results"`
+}
@@ -0,0 +1,102 @@
+package models
+
+import "time"
+
+// Типы изменений событий
+const (
+	ChangeCreated = "event.created"
+	ChangeUpdated = "event.updated"
+	ChangeDeleted = "event.deleted"
+	// ChangeRestored событие восстановлено из корзины
+	ChangeRestored = "event.restored"
+	// ChangeRemoved получатель убран из участников события; такое
+	// изменение не содержит самого события
+	ChangeRemoved = "event.removed"
+)
+
+// ChangeTypes все типы изменений событий
+var ChangeTypes = []string{ChangeCreated, ChangeUpdated, ChangeDeleted, ChangeRestored, ChangeRemoved}
+
+// Change изменение события. Seq растет с каждым изменением и позволяет
+// продолжить ленту изменений с места обрыва.
+type Change struct {
+	Seq  int64     `json:"seq"`
+	Type string    `json:"type"`
+	Time time.Time `json:"time"`
+	// ActorID пользователь, изменивший событие (0 — неизвестен)
+	ActorID int `json:"actor_id,omitempty"`
+	EventID int `json:"event_id"`
+	// Event событие после изменения, для удаления — перед удалением;
+	// пусто для ChangeRemoved
+	Event *Event `json:"event,omitempty"`
+	// Recipients пользователи, которым видно изменение
+	Recipients []int `json:"-"`
+}
+
+// VisibleTo сообщает, видно ли изменение пользователю
+func (c Change) VisibleTo(userID int) bool {
+	for _, id := range c.Recipients {
+		if id == userID {
+			return true
+		}
+	}
+	return false
+}
+
+// Webhook подписка пользователя на изменения его событий, которые
+// отправляются POST-запросом на URL с подписью HMAC-SHA256 секретом Secret
+type Webhook struct {
+	ID     int    `json:"id"`
+	UserID int    `json:"user_id"`
+	URL    string `json:"url"`
+	// Secret ключ подписи; возвращается только при регистрации
+	Secret string `json:"secret,omitempty"`
+	// Events типы изменений; пустой список — все изменения
+	Events    []string  `json:"events,omitempty"`
+	CreatedAt time.Time `json:"created_at"`
+}
+
+// Accepts сообщает, подписан ли webhook на изменения этого типа
+func (w Webhook) Accepts(changeType string) bool {
+	if len(w.Events) == 0 {
+		return true
+	}
+	for _, t := range w.Events {
+		if t == changeType {
+			return true
+		}
+	}
+	return false
+}
+
+// CreateWebhookRequest структура для регистрации webhook
+type CreateWebhookRequest struct {
+	UserID int    `json:"user_id"`
+	URL    string `json:"url"`
+	// Secret ключ подписи; если не задан, создается случайный
+	Secret string   `json:"secret,omitempty"`
+	Events []string `json:"events,omitempty"`
+}
+
+// WebhookList список webhook пользователя
+type WebhookList struct {
+	Webhooks []Webhook `json:"webhooks"`
+}
+
+// DeadLetter изменение, которое не удалось доставить на webhook
+// за все попытки
+type DeadLetter struct {
+	ID        int       `json:"id"`
+	WebhookID int       `json:"webhook_id"`
+	UserID    int       `json:"user_id"`
+	URL       string    `json:"url"`
+	Change    Change    `json:"change"`
+	Attempts  int       `json:"attempts"`
+	Error     string    `json:"error"`
+	FailedAt  time.Time `json:"failed_at"`
+}
+
+// DeadLetterList список недоставленных изменений
+type DeadLetterList struct {
+	DeadLetters []DeadLetter `json:"dead_letters"`
+}
@@ -1,39 +1,198 @@
 package models
 
-import "time"
+import (
+	"sort"
+	"time"
+)
 
 // Event представляет событие в календаре
 type Event struct {
 	ID          int       `json:"id"`
+	UID         string    `json:"uid"`
 	UserID      int       `json:"user_id"`
 	Date        time.Time `json:"date"`
 	Title       string    `json:"title"`
 	Description string    `json:"description"`
 	CreatedAt   time.Time `json:"created_at"`
 	UpdatedAt   time.Time `json:"updated_at"`
+	// Version увеличивается при каждом изменении и служит ETag события
+	Version int `json:"version"`
+
+	// Start и End задают полуинтервал [Start, End) времени события.
+	// Date хранит полночь дня начала и сохранена для совместимости.
+	// TimeZone — часовой пояс IANA, в котором задано событие: в нем
+	// вычисляются границы дня для событий на весь день и экземпляры серий.
+	Start    time.Time `json:"start"`
+	End      time.Time `json:"end"`
+	AllDay   bool      `json:"all_day"`
+	TimeZone string    `json:"time_zone"`
+
+	// RRule правило повторения серии в формате RFC 5545 (пусто для одиночных событий)
+	RRule string `json:"rrule,omitempty"`
+	// ExDates исключенные из серии экземпляры (удаленные или измененные отдельно)
+	ExDates []time.Time `json:"exdates,omitempty"`
+	// SeriesID ID серии, если событие является измененным экземпляром серии
+	SeriesID int `json:"series_id,omitempty"`
+	// RecurrenceID исходная дата экземпляра серии
+	RecurrenceID *time.Time `json:"recurrence_id,omitempty"`
+
+	// Reminders за сколько минут до начала напомнить о событии
+	Reminders []int `json:"reminders,omitempty"`
+	// Attendees приглашенные пользователи (владелец события в список не входит)
+	Attendees []Attendee `json:"attendees,omitempty"`
+	// Tags метки (категории) события
+	Tags []string `json:"tags,omitempty"`
+}
+
+// Attendee возвращает участника события или nil, если пользователь не приглашен
+func (e *Event) Attendee(userID int) *Attendee {
+	for i := range e.Attendees {
+		if e.Attendees[i].UserID == userID {
+			return &e.Attendees[i]
+		}
+	}
+	return nil
+}
+
+// IsRecurring сообщает, является ли событие повторяющейся серией
+func (e *Event) IsRecurring() bool {
+	return e.RRule != ""
+}
+
+// Duration возвращает продолжительность события
+func (e *Event) Duration() time.Duration {
+	return e.End.Sub(e.Start)
+}
+
+// Overlaps проверяет, пересекается ли событие с полуинтервалом [start, end).
+// Событие нулевой длительности попадает в интервал, если начинается внутри него.
+func (e *Event) Overlaps(start, end time.Time) bool {
+	if e.End.Equal(e.Start) {
+		return !e.Start.Before(start) && e.Start.Before(end)
+	}
+	return e.Start.Before(end) && e.End.After(start)
+}
+
+// SortByStart упорядочивает события хронологически (при равном начале — по ID)
+func SortByStart(events []*Event) {
+	sort.SliceStable(events, func(i, j int) bool {
+		if !events[i].Start.Equal(events[j].Start) {
+			return events[i].Start.Before(events[j].Start)
+		}
+		return events[i].ID < events[j].ID
+	})
 }
 
-// CreateEventRequest структура для создания события
+// CreateEventRequest структура для создания события.
+// Время задается либо датой Date (событие на весь день),
+// либо началом Start в RFC 3339 и концом End или длительностью Duration ("1h30m").
+// CalendarID задает чужой календарь, открытый пользователю на запись.
 type CreateEventRequest struct {
-	UserID      int    `json:"user_id"`
-	Date        string `json:"date"`
-	Title       string `json:"title"`
-	Description string `json:"description"`
+	UserID      int      `json:"user_id"`
+	CalendarID  int      `json:"calendar_id,omitempty"`
+	Date        string   `json:"date"`
+	Start       string   `json:"start,omitempty"`
+	End         string   `json:"end,omitempty"`
+	Duration    string   `json:"duration,omitempty"`
+	AllDay      bool     `json:"all_day,omitempty"`
+	TimeZone    string   `json:"time_zone,omitempty"`
+	Title       string   `json:"title"`
+	Description string   `json:"description"`
+	RRule       string   `json:"rrule,omitempty"`
+	Reminders   []int    `json:"reminders,omitempty"`
+	Attendees   []int    `json:"attendees,omitempty"`
+	Tags        []string `json:"tags,omitempty"`
+	// CheckConflicts запрещает создание, если время занято другими событиями
+	CheckConflicts bool `json:"check_conflicts,omitempty"`
 }
 
 // UpdateEventRequest структура для обновления события
 type UpdateEventRequest struct {
-	ID          int    `json:"id"`
-	UserID      int    `json:"user_id"`
-	Date        string `json:"date"`
-	Title       string `json:"title"`
-	Description string `json:"description"`
+	ID          int      `json:"id"`
+	UserID      int      `json:"user_id"`
+	Date        string   `json:"date"`
+	Start       string   `json:"start,omitempty"`
+	End         string   `json:"end,omitempty"`
+	Duration    string   `json:"duration,omitempty"`
+	AllDay      bool     `json:"all_day,omitempty"`
+	TimeZone    string   `json:"time_zone,omitempty"`
+	Title       string   `json:"title"`
+	Description string   `json:"description"`
+	RRule       string   `json:"rrule,omitempty"`
+	Reminders   []int    `json:"reminders,omitempty"`
+	Attendees   []int    `json:"attendees,omitempty"`
+	Tags        []string `json:"tags,omitempty"`
+	// CheckConflicts запрещает изменение, если новое время занято другими событиями
+	CheckConflicts bool `json:"check_conflicts,omitempty"`
+	// Version если задана, изменение выполняется только при совпадении с текущей версией
+	Version int `json:"version,omitempty"`
+	// OccurrenceDate если задана, изменяется только этот экземпляр серии
+	OccurrenceDate string `json:"occurrence_date,omitempty"`
+}
+
+// PatchEventRequest структура для частичного обновления события:
+// изменяются только переданные поля. Если меняется начало, а конец и
+// длительность не заданы, событие сохраняет прежнюю длительность.
+type PatchEventRequest struct {
+	Date        *string   `json:"date,omitempty"`
+	Start       *string   `json:"start,omitempty"`
+	End         *string   `json:"end,omitempty"`
+	Duration    *string   `json:"duration,omitempty"`
+	AllDay      *bool     `json:"all_day,omitempty"`
+	TimeZone    *string   `json:"time_zone,omitempty"`
+	Title       *string   `json:"title,omitempty"`
+	Description *string   `json:"description,omitempty"`
+	RRule       *string   `json:"rrule,omitempty"`
+	Reminders   *[]int    `json:"reminders,omitempty"`
+	Attendees   *[]int    `json:"attendees,omitempty"`
+	Tags        *[]string `json:"tags,omitempty"`
+	// CheckConflicts запрещает изменение, если новое время занято другими событиями
+	CheckConflicts bool `json:"check_conflicts,omitempty"`
+	// Version если задана, изменение выполняется только при совпадении с текущей версией
+	Version int `json:"version,omitempty"`
 }
 
 // DeleteEventRequest структура для удаления события
 type DeleteEventRequest struct {
 	ID     int `json:"id"`
 	UserID int `json:"user_id"`
+	// Version если задана, удаление выполняется только при совпадении с текущей версией
+	Version int `json:"version,omitempty"`
+	// OccurrenceDate если задана, удаляется только этот экземпляр серии
+	OccurrenceDate string `json:"occurrence_date,omitempty"`
+}
+
+// SetTimeZoneRequest структура для установки часового пояса пользователя
+type SetTimeZoneRequest struct {
+	UserID   int    `json:"user_id"`
+	TimeZone string `json:"time_zone"`
+}
+
+// ImportResult итог импорта событий из iCalendar
+type ImportResult struct {
+	Created int      `json:"created"`
+	Updated int      `json:"updated"`
+	Errors  []string `json:"errors,omitempty"`
+}
+
+// Reminder напоминание об экземпляре события, которое нужно доставить в момент FireAt
+type Reminder struct {
+	// Key однозначно определяет напоминание: событие, экземпляр и смещение
+	Key     string    `json:"key"`
+	EventID int       `json:"event_id"`
+	UID     string    `json:"uid"`
+	UserID  int       `json:"user_id"`
+	Title   string    `json:"title"`
+	Start   time.Time `json:"start"`
+	End     time.Time `json:"end"`
+	AllDay  bool      `json:"all_day"`
+	Minutes int       `json:"minutes"`
+	FireAt  time.Time `json:"fire_at"`
+}
+
+// EventList список событий в ответе API v2
+type EventList struct {
+	Events []*Event `json:"events"`
 }
 
 // APIResponse стандартный ответ API
@@ -41,4 +200,27 @@ type APIResponse struct {
 	Result string      `json:"result,omitempty"`
 	Error  string      `json:"error,omitempty"`
 	Data   interface{} `json:"data,omitempty"`
+	// Code машиночитаемый код ошибки, например not_found или validation_failed
+	Code string `json:"code,omitempty"`
+	// Fields сообщения об ошибках проверки по полям запроса
+	Fields map[string]string `json:"fields,omitempty"`
+}
+
+// TrashedEvent удаленное событие в корзине владельца
+type TrashedEvent struct {
+	Event     *Event    `json:"event"`
+	DeletedAt time.Time `json:"deleted_at"`
+	// PurgeAt когда событие будет удалено окончательно
+	PurgeAt time.Time `json:"purge_at,omitzero"`
+}
+
+// TrashList события в корзине пользователя
+type TrashList struct {
+	Events []TrashedEvent `json:"events"`
+}
+
+// RestoreEventRequest структура для восстановления события из корзины
+type RestoreEventRequest struct {
+	ID     int `json:"id"`
+	UserID int `json:"user_id"`
 }
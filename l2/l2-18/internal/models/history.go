@@ -0,0 +1,47 @@
+package models
+
+import (
+	"encoding/json"
+	"time"
+)
+
+// FieldChange изменение одного поля события; значения в том же виде,
+// что и в JSON события, отсутствующее значение — поле было или стало пустым
+type FieldChange struct {
+	Field string          `json:"field"`
+	Old   json.RawMessage `json:"old,omitempty"`
+	New   json.RawMessage `json:"new,omitempty"`
+}
+
+// Revision запись истории события об одном изменении
+type Revision struct {
+	// Revision номер записи в истории события, начиная с 1
+	Revision int `json:"revision"`
+	EventID  int `json:"event_id"`
+	// Action тип изменения, как в ленте изменений
+	Action string `json:"action"`
+	// ActorID пользователь, изменивший событие
+	ActorID int       `json:"actor_id"`
+	Time    time.Time `json:"time"`
+	// Changes измененные поля; при создании — все заполненные поля
+	Changes []FieldChange `json:"changes,omitempty"`
+	// RevertedTo запись, к которой событие возвращено этим изменением
+	RevertedTo int `json:"reverted_to,omitempty"`
+	// Event событие после изменения, для удаления — перед удалением
+	Event *Event `json:"event"`
+}
+
+// History история изменений события по возрастанию номеров
+type History struct {
+	EventID   int        `json:"event_id"`
+	Revisions []Revision `json:"revisions"`
+}
+
+// RevertEventRequest структура для возврата события к записи его истории
+type RevertEventRequest struct {
+	ID       int `json:"id"`
+	UserID   int `json:"user_id"`
+	Revision int `json:"revision"`
+	// Version ожидаемая текущая версия события (0 — без проверки)
+	Version int `json:"version,omitempty"`
+}
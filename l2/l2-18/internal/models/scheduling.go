@@ -0,0 +1,22 @@
+package models
+
+import "time"
+
+// Interval полуинтервал времени [Start, End)
+type Interval struct {
+	Start time.Time `json:"start"`
+	End   time.Time `json:"end"`
+}
+
+// FreeBusy занятое время пользователя без подробностей о событиях
+type FreeBusy struct {
+	UserID int        `json:"user_id"`
+	Busy   []Interval `json:"busy"`
+}
+
+// FreeBusyList занятость пользователей в окне [From, To)
+type FreeBusyList struct {
+	From  time.Time  `json:"from"`
+	To    time.Time  `json:"to"`
+	Users []FreeBusy `json:"users"`
+}
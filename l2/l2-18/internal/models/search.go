@@ -0,0 +1,37 @@
+package models
+
+import "time"
+
+// Порядок результатов поиска; минус в начале означает обратный порядок
+const (
+	SortStart   = "start"
+	SortTitle   = "title"
+	SortUpdated = "updated"
+)
+
+// SearchRequest параметры поиска событий пользователя
+type SearchRequest struct {
+	UserID int `json:"user_id"`
+	// Query слова, которые должны встречаться в названии или описании;
+	// каждое слово запроса совпадает с началом слова события
+	Query string `json:"q"`
+	// From и To ограничивают поиск полуинтервалом [From, To), серии при этом
+	// разворачиваются в экземпляры. Задаются вместе; без них поиск идет
+	// по всем событиям.
+	From time.Time `json:"from"`
+	To   time.Time `json:"to"`
+	// Tags и Attendees отбирают события со всеми перечисленными метками и участниками
+	Tags      []string `json:"tags,omitempty"`
+	Attendees []int    `json:"attendees,omitempty"`
+	// Sort порядок: start, title или updated, с минусом — обратный
+	Sort   string `json:"sort,omitempty"`
+	Limit  int    `json:"limit,omitempty"`
+	Cursor string `json:"cursor,omitempty"`
+}
+
+// SearchResult страница результатов поиска
+type SearchResult struct {
+	Events []*Event `json:"events"`
+	// NextCursor продолжает выдачу со следующей страницы (пусто на последней)
+	NextCursor string `json:"next_cursor,omitempty"`
+}
@@ -0,0 +1,49 @@
+package models
+
+// Ответы участника на приглашение (PARTSTAT в iCalendar)
+const (
+	RSVPNeedsAction = "needs-action"
+	RSVPAccepted    = "accepted"
+	RSVPDeclined    = "declined"
+	RSVPTentative   = "tentative"
+)
+
+// Attendee участник события и его ответ на приглашение
+type Attendee struct {
+	UserID int    `json:"user_id"`
+	Status string `json:"status"`
+}
+
+// Права доступа к чужому календарю
+const (
+	PermissionRead  = "read"
+	PermissionWrite = "write"
+)
+
+// Share доступ пользователя UserID к календарю пользователя OwnerID
+type Share struct {
+	OwnerID    int    `json:"owner_id"`
+	UserID     int    `json:"user_id"`
+	Permission string `json:"permission"`
+}
+
+// ShareCalendarRequest структура для открытия доступа к календарю
+type ShareCalendarRequest struct {
+	// UserID владелец календаря
+	UserID int `json:"user_id"`
+	// ShareWith пользователь, которому открывается доступ
+	ShareWith  int    `json:"share_with"`
+	Permission string `json:"permission"`
+}
+
+// RSVPRequest структура для ответа на приглашение
+type RSVPRequest struct {
+	ID     int    `json:"id"`
+	UserID int    `json:"user_id"`
+	Status string `json:"status"`
+}
+
+// ShareList список доступов к календарям в ответе API v2
+type ShareList struct {
+	Shares []Share `json:"shares"`
+}
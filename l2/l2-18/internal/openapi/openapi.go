@@ -0,0 +1,216 @@
+package openapi
+
+import (
+	"net/http"
+	"reflect"
+	"regexp"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// Operation описание одного маршрута API. Параметры пути берутся из шаблона Path
+// ({id}), схемы тела запроса и ответа строятся по типам Go из тегов json.
+type Operation struct {
+	Method  string
+	Path    string
+	Summary string
+	// Query параметры строки запроса и заголовки
+	Query []Param
+	// Request пример значения тела запроса (nil, если тела нет)
+	Request interface{}
+	// Response пример значения тела успешного ответа (nil, если тела нет)
+	Response interface{}
+	Status   int
+	// Errors коды ответов с ошибкой
+	Errors []int
+}
+
+// Param параметр строки запроса или заголовка
+type Param struct {
+	Name string
+	// In место параметра: query (по умолчанию) или header
+	In          string
+	Description string
+	Required    bool
+}
+
+// pathParam параметр в шаблоне пути
+var pathParam = regexp.MustCompile(`\{(\w+)\}`)
+
+// Document строит документ OpenAPI 3.1 по списку операций
+func Document(title, version string, operations []Operation, errorBody interface{}) map[string]interface{} {
+	b := &builder{components: make(map[string]interface{})}
+	paths := make(map[string]interface{})
+
+	for _, op := range operations {
+		item, _ := paths[op.Path].(map[string]interface{})
+		if item == nil {
+			item = make(map[string]interface{})
+			paths[op.Path] = item
+		}
+		item[strings.ToLower(op.Method)] = b.operation(op, errorBody)
+	}
+
+	return map[string]interface{}{
+		"openapi": "3.1.0",
+		"info": map[string]interface{}{
+			"title":   title,
+			"version": version,
+		},
+		"paths": paths,
+		"components": map[string]interface{}{
+			"schemas": b.components,
+			"securitySchemes": map[string]interface{}{
+				"bearer": map[string]interface{}{"type": "http", "scheme": "bearer", "bearerFormat": "JWT"},
+				"apiKey": map[string]interface{}{"type": "apiKey", "in": "header", "name": "X-API-Key"},
+			},
+		},
+		"security": []interface{}{
+			map[string]interface{}{"bearer": []string{}},
+			map[string]interface{}{"apiKey": []string{}},
+		},
+	}
+}
+
+// builder собирает схемы именованных типов в components
+type builder struct {
+	components map[string]interface{}
+}
+
+// operation строит описание операции
+func (b *builder) operation(op Operation, errorBody interface{}) map[string]interface{} {
+	var params []interface{}
+	for _, m := range pathParam.FindAllStringSubmatch(op.Path, -1) {
+		params = append(params, map[string]interface{}{
+			"name":     m[1],
+			"in":       "path",
+			"required": true,
+			"schema":   map[string]interface{}{"type": "integer"},
+		})
+	}
+	for _, p := range op.Query {
+		in := p.In
+		if in == "" {
+			in = "query"
+		}
+		param := map[string]interface{}{
+			"name":     p.Name,
+			"in":       in,
+			"required": p.Required,
+			"schema":   map[string]interface{}{"type": "string"},
+		}
+		if p.Description != "" {
+			param["description"] = p.Description
+		}
+		params = append(params, param)
+	}
+
+	result := map[string]interface{}{
+		"summary":   op.Summary,
+		"responses": b.responses(op, errorBody),
+	}
+	if len(params) > 0 {
+		result["parameters"] = params
+	}
+	if op.Request != nil {
+		result["requestBody"] = map[string]interface{}{
+			"required": true,
+			"content":  b.content(op.Request),
+		}
+	}
+
+	return result
+}
+
+// responses строит описание ответов операции
+func (b *builder) responses(op Operation, errorBody interface{}) map[string]interface{} {
+	success := map[string]interface{}{"description": http.StatusText(op.Status)}
+	if op.Response != nil {
+		success["content"] = b.content(op.Response)
+	}
+
+	responses := map[string]interface{}{strconv.Itoa(op.Status): success}
+	for _, code := range op.Errors {
+		response := map[string]interface{}{"description": http.StatusText(code)}
+		if errorBody != nil {
+			response["content"] = b.content(errorBody)
+		}
+		responses[strconv.Itoa(code)] = response
+	}
+
+	return responses
+}
+
+// content описание тела в формате JSON
+func (b *builder) content(v interface{}) map[string]interface{} {
+	return map[string]interface{}{
+		"application/json": map[string]interface{}{
+			"schema": b.schema(reflect.TypeOf(v)),
+		},
+	}
+}
+
+// timeType тип time.Time, который в JSON представлен строкой RFC 3339
+var timeType = reflect.TypeOf(time.Time{})
+
+// schema возвращает JSON Schema для типа. Именованные структуры выносятся
+// в components и подставляются ссылкой.
+func (b *builder) schema(t reflect.Type) map[string]interface{} {
+	if t.Kind() == reflect.Pointer {
+		t = t.Elem()
+	}
+
+	switch {
+	case t == timeType:
+		return map[string]interface{}{"type": "string", "format": "date-time"}
+	case t.Kind() == reflect.Struct && t.Name() != "":
+		if _, ok := b.components[t.Name()]; !ok {
+			b.components[t.Name()] = nil // защита от рекурсии
+			b.components[t.Name()] = b.object(t)
+		}
+		return map[string]interface{}{"$ref": "#/components/schemas/" + t.Name()}
+	}
+
+	switch t.Kind() {
+	case reflect.Struct:
+		return b.object(t)
+	case reflect.Slice, reflect.Array:
+		return map[string]interface{}{"type": "array", "items": b.schema(t.Elem())}
+	case reflect.Map:
+		return map[string]interface{}{"type": "object", "additionalProperties": b.schema(t.Elem())}
+	case reflect.String:
+		return map[string]interface{}{"type": "string"}
+	case reflect.Bool:
+		return map[string]interface{}{"type": "boolean"}
+	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64,
+		reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64:
+		return map[string]interface{}{"type": "integer"}
+	case reflect.Float32, reflect.Float64:
+		return map[string]interface{}{"type": "number"}
+	default:
+		return map[string]interface{}{}
+	}
+}
+
+// object строит схему структуры по ее полям с тегами json
+func (b *builder) object(t reflect.Type) map[string]interface{} {
+	properties := make(map[string]interface{})
+
+	for i := 0; i < t.NumField(); i++ {
+		field := t.Field(i)
+		if !field.IsExported() {
+			continue
+		}
+		name, _, _ := strings.Cut(field.Tag.Get("json"), ",")
+		if name == "-" {
+			continue
+		}
+		if name == "" {
+			name = field.Name
+		}
+		properties[name] = b.schema(field.Type)
+	}
+
+	return map[string]interface{}{"type": "object", "properties": properties}
+}
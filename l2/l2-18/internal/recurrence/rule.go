@@ -0,0 +1,398 @@
+package recurrence
+
+import (
+	"errors"
+	"fmt"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// Frequency частота повторения события
+type Frequency string
+
+// Поддерживаемые значения FREQ
+const (
+	Daily   Frequency = "DAILY"
+	Weekly  Frequency = "WEEKLY"
+	Monthly Frequency = "MONTHLY"
+	Yearly  Frequency = "YEARLY"
+)
+
+// untilLayout формат UNTIL в UTC согласно RFC 5545
+const untilLayout = "20060102T150405Z"
+
+// maxPeriods ограничивает перебор периодов за один запрос
+const maxPeriods = 100000
+
+// MaxOccurrences наибольшее число экземпляров, которое возвращает Between
+const MaxOccurrences = 10000
+
+// ErrTooManyOccurrences диапазон содержит больше экземпляров или периодов
+// повторения, чем перебирается за один запрос
+var ErrTooManyOccurrences = errors.New("too many occurrences in range")
+
+// weekdayCodes двухбуквенные коды дней недели RFC 5545
+var weekdayCodes = map[string]time.Weekday{
+	"MO": time.Monday,
+	"TU": time.Tuesday,
+	"WE": time.Wednesday,
+	"TH": time.Thursday,
+	"FR": time.Friday,
+	"SA": time.Saturday,
+	"SU": time.Sunday,
+}
+
+// WeekdayNum элемент BYDAY: день недели с необязательным порядковым номером
+// (например, 2MO — второй понедельник, -1FR — последняя пятница)
+type WeekdayNum struct {
+	Weekday time.Weekday
+	N       int
+}
+
+// Rule правило повторения (подмножество RRULE из RFC 5545)
+type Rule struct {
+	Freq     Frequency
+	Interval int
+	Count    int
+	Until    time.Time
+	ByDay    []WeekdayNum
+}
+
+// Parse разбирает строку RRULE, например "FREQ=WEEKLY;INTERVAL=2;BYDAY=MO,WE".
+// Префикс "RRULE:" допускается.
+func Parse(s string) (*Rule, error) {
+	s = strings.TrimPrefix(strings.TrimSpace(s), "RRULE:")
+	if s == "" {
+		return nil, fmt.Errorf("empty recurrence rule")
+	}
+
+	rule := &Rule{Interval: 1}
+	for _, part := range strings.Split(s, ";") {
+		key, value, ok := strings.Cut(part, "=")
+		if !ok {
+			return nil, fmt.Errorf("invalid rule part %q", part)
+		}
+
+		switch strings.ToUpper(key) {
+		case "FREQ":
+			freq := Frequency(strings.ToUpper(value))
+			switch freq {
+			case Daily, Weekly, Monthly, Yearly:
+				rule.Freq = freq
+			default:
+				return nil, fmt.Errorf("unsupported FREQ %q", value)
+			}
+		case "INTERVAL":
+			interval, err := strconv.Atoi(value)
+			if err != nil || interval <= 0 {
+				return nil, fmt.Errorf("invalid INTERVAL %q", value)
+			}
+			rule.Interval = interval
+		case "COUNT":
+			count, err := strconv.Atoi(value)
+			if err != nil || count <= 0 {
+				return nil, fmt.Errorf("invalid COUNT %q", value)
+			}
+			rule.Count = count
+		case "UNTIL":
+			until, err := parseUntil(value)
+			if err != nil {
+				return nil, fmt.Errorf("invalid UNTIL %q", value)
+			}
+			rule.Until = until
+		case "BYDAY":
+			for _, day := range strings.Split(value, ",") {
+				wd, err := parseWeekdayNum(day)
+				if err != nil {
+					return nil, err
+				}
+				rule.ByDay = append(rule.ByDay, wd)
+			}
+		default:
+			return nil, fmt.Errorf("unsupported rule part %q", key)
+		}
+	}
+
+	if rule.Freq == "" {
+		return nil, fmt.Errorf("FREQ is required")
+	}
+	if rule.Count > 0 && !rule.Until.IsZero() {
+		return nil, fmt.Errorf("COUNT and UNTIL are mutually exclusive")
+	}
+	for _, wd := range rule.ByDay {
+		if wd.N != 0 && rule.Freq != Monthly && rule.Freq != Yearly {
+			return nil, fmt.Errorf("BYDAY ordinals are only allowed with MONTHLY or YEARLY")
+		}
+	}
+
+	return rule, nil
+}
+
+// String возвращает правило в нормализованном виде RRULE
+func (r *Rule) String() string {
+	parts := []string{"FREQ=" + string(r.Freq)}
+	if r.Interval > 1 {
+		parts = append(parts, "INTERVAL="+strconv.Itoa(r.Interval))
+	}
+	if r.Count > 0 {
+		parts = append(parts, "COUNT="+strconv.Itoa(r.Count))
+	}
+	if !r.Until.IsZero() {
+		parts = append(parts, "UNTIL="+r.Until.UTC().Format(untilLayout))
+	}
+	if len(r.ByDay) > 0 {
+		days := make([]string, 0, len(r.ByDay))
+		for _, wd := range r.ByDay {
+			days = append(days, wd.String())
+		}
+		parts = append(parts, "BYDAY="+strings.Join(days, ","))
+	}
+	return strings.Join(parts, ";")
+}
+
+// String возвращает элемент BYDAY в формате RFC 5545
+func (wd WeekdayNum) String() string {
+	code := strings.ToUpper(wd.Weekday.String()[:2])
+	if wd.N != 0 {
+		return strconv.Itoa(wd.N) + code
+	}
+	return code
+}
+
+// Between возвращает экземпляры серии, начинающейся в dtstart,
+// которые попадают в полуинтервал [from, to). Даты из exdates пропускаются,
+// но, как и в RFC 5545, учитываются при подсчете COUNT. Если экземпляров
+// больше MaxOccurrences, возвращает ErrTooManyOccurrences.
+func (r *Rule) Between(dtstart, from, to time.Time, exdates []time.Time) ([]time.Time, error) {
+	var result []time.Time
+	err := r.Each(dtstart, from, to, exdates, func(occ time.Time) bool {
+		result = append(result, occ)
+		return len(result) <= MaxOccurrences
+	})
+	if err != nil {
+		return nil, err
+	}
+	if len(result) > MaxOccurrences {
+		return nil, fmt.Errorf("%w: more than %d", ErrTooManyOccurrences, MaxOccurrences)
+	}
+	return result, nil
+}
+
+// Each передает fn экземпляры серии из [from, to) по порядку, пока fn
+// не вернет false. Правило без COUNT перебирается с периода около from,
+// а не с dtstart, поэтому стоимость не растет с возрастом серии. Если
+// диапазон не перебрать за maxPeriods периодов, возвращает
+// ErrTooManyOccurrences.
+func (r *Rule) Each(dtstart, from, to time.Time, exdates []time.Time, fn func(time.Time) bool) error {
+	excluded := make(map[int64]bool, len(exdates))
+	for _, ex := range exdates {
+		excluded[ex.Unix()] = true
+	}
+
+	count := 0
+	first := r.firstPeriod(dtstart, from)
+	for period := first; period < first+maxPeriods; period++ {
+		for _, occ := range r.periodCandidates(dtstart, period) {
+			if occ.Before(dtstart) {
+				continue
+			}
+			if !r.Until.IsZero() && occ.After(r.Until) {
+				return nil
+			}
+			if !occ.Before(to) {
+				return nil
+			}
+
+			count++
+			if !occ.Before(from) && !excluded[occ.Unix()] && !fn(occ) {
+				return nil
+			}
+			if r.Count > 0 && count >= r.Count {
+				return nil
+			}
+		}
+	}
+
+	return fmt.Errorf("%w: more than %d periods", ErrTooManyOccurrences, maxPeriods)
+}
+
+// firstPeriod возвращает номер периода, с которого можно начинать перебор
+// экземпляров не раньше from: экземпляры предыдущих периодов все раньше
+// from. Правило с COUNT перебирается с первого периода, чтобы считать
+// экземпляры.
+func (r *Rule) firstPeriod(dtstart, from time.Time) int {
+	if r.Count > 0 || !from.After(dtstart) {
+		return 0
+	}
+
+	from = from.In(dtstart.Location())
+	var periods int
+	switch r.Freq {
+	case Daily:
+		periods = dayNumber(from) - dayNumber(dtstart)
+	case Weekly:
+		periods = (dayNumber(from) - dayNumber(dtstart)) / 7
+	case Monthly:
+		periods = (from.Year()-dtstart.Year())*12 + int(from.Month()-dtstart.Month())
+	case Yearly:
+		periods = from.Year() - dtstart.Year()
+	}
+	// Запас в один период покрывает неполные недели и месяцы
+	return max(periods/r.Interval-1, 0)
+}
+
+// dayNumber возвращает номер календарного дня t, не зависящий от перевода часов
+func dayNumber(t time.Time) int {
+	return int(time.Date(t.Year(), t.Month(), t.Day(), 0, 0, 0, 0, time.UTC).Unix() / 86400)
+}
+
+// periodCandidates возвращает отсортированные кандидаты на экземпляры
+// в n-м периоде повторения (день, неделя, месяц или год)
+func (r *Rule) periodCandidates(dtstart time.Time, n int) []time.Time {
+	step := n * r.Interval
+	y, m, d := dtstart.Date()
+	hh, mm, ss := dtstart.Clock()
+	loc := dtstart.Location()
+	at := func(year int, month time.Month, day int) time.Time {
+		return time.Date(year, month, day, hh, mm, ss, dtstart.Nanosecond(), loc)
+	}
+
+	var candidates []time.Time
+
+	switch r.Freq {
+	case Daily:
+		day := at(y, m, d+step)
+		if len(r.ByDay) == 0 || r.matchesWeekday(day.Weekday()) {
+			candidates = append(candidates, day)
+		}
+
+	case Weekly:
+		if len(r.ByDay) == 0 {
+			return []time.Time{at(y, m, d+7*step)}
+		}
+		// Неделя начинается с понедельника (WKST=MO по умолчанию)
+		offset := (int(dtstart.Weekday()) + 6) % 7
+		monday := d - offset + 7*step
+		for i := 0; i < 7; i++ {
+			day := at(y, m, monday+i)
+			if r.matchesWeekday(day.Weekday()) {
+				candidates = append(candidates, day)
+			}
+		}
+
+	case Monthly:
+		year, month := y, m+time.Month(step)
+		first := at(year, month, 1)
+		year, month = first.Year(), first.Month()
+		if len(r.ByDay) == 0 {
+			// Несуществующие даты (например, 31 апреля) пропускаются
+			if day := at(year, month, d); day.Month() == month {
+				candidates = append(candidates, day)
+			}
+			break
+		}
+		last := at(year, month+1, 0).Day()
+		candidates = r.byDayInRange(first, last)
+
+	case Yearly:
+		year := y + step
+		if len(r.ByDay) == 0 {
+			if day := at(year, m, d); day.Month() == m {
+				candidates = append(candidates, day)
+			}
+			break
+		}
+		first := at(year, time.January, 1)
+		last := at(year, time.December, 31).YearDay()
+		candidates = r.byDayInRange(first, last)
+	}
+
+	return candidates
+}
+
+// byDayInRange возвращает дни из BYDAY в пределах периода из total дней,
+// начинающегося с first, с учетом порядковых номеров
+func (r *Rule) byDayInRange(first time.Time, total int) []time.Time {
+	byWeekday := make(map[time.Weekday][]time.Time)
+	for i := 0; i < total; i++ {
+		day := first.AddDate(0, 0, i)
+		byWeekday[day.Weekday()] = append(byWeekday[day.Weekday()], day)
+	}
+
+	seen := make(map[int64]bool)
+	var result []time.Time
+	for _, wd := range r.ByDay {
+		days := byWeekday[wd.Weekday]
+		switch {
+		case wd.N == 0:
+			for _, day := range days {
+				if !seen[day.Unix()] {
+					seen[day.Unix()] = true
+					result = append(result, day)
+				}
+			}
+		case wd.N > 0 && wd.N <= len(days):
+			if day := days[wd.N-1]; !seen[day.Unix()] {
+				seen[day.Unix()] = true
+				result = append(result, day)
+			}
+		case wd.N < 0 && -wd.N <= len(days):
+			if day := days[len(days)+wd.N]; !seen[day.Unix()] {
+				seen[day.Unix()] = true
+				result = append(result, day)
+			}
+		}
+	}
+
+	sort.Slice(result, func(i, j int) bool { return result[i].Before(result[j]) })
+	return result
+}
+
+// matchesWeekday проверяет, входит ли день недели в BYDAY
+func (r *Rule) matchesWeekday(day time.Weekday) bool {
+	for _, wd := range r.ByDay {
+		if wd.Weekday == day {
+			return true
+		}
+	}
+	return false
+}
+
+// parseWeekdayNum разбирает элемент BYDAY вида "MO", "2TU" или "-1FR"
+func parseWeekdayNum(s string) (WeekdayNum, error) {
+	s = strings.ToUpper(strings.TrimSpace(s))
+	if len(s) < 2 {
+		return WeekdayNum{}, fmt.Errorf("invalid BYDAY %q", s)
+	}
+
+	weekday, ok := weekdayCodes[s[len(s)-2:]]
+	if !ok {
+		return WeekdayNum{}, fmt.Errorf("invalid BYDAY %q", s)
+	}
+
+	wd := WeekdayNum{Weekday: weekday}
+	if prefix := s[:len(s)-2]; prefix != "" {
+		n, err := strconv.Atoi(prefix)
+		if err != nil || n == 0 || n < -53 || n > 53 {
+			return WeekdayNum{}, fmt.Errorf("invalid BYDAY %q", s)
+		}
+		wd.N = n
+	}
+
+	return wd, nil
+}
+
+// parseUntil разбирает UNTIL в форме даты или даты-времени UTC
+func parseUntil(s string) (time.Time, error) {
+	if t, err := time.Parse(untilLayout, s); err == nil {
+		return t, nil
+	}
+	t, err := time.Parse("20060102", s)
+	if err != nil {
+		return time.Time{}, err
+	}
+	// Дата без времени включает весь день
+	return t.Add(24*time.Hour - time.Second), nil
+}
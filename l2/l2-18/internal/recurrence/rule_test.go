@@ -0,0 +1,186 @@
+package recurrence
+
+import (
+	"errors"
+	"testing"
+	"time"
+)
+
+func date(s string) time.Time {
+	t, err := time.Parse("2006-01-02", s)
+	if err != nil {
+		panic(err)
+	}
+	return t
+}
+
+func TestParse(t *testing.T) {
+	tests := []struct {
+		input   string
+		want    string
+		wantErr bool
+	}{
+		{"FREQ=DAILY", "FREQ=DAILY", false},
+		{"RRULE:freq=weekly;interval=2;byday=mo,we", "FREQ=WEEKLY;INTERVAL=2;BYDAY=MO,WE", false},
+		{"FREQ=MONTHLY;BYDAY=-1FR;COUNT=3", "FREQ=MONTHLY;COUNT=3;BYDAY=-1FR", false},
+		{"FREQ=YEARLY;UNTIL=20250101", "FREQ=YEARLY;UNTIL=20250101T235959Z", false},
+		{"", "", true},
+		{"INTERVAL=2", "", true},
+		{"FREQ=HOURLY", "", true},
+		{"FREQ=DAILY;INTERVAL=0", "", true},
+		{"FREQ=DAILY;COUNT=2;UNTIL=20250101", "", true},
+		{"FREQ=WEEKLY;BYDAY=2MO", "", true},
+		{"FREQ=WEEKLY;BYDAY=XX", "", true},
+		{"FREQ=DAILY;BYMONTH=1", "", true},
+	}
+
+	for _, tt := range tests {
+		rule, err := Parse(tt.input)
+		if (err != nil) != tt.wantErr {
+			t.Errorf("Parse(%q) error = %v, wantErr %v", tt.input, err, tt.wantErr)
+			continue
+		}
+		if !tt.wantErr && rule.String() != tt.want {
+			t.Errorf("Parse(%q).String() = %q, want %q", tt.input, rule.String(), tt.want)
+		}
+	}
+}
+
+func TestRule_Between(t *testing.T) {
+	tests := []struct {
+		name    string
+		rule    string
+		dtstart string
+		from    string
+		to      string
+		exdates []string
+		want    []string
+		wantErr error
+	}{
+		{
+			name:    "daily with count",
+			rule:    "FREQ=DAILY;COUNT=3",
+			dtstart: "2024-01-01",
+			from:    "2023-12-01",
+			to:      "2024-02-01",
+			want:    []string{"2024-01-01", "2024-01-02", "2024-01-03"},
+		},
+		{
+			name:    "weekly by day",
+			rule:    "FREQ=WEEKLY;BYDAY=MO,WE",
+			dtstart: "2024-01-01",
+			from:    "2024-01-08",
+			to:      "2024-01-15",
+			want:    []string{"2024-01-08", "2024-01-10"},
+		},
+		{
+			name:    "biweekly",
+			rule:    "FREQ=WEEKLY;INTERVAL=2",
+			dtstart: "2024-01-03",
+			from:    "2024-01-01",
+			to:      "2024-02-01",
+			want:    []string{"2024-01-03", "2024-01-17", "2024-01-31"},
+		},
+		{
+			name:    "monthly skips short months",
+			rule:    "FREQ=MONTHLY;COUNT=3",
+			dtstart: "2024-01-31",
+			from:    "2024-01-01",
+			to:      "2025-01-01",
+			want:    []string{"2024-01-31", "2024-03-31", "2024-05-31"},
+		},
+		{
+			name:    "last friday of month",
+			rule:    "FREQ=MONTHLY;BYDAY=-1FR",
+			dtstart: "2024-01-01",
+			from:    "2024-01-01",
+			to:      "2024-04-01",
+			want:    []string{"2024-01-26", "2024-02-23", "2024-03-29"},
+		},
+		{
+			name:    "yearly until",
+			rule:    "FREQ=YEARLY;UNTIL=20260101",
+			dtstart: "2024-02-29",
+			from:    "2024-01-01",
+			to:      "2030-01-01",
+			want:    []string{"2024-02-29"},
+		},
+		{
+			name:    "exdate still counted",
+			rule:    "FREQ=DAILY;COUNT=3",
+			dtstart: "2024-01-01",
+			from:    "2024-01-01",
+			to:      "2024-02-01",
+			exdates: []string{"2024-01-02"},
+			want:    []string{"2024-01-01", "2024-01-03"},
+		},
+		// Старые серии перебираются с периода около from
+		{
+			name:    "old weekly series",
+			rule:    "FREQ=WEEKLY;BYDAY=MO,SU",
+			dtstart: "2000-01-05",
+			from:    "2024-01-07",
+			to:      "2024-01-15",
+			want:    []string{"2024-01-07", "2024-01-08", "2024-01-14"},
+		},
+		{
+			name:    "old daily series with interval",
+			rule:    "FREQ=DAILY;INTERVAL=3",
+			dtstart: "2000-01-01",
+			from:    "2024-01-01",
+			to:      "2024-01-08",
+			want:    []string{"2024-01-01", "2024-01-04", "2024-01-07"},
+		},
+		{
+			name:    "old monthly series",
+			rule:    "FREQ=MONTHLY;BYDAY=-1FR",
+			dtstart: "2000-01-01",
+			from:    "2024-02-24",
+			to:      "2024-04-01",
+			want:    []string{"2024-03-29"},
+		},
+		{
+			name:    "old yearly series",
+			rule:    "FREQ=YEARLY;INTERVAL=4",
+			dtstart: "2000-02-29",
+			from:    "2024-01-01",
+			to:      "2029-01-01",
+			want:    []string{"2024-02-29", "2028-02-29"},
+		},
+		{
+			name:    "too many occurrences",
+			rule:    "FREQ=YEARLY;BYDAY=MO,TU,WE,TH,FR,SA,SU",
+			dtstart: "2000-01-01",
+			from:    "2000-01-01",
+			to:      "9999-12-31",
+			wantErr: ErrTooManyOccurrences,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			rule, err := Parse(tt.rule)
+			if err != nil {
+				t.Fatal("Parse() error:", err)
+			}
+
+			var exdates []time.Time
+			for _, ex := range tt.exdates {
+				exdates = append(exdates, date(ex))
+			}
+
+			got, err := rule.Between(date(tt.dtstart), date(tt.from), date(tt.to), exdates)
+			if !errors.Is(err, tt.wantErr) {
+				t.Fatalf("Between() error = %v, want %v", err, tt.wantErr)
+			}
+			if len(got) != len(tt.want) {
+				t.Fatalf("Between() got %v, want %v", got, tt.want)
+			}
+			for i := range got {
+				if !got[i].Equal(date(tt.want[i])) {
+					t.Errorf("Between()[%d] = %v, want %v", i, got[i], tt.want[i])
+				}
+			}
+		})
+	}
+}
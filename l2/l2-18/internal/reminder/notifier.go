@@ -0,0 +1,121 @@
+package reminder
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"l2-18/internal/models"
+	"log/slog"
+	"mime"
+	"net/http"
+	"net/smtp"
+	"strings"
+	"time"
+)
+
+// Notifier доставляет напоминания пользователю. Ошибка означает, что
+// напоминание не доставлено, и планировщик повторит попытку позже.
+type Notifier interface {
+	Notify(ctx context.Context, reminder models.Reminder) error
+}
+
+// LogNotifier пишет напоминания в лог приложения
+type LogNotifier struct{}
+
+// Notify записывает напоминание в лог
+func (LogNotifier) Notify(ctx context.Context, reminder models.Reminder) error {
+	slog.InfoContext(ctx, "reminder", "user_id", reminder.UserID, "text", Text(reminder))
+	return nil
+}
+
+// WebhookNotifier отправляет напоминания POST-запросом с JSON-телом
+type WebhookNotifier struct {
+	URL    string
+	Client *http.Client
+}
+
+// NewWebhookNotifier создает отправителя напоминаний на указанный URL
+func NewWebhookNotifier(url string) *WebhookNotifier {
+	return &WebhookNotifier{
+		URL:    url,
+		Client: &http.Client{Timeout: 10 * time.Second},
+	}
+}
+
+// Notify отправляет напоминание. Доставленным считается ответ 2xx.
+func (n *WebhookNotifier) Notify(ctx context.Context, reminder models.Reminder) error {
+	body, err := json.Marshal(reminder)
+	if err != nil {
+		return fmt.Errorf("failed to encode reminder: %v", err)
+	}
+
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, n.URL, bytes.NewReader(body))
+	if err != nil {
+		return err
+	}
+	req.Header.Set("Content-Type", "application/json")
+	req.Header.Set("Idempotency-Key", reminder.Key)
+
+	resp, err := n.Client.Do(req)
+	if err != nil {
+		return fmt.Errorf("failed to send webhook: %v", err)
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode < 200 || resp.StatusCode > 299 {
+		return fmt.Errorf("webhook responded with status %d", resp.StatusCode)
+	}
+
+	return nil
+}
+
+// SMTPNotifier отправляет напоминания письмом через SMTP-релей
+// (обычно локальный, без аутентификации)
+type SMTPNotifier struct {
+	Addr string
+	From string
+	To   []string
+}
+
+// NewSMTPNotifier создает отправителя писем через релей addr
+func NewSMTPNotifier(addr, from string, to []string) *SMTPNotifier {
+	return &SMTPNotifier{Addr: addr, From: from, To: to}
+}
+
+// Notify отправляет письмо с напоминанием
+func (n *SMTPNotifier) Notify(_ context.Context, reminder models.Reminder) error {
+	if len(n.To) == 0 {
+		return fmt.Errorf("no reminder recipients configured")
+	}
+
+	if err := smtp.SendMail(n.Addr, nil, n.From, n.To, n.message(reminder)); err != nil {
+		return fmt.Errorf("failed to send mail: %v", err)
+	}
+
+	return nil
+}
+
+// message формирует письмо с напоминанием
+func (n *SMTPNotifier) message(reminder models.Reminder) []byte {
+	var b strings.Builder
+	fmt.Fprintf(&b, "From: %s\r\n", n.From)
+	fmt.Fprintf(&b, "To: %s\r\n", strings.Join(n.To, ", "))
+	fmt.Fprintf(&b, "Subject: %s\r\n", mime.QEncoding.Encode("utf-8", "Напоминание: "+reminder.Title))
+	fmt.Fprintf(&b, "Date: %s\r\n", time.Now().Format(time.RFC1123Z))
+	fmt.Fprintf(&b, "Message-ID: <%s>\r\n", strings.NewReplacer("|", ".", "@", ".").Replace(reminder.Key)+"@l2-18")
+	b.WriteString("MIME-Version: 1.0\r\n")
+	b.WriteString("Content-Type: text/plain; charset=utf-8\r\n")
+	b.WriteString("Content-Transfer-Encoding: 8bit\r\n")
+	b.WriteString("\r\n")
+	b.WriteString(Text(reminder) + "\r\n")
+	return []byte(b.String())
+}
+
+// Text возвращает текст напоминания
+func Text(reminder models.Reminder) string {
+	if reminder.AllDay {
+		return fmt.Sprintf("%s on %s", reminder.Title, reminder.Start.Format("2006-01-02"))
+	}
+	return fmt.Sprintf("%s at %s", reminder.Title, reminder.Start.Format("2006-01-02 15:04 MST"))
+}
@@ -0,0 +1,106 @@
+package reminder
+
+import (
+	"context"
+	"fmt"
+	"l2-18/internal/service"
+	"l2-18/internal/storage"
+	"log/slog"
+	"time"
+)
+
+// Scheduler периодически ищет наступившие напоминания и доставляет их.
+// Доставка выполняется не менее одного раза: напоминание отмечается
+// доставленным только после успешной отправки, а отметка обработанного
+// времени не сдвигается дальше первого недоставленного напоминания.
+type Scheduler struct {
+	service  *service.EventService
+	tracker  storage.ReminderStorage
+	notifier Notifier
+	interval time.Duration
+	// maxDelay предел опоздания: более старые напоминания после простоя не отправляются
+	maxDelay time.Duration
+	now      func() time.Time
+}
+
+// NewScheduler создает планировщик напоминаний с периодом опроса interval
+func NewScheduler(service *service.EventService, tracker storage.ReminderStorage, notifier Notifier, interval, maxDelay time.Duration) *Scheduler {
+	return &Scheduler{
+		service:  service,
+		tracker:  tracker,
+		notifier: notifier,
+		interval: interval,
+		maxDelay: maxDelay,
+		now:      time.Now,
+	}
+}
+
+// Run обрабатывает напоминания до отмены контекста
+func (s *Scheduler) Run(ctx context.Context) {
+	ticker := time.NewTicker(s.interval)
+	defer ticker.Stop()
+
+	for {
+		if err := s.tick(ctx); err != nil {
+			slog.ErrorContext(ctx, "reminder scheduler failed", "error", err)
+		}
+
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+		}
+	}
+}
+
+// tick доставляет напоминания, сработавшие с прошлой отметки до текущего момента
+func (s *Scheduler) tick(ctx context.Context) error {
+	now := s.now()
+
+	from, err := s.tracker.ReminderCheckpoint()
+	if err != nil {
+		return fmt.Errorf("failed to read checkpoint: %v", err)
+	}
+	switch {
+	case from.IsZero():
+		// Первый запуск: прошлые напоминания не отправляем
+		from = now
+	case now.Sub(from) > s.maxDelay:
+		slog.WarnContext(ctx, "skipping overdue reminders", "max_delay", s.maxDelay)
+		from = now.Add(-s.maxDelay)
+	}
+
+	reminders, err := s.service.DueReminders(from, now)
+	if err != nil {
+		return err
+	}
+
+	checkpoint := now
+	for _, reminder := range reminders {
+		sent, err := s.tracker.IsReminderSent(reminder.Key)
+		if err != nil {
+			return fmt.Errorf("failed to check reminder: %v", err)
+		}
+		if sent {
+			continue
+		}
+
+		if err := s.notifier.Notify(ctx, reminder); err != nil {
+			slog.ErrorContext(ctx, "failed to deliver reminder", "key", reminder.Key, "error", err)
+			if reminder.FireAt.Before(checkpoint) {
+				checkpoint = reminder.FireAt
+			}
+			continue
+		}
+
+		if err := s.tracker.MarkReminderSent(reminder.Key, reminder.FireAt); err != nil {
+			return fmt.Errorf("failed to mark reminder as sent: %v", err)
+		}
+	}
+
+	if err := s.tracker.SetReminderCheckpoint(checkpoint); err != nil {
+		return fmt.Errorf("failed to save checkpoint: %v", err)
+	}
+
+	return nil
+}
@@ -0,0 +1,82 @@
+package reminder
+
+import (
+	"context"
+	"errors"
+	"l2-18/internal/models"
+	"l2-18/internal/service"
+	"l2-18/internal/storage"
+	"path/filepath"
+	"testing"
+	"time"
+)
+
+// recordingNotifier запоминает доставленные напоминания и может отказывать
+type recordingNotifier struct {
+	delivered []models.Reminder
+	fail      bool
+}
+
+func (n *recordingNotifier) Notify(_ context.Context, reminder models.Reminder) error {
+	if n.fail {
+		return errors.New("relay is down")
+	}
+	n.delivered = append(n.delivered, reminder)
+	return nil
+}
+
+func TestScheduler_AtLeastOnceAcrossRestarts(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "events.json")
+	open := func() (*service.EventService, *storage.FileEventStorage) {
+		strg, err := storage.NewFileEventStorage(path)
+		if err != nil {
+			t.Fatal("Failed to open file storage:", err)
+		}
+		return service.NewEventService(strg), strg
+	}
+
+	svc, strg := open()
+	if _, err := svc.CreateEvent(&models.CreateEventRequest{
+		UserID: 1, Start: "2024-01-08T09:00:00Z", Title: "Standup", Reminders: []int{10},
+	}); err != nil {
+		t.Fatal("CreateEvent() error:", err)
+	}
+
+	notifier := &recordingNotifier{}
+	scheduler := NewScheduler(svc, strg, notifier, time.Minute, 24*time.Hour)
+	clock := time.Date(2024, 1, 8, 8, 0, 0, 0, time.UTC)
+	scheduler.now = func() time.Time { return clock }
+
+	tick := func(at time.Time) {
+		t.Helper()
+		clock = at
+		if err := scheduler.tick(context.Background()); err != nil {
+			t.Fatal("tick() error:", err)
+		}
+	}
+
+	// Первый запуск только запоминает отметку
+	tick(clock)
+
+	// Релей недоступен: напоминание не теряется
+	notifier.fail = true
+	tick(time.Date(2024, 1, 8, 8, 51, 0, 0, time.UTC))
+	if len(notifier.delivered) != 0 {
+		t.Fatalf("delivered %d reminders while relay is down", len(notifier.delivered))
+	}
+
+	notifier.fail = false
+	tick(time.Date(2024, 1, 8, 8, 52, 0, 0, time.UTC))
+	if len(notifier.delivered) != 1 || notifier.delivered[0].Title != "Standup" {
+		t.Fatalf("delivered %+v, want the standup reminder", notifier.delivered)
+	}
+
+	// После перезапуска напоминание не отправляется повторно
+	svc, strg = open()
+	scheduler = NewScheduler(svc, strg, notifier, time.Minute, 24*time.Hour)
+	scheduler.now = func() time.Time { return clock }
+	tick(time.Date(2024, 1, 8, 8, 53, 0, 0, time.UTC))
+	if len(notifier.delivered) != 1 {
+		t.Errorf("delivered %d reminders after restart, want 1", len(notifier.delivered))
+	}
+}
@@ -0,0 +1,106 @@
+// Package search реализует разбор текста на слова и инвертированный индекс
+// для полнотекстового поиска событий
+package search
+
+import (
+	"sort"
+	"strings"
+	"unicode"
+)
+
+// Fold приводит текст к виду для сравнения без учета регистра:
+// нижний регистр, ё заменяется на е
+func Fold(s string) string {
+	return strings.Map(func(r rune) rune {
+		r = unicode.ToLower(r)
+		if r == 'ё' {
+			return 'е'
+		}
+		return r
+	}, s)
+}
+
+// Tokens разбивает текст на слова без повторов. Словом считается
+// последовательность букв и цифр любого алфавита, слова приводятся через Fold.
+func Tokens(text string) []string {
+	words := strings.FieldsFunc(Fold(text), func(r rune) bool {
+		return !unicode.IsLetter(r) && !unicode.IsDigit(r)
+	})
+
+	seen := make(map[string]bool, len(words))
+	result := words[:0]
+	for _, word := range words {
+		if !seen[word] {
+			seen[word] = true
+			result = append(result, word)
+		}
+	}
+	return result
+}
+
+// Index инвертированный индекс: слово -> идентификаторы документов.
+// Словарь хранится отсортированным для поиска по началу слова.
+// Index не безопасен для одновременного использования.
+type Index struct {
+	postings map[string]map[int]struct{}
+	vocab    []string
+}
+
+// NewIndex создает пустой индекс
+func NewIndex() *Index {
+	return &Index{postings: make(map[string]map[int]struct{})}
+}
+
+// Add добавляет документ id со словами terms
+func (ix *Index) Add(id int, terms []string) {
+	for _, term := range terms {
+		ids, ok := ix.postings[term]
+		if !ok {
+			ids = make(map[int]struct{})
+			ix.postings[term] = ids
+
+			i := sort.SearchStrings(ix.vocab, term)
+			ix.vocab = append(ix.vocab, "")
+			copy(ix.vocab[i+1:], ix.vocab[i:])
+			ix.vocab[i] = term
+		}
+		ids[id] = struct{}{}
+	}
+}
+
+// Remove удаляет документ id, проиндексированный со словами terms
+func (ix *Index) Remove(id int, terms []string) {
+	for _, term := range terms {
+		ids, ok := ix.postings[term]
+		if !ok {
+			continue
+		}
+		delete(ids, id)
+		if len(ids) == 0 {
+			delete(ix.postings, term)
+			if i := sort.SearchStrings(ix.vocab, term); i < len(ix.vocab) && ix.vocab[i] == term {
+				ix.vocab = append(ix.vocab[:i], ix.vocab[i+1:]...)
+			}
+		}
+	}
+}
+
+// Lookup возвращает документы, содержащие слово term
+func (ix *Index) Lookup(term string) map[int]struct{} {
+	result := make(map[int]struct{}, len(ix.postings[term]))
+	for id := range ix.postings[term] {
+		result[id] = struct{}{}
+	}
+	return result
+}
+
+// LookupPrefix возвращает документы, содержащие слово, которое начинается с prefix
+func (ix *Index) LookupPrefix(prefix string) map[int]struct{} {
+	result := make(map[int]struct{})
+	for i := sort.SearchStrings(ix.vocab, prefix); i < len(ix.vocab) && strings.HasPrefix(ix.vocab[i], prefix); i++ {
+		for id := range ix.postings[ix.vocab[i]] {
+			result[id] = struct{}{}
+		}
+	}
+	return result
+}
@@ -0,0 +1,65 @@
+package search
+
+import (
+	"reflect"
+	"testing"
+)
+
+func TestTokens(t *testing.T) {
+	tests := []struct {
+		text string
+		want []string
+	}{
+		{"Daily Standup", []string{"daily", "standup"}},
+		{"Ёлка в офисе, ЁЛКА!", []string{"елка", "в", "офисе"}},
+		{"Встреча с командой — Q3/2024", []string{"встреча", "с", "командой", "q3", "2024"}},
+		{"  ", []string{}},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.text, func(t *testing.T) {
+			if got := Tokens(tt.text); !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("Tokens(%q) = %q, want %q", tt.text, got, tt.want)
+			}
+		})
+	}
+}
+
+func TestIndex(t *testing.T) {
+	ix := NewIndex()
+	ix.Add(1, Tokens("Планирование спринта"))
+	ix.Add(2, Tokens("Планерка"))
+	ix.Add(3, Tokens("Sprint review"))
+
+	tests := []struct {
+		name   string
+		lookup func() map[int]struct{}
+		want   []int
+	}{
+		{"exact", func() map[int]struct{} { return ix.Lookup("спринта") }, []int{1}},
+		{"prefix", func() map[int]struct{} { return ix.LookupPrefix("план") }, []int{1, 2}},
+		{"missing", func() map[int]struct{} { return ix.LookupPrefix("demo") }, nil},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got := tt.lookup()
+			if len(got) != len(tt.want) {
+				t.Fatalf("got %v, want %v", got, tt.want)
+			}
+			for _, id := range tt.want {
+				if _, ok := got[id]; !ok {
+					t.Errorf("got %v, want %v", got, tt.want)
+				}
+			}
+		})
+	}
+
+	ix.Remove(2, Tokens("Планерка"))
+	if got := ix.LookupPrefix("план"); len(got) != 1 {
+		t.Errorf("LookupPrefix() after Remove() = %v, want only document 1", got)
+	}
+	if len(ix.vocab) != 4 {
+		t.Errorf("vocabulary = %q, removed words must be dropped", ix.vocab)
+	}
+}
@@ -0,0 +1,252 @@
+package service
+
+import (
+	"errors"
+	"fmt"
+	"l2-18/internal/apperrors"
+	"l2-18/internal/models"
+	"l2-18/internal/storage"
+	"sort"
+)
+
+// MaxBatchOperations наибольшее число операций в пакете
+const MaxBatchOperations = 500
+
+// BatchError ошибки операций, из-за которых пакет не выполнен,
+// по номерам операций
+type BatchError struct {
+	Errors map[int]error
+}
+
+// Error возвращает ошибку первой неудачной операции
+func (e *BatchError) Error() string {
+	indexes := e.Indexes()
+	if len(indexes) == 0 {
+		return "batch is not applied"
+	}
+	msg := fmt.Sprintf("batch is not applied: operation %d: %v", indexes[0], e.Errors[indexes[0]])
+	if len(indexes) > 1 {
+		msg += fmt.Sprintf(" (and %d more failed operations)", len(indexes)-1)
+	}
+	return msg
+}
+
+// Unwrap возвращает ошибки операций для errors.Is
+func (e *BatchError) Unwrap() []error {
+	errs := make([]error, 0, len(e.Errors))
+	for _, index := range e.Indexes() {
+		errs = append(errs, e.Errors[index])
+	}
+	return errs
+}
+
+// Indexes возвращает номера неудачных операций по возрастанию
+func (e *BatchError) Indexes() []int {
+	indexes := make([]int, 0, len(e.Errors))
+	for index := range e.Errors {
+		indexes = append(indexes, index)
+	}
+	sort.Ints(indexes)
+	return indexes
+}
+
+// mutation изменение хранилища, которым выполняется операция,
+// событие до него и изменивший его пользователь, чтобы опубликовать изменение
+type mutation struct {
+	op     storage.BatchOp
+	before *models.Event
+	actor  int
+}
+
+// creating возвращает создание события пользователем actor
+func creating(event *models.Event, actor int) mutation {
+	return mutation{op: storage.BatchOp{Kind: storage.BatchCreate, Event: event}, actor: actor}
+}
+
+// updating возвращает замену события before на event пользователем actor
+func updating(event, before *models.Event, actor int) mutation {
+	return mutation{op: storage.BatchOp{Kind: storage.BatchUpdate, Event: event}, before: before, actor: actor}
+}
+
+// deleting возвращает удаление пользователем actor события, не изменившегося
+// с версии version: перенос в корзину, если хранилище ее поддерживает
+func (s *EventService) deleting(event *models.Event, version, actor int) mutation {
+	kind := storage.BatchDelete
+	if s.trash != nil {
+		kind = storage.BatchTrash
+	}
+	return mutation{
+		op:     storage.BatchOp{Kind: kind, ID: event.ID, UserID: event.UserID, Version: version},
+		before: event,
+		actor:  actor,
+	}
+}
+
+// commit выполняет одно изменение и публикует его
+func (s *EventService) commit(m mutation) error {
+	switch m.op.Kind {
+	case storage.BatchCreate:
+		return s.create(m.op.Event, m.actor)
+	case storage.BatchUpdate:
+		return s.update(m.op.Event, m.before, m.actor)
+	default:
+		return s.remove(m.before, m.op.Version, m.actor)
+	}
+}
+
+// ApplyBatch выполняет пакет операций пользователя атомарно. Каждая операция
+// проверяется так же, как отдельный запрос, и если хотя бы одна неверна или
+// невыполнима, не выполняется ни одна и возвращается *BatchError с ошибками
+// операций. Иначе возвращает события после операций в их порядке
+// (nil для удалений). Одно событие может изменяться в пакете только один раз.
+func (s *EventService) ApplyBatch(req *models.BatchRequest) ([]*models.Event, error) {
+	v := &apperrors.ValidationError{}
+	if req.UserID <= 0 {
+		v.Add("user_id", "invalid user ID")
+	}
+	switch {
+	case len(req.Operations) == 0:
+		v.Add("operations", "batch has no operations")
+	case len(req.Operations) > MaxBatchOperations:
+		v.Add("operations", "batch has more than %d operations", MaxBatchOperations)
+	}
+	if err := v.Err(); err != nil {
+		return nil, err
+	}
+	if s.batches == nil {
+		return nil, fmt.Errorf("batches are not supported by storage")
+	}
+
+	var (
+		mutations []mutation
+		// owners номер операции пакета для каждого изменения хранилища
+		owners  []int
+		results = make([]*models.Event, len(req.Operations))
+		failed  = make(map[int]error)
+	)
+	for i, op := range req.Operations {
+		planned, result, err := s.plan(req, op)
+		if err != nil {
+			failed[i] = err
+			continue
+		}
+		results[i] = result
+		for _, m := range planned {
+			mutations = append(mutations, m)
+			owners = append(owners, i)
+		}
+	}
+	if len(failed) > 0 {
+		return nil, &BatchError{Errors: failed}
+	}
+
+	ops := make([]storage.BatchOp, len(mutations))
+	for i, m := range mutations {
+		ops[i] = m.op
+	}
+	if err := s.batches.ApplyBatch(ops); err != nil {
+		var opErr *storage.BatchError
+		if errors.As(err, &opErr) {
+			return nil, &BatchError{Errors: map[int]error{owners[opErr.Index]: opErr.Err}}
+		}
+		return nil, fmt.Errorf("failed to apply batch: %w", err)
+	}
+
+	// Пакет уже применен, поэтому публикуются все изменения
+	var published []error
+	for _, m := range mutations {
+		switch m.op.Kind {
+		case storage.BatchCreate:
+			published = append(published, s.publish(models.ChangeCreated, m.actor, m.op.Event, nil))
+		case storage.BatchUpdate:
+			published = append(published, s.publish(models.ChangeUpdated, m.actor, m.op.Event, m.before))
+		case storage.BatchDelete, storage.BatchTrash:
+			published = append(published, s.publish(models.ChangeDeleted, m.actor, m.before, nil))
+		}
+	}
+	if err := errors.Join(published...); err != nil {
+		return nil, fmt.Errorf("failed to apply batch: %w", err)
+	}
+
+	return results, nil
+}
+
+// plan проверяет операцию пакета и возвращает изменения хранилища,
+// которыми она выполняется, и событие для ее результата
+func (s *EventService) plan(req *models.BatchRequest, op models.BatchOperation) ([]mutation, *models.Event, error) {
+	set := 0
+	for _, given := range []bool{op.Create != nil, op.Update != nil, op.Delete != nil} {
+		if given {
+			set++
+		}
+	}
+	if set != 1 {
+		return nil, nil, apperrors.Field("operation", "exactly one of create, update or delete must be set")
+	}
+
+	switch {
+	case op.Create != nil:
+		create := *op.Create
+		if err := batchUser(&create.UserID, req.UserID); err != nil {
+			return nil, nil, err
+		}
+		if create.CalendarID == 0 {
+			create.CalendarID = req.CalendarID
+		}
+		event, err := s.newEvent(&create)
+		if err != nil {
+			return nil, nil, err
+		}
+		return []mutation{creating(event, req.UserID)}, event, nil
+
+	case op.Update != nil:
+		update := *op.Update
+		if err := batchUser(&update.UserID, req.UserID); err != nil {
+			return nil, nil, err
+		}
+		if err := s.validateUpdateRequest(&update); err != nil {
+			return nil, nil, err
+		}
+		if update.OccurrenceDate != "" {
+			exception, series, err := s.occurrenceException(&update)
+			if err != nil {
+				return nil, nil, err
+			}
+			excluded := exclusion(series, *exception.RecurrenceID, update.Version)
+			return []mutation{creating(exception, req.UserID), updating(excluded, series, req.UserID)}, exception, nil
+		}
+		event, existing, err := s.replacement(&update)
+		if err != nil {
+			return nil, nil, err
+		}
+		orphans, err := s.orphanDeletions(event, existing, req.UserID)
+		if err != nil {
+			return nil, nil, fmt.Errorf("failed to update event: %w", err)
+		}
+		return append([]mutation{updating(event, existing, req.UserID)}, orphans...), event, nil
+
+	default:
+		del := *op.Delete
+		if err := batchUser(&del.UserID, req.UserID); err != nil {
+			return nil, nil, err
+		}
+		if err := s.validateDeleteRequest(&del); err != nil {
+			return nil, nil, err
+		}
+		mutations, err := s.deletion(&del)
+		if err != nil {
+			return nil, nil, fmt.Errorf("failed to delete event: %w", err)
+		}
+		return mutations, nil, nil
+	}
+}
+
+// batchUser подставляет пользователя пакета в операцию или возвращает
+// ошибку, если в операции указан другой пользователь
+func batchUser(userID *int, batchUserID int) error {
+	if *userID != 0 && *userID != batchUserID {
+		return apperrors.Errorf(apperrors.ErrForbidden, "operation user ID %d does not match the batch user %d", *userID, batchUserID)
+	}
+	*userID = batchUserID
+	return nil
+}
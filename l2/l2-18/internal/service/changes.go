@@ -0,0 +1,129 @@
+package service
+
+import (
+	"l2-18/internal/apperrors"
+	"l2-18/internal/feed"
+	"l2-18/internal/models"
+	"slices"
+)
+
+// changeBuffer размер очереди изменений подписчика ленты
+const changeBuffer = 256
+
+// Changes возвращает шину изменений событий
+func (s *EventService) Changes() *feed.Bus {
+	return s.changes
+}
+
+// SubscribeChanges подписывает пользователя на изменения его событий
+// и приглашений, начиная с изменения после after
+func (s *EventService) SubscribeChanges(userID int, after int64) (*feed.Subscription, error) {
+	if userID <= 0 {
+		return nil, apperrors.Field("user_id", "invalid user ID")
+	}
+	if after < 0 {
+		return nil, apperrors.Field("last_event_id", "invalid change sequence number")
+	}
+
+	return s.changes.Subscribe(after, func(c models.Change) bool { return c.VisibleTo(userID) }, changeBuffer)
+}
+
+// create сохраняет новое событие, созданное пользователем actor,
+// и публикует его создание
+func (s *EventService) create(event *models.Event, actor int) error {
+	if err := s.storage.Create(event); err != nil {
+		return err
+	}
+	return s.publish(models.ChangeCreated, actor, event, nil)
+}
+
+// update сохраняет событие, измененное пользователем actor, и публикует
+// изменение; before — событие до изменения, чтобы о нем узнали
+// и удаленные участники. Если у серии убран RRULE, ее отдельно
+// измененные экземпляры удаляются вместе с ней, даже если не удалось
+// записать историю самой серии.
+func (s *EventService) update(event, before *models.Event, actor int) error {
+	orphans, err := s.orphanDeletions(event, before, actor)
+	if err != nil {
+		return err
+	}
+
+	if err := s.storage.Update(event); err != nil {
+		return err
+	}
+	published := s.publish(models.ChangeUpdated, actor, event, before)
+
+	for _, m := range orphans {
+		if err := s.commit(m); err != nil {
+			return err
+		}
+	}
+	return published
+}
+
+// remove удаляет событие по просьбе пользователя actor, если оно
+// не изменилось с версии version, — в корзину, если хранилище ее
+// поддерживает, — и публикует удаление
+func (s *EventService) remove(event *models.Event, version, actor int) error {
+	var err error
+	if s.trash != nil {
+		err = s.trash.TrashEvent(event.ID, event.UserID, version)
+	} else {
+		err = s.storage.Delete(event.ID, event.UserID, version)
+	}
+	if err != nil {
+		return err
+	}
+	return s.publish(models.ChangeDeleted, actor, event, nil)
+}
+
+// publish записывает изменение события пользователем actor в историю
+// события и отправляет его в шину
+func (s *EventService) publish(changeType string, actor int, event, before *models.Event) error {
+	return s.publishRevision(models.Revision{Action: changeType, ActorID: actor}, event, before)
+}
+
+// publishRevision записывает изменение события rev в историю события
+// и отправляет его в шину. Получатели — владелец, участники события
+// и пользователи, которым открыт календарь владельца. Участники, убранные
+// из события, получают только изменение ChangeRemoved с его ID: события
+// они больше не видят. Изменение уже сохранено, поэтому оно отправляется
+// в шину, даже если историю записать не удалось; ошибка истории
+// возвращается.
+func (s *EventService) publishRevision(rev models.Revision, event, before *models.Event) error {
+	recorded := s.record(rev, event, before)
+
+	recipients := []int{event.UserID}
+	for _, attendee := range event.Attendees {
+		if !slices.Contains(recipients, attendee.UserID) {
+			recipients = append(recipients, attendee.UserID)
+		}
+	}
+	if s.sharing != nil {
+		// Без списка доступа изменение все равно доходит до владельца
+		// и участников
+		shares, _ := s.sharing.GetShares(event.UserID)
+		for _, share := range shares {
+			if !slices.Contains(recipients, share.UserID) {
+				recipients = append(recipients, share.UserID)
+			}
+		}
+	}
+
+	copied := *event
+	s.changes.Publish(models.Change{Type: rev.Action, ActorID: rev.ActorID, EventID: event.ID, Event: &copied, Recipients: recipients})
+
+	var removed []int
+	if before != nil {
+		for _, attendee := range before.Attendees {
+			if !slices.Contains(recipients, attendee.UserID) && !slices.Contains(removed, attendee.UserID) {
+				removed = append(removed, attendee.UserID)
+			}
+		}
+	}
+	if len(removed) > 0 {
+		s.changes.Publish(models.Change{Type: models.ChangeRemoved, ActorID: rev.ActorID, EventID: event.ID, Recipients: removed})
+	}
+
+	return recorded
+}
@@ -0,0 +1,201 @@
+package service
+
+import (
+	"errors"
+	"fmt"
+	"l2-18/internal/apperrors"
+	"l2-18/internal/models"
+	"slices"
+	"time"
+)
+
+// Объект календаря — все компоненты с одним UID: одиночное событие
+// или серия вместе с ее измененными экземплярами. Так события
+// представлены в iCalendar и в CalDAV.
+
+// GetEventObject возвращает компоненты объекта календаря с указанным UID,
+// основное событие идет первым
+func (s *EventService) GetEventObject(userID int, uid string) ([]*models.Event, error) {
+	if userID <= 0 {
+		return nil, apperrors.Field("user_id", "invalid user ID")
+	}
+
+	events, err := s.userEvents(userID)
+	if err != nil {
+		return nil, err
+	}
+
+	object := groupByUID(events)[uid]
+	if len(object) == 0 {
+		return nil, apperrors.Errorf(apperrors.ErrNotFound, "event with UID %s not found", uid)
+	}
+
+	return object, nil
+}
+
+// ListEventObjects возвращает объекты календаря пользователя, хотя бы один
+// компонент которых пересекается с [from, to). Нулевой to означает
+// отсутствие верхней границы.
+func (s *EventService) ListEventObjects(userID int, from, to time.Time) ([][]*models.Event, error) {
+	if userID <= 0 {
+		return nil, apperrors.Field("user_id", "invalid user ID")
+	}
+	if to.IsZero() {
+		to = maxDate
+	}
+
+	events, err := s.userEvents(userID)
+	if err != nil {
+		return nil, err
+	}
+
+	groups := groupByUID(events)
+
+	var result [][]*models.Event
+	for _, event := range events {
+		if event.SeriesID != 0 {
+			continue
+		}
+		object := groups[event.UID]
+
+		matched, err := objectOverlaps(object, from, to)
+		if err != nil {
+			return nil, err
+		}
+		if matched {
+			result = append(result, object)
+		}
+	}
+
+	return result, nil
+}
+
+// PutEventObject заменяет объект календаря с указанным UID переданными
+// компонентами или создает его. Измененные экземпляры, которых нет
+// среди компонентов, удаляются. Если versions не nil, объект заменяется,
+// только если его компоненты не изменились с версий versions (по ID),
+// иначе возвращается ErrConflict. Возвращает true, если объект создан.
+func (s *EventService) PutEventObject(userID int, uid string, components []*models.Event, versions map[int]int) (bool, error) {
+	if userID <= 0 {
+		return false, apperrors.Field("user_id", "invalid user ID")
+	}
+
+	masters := 0
+	for _, component := range components {
+		if component.UID != uid {
+			return false, apperrors.Field("uid", "component UID %s does not match %s", component.UID, uid)
+		}
+		if component.RecurrenceID == nil {
+			masters++
+		}
+	}
+	if masters != 1 {
+		return false, apperrors.Errorf(apperrors.ErrValidation, "calendar object must contain exactly one master event")
+	}
+
+	events, err := s.userEvents(userID)
+	if err != nil {
+		return false, err
+	}
+	existing := groupByUID(events)[uid]
+	if err := checkObjectVersions(existing, versions); err != nil {
+		return false, err
+	}
+
+	byUID, byRecurrence := indexImported(events)
+	for _, component := range importOrder(components) {
+		if _, err := s.importEvent(userID, component, byUID, byRecurrence, versions); err != nil {
+			return false, fmt.Errorf("failed to put calendar object: %w", err)
+		}
+	}
+
+	// Удаляем экземпляры, которые клиент больше не прислал
+	for _, old := range existing {
+		if old.RecurrenceID == nil {
+			continue
+		}
+		kept := slices.ContainsFunc(components, func(c *models.Event) bool {
+			return c.RecurrenceID != nil && c.RecurrenceID.Equal(*old.RecurrenceID)
+		})
+		if !kept {
+			// Экземпляр мог быть удален вместе с RRULE серии
+			err := s.remove(old, versions[old.ID], userID)
+			if err != nil && !errors.Is(err, apperrors.ErrNotFound) {
+				return false, fmt.Errorf("failed to delete stale occurrence: %w", err)
+			}
+		}
+	}
+
+	return len(existing) == 0, nil
+}
+
+// DeleteEventObject удаляет все компоненты объекта календаря. Если versions
+// не nil, объект удаляется, только если его компоненты не изменились
+// с версий versions (по ID), иначе возвращается ErrConflict.
+func (s *EventService) DeleteEventObject(userID int, uid string, versions map[int]int) error {
+	object, err := s.GetEventObject(userID, uid)
+	if err != nil {
+		return err
+	}
+	if err := checkObjectVersions(object, versions); err != nil {
+		return err
+	}
+
+	for _, event := range object {
+		if err := s.remove(event, versions[event.ID], userID); err != nil {
+			return fmt.Errorf("failed to delete event: %w", err)
+		}
+	}
+
+	return nil
+}
+
+// checkObjectVersions проверяет, что объект состоит из тех же компонентов,
+// что перечислены в versions. Версии самих компонентов проверяются
+// хранилищем при их изменении.
+func checkObjectVersions(object []*models.Event, versions map[int]int) error {
+	if versions == nil {
+		return nil
+	}
+	if len(object) != len(versions) {
+		return apperrors.Errorf(apperrors.ErrConflict, "calendar object has been modified")
+	}
+	for _, event := range object {
+		if _, ok := versions[event.ID]; !ok {
+			return apperrors.Errorf(apperrors.ErrConflict, "calendar object has been modified")
+		}
+	}
+	return nil
+}
+
+// groupByUID группирует события по UID, основное событие идет первым
+func groupByUID(events []*models.Event) map[string][]*models.Event {
+	groups := make(map[string][]*models.Event)
+	for _, event := range events {
+		if event.SeriesID == 0 {
+			groups[event.UID] = append([]*models.Event{event}, groups[event.UID]...)
+		} else {
+			groups[event.UID] = append(groups[event.UID], event)
+		}
+	}
+	return groups
+}
+
+// objectOverlaps проверяет, пересекается ли хотя бы один компонент объекта с [from, to)
+func objectOverlaps(object []*models.Event, from, to time.Time) (bool, error) {
+	for _, event := range object {
+		if !event.IsRecurring() {
+			if event.Overlaps(from, to) {
+				return true, nil
+			}
+			continue
+		}
+
+		found, err := hasOccurrence(event, from, to)
+		if err != nil || found {
+			return found, err
+		}
+	}
+
+	return false, nil
+}
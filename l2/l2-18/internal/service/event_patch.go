@@ -0,0 +1,112 @@
+package service
+
+import (
+	"fmt"
+	"l2-18/internal/apperrors"
+	"l2-18/internal/models"
+	"slices"
+	"time"
+)
+
+// PatchEvent частично обновляет событие: непереданные поля берутся
+// из текущего состояния, после чего выполняется обычное обновление
+func (s *EventService) PatchEvent(id, userID int, patch *models.PatchEventRequest) (*models.Event, error) {
+	if id <= 0 {
+		return nil, apperrors.Field("id", "invalid event ID")
+	}
+	if userID <= 0 {
+		return nil, apperrors.Field("user_id", "invalid user ID")
+	}
+	if patch.End != nil && patch.Duration != nil {
+		return nil, apperrors.Field("duration", "end and duration are mutually exclusive")
+	}
+
+	existing, err := s.authorize(id, userID, models.PermissionWrite)
+	if err != nil {
+		return nil, fmt.Errorf("failed to update event: %w", err)
+	}
+
+	req := updateRequestFrom(existing)
+	req.UserID = userID
+	req.CheckConflicts = patch.CheckConflicts
+	applyPatch(req, existing, patch)
+	req.Version = expectedVersion(patch.Version, existing)
+
+	return s.UpdateEvent(req)
+}
+
+// updateRequestFrom возвращает запрос на обновление, который оставляет событие без изменений
+func updateRequestFrom(event *models.Event) *models.UpdateEventRequest {
+	req := &models.UpdateEventRequest{
+		ID:          event.ID,
+		UserID:      event.UserID,
+		AllDay:      event.AllDay,
+		TimeZone:    event.TimeZone,
+		Title:       event.Title,
+		Description: event.Description,
+		RRule:       event.RRule,
+		Reminders:   slices.Clone(event.Reminders),
+		Tags:        slices.Clone(event.Tags),
+		Attendees:   attendeeIDs(event),
+	}
+
+	loc := eventLocation(event)
+	if event.AllDay {
+		req.Date = event.Start.In(loc).Format("2006-01-02")
+		req.End = event.End.In(loc).Format("2006-01-02")
+	} else {
+		req.Start = event.Start.In(loc).Format(time.RFC3339)
+		req.End = event.End.In(loc).Format(time.RFC3339)
+	}
+
+	return req
+}
+
+// applyPatch переносит в запрос переданные поля частичного обновления
+func applyPatch(req *models.UpdateEventRequest, existing *models.Event, patch *models.PatchEventRequest) {
+	if patch.Date != nil || patch.Start != nil {
+		req.Date, req.Start = "", ""
+		if patch.Date != nil {
+			req.Date = *patch.Date
+		}
+		if patch.Start != nil {
+			req.Start = *patch.Start
+			req.AllDay = false
+		}
+		// Перенос начала без нового конца сохраняет длительность
+		if patch.End == nil && patch.Duration == nil {
+			req.End = ""
+			req.Duration = existing.Duration().String()
+		}
+	}
+	if patch.End != nil {
+		req.End, req.Duration = *patch.End, ""
+	}
+	if patch.Duration != nil {
+		req.Duration, req.End = *patch.Duration, ""
+	}
+	if patch.AllDay != nil {
+		req.AllDay = *patch.AllDay
+	}
+	if patch.TimeZone != nil {
+		req.TimeZone = *patch.TimeZone
+	}
+	if patch.Title != nil {
+		req.Title = *patch.Title
+	}
+	if patch.Description != nil {
+		req.Description = *patch.Description
+	}
+	if patch.RRule != nil {
+		req.RRule = *patch.RRule
+	}
+	if patch.Reminders != nil {
+		req.Reminders = *patch.Reminders
+	}
+	if patch.Attendees != nil {
+		req.Attendees = *patch.Attendees
+	}
+	if patch.Tags != nil {
+		req.Tags = *patch.Tags
+	}
+}
@@ -1,43 +1,189 @@
 package service
 
 import (
+	"errors"
 	"fmt"
+	"l2-18/internal/apperrors"
+	"l2-18/internal/feed"
 	"l2-18/internal/models"
+	"l2-18/internal/recurrence"
 	"l2-18/internal/storage"
+	"slices"
 	"strings"
 	"time"
 )
 
+// maxDate верхняя граница для выборки всех событий пользователя
+var maxDate = time.Date(9999, 12, 31, 0, 0, 0, 0, time.UTC)
+
+// DefaultMaxRange наибольшая длина диапазона выборки событий по умолчанию
+const DefaultMaxRange = 2 * 366 * 24 * time.Hour
+
+// maxOccurrences наибольшее число экземпляров серий, которое
+// разворачивается для одного запроса
+const maxOccurrences = 5 * recurrence.MaxOccurrences
+
 // EventService содержит бизнес-логику для работы с событиями
 type EventService struct {
-	storage storage.EventStorage
+	storage   storage.EventStorage
+	users     storage.UserStorage
+	reminders storage.ReminderStorage
+	sharing   storage.SharingStorage
+	searcher  storage.SearchStorage
+	health    storage.HealthChecker
+	stats     storage.StatsStorage
+	webhooks  storage.WebhookStorage
+	batches   storage.BatchStorage
+	trash     storage.TrashStorage
+	history   storage.HistoryStorage
+	changes   *feed.Bus
+	// trashRetention сколько удаленные события хранятся в корзине
+	trashRetention time.Duration
+	// privateWebhooks разрешает webhook на внутренние адреса
+	privateWebhooks bool
+	// maxRange наибольшая длина диапазона выборки событий
+	maxRange time.Duration
 }
 
-// NewEventService создает новый сервис событий
-func NewEventService(storage storage.EventStorage) *EventService {
-	return &EventService{storage: storage}
+// NewEventService создает новый сервис событий. Возможности хранилища
+// сверх EventStorage необязательны, сервис использует те, что есть:
+//   - настройки пользователей — для часовых поясов;
+//   - поиск событий всех пользователей — для напоминаний;
+//   - участники и доступы — для совместных календарей;
+//   - индекс событий — для поиска;
+//   - состояние хранилища — для проверки готовности;
+//   - сведения о содержимом — для метрик;
+//   - webhook — для их регистрации;
+//   - атомарные пакеты изменений — для пакетных операций;
+//   - корзина — в нее переносятся удаленные события;
+//   - история — в нее записываются все изменения событий.
+//
+// Изменения событий сервис публикует в шину Changes.
+func NewEventService(strg storage.EventStorage) *EventService {
+	users, _ := strg.(storage.UserStorage)
+	reminders, _ := strg.(storage.ReminderStorage)
+	sharing, _ := strg.(storage.SharingStorage)
+	searcher, _ := strg.(storage.SearchStorage)
+	health, _ := strg.(storage.HealthChecker)
+	stats, _ := strg.(storage.StatsStorage)
+	webhooks, _ := strg.(storage.WebhookStorage)
+	batches, _ := strg.(storage.BatchStorage)
+	trash, _ := strg.(storage.TrashStorage)
+	history, _ := strg.(storage.HistoryStorage)
+	return &EventService{
+		storage:        strg,
+		users:          users,
+		reminders:      reminders,
+		sharing:        sharing,
+		searcher:       searcher,
+		health:         health,
+		stats:          stats,
+		webhooks:       webhooks,
+		batches:        batches,
+		trash:          trash,
+		history:        history,
+		changes:        feed.NewBus(0),
+		trashRetention: DefaultTrashRetention,
+		maxRange:       DefaultMaxRange,
+	}
+}
+
+// CheckHealth возвращает ошибку, если хранилище не может сохранять изменения
+func (s *EventService) CheckHealth() error {
+	if s.health == nil {
+		return nil
+	}
+	if err := s.health.Ping(); err != nil {
+		return fmt.Errorf("storage is unavailable: %w", err)
+	}
+	return nil
 }
 
-// CreateEvent создает новое событие
+// Stats возвращает число событий и пользователей в хранилище.
+// Если хранилище этого не умеет, возвращает ok == false.
+func (s *EventService) Stats() (stats storage.Stats, ok bool, err error) {
+	if s.stats == nil {
+		return storage.Stats{}, false, nil
+	}
+	stats, err = s.stats.Stats()
+	return stats, err == nil, err
+}
+
+// CreateEvent создает новое событие в календаре пользователя или, если задан
+// CalendarID, в чужом календаре, открытом пользователю на запись
 func (s *EventService) CreateEvent(req *models.CreateEventRequest) (*models.Event, error) {
+	event, err := s.newEvent(req)
+	if err != nil {
+		return nil, err
+	}
+
+	if err := s.create(event, req.UserID); err != nil {
+		return nil, fmt.Errorf("failed to create event: %w", err)
+	}
+
+	return event, nil
+}
+
+// newEvent проверяет запрос на создание и возвращает новое событие
+func (s *EventService) newEvent(req *models.CreateEventRequest) (*models.Event, error) {
 	if err := s.validateCreateRequest(req); err != nil {
 		return nil, err
 	}
 
-	date, err := time.Parse("2006-01-02", req.Date)
+	ownerID := req.UserID
+	if req.CalendarID != 0 {
+		ownerID = req.CalendarID
+	}
+	if err := s.checkCalendarAccess(ownerID, req.UserID, models.PermissionWrite); err != nil {
+		return nil, err
+	}
+
+	loc, err := s.location(req.UserID, req.TimeZone)
 	if err != nil {
-		return nil, fmt.Errorf("invalid date format: %v", err)
+		return nil, err
+	}
+
+	when, err := parseEventTime(req.Date, req.Start, req.End, req.Duration, req.AllDay, loc)
+	if err != nil {
+		return nil, err
+	}
+
+	rrule, err := normalizeRRule(req.RRule)
+	if err != nil {
+		return nil, err
+	}
+
+	reminders, err := normalizeReminders(req.Reminders)
+	if err != nil {
+		return nil, err
+	}
+
+	tags, err := normalizeTags(req.Tags)
+	if err != nil {
+		return nil, err
+	}
+
+	attendees, err := mergeAttendees(nil, req.Attendees, ownerID)
+	if err != nil {
+		return nil, err
 	}
 
 	event := &models.Event{
-		UserID:      req.UserID,
-		Date:        date,
+		UID:         newUID(),
+		UserID:      ownerID,
 		Title:       strings.TrimSpace(req.Title),
 		Description: strings.TrimSpace(req.Description),
+		RRule:       rrule,
+		Reminders:   reminders,
+		Tags:        tags,
+		Attendees:   attendees,
 	}
+	when.apply(event)
 
-	if err := s.storage.Create(event); err != nil {
-		return nil, fmt.Errorf("failed to create event: %v", err)
+	if req.CheckConflicts {
+		if err := s.checkConflicts(event); err != nil {
+			return nil, err
+		}
 	}
 
 	return event, nil
@@ -49,58 +195,321 @@ func (s *EventService) UpdateEvent(req *models.UpdateEventRequest) (*models.Even
 		return nil, err
 	}
 
-	date, err := time.Parse("2006-01-02", req.Date)
+	if req.OccurrenceDate != "" {
+		return s.updateOccurrence(req)
+	}
+
+	event, existing, err := s.replacement(req)
+	if err != nil {
+		return nil, err
+	}
+
+	if err := s.update(event, existing, req.UserID); err != nil {
+		return nil, fmt.Errorf("failed to update event: %w", err)
+	}
+
+	return event, nil
+}
+
+// replacement проверяет запрос на обновление события целиком и возвращает
+// новое состояние события и текущее
+func (s *EventService) replacement(req *models.UpdateEventRequest) (*models.Event, *models.Event, error) {
+	rrule, err := normalizeRRule(req.RRule)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	reminders, err := normalizeReminders(req.Reminders)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	tags, err := normalizeTags(req.Tags)
 	if err != nil {
-		return nil, fmt.Errorf("invalid date format: %v", err)
+		return nil, nil, err
+	}
+
+	existing, err := s.authorize(req.ID, req.UserID, models.PermissionWrite)
+	if err != nil {
+		return nil, nil, fmt.Errorf("failed to update event: %w", err)
+	}
+	if existing.SeriesID != 0 && rrule != "" {
+		return nil, nil, apperrors.Field("rrule", "occurrence of a series cannot be recurring")
+	}
+
+	attendees, err := mergeAttendees(existing.Attendees, req.Attendees, existing.UserID)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	loc, err := s.location(req.UserID, req.TimeZone, existing.TimeZone)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	when, err := parseEventTime(req.Date, req.Start, req.End, req.Duration, req.AllDay, loc)
+	if err != nil {
+		return nil, nil, err
 	}
 
 	event := &models.Event{
-		ID:          req.ID,
-		UserID:      req.UserID,
-		Date:        date,
-		Title:       strings.TrimSpace(req.Title),
-		Description: strings.TrimSpace(req.Description),
+		ID:           req.ID,
+		UID:          existing.UID,
+		UserID:       existing.UserID,
+		Title:        strings.TrimSpace(req.Title),
+		Description:  strings.TrimSpace(req.Description),
+		RRule:        rrule,
+		SeriesID:     existing.SeriesID,
+		RecurrenceID: existing.RecurrenceID,
+		Reminders:    reminders,
+		Tags:         tags,
+		Attendees:    attendees,
+		Version:      expectedVersion(req.Version, existing),
+	}
+	when.apply(event)
+	if rrule != "" {
+		event.ExDates = slices.Clone(existing.ExDates)
 	}
 
-	if err := s.storage.Update(event); err != nil {
-		return nil, fmt.Errorf("failed to update event: %v", err)
+	if req.CheckConflicts {
+		if err := s.checkConflicts(event); err != nil {
+			return nil, nil, err
+		}
 	}
 
-	return event, nil
+	return event, existing, nil
+}
+
+// updateOccurrence изменяет один экземпляр серии: экземпляр исключается
+// из серии через EXDATE и сохраняется как отдельное событие
+func (s *EventService) updateOccurrence(req *models.UpdateEventRequest) (*models.Event, error) {
+	exception, series, err := s.occurrenceException(req)
+	if err != nil {
+		return nil, err
+	}
+
+	if err := s.storage.Create(exception); err != nil {
+		return nil, fmt.Errorf("failed to update event: %w", err)
+	}
+
+	err = s.update(exclusion(series, *exception.RecurrenceID, req.Version), series, req.UserID)
+	if err != nil && !errors.Is(err, errHistory) {
+		// Откатываем созданное исключение, чтобы экземпляр не задвоился
+		_ = s.storage.Delete(exception.ID, exception.UserID, 0)
+		return nil, fmt.Errorf("failed to update event: %w", err)
+	}
+	// Серия уже изменена, поэтому создание исключения публикуется
+	// и при ошибке записи ее истории
+	if err := errors.Join(err, s.publish(models.ChangeCreated, req.UserID, exception, nil)); err != nil {
+		return nil, fmt.Errorf("failed to update event: %w", err)
+	}
+
+	return exception, nil
+}
+
+// occurrenceException проверяет запрос на изменение экземпляра серии
+// и возвращает новое событие для экземпляра и серию
+func (s *EventService) occurrenceException(req *models.UpdateEventRequest) (*models.Event, *models.Event, error) {
+	if req.RRule != "" {
+		return nil, nil, apperrors.Field("rrule", "occurrence of a series cannot be recurring")
+	}
+
+	reminders, err := normalizeReminders(req.Reminders)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	tags, err := normalizeTags(req.Tags)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	series, occurrence, err := s.findOccurrence(req.ID, req.UserID, req.OccurrenceDate)
+	if err != nil {
+		return nil, nil, fmt.Errorf("failed to update event: %w", err)
+	}
+
+	attendees, err := mergeAttendees(series.Attendees, req.Attendees, series.UserID)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	loc, err := s.location(req.UserID, req.TimeZone, series.TimeZone)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	when, err := parseEventTime(req.Date, req.Start, req.End, req.Duration, req.AllDay, loc)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	// Измененный экземпляр делит UID с серией, как RECURRENCE-ID в iCalendar
+	exception := &models.Event{
+		UID:          series.UID,
+		UserID:       series.UserID,
+		Title:        strings.TrimSpace(req.Title),
+		Description:  strings.TrimSpace(req.Description),
+		SeriesID:     series.ID,
+		RecurrenceID: &occurrence,
+		Reminders:    reminders,
+		Tags:         tags,
+		Attendees:    attendees,
+	}
+	when.apply(exception)
+
+	if req.CheckConflicts {
+		if err := s.checkConflicts(exception); err != nil {
+			return nil, nil, err
+		}
+	}
+
+	return exception, series, nil
 }
 
 // DeleteEvent удаляет событие
 func (s *EventService) DeleteEvent(req *models.DeleteEventRequest) error {
-	if req.ID <= 0 {
-		return fmt.Errorf("invalid event ID")
-	}
-	if req.UserID <= 0 {
-		return fmt.Errorf("invalid user ID")
+	if err := s.validateDeleteRequest(req); err != nil {
+		return err
 	}
 
-	if err := s.storage.Delete(req.ID, req.UserID); err != nil {
-		return fmt.Errorf("failed to delete event: %v", err)
+	mutations, err := s.deletion(req)
+	if err != nil {
+		return fmt.Errorf("failed to delete event: %w", err)
+	}
+	for _, m := range mutations {
+		if err := s.commit(m); err != nil {
+			return fmt.Errorf("failed to delete event: %w", err)
+		}
 	}
 
 	return nil
 }
 
-// GetEventsForDay возвращает события на день
+// deletion возвращает изменения хранилища, которыми выполняется удаление:
+// исключение экземпляра из серии через EXDATE или удаление события,
+// а вместе с серией — и ее отдельно измененных экземпляров
+func (s *EventService) deletion(req *models.DeleteEventRequest) ([]mutation, error) {
+	if req.OccurrenceDate != "" {
+		series, occurrence, err := s.findOccurrence(req.ID, req.UserID, req.OccurrenceDate)
+		if err != nil {
+			return nil, err
+		}
+		return []mutation{updating(exclusion(series, occurrence, req.Version), series, req.UserID)}, nil
+	}
+
+	event, err := s.authorize(req.ID, req.UserID, models.PermissionWrite)
+	if err != nil {
+		return nil, err
+	}
+	exceptions, err := s.exceptionDeletions(event, req.UserID)
+	if err != nil {
+		return nil, err
+	}
+
+	return append([]mutation{s.deleting(event, expectedVersion(req.Version, event), req.UserID)}, exceptions...), nil
+}
+
+// exceptionDeletions возвращает удаление пользователем actor отдельно
+// измененных экземпляров серии
+func (s *EventService) exceptionDeletions(series *models.Event, actor int) ([]mutation, error) {
+	// Каждый измененный экземпляр исключен из серии через EXDATE
+	if !series.IsRecurring() || len(series.ExDates) == 0 {
+		return nil, nil
+	}
+
+	events, err := s.userEvents(series.UserID)
+	if err != nil {
+		return nil, err
+	}
+	var mutations []mutation
+	for _, exception := range events {
+		if exception.SeriesID == series.ID {
+			mutations = append(mutations, s.deleting(exception, 0, actor))
+		}
+	}
+	return mutations, nil
+}
+
+// orphanDeletions возвращает удаление экземпляров, которые остаются без
+// серии, когда у события before убирают RRULE
+func (s *EventService) orphanDeletions(event, before *models.Event, actor int) ([]mutation, error) {
+	if before == nil || event.IsRecurring() {
+		return nil, nil
+	}
+	return s.exceptionDeletions(before, actor)
+}
+
+// findOccurrence находит серию, которую пользователь может изменять, и ее
+// экземпляр. occurrence задается либо точным временем начала в RFC 3339,
+// либо датой — тогда берется первый экземпляр в этот день.
+func (s *EventService) findOccurrence(id, userID int, occurrence string) (*models.Event, time.Time, error) {
+	series, err := s.authorize(id, userID, models.PermissionWrite)
+	if err != nil {
+		return nil, time.Time{}, err
+	}
+	if !series.IsRecurring() {
+		return nil, time.Time{}, apperrors.Field("occurrence_date", "event is not recurring")
+	}
+
+	rule, err := recurrence.Parse(series.RRule)
+	if err != nil {
+		return nil, time.Time{}, err
+	}
+
+	from, to, err := parseOccurrence(occurrence, eventLocation(series))
+	if err != nil {
+		return nil, time.Time{}, err
+	}
+
+	occurrences, err := rule.Between(series.Start.In(eventLocation(series)), from, to, series.ExDates)
+	if err != nil {
+		return nil, time.Time{}, fmt.Errorf("failed to find occurrence: %w", err)
+	}
+	if len(occurrences) == 0 {
+		return nil, time.Time{}, apperrors.Errorf(apperrors.ErrNotFound, "series has no occurrence at %s", occurrence)
+	}
+
+	return series, occurrences[0], nil
+}
+
+// expectedVersion возвращает версию, при которой допустимо изменение события:
+// переданную клиентом или прочитанную сервисом, чтобы изменение,
+// сделанное между чтением и записью, не было потеряно
+func expectedVersion(requested int, current *models.Event) int {
+	if requested != 0 {
+		return requested
+	}
+	return current.Version
+}
+
+// exclusion возвращает серию с экземпляром, добавленным в EXDATE; изменение
+// допустимо, если серия не изменилась с версии version (0 — с прочитанной версии)
+func exclusion(series *models.Event, occurrence time.Time, version int) *models.Event {
+	updated := *series
+	updated.ExDates = append(slices.Clone(series.ExDates), occurrence)
+	updated.Version = expectedVersion(version, series)
+	return &updated
+}
+
+// GetEventsForDay возвращает события на день. Границы дня вычисляются
+// в часовом поясе date, в нем же возвращается время событий.
 func (s *EventService) GetEventsForDay(userID int, date time.Time) ([]*models.Event, error) {
 	if userID <= 0 {
-		return nil, fmt.Errorf("invalid user ID")
+		return nil, apperrors.Field("user_id", "invalid user ID")
 	}
 
-	start := time.Date(date.Year(), date.Month(), date.Day(), 0, 0, 0, 0, time.UTC)
-	end := start
+	start := startOfDay(date)
+	end := start.AddDate(0, 0, 1)
 
-	return s.storage.GetByDateRange(userID, start, end)
+	return s.getEvents(userID, start, end)
 }
 
-// GetEventsForWeek возвращает события на неделю
+// GetEventsForWeek возвращает события на неделю (с понедельника)
+// в часовом поясе date
 func (s *EventService) GetEventsForWeek(userID int, date time.Time) ([]*models.Event, error) {
 	if userID <= 0 {
-		return nil, fmt.Errorf("invalid user ID")
+		return nil, apperrors.Field("user_id", "invalid user ID")
 	}
 
 	// Находим начало недели (понедельник)
@@ -108,57 +517,308 @@ func (s *EventService) GetEventsForWeek(userID int, date time.Time) ([]*models.E
 	if weekday == time.Sunday {
 		weekday = 7
 	}
-	start := date.AddDate(0, 0, -int(weekday-1))
-	start = time.Date(start.Year(), start.Month(), start.Day(), 0, 0, 0, 0, time.UTC)
+	start := startOfDay(date).AddDate(0, 0, -int(weekday-1))
 
-	// Конец недели (воскресенье)
-	end := start.AddDate(0, 0, 6)
+	// Конец недели (начало следующего понедельника)
+	end := start.AddDate(0, 0, 7)
 
-	return s.storage.GetByDateRange(userID, start, end)
+	return s.getEvents(userID, start, end)
 }
 
-// GetEventsForMonth возвращает события на месяц
+// GetEventsForMonth возвращает события на месяц в часовом поясе date
 func (s *EventService) GetEventsForMonth(userID int, date time.Time) ([]*models.Event, error) {
 	if userID <= 0 {
-		return nil, fmt.Errorf("invalid user ID")
+		return nil, apperrors.Field("user_id", "invalid user ID")
 	}
 
 	// Начало месяца
-	start := time.Date(date.Year(), date.Month(), 1, 0, 0, 0, 0, time.UTC)
+	start := time.Date(date.Year(), date.Month(), 1, 0, 0, 0, 0, date.Location())
+
+	// Конец месяца (начало следующего)
+	end := start.AddDate(0, 1, 0)
+
+	return s.getEvents(userID, start, end)
+}
+
+// GetEvents возвращает события пользователя, пересекающиеся с полуинтервалом
+// [start, end), разворачивая серии. Время событий — в часовом поясе start.
+func (s *EventService) GetEvents(userID int, start, end time.Time) ([]*models.Event, error) {
+	if userID <= 0 {
+		return nil, apperrors.Field("user_id", "invalid user ID")
+	}
+	if err := s.checkRange(start, end); err != nil {
+		return nil, err
+	}
+
+	return s.getEvents(userID, start, end)
+}
+
+// SetMaxRange задает наибольшую длину диапазона выборки событий
+func (s *EventService) SetMaxRange(maxRange time.Duration) {
+	s.maxRange = maxRange
+}
+
+// checkRange проверяет, что диапазон выборки не пуст и не длиннее maxRange
+func (s *EventService) checkRange(start, end time.Time) error {
+	switch {
+	case !start.Before(end):
+		return apperrors.Errorf(apperrors.ErrValidation, "invalid date range")
+	case end.Sub(start) > s.maxRange:
+		return apperrors.Field("to", "date range must not exceed %d days", int(s.maxRange.Hours()/24))
+	}
+	return nil
+}
+
+// GetEvent возвращает событие по ID, если пользователь может его читать
+func (s *EventService) GetEvent(id, userID int) (*models.Event, error) {
+	if id <= 0 {
+		return nil, apperrors.Field("id", "invalid event ID")
+	}
+	if userID <= 0 {
+		return nil, apperrors.Field("user_id", "invalid user ID")
+	}
+
+	event, err := s.authorize(id, userID, models.PermissionRead)
+	if err != nil {
+		return nil, fmt.Errorf("failed to get event: %w", err)
+	}
+
+	return localize([]*models.Event{event}, eventLocation(event))[0], nil
+}
+
+// userEvents возвращает все события пользователя без разворачивания серий
+func (s *EventService) userEvents(userID int) ([]*models.Event, error) {
+	return s.storage.GetByDateRange(userID, time.Time{}, maxDate)
+}
+
+// getEvents возвращает события пользователя и приглашения, пересекающиеся
+// с полуинтервалом [start, end)
+func (s *EventService) getEvents(userID int, start, end time.Time) ([]*models.Event, error) {
+	events, err := s.storage.GetByDateRange(userID, start, end)
+	if err != nil {
+		return nil, err
+	}
+
+	if s.sharing != nil {
+		invitations, err := s.sharing.GetInvitations(userID, start, end)
+		if err != nil {
+			return nil, err
+		}
+		events = append(events, invitations...)
+	}
+
+	return expandEvents(events, start, end)
+}
+
+// expandEvents разворачивает повторяющиеся серии в экземпляры, пересекающиеся
+// с [start, end), и возвращает события в хронологическом порядке
+// со временем в часовом поясе start
+func expandEvents(events []*models.Event, start, end time.Time) ([]*models.Event, error) {
+	events, err := expandOccurrences(events, start, end)
+	if err != nil {
+		return nil, err
+	}
+	models.SortByStart(events)
+
+	return localize(events, start.Location()), nil
+}
+
+// localize возвращает копии событий со временем в часовом поясе loc
+func localize(events []*models.Event, loc *time.Location) []*models.Event {
+	result := make([]*models.Event, 0, len(events))
+	for _, event := range events {
+		local := *event
+		local.Start = event.Start.In(loc)
+		local.End = event.End.In(loc)
+		local.Date = startOfDay(local.Start)
+		if local.TimeZone == "" {
+			local.TimeZone = time.UTC.String()
+		}
+		result = append(result, &local)
+	}
+	return result
+}
+
+// expandOccurrences заменяет серии их экземплярами, пересекающимися с [from, to).
+// Если экземпляров больше maxOccurrences, возвращает ErrValidation, а не
+// часть списка.
+func expandOccurrences(events []*models.Event, from, to time.Time) ([]*models.Event, error) {
+	var result []*models.Event
+	expanded := 0
+
+	for _, event := range events {
+		if !event.IsRecurring() {
+			result = append(result, event)
+			continue
+		}
+
+		rule, err := recurrence.Parse(event.RRule)
+		if err != nil {
+			return nil, fmt.Errorf("event %d has invalid recurrence rule: %v", event.ID, err)
+		}
+
+		// Экземпляры вычисляются в часовом поясе события, чтобы при переходе
+		// на летнее время сохранялось местное время начала.
+		// Экземпляр, начавшийся до from, может еще продолжаться.
+		dtstart := event.Start.In(eventLocation(event))
+		duration := event.Duration()
+		occurrences, err := rule.Between(dtstart, from.Add(-duration), to, event.ExDates)
+		expanded += len(occurrences)
+		if errors.Is(err, recurrence.ErrTooManyOccurrences) || expanded > maxOccurrences {
+			return nil, tooManyOccurrences()
+		}
+		if err != nil {
+			return nil, fmt.Errorf("failed to expand event %d: %w", event.ID, err)
+		}
+		for _, occurrence := range occurrences {
+			instance := *event
+			instance.Start = occurrence
+			instance.End = occurrence.Add(duration)
+			instance.Date = startOfDay(occurrence)
+			instance.RecurrenceID = &occurrence
+			if instance.Overlaps(from, to) {
+				result = append(result, &instance)
+			}
+		}
+	}
+
+	return result, nil
+}
+
+// tooManyOccurrences возвращает ошибку запроса, в диапазон которого
+// попадает больше maxOccurrences экземпляров серий
+func tooManyOccurrences() error {
+	return apperrors.Errorf(apperrors.ErrValidation, "range contains more than %d occurrences of recurring events, narrow the range", maxOccurrences)
+}
+
+// hasOccurrence сообщает, пересекается ли с [from, to) хотя бы один
+// экземпляр серии. Экземпляры не накапливаются, поэтому диапазон может
+// быть любой длины.
+func hasOccurrence(series *models.Event, from, to time.Time) (bool, error) {
+	rule, err := recurrence.Parse(series.RRule)
+	if err != nil {
+		return false, fmt.Errorf("event %d has invalid recurrence rule: %v", series.ID, err)
+	}
+
+	duration := series.Duration()
+	found := false
+	err = rule.Each(series.Start.In(eventLocation(series)), from.Add(-duration), to, series.ExDates, func(occurrence time.Time) bool {
+		instance := *series
+		instance.Start = occurrence
+		instance.End = occurrence.Add(duration)
+		found = instance.Overlaps(from, to)
+		return !found
+	})
+	if err != nil {
+		return false, fmt.Errorf("failed to expand event %d: %w", series.ID, err)
+	}
+	return found, nil
+}
 
-	// Конец месяца
-	end := start.AddDate(0, 1, -1)
+// normalizeRRule проверяет правило повторения и приводит его к каноническому виду
+func normalizeRRule(rrule string) (string, error) {
+	if strings.TrimSpace(rrule) == "" {
+		return "", nil
+	}
+
+	rule, err := recurrence.Parse(rrule)
+	if err != nil {
+		return "", apperrors.Field("rrule", "invalid recurrence rule: %v", err)
+	}
+
+	return rule.String(), nil
+}
+
+// SetUserTimeZone устанавливает часовой пояс пользователя по умолчанию
+func (s *EventService) SetUserTimeZone(req *models.SetTimeZoneRequest) error {
+	if req.UserID <= 0 {
+		return apperrors.Field("user_id", "invalid user ID")
+	}
+	if s.users == nil {
+		return fmt.Errorf("user settings are not supported by storage")
+	}
+
+	loc, err := loadLocation(req.TimeZone)
+	if err != nil {
+		return err
+	}
+
+	if err := s.users.SetTimeZone(req.UserID, loc.String()); err != nil {
+		return fmt.Errorf("failed to set time zone: %w", err)
+	}
+
+	return nil
+}
+
+// ResolveLocation возвращает часовой пояс для запроса пользователя:
+// явно переданный tz, иначе часовой пояс пользователя, иначе UTC
+func (s *EventService) ResolveLocation(userID int, tz string) (*time.Location, error) {
+	return s.location(userID, tz)
+}
+
+// location возвращает первый непустой из часовых поясов zones,
+// а если все пусты — часовой пояс пользователя или UTC
+func (s *EventService) location(userID int, zones ...string) (*time.Location, error) {
+	for _, tz := range zones {
+		if tz != "" {
+			return loadLocation(tz)
+		}
+	}
+
+	if s.users != nil {
+		tz, err := s.users.GetTimeZone(userID)
+		if err != nil {
+			return nil, fmt.Errorf("failed to get user time zone: %w", err)
+		}
+		if tz != "" {
+			return loadLocation(tz)
+		}
+	}
 
-	return s.storage.GetByDateRange(userID, start, end)
+	return time.UTC, nil
 }
 
 // validateCreateRequest валидирует запрос на создание события
 func (s *EventService) validateCreateRequest(req *models.CreateEventRequest) error {
+	v := &apperrors.ValidationError{}
 	if req.UserID <= 0 {
-		return fmt.Errorf("invalid user ID")
+		v.Add("user_id", "invalid user ID")
 	}
 	if strings.TrimSpace(req.Title) == "" {
-		return fmt.Errorf("title is required")
+		v.Add("title", "title is required")
 	}
-	if req.Date == "" {
-		return fmt.Errorf("date is required")
+	if req.Date == "" && req.Start == "" {
+		v.Add("date", "date or start is required")
 	}
-	return nil
+	return v.Err()
 }
 
 // validateUpdateRequest валидирует запрос на обновление события
 func (s *EventService) validateUpdateRequest(req *models.UpdateEventRequest) error {
+	v := &apperrors.ValidationError{}
 	if req.ID <= 0 {
-		return fmt.Errorf("invalid event ID")
+		v.Add("id", "invalid event ID")
 	}
 	if req.UserID <= 0 {
-		return fmt.Errorf("invalid user ID")
+		v.Add("user_id", "invalid user ID")
 	}
 	if strings.TrimSpace(req.Title) == "" {
-		return fmt.Errorf("title is required")
+		v.Add("title", "title is required")
 	}
-	if req.Date == "" {
-		return fmt.Errorf("date is required")
+	if req.Date == "" && req.Start == "" {
+		v.Add("date", "date or start is required")
 	}
-	return nil
+	return v.Err()
+}
+
+// validateDeleteRequest валидирует запрос на удаление события
+func (s *EventService) validateDeleteRequest(req *models.DeleteEventRequest) error {
+	v := &apperrors.ValidationError{}
+	if req.ID <= 0 {
+		v.Add("id", "invalid event ID")
+	}
+	if req.UserID <= 0 {
+		v.Add("user_id", "invalid user ID")
+	}
+	return v.Err()
 }
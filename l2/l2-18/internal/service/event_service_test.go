@@ -1,341 +1,1706 @@
 package service
 
 import (
+	"errors"
+	"l2-18/internal/apperrors"
 	"l2-18/internal/models"
 	"l2-18/internal/storage"
+	"path/filepath"
+	"slices"
 	"testing"
 	"time"
 )
 
-func TestEventService_CreateEvent(t *testing.T) {
-	strg := storage.NewInMemoryEventStorage()
-	service := NewEventService(strg)
-
-	tests := []struct {
-		name    string
-		req     *models.CreateEventRequest
-		wantErr bool
+// forEachStorage запускает тест для каждой реализации хранилища
+func forEachStorage(t *testing.T, test func(t *testing.T, strg storage.EventStorage)) {
+	backends := []struct {
+		name string
+		open func(t *testing.T) storage.EventStorage
 	}{
 		{
-			name: "valid event",
-			req: &models.CreateEventRequest{
-				UserID:      1,
-				Date:        "2023-12-31",
-				Title:       "New Year Party",
-				Description: "Celebrate new year",
-			},
-			wantErr: false,
-		},
-		{
-			name: "invalid user ID",
-			req: &models.CreateEventRequest{
-				UserID:      0,
-				Date:        "2023-12-31",
-				Title:       "New Year Party",
-				Description: "Celebrate new year",
+			name: "memory",
+			open: func(t *testing.T) storage.EventStorage {
+				return storage.NewInMemoryEventStorage()
 			},
-			wantErr: true,
 		},
 		{
-			name: "empty title",
-			req: &models.CreateEventRequest{
-				UserID:      1,
-				Date:        "2023-12-31",
-				Title:       "",
-				Description: "Celebrate new year",
+			name: "file",
+			open: func(t *testing.T) storage.EventStorage {
+				strg, err := storage.NewFileEventStorage(filepath.Join(t.TempDir(), "events.json"))
+				if err != nil {
+					t.Fatal("Failed to open file storage:", err)
+				}
+				return strg
 			},
-			wantErr: true,
 		},
 		{
-			name: "invalid date format",
-			req: &models.CreateEventRequest{
-				UserID:      1,
-				Date:        "31-12-2023",
-				Title:       "New Year Party",
-				Description: "Celebrate new year",
+			name: "wal",
+			open: func(t *testing.T) storage.EventStorage {
+				// Частое сворачивание журнала проверяет и снимки, и журнал
+				strg, err := storage.NewWALEventStorage(filepath.Join(t.TempDir(), "events.json"), 3)
+				if err != nil {
+					t.Fatal("Failed to open WAL storage:", err)
+				}
+				t.Cleanup(func() { strg.Close() })
+				return strg
 			},
-			wantErr: true,
 		},
 	}
 
-	for _, tt := range tests {
-		t.Run(tt.name, func(t *testing.T) {
-			event, err := service.CreateEvent(tt.req)
-			if (err != nil) != tt.wantErr {
-				t.Errorf("CreateEvent() error = %v, wantErr %v", err, tt.wantErr)
-				return
-			}
-			if !tt.wantErr {
-				if event == nil {
-					t.Error("CreateEvent() returned nil event")
+	for _, backend := range backends {
+		t.Run(backend.name, func(t *testing.T) {
+			test(t, backend.open(t))
+		})
+	}
+}
+
+func TestEventService_CreateEvent(t *testing.T) {
+	forEachStorage(t, func(t *testing.T, strg storage.EventStorage) {
+		service := NewEventService(strg)
+
+		tests := []struct {
+			name    string
+			req     *models.CreateEventRequest
+			wantErr bool
+		}{
+			{
+				name: "valid event",
+				req: &models.CreateEventRequest{
+					UserID:      1,
+					Date:        "2023-12-31",
+					Title:       "New Year Party",
+					Description: "Celebrate new year",
+				},
+				wantErr: false,
+			},
+			{
+				name: "invalid user ID",
+				req: &models.CreateEventRequest{
+					UserID:      0,
+					Date:        "2023-12-31",
+					Title:       "New Year Party",
+					Description: "Celebrate new year",
+				},
+				wantErr: true,
+			},
+			{
+				name: "empty title",
+				req: &models.CreateEventRequest{
+					UserID:      1,
+					Date:        "2023-12-31",
+					Title:       "",
+					Description: "Celebrate new year",
+				},
+				wantErr: true,
+			},
+			{
+				name: "invalid date format",
+				req: &models.CreateEventRequest{
+					UserID:      1,
+					Date:        "31-12-2023",
+					Title:       "New Year Party",
+					Description: "Celebrate new year",
+				},
+				wantErr: true,
+			},
+		}
+
+		for _, tt := range tests {
+			t.Run(tt.name, func(t *testing.T) {
+				event, err := service.CreateEvent(tt.req)
+				if (err != nil) != tt.wantErr {
+					t.Errorf("CreateEvent() error = %v, wantErr %v", err, tt.wantErr)
 					return
 				}
-				if event.ID == 0 {
-					t.Error("CreateEvent() event ID not set")
-				}
-				if event.CreatedAt.IsZero() {
-					t.Error("CreateEvent() CreatedAt not set")
+				if !tt.wantErr {
+					if event == nil {
+						t.Error("CreateEvent() returned nil event")
+						return
+					}
+					if event.ID == 0 {
+						t.Error("CreateEvent() event ID not set")
+					}
+					if event.CreatedAt.IsZero() {
+						t.Error("CreateEvent() CreatedAt not set")
+					}
 				}
-			}
-		})
-	}
+			})
+		}
+	})
 }
 
 func TestEventService_UpdateEvent(t *testing.T) {
-	strg := storage.NewInMemoryEventStorage()
-	service := NewEventService(strg)
+	forEachStorage(t, func(t *testing.T, strg storage.EventStorage) {
+		service := NewEventService(strg)
 
-	// Создаем событие для обновления
-	createReq := &models.CreateEventRequest{
-		UserID:      1,
-		Date:        "2023-12-31",
-		Title:       "Original Title",
-		Description: "Original Description",
-	}
-	event, err := service.CreateEvent(createReq)
-	if err != nil {
-		t.Fatal("Failed to create event for test:", err)
-	}
+		// Создаем событие для обновления
+		createReq := &models.CreateEventRequest{
+			UserID:      1,
+			Date:        "2023-12-31",
+			Title:       "Original Title",
+			Description: "Original Description",
+		}
+		event, err := service.CreateEvent(createReq)
+		if err != nil {
+			t.Fatal("Failed to create event for test:", err)
+		}
 
-	tests := []struct {
-		name    string
-		req     *models.UpdateEventRequest
-		wantErr bool
-	}{
-		{
-			name: "valid update",
-			req: &models.UpdateEventRequest{
-				ID:          event.ID,
-				UserID:      1,
-				Date:        "2024-01-01",
-				Title:       "Updated Title",
-				Description: "Updated Description",
+		tests := []struct {
+			name    string
+			req     *models.UpdateEventRequest
+			wantErr bool
+		}{
+			{
+				name: "valid update",
+				req: &models.UpdateEventRequest{
+					ID:          event.ID,
+					UserID:      1,
+					Date:        "2024-01-01",
+					Title:       "Updated Title",
+					Description: "Updated Description",
+				},
+				wantErr: false,
 			},
-			wantErr: false,
-		},
-		{
-			name: "non-existent event",
-			req: &models.UpdateEventRequest{
-				ID:          9999,
-				UserID:      1,
-				Date:        "2024-01-01",
-				Title:       "Updated Title",
-				Description: "Updated Description",
+			{
+				name: "non-existent event",
+				req: &models.UpdateEventRequest{
+					ID:          9999,
+					UserID:      1,
+					Date:        "2024-01-01",
+					Title:       "Updated Title",
+					Description: "Updated Description",
+				},
+				wantErr: true,
 			},
-			wantErr: true,
-		},
-		{
-			name: "wrong user",
-			req: &models.UpdateEventRequest{
-				ID:          event.ID,
-				UserID:      2,
-				Date:        "2024-01-01",
-				Title:       "Updated Title",
-				Description: "Updated Description",
+			{
+				name: "wrong user",
+				req: &models.UpdateEventRequest{
+					ID:          event.ID,
+					UserID:      2,
+					Date:        "2024-01-01",
+					Title:       "Updated Title",
+					Description: "Updated Description",
+				},
+				wantErr: true,
 			},
-			wantErr: true,
-		},
-	}
+		}
 
-	for _, tt := range tests {
-		t.Run(tt.name, func(t *testing.T) {
-			updatedEvent, err := service.UpdateEvent(tt.req)
-			if (err != nil) != tt.wantErr {
-				t.Errorf("UpdateEvent() error = %v, wantErr %v", err, tt.wantErr)
-				return
-			}
-			if !tt.wantErr {
-				if updatedEvent == nil {
-					t.Error("UpdateEvent() returned nil event")
+		for _, tt := range tests {
+			t.Run(tt.name, func(t *testing.T) {
+				updatedEvent, err := service.UpdateEvent(tt.req)
+				if (err != nil) != tt.wantErr {
+					t.Errorf("UpdateEvent() error = %v, wantErr %v", err, tt.wantErr)
 					return
 				}
-				if updatedEvent.Title != tt.req.Title {
-					t.Errorf("UpdateEvent() title = %v, want %v", updatedEvent.Title, tt.req.Title)
+				if !tt.wantErr {
+					if updatedEvent == nil {
+						t.Error("UpdateEvent() returned nil event")
+						return
+					}
+					if updatedEvent.Title != tt.req.Title {
+						t.Errorf("UpdateEvent() title = %v, want %v", updatedEvent.Title, tt.req.Title)
+					}
 				}
-			}
-		})
-	}
+			})
+		}
+	})
 }
 
 func TestEventService_DeleteEvent(t *testing.T) {
-	strg := storage.NewInMemoryEventStorage()
-	service := NewEventService(strg)
+	forEachStorage(t, func(t *testing.T, strg storage.EventStorage) {
+		service := NewEventService(strg)
 
-	// Создаем событие для удаления
-	createReq := &models.CreateEventRequest{
-		UserID:      1,
-		Date:        "2023-12-31",
-		Title:       "Event to Delete",
-		Description: "This will be deleted",
-	}
-	event, err := service.CreateEvent(createReq)
-	if err != nil {
-		t.Fatal("Failed to create event for test:", err)
-	}
+		// Создаем событие для удаления
+		createReq := &models.CreateEventRequest{
+			UserID:      1,
+			Date:        "2023-12-31",
+			Title:       "Event to Delete",
+			Description: "This will be deleted",
+		}
+		event, err := service.CreateEvent(createReq)
+		if err != nil {
+			t.Fatal("Failed to create event for test:", err)
+		}
 
-	tests := []struct {
-		name    string
-		req     *models.DeleteEventRequest
-		wantErr bool
-	}{
-		{
-			name: "valid deletion",
-			req: &models.DeleteEventRequest{
-				ID:     event.ID,
-				UserID: 1,
+		tests := []struct {
+			name    string
+			req     *models.DeleteEventRequest
+			wantErr bool
+		}{
+			{
+				name: "valid deletion",
+				req: &models.DeleteEventRequest{
+					ID:     event.ID,
+					UserID: 1,
+				},
+				wantErr: false,
 			},
-			wantErr: false,
-		},
-		{
-			name: "non-existent event",
-			req: &models.DeleteEventRequest{
-				ID:     9999,
-				UserID: 1,
+			{
+				name: "non-existent event",
+				req: &models.DeleteEventRequest{
+					ID:     9999,
+					UserID: 1,
+				},
+				wantErr: true,
 			},
-			wantErr: true,
-		},
-		{
-			name: "wrong user",
-			req: &models.DeleteEventRequest{
-				ID:     event.ID,
-				UserID: 2,
+			{
+				name: "wrong user",
+				req: &models.DeleteEventRequest{
+					ID:     event.ID,
+					UserID: 2,
+				},
+				wantErr: true,
 			},
-			wantErr: true,
-		},
-	}
+		}
 
-	for _, tt := range tests {
-		t.Run(tt.name, func(t *testing.T) {
-			err := service.DeleteEvent(tt.req)
-			if (err != nil) != tt.wantErr {
-				t.Errorf("DeleteEvent() error = %v, wantErr %v", err, tt.wantErr)
-			}
-		})
-	}
+		for _, tt := range tests {
+			t.Run(tt.name, func(t *testing.T) {
+				err := service.DeleteEvent(tt.req)
+				if (err != nil) != tt.wantErr {
+					t.Errorf("DeleteEvent() error = %v, wantErr %v", err, tt.wantErr)
+				}
+			})
+		}
+	})
 }
 
 func TestEventService_GetEventsForDay(t *testing.T) {
-	strg := storage.NewInMemoryEventStorage()
-	service := NewEventService(strg)
+	forEachStorage(t, func(t *testing.T, strg storage.EventStorage) {
+		service := NewEventService(strg)
 
-	// Создаем несколько событий
-	events := []struct {
-		userID int
-		date   string
-		title  string
-	}{
-		{1, "2023-12-31", "Event 1"},
-		{1, "2023-12-31", "Event 2"},
-		{1, "2024-01-01", "Event 3"},
-		{2, "2023-12-31", "Event 4"},
-	}
+		// Создаем несколько событий
+		events := []struct {
+			userID int
+			date   string
+			title  string
+		}{
+			{1, "2023-12-31", "Event 1"},
+			{1, "2023-12-31", "Event 2"},
+			{1, "2024-01-01", "Event 3"},
+			{2, "2023-12-31", "Event 4"},
+		}
+
+		for _, e := range events {
+			_, err := service.CreateEvent(&models.CreateEventRequest{
+				UserID: e.userID,
+				Date:   e.date,
+				Title:  e.title,
+			})
+			if err != nil {
+				t.Fatal("Failed to create event:", err)
+			}
+		}
+
+		testDate, _ := time.Parse("2006-01-02", "2023-12-31")
+
+		// Тест получения событий пользователя 1 на 31.12.2023
+		dayEvents, err := service.GetEventsForDay(1, testDate)
+		if err != nil {
+			t.Error("GetEventsForDay() error:", err)
+		}
+
+		if len(dayEvents) != 2 {
+			t.Errorf("GetEventsForDay() got %d events, want 2", len(dayEvents))
+		}
+
+		// Тест для несуществующего пользователя
+		_, err = service.GetEventsForDay(0, testDate)
+		if err == nil {
+			t.Error("GetEventsForDay() should return error for invalid user ID")
+		}
+	})
+}
+
+func TestEventService_GetEventsForWeek(t *testing.T) {
+	forEachStorage(t, func(t *testing.T, strg storage.EventStorage) {
+		service := NewEventService(strg)
+
+		// Создаем события на разные дни недели
+		// 2023-12-31 - воскресенье (конец недели)
+		// 2023-12-25 - понедельник (начало той же недели)
+		events := []struct {
+			date  string
+			title string
+		}{
+			{"2023-12-25", "Monday Event"},    // Понедельник
+			{"2023-12-27", "Wednesday Event"}, // Среда
+			{"2023-12-31", "Sunday Event"},    // Воскресенье
+			{"2024-01-01", "Next Week"},       // Следующая неделя
+		}
+
+		for _, e := range events {
+			_, err := service.CreateEvent(&models.CreateEventRequest{
+				UserID: 1,
+				Date:   e.date,
+				Title:  e.title,
+			})
+			if err != nil {
+				t.Fatal("Failed to create event:", err)
+			}
+		}
+
+		// Запрашиваем события на неделю, содержащую 31.12.2023
+		testDate, _ := time.Parse("2006-01-02", "2023-12-31")
+		weekEvents, err := service.GetEventsForWeek(1, testDate)
+		if err != nil {
+			t.Error("GetEventsForWeek() error:", err)
+		}
+
+		// Должны получить 3 события (25, 27, 31 декабря)
+		if len(weekEvents) != 3 {
+			t.Errorf("GetEventsForWeek() got %d events, want 3", len(weekEvents))
+		}
+	})
+}
+
+func TestEventService_GetEventsForMonth(t *testing.T) {
+	forEachStorage(t, func(t *testing.T, strg storage.EventStorage) {
+		service := NewEventService(strg)
+
+		// Создаем события в декабре и январе
+		events := []struct {
+			date  string
+			title string
+		}{
+			{"2023-12-01", "December Start"},
+			{"2023-12-15", "December Middle"},
+			{"2023-12-31", "December End"},
+			{"2024-01-01", "January Start"},
+		}
+
+		for _, e := range events {
+			_, err := service.CreateEvent(&models.CreateEventRequest{
+				UserID: 1,
+				Date:   e.date,
+				Title:  e.title,
+			})
+			if err != nil {
+				t.Fatal("Failed to create event:", err)
+			}
+		}
+
+		// Запрашиваем события декабря 2023
+		testDate, _ := time.Parse("2006-01-02", "2023-12-15")
+		monthEvents, err := service.GetEventsForMonth(1, testDate)
+		if err != nil {
+			t.Error("GetEventsForMonth() error:", err)
+		}
+
+		// Должны получить 3 события декабря
+		if len(monthEvents) != 3 {
+			t.Errorf("GetEventsForMonth() got %d events, want 3", len(monthEvents))
+		}
+	})
+}
 
-	for _, e := range events {
-		_, err := service.CreateEvent(&models.CreateEventRequest{
-			UserID: e.userID,
-			Date:   e.date,
-			Title:  e.title,
+func TestEventService_RecurringEvents(t *testing.T) {
+	forEachStorage(t, func(t *testing.T, strg storage.EventStorage) {
+		service := NewEventService(strg)
+
+		// Еженедельная встреча по понедельникам и средам с 1 января 2024
+		series, err := service.CreateEvent(&models.CreateEventRequest{
+			UserID: 1,
+			Date:   "2024-01-01",
+			Title:  "Standup",
+			RRule:  "FREQ=WEEKLY;BYDAY=MO,WE",
 		})
 		if err != nil {
-			t.Fatal("Failed to create event:", err)
+			t.Fatal("Failed to create series:", err)
 		}
-	}
 
-	testDate, _ := time.Parse("2006-01-02", "2023-12-31")
+		january, _ := time.Parse("2006-01-02", "2024-01-15")
+		monthEvents, err := service.GetEventsForMonth(1, january)
+		if err != nil {
+			t.Fatal("GetEventsForMonth() error:", err)
+		}
+		// Понедельники и среды января 2024: 1, 3, 8, 10, 15, 17, 22, 24, 29, 31
+		if len(monthEvents) != 10 {
+			t.Errorf("GetEventsForMonth() got %d occurrences, want 10", len(monthEvents))
+		}
 
-	// Тест получения событий пользователя 1 на 31.12.2023
-	dayEvents, err := service.GetEventsForDay(1, testDate)
-	if err != nil {
-		t.Error("GetEventsForDay() error:", err)
-	}
+		// Переносим экземпляр 10 января на 11 января
+		moved, err := service.UpdateEvent(&models.UpdateEventRequest{
+			ID:             series.ID,
+			UserID:         1,
+			Date:           "2024-01-11",
+			Title:          "Moved standup",
+			OccurrenceDate: "2024-01-10",
+		})
+		if err != nil {
+			t.Fatal("UpdateEvent() occurrence error:", err)
+		}
+		if moved.SeriesID != series.ID {
+			t.Errorf("UpdateEvent() exception SeriesID = %d, want %d", moved.SeriesID, series.ID)
+		}
 
-	if len(dayEvents) != 2 {
-		t.Errorf("GetEventsForDay() got %d events, want 2", len(dayEvents))
-	}
+		// Удаляем экземпляр 8 января
+		err = service.DeleteEvent(&models.DeleteEventRequest{
+			ID:             series.ID,
+			UserID:         1,
+			OccurrenceDate: "2024-01-08",
+		})
+		if err != nil {
+			t.Fatal("DeleteEvent() occurrence error:", err)
+		}
 
-	// Тест для несуществующего пользователя
-	_, err = service.GetEventsForDay(0, testDate)
-	if err == nil {
-		t.Error("GetEventsForDay() should return error for invalid user ID")
-	}
+		weekDate, _ := time.Parse("2006-01-02", "2024-01-08")
+		weekEvents, err := service.GetEventsForWeek(1, weekDate)
+		if err != nil {
+			t.Fatal("GetEventsForWeek() error:", err)
+		}
+		if len(weekEvents) != 1 || weekEvents[0].Title != "Moved standup" {
+			t.Errorf("GetEventsForWeek() got %v, want only the moved occurrence", weekEvents)
+		}
+
+		// Несуществующий экземпляр серии
+		err = service.DeleteEvent(&models.DeleteEventRequest{
+			ID:             series.ID,
+			UserID:         1,
+			OccurrenceDate: "2024-01-09",
+		})
+		if err == nil {
+			t.Error("DeleteEvent() should fail for a date without occurrence")
+		}
+
+		// Удаление всей серии удаляет и измененные экземпляры
+		if err := service.DeleteEvent(&models.DeleteEventRequest{ID: series.ID, UserID: 1}); err != nil {
+			t.Fatal("DeleteEvent() series error:", err)
+		}
+		monthEvents, err = service.GetEventsForMonth(1, january)
+		if err != nil {
+			t.Fatal("GetEventsForMonth() error:", err)
+		}
+		if len(monthEvents) != 0 {
+			t.Errorf("GetEventsForMonth() got %d events after series deletion, want 0", len(monthEvents))
+		}
+	})
 }
 
-func TestEventService_GetEventsForWeek(t *testing.T) {
-	strg := storage.NewInMemoryEventStorage()
-	service := NewEventService(strg)
+func TestEventService_RemoveRRule(t *testing.T) {
+	forEachStorage(t, func(t *testing.T, strg storage.EventStorage) {
+		service := NewEventService(strg)
 
-	// Создаем события на разные дни недели
-	// 2023-12-31 - воскресенье (конец недели)
-	// 2023-12-25 - понедельник (начало той же недели)
-	events := []struct {
-		date  string
-		title string
-	}{
-		{"2023-12-25", "Monday Event"},    // Понедельник
-		{"2023-12-27", "Wednesday Event"}, // Среда
-		{"2023-12-31", "Sunday Event"},    // Воскресенье
-		{"2024-01-01", "Next Week"},       // Следующая неделя
-	}
+		series, err := service.CreateEvent(&models.CreateEventRequest{
+			UserID: 1,
+			Date:   "2024-01-01",
+			Title:  "Standup",
+			RRule:  "FREQ=DAILY",
+		})
+		if err != nil {
+			t.Fatal("Failed to create series:", err)
+		}
+		moved, err := service.UpdateEvent(&models.UpdateEventRequest{
+			ID:             series.ID,
+			UserID:         1,
+			Date:           "2024-01-03",
+			Title:          "Moved standup",
+			OccurrenceDate: "2024-01-02",
+		})
+		if err != nil {
+			t.Fatal("UpdateEvent() occurrence error:", err)
+		}
 
-	for _, e := range events {
-		_, err := service.CreateEvent(&models.CreateEventRequest{
+		// Серия становится одиночным событием, ее измененный экземпляр удаляется
+		updated, err := service.UpdateEvent(&models.UpdateEventRequest{
+			ID:     series.ID,
 			UserID: 1,
-			Date:   e.date,
-			Title:  e.title,
+			Date:   "2024-01-01",
+			Title:  "Single standup",
 		})
 		if err != nil {
-			t.Fatal("Failed to create event:", err)
+			t.Fatal("UpdateEvent() error:", err)
+		}
+		if updated.IsRecurring() || len(updated.ExDates) != 0 {
+			t.Errorf("UpdateEvent() got RRule %q and ExDates %v, want a single event", updated.RRule, updated.ExDates)
+		}
+
+		if _, err := service.GetEvent(moved.ID, 1); !errors.Is(err, apperrors.ErrNotFound) {
+			t.Errorf("GetEvent() exception error = %v, want ErrNotFound", err)
+		}
+		events, err := service.GetEvents(1, time.Date(2024, 1, 1, 0, 0, 0, 0, time.UTC), time.Date(2024, 2, 1, 0, 0, 0, 0, time.UTC))
+		if err != nil {
+			t.Fatal("GetEvents() error:", err)
+		}
+		if len(events) != 1 || events[0].ID != series.ID {
+			t.Errorf("GetEvents() got %v, want only the former series", events)
 		}
+	})
+}
+
+func TestEventService_OccurrenceLimit(t *testing.T) {
+	service := NewEventService(storage.NewInMemoryEventStorage())
+	if _, err := service.CreateEvent(&models.CreateEventRequest{
+		UserID: 1, Start: "2000-01-01T09:00:00Z", Duration: "30m", Title: "Daily", RRule: "FREQ=YEARLY;BYDAY=MO,TU,WE,TH,FR,SA,SU",
+	}); err != nil {
+		t.Fatal("CreateEvent() error:", err)
 	}
 
-	// Запрашиваем события на неделю, содержащую 31.12.2023
-	testDate, _ := time.Parse("2006-01-02", "2023-12-31")
-	weekEvents, err := service.GetEventsForWeek(1, testDate)
-	if err != nil {
-		t.Error("GetEventsForWeek() error:", err)
+	// Диапазон с миллионами экземпляров отклоняется, а не разворачивается
+	started := time.Now()
+	if _, err := service.GetEvents(1, time.Date(2000, 1, 1, 0, 0, 0, 0, time.UTC), maxDate); !errors.Is(err, apperrors.ErrValidation) {
+		t.Errorf("GetEvents() of the whole series error = %v, want ErrValidation", err)
+	}
+	if elapsed := time.Since(started); elapsed > time.Second {
+		t.Errorf("GetEvents() of the whole series took %v", elapsed)
+	}
+
+	// Неделя спустя десятилетия после начала серии
+	week, err := service.GetEvents(1, time.Date(2030, 1, 7, 0, 0, 0, 0, time.UTC), time.Date(2030, 1, 14, 0, 0, 0, 0, time.UTC))
+	if err != nil || len(week) != 7 {
+		t.Errorf("GetEvents() of a week = %d events, %v; want 7", len(week), err)
 	}
 
-	// Должны получить 3 события (25, 27, 31 декабря)
-	if len(weekEvents) != 3 {
-		t.Errorf("GetEventsForWeek() got %d events, want 3", len(weekEvents))
+	// Экспорт проверяет серию без разворачивания всех экземпляров
+	if exported, err := service.ExportEvents(1, time.Time{}, time.Time{}); err != nil || len(exported) != 1 {
+		t.Errorf("ExportEvents() = %d events, %v; want the series", len(exported), err)
 	}
 }
 
-func TestEventService_GetEventsForMonth(t *testing.T) {
-	strg := storage.NewInMemoryEventStorage()
-	service := NewEventService(strg)
+func TestEventService_TimedEvents(t *testing.T) {
+	forEachStorage(t, func(t *testing.T, strg storage.EventStorage) {
+		service := NewEventService(strg)
 
-	// Создаем события в декабре и январе
-	events := []struct {
-		date  string
-		title string
-	}{
-		{"2023-12-01", "December Start"},
-		{"2023-12-15", "December Middle"},
-		{"2023-12-31", "December End"},
-		{"2024-01-01", "January Start"},
-	}
+		requests := []*models.CreateEventRequest{
+			{UserID: 1, Start: "2024-03-05T15:00:00Z", Duration: "1h", Title: "Afternoon"},
+			{UserID: 1, Start: "2024-03-05T10:00:00Z", End: "2024-03-05T11:30:00Z", Title: "Morning"},
+			{UserID: 1, Date: "2024-03-05", Title: "All day"},
+			{UserID: 1, Start: "2024-03-04T23:00:00Z", Duration: "2h", Title: "Overnight"},
+		}
+		for _, req := range requests {
+			if _, err := service.CreateEvent(req); err != nil {
+				t.Fatalf("CreateEvent(%q) error: %v", req.Title, err)
+			}
+		}
+
+		day, _ := time.Parse("2006-01-02", "2024-03-05")
+		events, err := service.GetEventsForDay(1, day)
+		if err != nil {
+			t.Fatal("GetEventsForDay() error:", err)
+		}
+
+		want := []string{"Overnight", "All day", "Morning", "Afternoon"}
+		if len(events) != len(want) {
+			t.Fatalf("GetEventsForDay() got %d events, want %d", len(events), len(want))
+		}
+		for i, title := range want {
+			if events[i].Title != title {
+				t.Errorf("GetEventsForDay()[%d] = %q, want %q", i, events[i].Title, title)
+			}
+		}
+
+		if !events[1].AllDay || events[1].Duration() != 24*time.Hour {
+			t.Errorf("date-only event should last the whole day, got %+v", events[1])
+		}
+		if events[2].Duration() != 90*time.Minute {
+			t.Errorf("Morning duration = %v, want 1h30m", events[2].Duration())
+		}
+
+		_, err = service.CreateEvent(&models.CreateEventRequest{
+			UserID: 1,
+			Start:  "2024-03-05T10:00:00Z",
+			End:    "2024-03-05T09:00:00Z",
+			Title:  "Backwards",
+		})
+		if err == nil {
+			t.Error("CreateEvent() should reject end before start")
+		}
+	})
+}
+
+func TestEventService_TimeZones(t *testing.T) {
+	forEachStorage(t, func(t *testing.T, strg storage.EventStorage) {
+		service := NewEventService(strg)
+
+		if err := service.SetUserTimeZone(&models.SetTimeZoneRequest{UserID: 1, TimeZone: "Europe/Moscow"}); err != nil {
+			t.Fatal("SetUserTimeZone() error:", err)
+		}
+		if err := service.SetUserTimeZone(&models.SetTimeZoneRequest{UserID: 1, TimeZone: "Mars/Olympus"}); err == nil {
+			t.Error("SetUserTimeZone() should reject unknown time zone")
+		}
 
-	for _, e := range events {
-		_, err := service.CreateEvent(&models.CreateEventRequest{
+		// 22:30 UTC 5 марта — это 01:30 6 марта по Москве
+		late, err := service.CreateEvent(&models.CreateEventRequest{
 			UserID: 1,
-			Date:   e.date,
-			Title:  e.title,
+			Start:  "2024-03-05T22:30:00Z",
+			Title:  "Late call",
 		})
 		if err != nil {
-			t.Fatal("Failed to create event:", err)
+			t.Fatal("CreateEvent() error:", err)
+		}
+		if late.TimeZone != "Europe/Moscow" {
+			t.Errorf("CreateEvent() TimeZone = %q, want user time zone", late.TimeZone)
 		}
-	}
 
-	// Запрашиваем события декабря 2023
-	testDate, _ := time.Parse("2006-01-02", "2023-12-15")
-	monthEvents, err := service.GetEventsForMonth(1, testDate)
-	if err != nil {
-		t.Error("GetEventsForMonth() error:", err)
-	}
+		moscow, err := service.ResolveLocation(1, "")
+		if err != nil {
+			t.Fatal("ResolveLocation() error:", err)
+		}
+		day, _ := time.ParseInLocation("2006-01-02", "2024-03-06", moscow)
+		events, err := service.GetEventsForDay(1, day)
+		if err != nil {
+			t.Fatal("GetEventsForDay() error:", err)
+		}
+		if len(events) != 1 || events[0].Start.Hour() != 1 {
+			t.Errorf("GetEventsForDay() in Moscow got %v, want the late call at 01:30", events)
+		}
+
+		utcDay, _ := time.Parse("2006-01-02", "2024-03-06")
+		events, err = service.GetEventsForDay(1, utcDay)
+		if err != nil {
+			t.Fatal("GetEventsForDay() error:", err)
+		}
+		if len(events) != 0 {
+			t.Errorf("GetEventsForDay() in UTC got %d events, want 0", len(events))
+		}
+
+		// Еженедельная встреча в 09:00 по Берлину сохраняет местное время после перехода на летнее время
+		_, err = service.CreateEvent(&models.CreateEventRequest{
+			UserID:   2,
+			Start:    "2024-03-25T09:00:00+01:00",
+			Duration: "30m",
+			TimeZone: "Europe/Berlin",
+			Title:    "Weekly sync",
+			RRule:    "FREQ=WEEKLY",
+		})
+		if err != nil {
+			t.Fatal("CreateEvent() series error:", err)
+		}
+
+		berlin, err := service.ResolveLocation(2, "Europe/Berlin")
+		if err != nil {
+			t.Fatal("ResolveLocation() error:", err)
+		}
+		afterDST, _ := time.ParseInLocation("2006-01-02", "2024-04-01", berlin)
+		events, err = service.GetEventsForDay(2, afterDST)
+		if err != nil {
+			t.Fatal("GetEventsForDay() error:", err)
+		}
+		if len(events) != 1 || events[0].Start.Hour() != 9 || events[0].Start.UTC().Hour() != 7 {
+			t.Errorf("GetEventsForDay() after DST got %v, want 09:00 local (07:00 UTC)", events)
+		}
+	})
+}
+
+func TestEventService_ImportEvents(t *testing.T) {
+	forEachStorage(t, func(t *testing.T, strg storage.EventStorage) {
+		service := NewEventService(strg)
+
+		rid := time.Date(2024, 1, 10, 9, 0, 0, 0, time.UTC)
+		imported := func() []*models.Event {
+			return []*models.Event{
+				{
+					UID:          "standup@example",
+					Start:        rid.Add(2 * time.Hour),
+					End:          rid.Add(3 * time.Hour),
+					TimeZone:     "UTC",
+					Title:        "Late standup",
+					RecurrenceID: &rid,
+				},
+				{
+					UID:      "standup@example",
+					Start:    time.Date(2024, 1, 8, 9, 0, 0, 0, time.UTC),
+					End:      time.Date(2024, 1, 8, 9, 15, 0, 0, time.UTC),
+					TimeZone: "UTC",
+					Title:    "Standup",
+					RRule:    "FREQ=DAILY;COUNT=5",
+				},
+				{UID: "broken@example", Start: rid, End: rid},
+			}
+		}
+
+		result, err := service.ImportEvents(1, imported())
+		if err != nil {
+			t.Fatal("ImportEvents() error:", err)
+		}
+		if result.Created != 2 || result.Updated != 0 || len(result.Errors) != 1 {
+			t.Errorf("ImportEvents() = %+v, want 2 created and 1 error", result)
+		}
+
+		// Повторный импорт обновляет события, а не создает дубликаты
+		result, err = service.ImportEvents(1, imported())
+		if err != nil {
+			t.Fatal("ImportEvents() error:", err)
+		}
+		if result.Created != 0 || result.Updated != 2 {
+			t.Errorf("ImportEvents() repeated = %+v, want 2 updated", result)
+		}
+
+		day, _ := time.Parse("2006-01-02", "2024-01-10")
+		events, err := service.GetEventsForDay(1, day)
+		if err != nil {
+			t.Fatal("GetEventsForDay() error:", err)
+		}
+		if len(events) != 1 || events[0].Title != "Late standup" {
+			t.Errorf("GetEventsForDay() got %v, want only the moved occurrence", events)
+		}
+
+		week, err := service.GetEventsForWeek(1, day)
+		if err != nil {
+			t.Fatal("GetEventsForWeek() error:", err)
+		}
+		if len(week) != 5 {
+			t.Errorf("GetEventsForWeek() got %d events, want 5", len(week))
+		}
+	})
+}
 
-	// Должны получить 3 события декабря
-	if len(monthEvents) != 3 {
-		t.Errorf("GetEventsForMonth() got %d events, want 3", len(monthEvents))
+func TestEventService_EventObjects(t *testing.T) {
+	forEachStorage(t, func(t *testing.T, strg storage.EventStorage) {
+		service := NewEventService(strg)
+
+		start := time.Date(2024, 1, 8, 9, 0, 0, 0, time.UTC)
+		object := func(title string) []*models.Event {
+			return []*models.Event{{
+				UID:   "standup-1",
+				Title: title,
+				Date:  time.Date(2024, 1, 8, 0, 0, 0, 0, time.UTC),
+				Start: start,
+				End:   start.Add(15 * time.Minute),
+			}}
+		}
+
+		if created, err := service.PutEventObject(1, "standup-1", object("Standup"), nil); err != nil || !created {
+			t.Fatalf("PutEventObject() = %v, %v, want created", created, err)
+		}
+		read, err := service.GetEventObject(1, "standup-1")
+		if err != nil {
+			t.Fatal("GetEventObject() error:", err)
+		}
+		versions := map[int]int{read[0].ID: read[0].Version}
+
+		// Из двух записей по одной прочитанной версии проходит только первая
+		if _, err := service.PutEventObject(1, "standup-1", object("First"), versions); err != nil {
+			t.Fatal("PutEventObject() error:", err)
+		}
+		if _, err := service.PutEventObject(1, "standup-1", object("Second"), versions); !errors.Is(err, apperrors.ErrConflict) {
+			t.Errorf("PutEventObject() with stale versions error = %v, want ErrConflict", err)
+		}
+		if err := service.DeleteEventObject(1, "standup-1", versions); !errors.Is(err, apperrors.ErrConflict) {
+			t.Errorf("DeleteEventObject() with stale versions error = %v, want ErrConflict", err)
+		}
+
+		current, err := service.GetEventObject(1, "standup-1")
+		if err != nil {
+			t.Fatal("GetEventObject() error:", err)
+		}
+		if current[0].Title != "First" {
+			t.Errorf("GetEventObject() title = %q, want First", current[0].Title)
+		}
+		if err := service.DeleteEventObject(1, "standup-1", map[int]int{current[0].ID: current[0].Version}); err != nil {
+			t.Errorf("DeleteEventObject() with current versions error = %v", err)
+		}
+	})
+}
+
+func TestEventService_DueReminders(t *testing.T) {
+	forEachStorage(t, func(t *testing.T, strg storage.EventStorage) {
+		service := NewEventService(strg)
+
+		if _, err := service.CreateEvent(&models.CreateEventRequest{
+			UserID: 1, Start: "2024-01-08T09:00:00Z", Title: "Standup",
+			RRule: "FREQ=DAILY;COUNT=3", Reminders: []int{15, 5, 15},
+		}); err != nil {
+			t.Fatal("CreateEvent() error:", err)
+		}
+		if _, err := service.CreateEvent(&models.CreateEventRequest{
+			UserID: 2, Start: "2024-01-09T10:00:00Z", Title: "Review", Reminders: []int{60},
+		}); err != nil {
+			t.Fatal("CreateEvent() error:", err)
+		}
+		if _, err := service.CreateEvent(&models.CreateEventRequest{
+			UserID: 2, Start: "2024-01-09T09:00:00Z", Title: "No reminders",
+		}); err != nil {
+			t.Fatal("CreateEvent() error:", err)
+		}
+		if _, err := service.CreateEvent(&models.CreateEventRequest{
+			UserID: 1, Date: "2024-01-09", Title: "Too early", Reminders: []int{-5},
+		}); err == nil {
+			t.Error("CreateEvent() should reject negative reminder")
+		}
+
+		from := time.Date(2024, 1, 9, 8, 0, 0, 0, time.UTC)
+		reminders, err := service.DueReminders(from, from.Add(2*time.Hour))
+		if err != nil {
+			t.Fatal("DueReminders() error:", err)
+		}
+
+		want := []struct {
+			title  string
+			fireAt time.Time
+		}{
+			{"Standup", time.Date(2024, 1, 9, 8, 45, 0, 0, time.UTC)},
+			{"Standup", time.Date(2024, 1, 9, 8, 55, 0, 0, time.UTC)},
+			{"Review", time.Date(2024, 1, 9, 9, 0, 0, 0, time.UTC)},
+		}
+		if len(reminders) != len(want) {
+			t.Fatalf("DueReminders() got %d reminders, want %d: %+v", len(reminders), len(want), reminders)
+		}
+		for i, w := range want {
+			if reminders[i].Title != w.title || !reminders[i].FireAt.Equal(w.fireAt) {
+				t.Errorf("reminder %d = %s at %v, want %s at %v", i, reminders[i].Title, reminders[i].FireAt, w.title, w.fireAt)
+			}
+		}
+		if reminders[0].Key == reminders[1].Key {
+			t.Error("reminders with different offsets share a key")
+		}
+	})
+}
+
+func TestEventService_Sharing(t *testing.T) {
+	forEachStorage(t, func(t *testing.T, strg storage.EventStorage) {
+		service := NewEventService(strg)
+		day := time.Date(2024, 1, 8, 0, 0, 0, 0, time.UTC)
+
+		meeting, err := service.CreateEvent(&models.CreateEventRequest{
+			UserID:    1,
+			Start:     "2024-01-08T10:00:00Z",
+			Duration:  "1h",
+			Title:     "Planning",
+			Attendees: []int{2, 3, 1, 2},
+		})
+		if err != nil {
+			t.Fatal("Failed to create event:", err)
+		}
+		if len(meeting.Attendees) != 2 || meeting.Attendees[0] != (models.Attendee{UserID: 2, Status: models.RSVPNeedsAction}) {
+			t.Fatalf("attendees = %+v, want users 2 and 3 without owner and duplicates", meeting.Attendees)
+		}
+
+		// Приглашение видно участнику, но не постороннему
+		for userID, want := range map[int]int{2: 1, 3: 1, 4: 0} {
+			events, err := service.GetEventsForDay(userID, day)
+			if err != nil || len(events) != want {
+				t.Errorf("GetEventsForDay(user %d) = %d events, %v; want %d", userID, len(events), err, want)
+			}
+		}
+
+		if _, err := service.GetEvent(meeting.ID, 2); err != nil {
+			t.Error("GetEvent() by attendee error:", err)
+		}
+		if _, err := service.GetEvent(meeting.ID, 4); !errors.Is(err, apperrors.ErrForbidden) {
+			t.Errorf("GetEvent() by stranger error = %v, want forbidden", err)
+		}
+		if err := service.DeleteEvent(&models.DeleteEventRequest{ID: meeting.ID, UserID: 2}); !errors.Is(err, apperrors.ErrForbidden) {
+			t.Errorf("DeleteEvent() by attendee error = %v, want forbidden", err)
+		}
+
+		rsvpTests := []struct {
+			name    string
+			req     *models.RSVPRequest
+			wantErr error
+		}{
+			{"accept", &models.RSVPRequest{ID: meeting.ID, UserID: 2, Status: models.RSVPAccepted}, nil},
+			{"decline", &models.RSVPRequest{ID: meeting.ID, UserID: 3, Status: models.RSVPDeclined}, nil},
+			{"invalid status", &models.RSVPRequest{ID: meeting.ID, UserID: 2, Status: "maybe"}, apperrors.ErrValidation},
+			{"not invited", &models.RSVPRequest{ID: meeting.ID, UserID: 4, Status: models.RSVPAccepted}, apperrors.ErrForbidden},
+			{"owner", &models.RSVPRequest{ID: meeting.ID, UserID: 1, Status: models.RSVPAccepted}, apperrors.ErrForbidden},
+		}
+		for _, tt := range rsvpTests {
+			t.Run(tt.name, func(t *testing.T) {
+				_, err := service.RespondToEvent(tt.req)
+				if (tt.wantErr == nil && err != nil) || (tt.wantErr != nil && !errors.Is(err, tt.wantErr)) {
+					t.Errorf("RespondToEvent() error = %v, want %v", err, tt.wantErr)
+				}
+			})
+		}
+
+		// Владелец убирает участника 3, ответ участника 2 сохраняется
+		updated, err := service.PatchEvent(meeting.ID, 1, &models.PatchEventRequest{Attendees: &[]int{2}})
+		if err != nil {
+			t.Fatal("PatchEvent() error:", err)
+		}
+		if len(updated.Attendees) != 1 || updated.Attendees[0].Status != models.RSVPAccepted {
+			t.Errorf("attendees after update = %+v, want user 2 accepted", updated.Attendees)
+		}
+		if events, _ := service.GetEventsForDay(3, day); len(events) != 0 {
+			t.Errorf("removed attendee still sees %d events", len(events))
+		}
+
+		// Доступ к календарю на чтение, затем на запись
+		if _, err := service.ShareCalendar(&models.ShareCalendarRequest{UserID: 1, ShareWith: 4, Permission: models.PermissionRead}); err != nil {
+			t.Fatal("ShareCalendar() error:", err)
+		}
+		if events, err := service.GetCalendarEvents(4, 1, day, day.AddDate(0, 0, 1)); err != nil || len(events) != 1 {
+			t.Errorf("GetCalendarEvents() = %d events, %v; want 1", len(events), err)
+		}
+		create := &models.CreateEventRequest{UserID: 4, CalendarID: 1, Date: "2024-01-09", Title: "Offsite"}
+		if _, err := service.CreateEvent(create); !errors.Is(err, apperrors.ErrForbidden) {
+			t.Errorf("CreateEvent() with read access error = %v, want forbidden", err)
+		}
+
+		if _, err := service.ShareCalendar(&models.ShareCalendarRequest{UserID: 1, ShareWith: 4, Permission: models.PermissionWrite}); err != nil {
+			t.Fatal("ShareCalendar() error:", err)
+		}
+		offsite, err := service.CreateEvent(create)
+		if err != nil || offsite.UserID != 1 {
+			t.Fatalf("CreateEvent() with write access = %+v, %v; want event in calendar 1", offsite, err)
+		}
+		if _, err := service.PatchEvent(meeting.ID, 4, &models.PatchEventRequest{Title: ptrTo("Planning v2")}); err != nil {
+			t.Error("PatchEvent() with write access error:", err)
+		}
+
+		if err := service.UnshareCalendar(1, 4); err != nil {
+			t.Fatal("UnshareCalendar() error:", err)
+		}
+		if _, err := service.GetCalendarEvents(4, 1, day, day.AddDate(0, 0, 1)); !errors.Is(err, apperrors.ErrForbidden) {
+			t.Errorf("GetCalendarEvents() after unshare error = %v, want forbidden", err)
+		}
+	})
+}
+
+func TestEventService_Scheduling(t *testing.T) {
+	forEachStorage(t, func(t *testing.T, strg storage.EventStorage) {
+		service := NewEventService(strg)
+		at := func(hour, minute int) time.Time {
+			return time.Date(2024, 1, 8, hour, minute, 0, 0, time.UTC)
+		}
+
+		fixtures := []*models.CreateEventRequest{
+			{UserID: 1, Start: "2024-01-08T09:00:00Z", Duration: "1h", Title: "Standup"},
+			{UserID: 1, Start: "2024-01-08T09:30:00Z", Duration: "90m", Title: "Review"},
+			{UserID: 1, Start: "2024-01-08T12:00:00Z", Title: "Ping"},
+			{UserID: 2, Start: "2024-01-08T13:00:00Z", Duration: "1h", Title: "Lunch"},
+			{UserID: 3, Start: "2024-01-08T15:00:00Z", Duration: "1h", Title: "Demo", Attendees: []int{2}},
+		}
+		var created []*models.Event
+		for _, req := range fixtures {
+			event, err := service.CreateEvent(req)
+			if err != nil {
+				t.Fatal("Failed to create event:", err)
+			}
+			created = append(created, event)
+		}
+		demo := created[4]
+		if _, err := service.RespondToEvent(&models.RSVPRequest{ID: demo.ID, UserID: 2, Status: models.RSVPDeclined}); err != nil {
+			t.Fatal("RespondToEvent() error:", err)
+		}
+
+		// Пересекающиеся события сливаются, события без длительности
+		// и отклоненные приглашения время не занимают
+		freeBusy, err := service.FreeBusy([]int{1, 2, 1}, at(8, 0), at(18, 0))
+		if err != nil {
+			t.Fatal("FreeBusy() error:", err)
+		}
+		want := map[int][]models.Interval{
+			1: {{Start: at(9, 0), End: at(11, 0)}},
+			2: {{Start: at(13, 0), End: at(14, 0)}},
+		}
+		if len(freeBusy.Users) != 2 {
+			t.Fatalf("FreeBusy() returned %d users, want 2", len(freeBusy.Users))
+		}
+		for _, user := range freeBusy.Users {
+			if len(user.Busy) != len(want[user.UserID]) || !user.Busy[0].Start.Equal(want[user.UserID][0].Start) || !user.Busy[0].End.Equal(want[user.UserID][0].End) {
+				t.Errorf("FreeBusy() user %d busy = %+v, want %+v", user.UserID, user.Busy, want[user.UserID])
+			}
+		}
+
+		slotTests := []struct {
+			name      string
+			from      time.Time
+			minutes   int
+			wantStart time.Time
+			wantErr   bool
+		}{
+			{"before first event", at(8, 0), 60, at(8, 0), false},
+			{"between events", at(9, 0), 60, at(11, 0), false},
+			{"after all events", at(9, 0), 180, at(14, 0), false},
+			{"no slot in window", at(9, 0), 600, time.Time{}, true},
+		}
+		for _, tt := range slotTests {
+			t.Run(tt.name, func(t *testing.T) {
+				slot, err := service.NextFreeSlot([]int{1, 2}, time.Duration(tt.minutes)*time.Minute, tt.from, at(18, 0))
+				if (err != nil) != tt.wantErr {
+					t.Fatalf("NextFreeSlot() error = %v, wantErr %v", err, tt.wantErr)
+				}
+				if err == nil && !slot.Start.Equal(tt.wantStart) {
+					t.Errorf("NextFreeSlot() = %v, want start %v", slot.Start, tt.wantStart)
+				}
+			})
+		}
+
+		conflictTests := []struct {
+			name          string
+			create        func() error
+			wantConflicts int
+		}{
+			{"overlapping event", func() error {
+				_, err := service.CreateEvent(&models.CreateEventRequest{UserID: 1, Start: "2024-01-08T09:45:00Z", Duration: "1h", Title: "Sync", CheckConflicts: true})
+				return err
+			}, 2},
+			{"overlapping series", func() error {
+				_, err := service.CreateEvent(&models.CreateEventRequest{UserID: 1, Start: "2024-01-01T10:45:00Z", Duration: "30m", Title: "Daily", RRule: "FREQ=DAILY", CheckConflicts: true})
+				return err
+			}, 1},
+			{"free time", func() error {
+				_, err := service.CreateEvent(&models.CreateEventRequest{UserID: 1, Start: "2024-01-08T11:00:00Z", Duration: "1h", Title: "Focus", CheckConflicts: true})
+				return err
+			}, 0},
+			{"declined invitation", func() error {
+				_, err := service.CreateEvent(&models.CreateEventRequest{UserID: 2, Start: "2024-01-08T15:00:00Z", Duration: "1h", Title: "Gym", CheckConflicts: true})
+				return err
+			}, 0},
+			{"event does not conflict with itself", func() error {
+				_, err := service.PatchEvent(created[3].ID, 2, &models.PatchEventRequest{Duration: ptrTo("30m"), CheckConflicts: true})
+				return err
+			}, 0},
+		}
+		for _, tt := range conflictTests {
+			t.Run(tt.name, func(t *testing.T) {
+				err := tt.create()
+				var conflict *ScheduleConflictError
+				switch {
+				case tt.wantConflicts == 0 && err != nil:
+					t.Errorf("unexpected error: %v", err)
+				case tt.wantConflicts > 0 && !errors.As(err, &conflict):
+					t.Errorf("error = %v, want schedule conflict", err)
+				case tt.wantConflicts > 0 && len(conflict.Events) != tt.wantConflicts:
+					t.Errorf("conflicts = %d events, want %d", len(conflict.Events), tt.wantConflicts)
+				}
+			})
+		}
+	})
+}
+
+// ptrTo возвращает указатель на значение
+func TestEventService_Search(t *testing.T) {
+	forEachStorage(t, func(t *testing.T, strg storage.EventStorage) {
+		service := NewEventService(strg)
+
+		fixtures := []*models.CreateEventRequest{
+			{UserID: 1, Start: "2024-01-10T10:00:00Z", Duration: "1h", Title: "Планирование спринта", Tags: []string{"Работа", " работа ", ""}},
+			{UserID: 1, Start: "2024-01-09T10:00:00Z", Duration: "1h", Title: "Ёлка в офисе", Description: "Праздник для команды", Attendees: []int{2}},
+			{UserID: 1, Date: "2024-01-01", AllDay: true, Title: "Planning poker", RRule: "FREQ=WEEKLY", Tags: []string{"работа"}},
+			{UserID: 2, Start: "2024-01-11T10:00:00Z", Duration: "1h", Title: "Планерка", Attendees: []int{1}},
+			{UserID: 3, Start: "2024-01-12T10:00:00Z", Duration: "1h", Title: "Планы чужого календаря"},
+		}
+		ids := make(map[string]int)
+		for _, req := range fixtures {
+			event, err := service.CreateEvent(req)
+			if err != nil {
+				t.Fatal("Failed to create event:", err)
+			}
+			ids[event.Title] = event.ID
+		}
+
+		titles := func(events []*models.Event) []string {
+			var result []string
+			for _, event := range events {
+				result = append(result, event.Title)
+			}
+			return result
+		}
+
+		tests := []struct {
+			name    string
+			req     models.SearchRequest
+			want    []string
+			wantErr bool
+		}{
+			{
+				name: "prefix in any case with invitations",
+				req:  models.SearchRequest{UserID: 1, Query: "ПЛАН"},
+				want: []string{"Планирование спринта", "Планерка"},
+			},
+			{
+				name: "yo folded and description searched",
+				req:  models.SearchRequest{UserID: 1, Query: "елка команды"},
+				want: []string{"Ёлка в офисе"},
+			},
+			{
+				name: "tags",
+				req:  models.SearchRequest{UserID: 1, Tags: []string{"РАБОТА"}},
+				want: []string{"Planning poker", "Планирование спринта"},
+			},
+			{
+				name: "attendees",
+				req:  models.SearchRequest{UserID: 1, Attendees: []int{2}},
+				want: []string{"Ёлка в офисе", "Планерка"},
+			},
+			{
+				name: "range expands series",
+				req: models.SearchRequest{
+					UserID: 1, Query: "poker",
+					From: time.Date(2024, 1, 1, 0, 0, 0, 0, time.UTC),
+					To:   time.Date(2024, 1, 15, 0, 0, 0, 0, time.UTC),
+				},
+				want: []string{"Planning poker", "Planning poker"},
+			},
+			{
+				name: "title descending",
+				req:  models.SearchRequest{UserID: 1, Sort: "-title"},
+				want: []string{"Планирование спринта", "Планерка", "Ёлка в офисе", "Planning poker"},
+			},
+			{name: "unknown sort", req: models.SearchRequest{UserID: 1, Sort: "rank"}, wantErr: true},
+			{name: "half-open range", req: models.SearchRequest{UserID: 1, From: time.Now()}, wantErr: true},
+			{
+				name: "range too long",
+				req: models.SearchRequest{
+					UserID: 1, Query: "poker",
+					From: time.Date(2024, 1, 1, 0, 0, 0, 0, time.UTC),
+					To:   time.Date(2124, 1, 1, 0, 0, 0, 0, time.UTC),
+				},
+				wantErr: true,
+			},
+			{name: "invalid cursor", req: models.SearchRequest{UserID: 1, Cursor: "???"}, wantErr: true},
+		}
+
+		for _, tt := range tests {
+			t.Run(tt.name, func(t *testing.T) {
+				result, err := service.SearchEvents(&tt.req)
+				if (err != nil) != tt.wantErr {
+					t.Fatalf("SearchEvents() error = %v, wantErr %v", err, tt.wantErr)
+				}
+				if tt.wantErr {
+					if !errors.Is(err, apperrors.ErrValidation) {
+						t.Errorf("SearchEvents() error = %v, want validation error", err)
+					}
+					return
+				}
+				if got := titles(result.Events); !slices.Equal(got, tt.want) {
+					t.Errorf("SearchEvents() = %q, want %q", got, tt.want)
+				}
+			})
+		}
+
+		event, err := service.GetEvent(ids["Планирование спринта"], 1)
+		if err != nil {
+			t.Fatal("GetEvent() error:", err)
+		}
+		if !slices.Equal(event.Tags, []string{"Работа"}) {
+			t.Errorf("Tags = %q, want duplicates and blanks removed", event.Tags)
+		}
+
+		// Постраничная выдача проходит все результаты без пропусков и повторов
+		var pages []string
+		req := models.SearchRequest{UserID: 1, Sort: models.SortTitle, Limit: 3}
+		for range 3 {
+			result, err := service.SearchEvents(&req)
+			if err != nil {
+				t.Fatal("SearchEvents() error:", err)
+			}
+			pages = append(pages, titles(result.Events)...)
+			if result.NextCursor == "" {
+				break
+			}
+			req.Cursor = result.NextCursor
+		}
+		want := []string{"Planning poker", "Ёлка в офисе", "Планерка", "Планирование спринта"}
+		if !slices.Equal(pages, want) {
+			t.Errorf("paged SearchEvents() = %q, want %q", pages, want)
+		}
+
+		// Удаленные и измененные события пропадают из индекса
+		if err := service.DeleteEvent(&models.DeleteEventRequest{ID: ids["Планерка"], UserID: 2}); err != nil {
+			t.Fatal("DeleteEvent() error:", err)
+		}
+		if _, err := service.PatchEvent(ids["Ёлка в офисе"], 1, &models.PatchEventRequest{Title: ptrTo("Корпоратив")}); err != nil {
+			t.Fatal("PatchEvent() error:", err)
+		}
+		for _, query := range []string{"планерка", "елка"} {
+			result, err := service.SearchEvents(&models.SearchRequest{UserID: 1, Query: query})
+			if err != nil {
+				t.Fatal("SearchEvents() error:", err)
+			}
+			if len(result.Events) != 0 {
+				t.Errorf("SearchEvents(%q) after changes = %q, want none", query, titles(result.Events))
+			}
+		}
+		result, err := service.SearchEvents(&models.SearchRequest{UserID: 1, Query: "корп"})
+		if err != nil {
+			t.Fatal("SearchEvents() error:", err)
+		}
+		if got := titles(result.Events); !slices.Equal(got, []string{"Корпоратив"}) {
+			t.Errorf("SearchEvents() after rename = %q, want [Корпоратив]", got)
+		}
+	})
+}
+
+func ptrTo[T any](v T) *T {
+	return &v
+}
+
+func TestEventService_Changes(t *testing.T) {
+	service := NewEventService(storage.NewInMemoryEventStorage())
+
+	owner, err := service.SubscribeChanges(1, 0)
+	if err != nil {
+		t.Fatal("SubscribeChanges() error:", err)
+	}
+	attendee, _ := service.SubscribeChanges(3, 0)
+	stranger, _ := service.SubscribeChanges(4, 0)
+	if _, err := service.ShareCalendar(&models.ShareCalendarRequest{UserID: 1, ShareWith: 5, Permission: models.PermissionRead}); err != nil {
+		t.Fatal("ShareCalendar() error:", err)
+	}
+	shared, _ := service.SubscribeChanges(5, 0)
+	if _, err := service.SubscribeChanges(0, 0); !errors.Is(err, apperrors.ErrValidation) {
+		t.Errorf("SubscribeChanges(0) error = %v, want validation error", err)
+	}
+
+	event, err := service.CreateEvent(&models.CreateEventRequest{
+		UserID: 1, Start: "2024-01-08T10:00:00Z", Duration: "1h", Title: "Planning", Attendees: []int{2, 3},
+	})
+	if err != nil {
+		t.Fatal("Failed to create event:", err)
+	}
+	// Участник 3 убран из события и узнает об этом из ленты без подробностей
+	if _, err := service.PatchEvent(event.ID, 1, &models.PatchEventRequest{Attendees: &[]int{2}}); err != nil {
+		t.Fatal("Failed to patch event:", err)
+	}
+	if err := service.DeleteEvent(&models.DeleteEventRequest{ID: event.ID, UserID: 1}); err != nil {
+		t.Fatal("Failed to delete event:", err)
+	}
+	// Неудачное изменение не попадает в ленту
+	if _, err := service.PatchEvent(event.ID, 1, &models.PatchEventRequest{}); err == nil {
+		t.Fatal("PatchEvent() of deleted event succeeded")
+	}
+
+	receive := func(sub <-chan models.Change) []string {
+		var types []string
+		for {
+			select {
+			case change := <-sub:
+				types = append(types, change.Type)
+				if change.EventID != event.ID {
+					t.Errorf("change %s event ID = %d, want %d", change.Type, change.EventID, event.ID)
+				}
+				if removal := change.Type == models.ChangeRemoved; removal != (change.Event == nil) {
+					t.Errorf("change %s event = %+v", change.Type, change.Event)
+				}
+			default:
+				return types
+			}
+		}
+	}
+
+	want := []string{models.ChangeCreated, models.ChangeUpdated, models.ChangeDeleted}
+	if got := receive(owner.C); !slices.Equal(got, want) {
+		t.Errorf("owner changes = %v, want %v", got, want)
+	}
+	if got := receive(shared.C); !slices.Equal(got, want) {
+		t.Errorf("shared calendar changes = %v, want %v", got, want)
+	}
+	if got, want := receive(attendee.C), []string{models.ChangeCreated, models.ChangeRemoved}; !slices.Equal(got, want) {
+		t.Errorf("removed attendee changes = %v, want %v", got, want)
+	}
+	if got := receive(stranger.C); len(got) != 0 {
+		t.Errorf("stranger changes = %v, want none", got)
+	}
+
+	// Продолжение с последнего полученного изменения
+	resumed, _ := service.SubscribeChanges(1, 2)
+	if got := receive(resumed.C); !slices.Equal(got, want[2:]) {
+		t.Errorf("changes after seq 2 = %v, want %v", got, want[2:])
+	}
+}
+
+func TestEventService_Webhooks(t *testing.T) {
+	forEachStorage(t, func(t *testing.T, strg storage.EventStorage) {
+		service := NewEventService(strg)
+
+		invalid := []*models.CreateWebhookRequest{
+			{UserID: 1, URL: "ftp://example.com/hook"},
+			{UserID: 1, URL: "/relative"},
+			{UserID: 1, URL: "http://127.0.0.1:8080/hook"},
+			{UserID: 1, URL: "http://localhost/hook"},
+			{UserID: 1, URL: "http://[::1]/hook"},
+			{UserID: 1, URL: "http://169.254.169.254/latest/meta-data"},
+			{UserID: 1, URL: "https://10.1.2.3/hook"},
+			{UserID: 1, URL: "https://[::ffff:192.168.0.1]/hook"},
+			{UserID: 1, URL: "https://example.com/hook", Secret: "short"},
+			{UserID: 1, URL: "https://example.com/hook", Events: []string{"event.renamed"}},
+			{UserID: 0, URL: "https://example.com/hook"},
+		}
+		for _, req := range invalid {
+			if _, err := service.RegisterWebhook(req); !errors.Is(err, apperrors.ErrValidation) {
+				t.Errorf("RegisterWebhook(%+v) error = %v, want validation error", req, err)
+			}
+		}
+
+		all, err := service.RegisterWebhook(&models.CreateWebhookRequest{UserID: 1, URL: "https://example.com/all"})
+		if err != nil {
+			t.Fatal("RegisterWebhook() error:", err)
+		}
+		if len(all.Secret) != 64 {
+			t.Errorf("generated secret = %q, want 64 hex characters", all.Secret)
+		}
+		deletes, err := service.RegisterWebhook(&models.CreateWebhookRequest{
+			UserID: 2, URL: "https://example.com/deletes", Secret: "attendee-secret-123",
+			Events: []string{models.ChangeDeleted, models.ChangeDeleted},
+		})
+		if err != nil {
+			t.Fatal("RegisterWebhook() error:", err)
+		}
+		if len(deletes.Events) != 1 {
+			t.Errorf("events = %v, want duplicates removed", deletes.Events)
+		}
+
+		listed, err := service.GetWebhooks(1)
+		if err != nil || len(listed) != 1 || listed[0].ID != all.ID || listed[0].Secret != "" {
+			t.Errorf("GetWebhooks() = %+v, %v; want webhook %d without secret", listed, err, all.ID)
+		}
+
+		targets := func(changeType string) []int {
+			webhooks, err := service.WebhookTargets(models.Change{Type: changeType, Recipients: []int{1, 2}})
+			if err != nil {
+				t.Fatal("WebhookTargets() error:", err)
+			}
+			var ids []int
+			for _, w := range webhooks {
+				if w.Secret == "" {
+					t.Errorf("target %d has no secret", w.ID)
+				}
+				ids = append(ids, w.ID)
+			}
+			return ids
+		}
+		if got := targets(models.ChangeUpdated); !slices.Equal(got, []int{all.ID}) {
+			t.Errorf("targets of update = %v, want [%d]", got, all.ID)
+		}
+		if got := targets(models.ChangeDeleted); !slices.Equal(got, []int{all.ID, deletes.ID}) {
+			t.Errorf("targets of delete = %v, want [%d %d]", got, all.ID, deletes.ID)
+		}
+
+		letter := &models.DeadLetter{WebhookID: all.ID, UserID: 1, URL: all.URL, Attempts: 5, Error: "webhook responded with status 500"}
+		if err := service.RecordDeadLetter(letter); err != nil {
+			t.Fatal("RecordDeadLetter() error:", err)
+		}
+		if letters, err := service.GetDeadLetters(1); err != nil || len(letters) != 1 || letters[0].ID != letter.ID {
+			t.Errorf("GetDeadLetters() = %+v, %v; want the recorded letter", letters, err)
+		}
+
+		if err := service.DeleteWebhook(2, all.ID); !errors.Is(err, apperrors.ErrNotFound) {
+			t.Errorf("DeleteWebhook() by another user error = %v, want not found", err)
+		}
+		if err := service.DeleteWebhook(1, all.ID); err != nil {
+			t.Error("DeleteWebhook() error:", err)
+		}
+		if got := targets(models.ChangeUpdated); len(got) != 0 {
+			t.Errorf("targets after delete = %v, want none", got)
+		}
+	})
+}
+
+func TestEventService_Batch(t *testing.T) {
+	forEachStorage(t, func(t *testing.T, strg storage.EventStorage) {
+		service := NewEventService(strg)
+
+		series, err := service.CreateEvent(&models.CreateEventRequest{
+			UserID: 1, Start: "2024-01-08T09:00:00Z", Duration: "30m", Title: "Standup", RRule: "FREQ=WEEKLY",
+		})
+		if err != nil {
+			t.Fatal("Failed to create series:", err)
+		}
+		exception, err := service.UpdateEvent(&models.UpdateEventRequest{
+			ID: series.ID, UserID: 1, Start: "2024-01-15T10:00:00Z", Duration: "30m", Title: "Late standup", OccurrenceDate: "2024-01-15",
+		})
+		if err != nil {
+			t.Fatal("Failed to update occurrence:", err)
+		}
+		review, err := service.CreateEvent(&models.CreateEventRequest{
+			UserID: 1, Start: "2024-01-09T14:00:00Z", Duration: "1h", Title: "Review",
+		})
+		if err != nil {
+			t.Fatal("Failed to create event:", err)
+		}
+
+		retro := &models.CreateEventRequest{Start: "2024-01-12T16:00:00Z", Duration: "1h", Title: "Retro"}
+		failing := []struct {
+			name        string
+			ops         []models.BatchOperation
+			wantIndexes []int
+			wantErr     error
+		}{
+			{"invalid operations", []models.BatchOperation{
+				{Create: retro},
+				{Update: &models.UpdateEventRequest{ID: review.ID, Start: "2024-01-09T15:00:00Z"}},
+				{Delete: &models.DeleteEventRequest{ID: 42}},
+				{},
+			}, []int{1, 2, 3}, apperrors.ErrValidation},
+			{"event changed twice", []models.BatchOperation{
+				{Update: &models.UpdateEventRequest{ID: review.ID, Start: "2024-01-09T15:00:00Z", Title: "Review"}},
+				{Delete: &models.DeleteEventRequest{ID: review.ID}},
+			}, []int{1}, apperrors.ErrConflict},
+			{"stale version", []models.BatchOperation{
+				{Create: retro},
+				{Delete: &models.DeleteEventRequest{ID: review.ID, Version: 7}},
+			}, []int{1}, apperrors.ErrConflict},
+			{"other user", []models.BatchOperation{
+				{Create: &models.CreateEventRequest{UserID: 2, Date: "2024-01-12", Title: "Retro"}},
+			}, []int{0}, apperrors.ErrForbidden},
+		}
+		for _, tt := range failing {
+			_, err := service.ApplyBatch(&models.BatchRequest{UserID: 1, Operations: tt.ops})
+			var batchErr *BatchError
+			if !errors.As(err, &batchErr) || !slices.Equal(batchErr.Indexes(), tt.wantIndexes) || !errors.Is(err, tt.wantErr) {
+				t.Errorf("%s: ApplyBatch() error = %v, want operations %v failing with %v", tt.name, err, tt.wantIndexes, tt.wantErr)
+			}
+		}
+		if _, err := service.ApplyBatch(&models.BatchRequest{UserID: 1}); !errors.Is(err, apperrors.ErrValidation) {
+			t.Errorf("empty batch error = %v, want validation error", err)
+		}
+
+		all, _ := service.userEvents(1)
+		if len(all) != 3 {
+			t.Fatalf("after failed batches user has %d events, want 3", len(all))
+		}
+
+		changes, _ := service.SubscribeChanges(1, service.Changes().Seq())
+		events, err := service.ApplyBatch(&models.BatchRequest{UserID: 1, Operations: []models.BatchOperation{
+			{Create: retro},
+			{Update: &models.UpdateEventRequest{ID: review.ID, Start: "2024-01-09T15:00:00Z", Duration: "1h", Title: "Design review", Version: 1}},
+			{Delete: &models.DeleteEventRequest{ID: series.ID, OccurrenceDate: "2024-01-22"}},
+		}})
+		if err != nil {
+			t.Fatal("ApplyBatch() error:", err)
+		}
+		if len(events) != 3 || events[0].ID == 0 || events[0].UserID != 1 || events[1].Version != 2 || events[2] != nil {
+			t.Fatalf("ApplyBatch() = %+v, want created event, updated event at version 2 and nil", events)
+		}
+		if got, _ := service.GetEvent(series.ID, 1); len(got.ExDates) != 2 {
+			t.Errorf("series exdates = %v, want 2 excluded occurrences", got.ExDates)
+		}
+
+		var types []string
+		for len(changes.C) > 0 {
+			types = append(types, (<-changes.C).Type)
+		}
+		if want := []string{models.ChangeCreated, models.ChangeUpdated, models.ChangeUpdated}; !slices.Equal(types, want) {
+			t.Errorf("published changes = %v, want %v", types, want)
+		}
+
+		// Удаление серии в пакете удаляет и ее измененные экземпляры
+		if _, err := service.ApplyBatch(&models.BatchRequest{UserID: 1, Operations: []models.BatchOperation{
+			{Delete: &models.DeleteEventRequest{ID: series.ID}},
+		}}); err != nil {
+			t.Fatal("ApplyBatch() error:", err)
+		}
+		if _, err := service.GetEvent(exception.ID, 1); !errors.Is(err, apperrors.ErrNotFound) {
+			t.Errorf("exception of deleted series GetEvent() error = %v, want ErrNotFound", err)
+		}
+	})
+}
+
+func TestEventService_Trash(t *testing.T) {
+	forEachStorage(t, func(t *testing.T, strg storage.EventStorage) {
+		service := NewEventService(strg)
+
+		series, err := service.CreateEvent(&models.CreateEventRequest{
+			UserID: 1, Start: "2024-01-08T09:00:00Z", Duration: "30m", Title: "Standup", RRule: "FREQ=WEEKLY",
+		})
+		if err != nil {
+			t.Fatal("Failed to create series:", err)
+		}
+		exception, err := service.UpdateEvent(&models.UpdateEventRequest{
+			ID: series.ID, UserID: 1, Start: "2024-01-15T10:00:00Z", Duration: "30m", Title: "Late standup", OccurrenceDate: "2024-01-15",
+		})
+		if err != nil {
+			t.Fatal("Failed to update occurrence:", err)
+		}
+		review, err := service.CreateEvent(&models.CreateEventRequest{
+			UserID: 1, Start: "2024-01-09T14:00:00Z", Duration: "1h", Title: "Review",
+		})
+		if err != nil {
+			t.Fatal("Failed to create event:", err)
+		}
+
+		if err := service.DeleteEvent(&models.DeleteEventRequest{ID: series.ID, UserID: 1}); err != nil {
+			t.Fatal("DeleteEvent() error:", err)
+		}
+		if _, err := service.GetEvent(series.ID, 1); !errors.Is(err, apperrors.ErrNotFound) {
+			t.Errorf("deleted series GetEvent() error = %v, want ErrNotFound", err)
+		}
+		trashed, err := service.GetTrash(1)
+		if err != nil || len(trashed) != 2 {
+			t.Fatalf("GetTrash() = %+v, %v; want series and its exception", trashed, err)
+		}
+		for _, entry := range trashed {
+			if want := entry.DeletedAt.Add(DefaultTrashRetention); !entry.PurgeAt.Equal(want) {
+				t.Errorf("event %d purge at %v, want %v", entry.Event.ID, entry.PurgeAt, want)
+			}
+		}
+
+		failing := []struct {
+			name    string
+			req     *models.RestoreEventRequest
+			wantErr error
+		}{
+			{"invalid request", &models.RestoreEventRequest{ID: 0, UserID: 1}, apperrors.ErrValidation},
+			{"not in trash", &models.RestoreEventRequest{ID: review.ID, UserID: 1}, apperrors.ErrNotFound},
+			{"other user", &models.RestoreEventRequest{ID: series.ID, UserID: 2}, apperrors.ErrNotFound},
+			{"exception of deleted series", &models.RestoreEventRequest{ID: exception.ID, UserID: 1}, apperrors.ErrConflict},
+		}
+		for _, tt := range failing {
+			if _, err := service.RestoreEvent(tt.req); !errors.Is(err, tt.wantErr) {
+				t.Errorf("%s: RestoreEvent() error = %v, want %v", tt.name, err, tt.wantErr)
+			}
+		}
+
+		changes, _ := service.SubscribeChanges(1, service.Changes().Seq())
+		defer changes.Close()
+
+		// Серия восстанавливается вместе с экземплярами, удаленными с ней
+		restored, err := service.RestoreEvent(&models.RestoreEventRequest{ID: series.ID, UserID: 1})
+		if err != nil || restored.Version != series.Version+2 {
+			t.Fatalf("RestoreEvent() = %+v, %v; want series at version %d", restored, err, series.Version+2)
+		}
+		if got, err := service.GetEvent(exception.ID, 1); err != nil || got.Title != "Late standup" {
+			t.Errorf("exception after restore = %+v, %v; want Late standup", got, err)
+		}
+		if trashed, _ := service.GetTrash(1); len(trashed) != 0 {
+			t.Errorf("GetTrash() after restore = %+v, want empty", trashed)
+		}
+		var types []string
+		for len(changes.C) > 0 {
+			types = append(types, (<-changes.C).Type)
+		}
+		if want := []string{models.ChangeRestored, models.ChangeRestored}; !slices.Equal(types, want) {
+			t.Errorf("published changes = %v, want %v", types, want)
+		}
+
+		if err := service.DeleteEvent(&models.DeleteEventRequest{ID: review.ID, UserID: 1}); err != nil {
+			t.Fatal("DeleteEvent() error:", err)
+		}
+		if n, err := service.PurgeTrash(time.Now()); err != nil || n != 0 {
+			t.Errorf("PurgeTrash() before retention = %d, %v; want 0", n, err)
+		}
+		if n, err := service.PurgeTrash(time.Now().Add(DefaultTrashRetention + time.Minute)); err != nil || n != 1 {
+			t.Errorf("PurgeTrash() after retention = %d, %v; want 1", n, err)
+		}
+		if _, err := service.RestoreEvent(&models.RestoreEventRequest{ID: review.ID, UserID: 1}); !errors.Is(err, apperrors.ErrNotFound) {
+			t.Errorf("purged event RestoreEvent() error = %v, want ErrNotFound", err)
+		}
+	})
+}
+
+func TestEventService_History(t *testing.T) {
+	forEachStorage(t, func(t *testing.T, strg storage.EventStorage) {
+		service := NewEventService(strg)
+		if _, err := service.ShareCalendar(&models.ShareCalendarRequest{UserID: 1, ShareWith: 2, Permission: models.PermissionWrite}); err != nil {
+			t.Fatal("ShareCalendar() error:", err)
+		}
+
+		event, err := service.CreateEvent(&models.CreateEventRequest{
+			UserID: 1, Start: "2024-01-08T09:00:00Z", Duration: "30m", Title: "Standup",
+		})
+		if err != nil {
+			t.Fatal("Failed to create event:", err)
+		}
+		// Встречу переносит пользователь с доступом к календарю
+		if _, err := service.UpdateEvent(&models.UpdateEventRequest{
+			ID: event.ID, UserID: 2, Start: "2024-01-08T11:00:00Z", Duration: "30m", Title: "Standup",
+		}); err != nil {
+			t.Fatal("Failed to update event:", err)
+		}
+
+		history, err := service.GetHistory(event.ID, 2)
+		if err != nil || len(history.Revisions) != 2 {
+			t.Fatalf("GetHistory() = %+v, %v; want 2 revisions", history, err)
+		}
+		created, moved := history.Revisions[0], history.Revisions[1]
+		if created.Action != models.ChangeCreated || created.ActorID != 1 || !slices.ContainsFunc(created.Changes, func(c models.FieldChange) bool { return c.Field == "title" }) {
+			t.Errorf("first revision = %+v, want creation by user 1 with title", created)
+		}
+		var fields []string
+		for _, change := range moved.Changes {
+			fields = append(fields, change.Field)
+		}
+		if moved.Action != models.ChangeUpdated || moved.ActorID != 2 || !slices.Equal(fields, []string{"end", "start"}) {
+			t.Errorf("second revision = %+v with fields %v, want update by user 2 of end and start", moved, fields)
+		}
+		if got := string(moved.Changes[1].Old); got != `"2024-01-08T09:00:00Z"` {
+			t.Errorf("old start = %s, want 2024-01-08T09:00:00Z", got)
+		}
+		if _, err := service.GetHistory(event.ID, 3); !errors.Is(err, apperrors.ErrForbidden) {
+			t.Errorf("GetHistory() by stranger error = %v, want ErrForbidden", err)
+		}
+
+		failing := []struct {
+			name    string
+			req     *models.RevertEventRequest
+			wantErr error
+		}{
+			{"invalid revision", &models.RevertEventRequest{ID: event.ID, UserID: 1}, apperrors.ErrValidation},
+			{"missing revision", &models.RevertEventRequest{ID: event.ID, UserID: 1, Revision: 9}, apperrors.ErrNotFound},
+			{"stale version", &models.RevertEventRequest{ID: event.ID, UserID: 1, Revision: 1, Version: 1}, apperrors.ErrConflict},
+			{"stranger", &models.RevertEventRequest{ID: event.ID, UserID: 3, Revision: 1}, apperrors.ErrForbidden},
+		}
+		for _, tt := range failing {
+			if _, err := service.RevertEvent(tt.req); !errors.Is(err, tt.wantErr) {
+				t.Errorf("%s: RevertEvent() error = %v, want %v", tt.name, err, tt.wantErr)
+			}
+		}
+
+		reverted, err := service.RevertEvent(&models.RevertEventRequest{ID: event.ID, UserID: 1, Revision: 1, Version: 2})
+		if err != nil {
+			t.Fatal("RevertEvent() error:", err)
+		}
+		if !reverted.Start.Equal(event.Start) || reverted.Version != 3 {
+			t.Errorf("reverted event starts %v at version %d, want %v at version 3", reverted.Start, reverted.Version, event.Start)
+		}
+
+		if err := service.DeleteEvent(&models.DeleteEventRequest{ID: event.ID, UserID: 1}); err != nil {
+			t.Fatal("DeleteEvent() error:", err)
+		}
+		// История удаленного события доступна владельцу, пока оно в корзине
+		history, err = service.GetHistory(event.ID, 1)
+		if err != nil || len(history.Revisions) != 4 {
+			t.Fatalf("GetHistory() after delete = %+v, %v; want 4 revisions", history, err)
+		}
+		revert, deleted := history.Revisions[2], history.Revisions[3]
+		if revert.RevertedTo != 1 || revert.ActorID != 1 || len(revert.Changes) != 2 {
+			t.Errorf("revert revision = %+v, want revert to 1 by user 1 changing 2 fields", revert)
+		}
+		if deleted.Action != models.ChangeDeleted || deleted.Event.Version != 3 {
+			t.Errorf("delete revision = %+v, want deletion of version 3", deleted)
+		}
+	})
+}
+
+// failingHistory хранилище, которое не может записывать историю
+type failingHistory struct {
+	*storage.InMemoryEventStorage
+}
+
+func (failingHistory) AppendRevision(*models.Revision) error {
+	return errors.New("disk is full")
+}
+
+func TestEventService_HistoryFailure(t *testing.T) {
+	strg := failingHistory{storage.NewInMemoryEventStorage()}
+	service := NewEventService(strg)
+	changes, _ := service.SubscribeChanges(1, 0)
+	defer changes.Close()
+
+	// Ошибка истории возвращается, но изменение сохранено и опубликовано
+	_, err := service.CreateEvent(&models.CreateEventRequest{
+		UserID: 1, Start: "2024-01-08T09:00:00Z", Duration: "30m", Title: "Standup", RRule: "FREQ=DAILY",
+	})
+	if err == nil || errors.Is(err, apperrors.ErrValidation) {
+		t.Fatalf("CreateEvent() error = %v, want history error", err)
+	}
+	month := func() []*models.Event {
+		events, _ := strg.GetByDateRange(1, time.Date(2024, 1, 1, 0, 0, 0, 0, time.UTC), time.Date(2024, 2, 1, 0, 0, 0, 0, time.UTC))
+		return events
+	}
+	events := month()
+	if len(events) != 1 {
+		t.Fatalf("stored events = %+v, want the series", events)
+	}
+	series := events[0]
+	if len(changes.C) != 1 {
+		t.Errorf("published %d changes, want 1", len(changes.C))
+	}
+
+	// Исключение не откатывается: серия уже исключила его экземпляр
+	_, err = service.UpdateEvent(&models.UpdateEventRequest{
+		ID: series.ID, UserID: 1, Start: "2024-01-09T10:00:00Z", Duration: "30m", Title: "Late standup", OccurrenceDate: "2024-01-09",
+	})
+	if err == nil {
+		t.Fatal("UpdateEvent() of occurrence succeeded without history")
+	}
+	if events := month(); len(events) != 2 {
+		t.Errorf("stored events after occurrence update = %+v, want series and exception", events)
+	}
+	if got, _ := strg.GetByID(series.ID, 1); len(got.ExDates) != 1 {
+		t.Errorf("series exdates = %v, want the changed occurrence", got.ExDates)
 	}
 }
@@ -0,0 +1,138 @@
+package service
+
+import (
+	"l2-18/internal/apperrors"
+	"l2-18/internal/models"
+	"time"
+)
+
+// eventTime время проведения события
+type eventTime struct {
+	start  time.Time
+	end    time.Time
+	allDay bool
+}
+
+// parseEventTime вычисляет начало и конец события по полям запроса.
+// Запрос только с датой (старый формат) создает событие на весь день.
+// Конец задается явно через end или длительностью duration; без них
+// событие на весь день длится сутки, а обычное не имеет длительности.
+// Даты без времени и границы дней отсчитываются в часовом поясе loc.
+func parseEventTime(date, start, end, duration string, allDay bool, loc *time.Location) (*eventTime, error) {
+	when := &eventTime{allDay: allDay}
+
+	switch {
+	case start != "":
+		begin, err := parseTimestamp(start, loc)
+		if err != nil {
+			return nil, apperrors.Field("start", "invalid start format: %v", err)
+		}
+		when.start = begin
+	default:
+		begin, err := time.ParseInLocation("2006-01-02", date, loc)
+		if err != nil {
+			return nil, apperrors.Field("date", "invalid date format: %v", err)
+		}
+		when.start = begin
+		when.allDay = true
+	}
+
+	switch {
+	case end != "" && duration != "":
+		return nil, apperrors.Field("duration", "end and duration are mutually exclusive")
+	case end != "":
+		finish, err := parseTimestamp(end, loc)
+		if err != nil {
+			return nil, apperrors.Field("end", "invalid end format: %v", err)
+		}
+		when.end = finish
+	case duration != "":
+		d, err := time.ParseDuration(duration)
+		if err != nil {
+			return nil, apperrors.Field("duration", "invalid duration: %v", err)
+		}
+		if d < 0 {
+			return nil, apperrors.Field("duration", "duration must not be negative")
+		}
+		when.end = when.start.Add(d)
+	default:
+		when.end = when.start
+	}
+
+	if when.end.Before(when.start) {
+		return nil, apperrors.Field("end", "end must not be before start")
+	}
+	when.start = when.start.In(loc)
+	when.end = when.end.In(loc)
+
+	// Событие на весь день занимает целые сутки
+	if when.allDay {
+		when.start = startOfDay(when.start)
+		if end := startOfDay(when.end); end.Equal(when.end) && end.After(when.start) {
+			when.end = end
+		} else {
+			when.end = end.AddDate(0, 0, 1)
+		}
+	}
+
+	return when, nil
+}
+
+// apply записывает время в событие
+func (t *eventTime) apply(event *models.Event) {
+	event.Start = t.start
+	event.End = t.end
+	event.AllDay = t.allDay
+	event.Date = startOfDay(t.start)
+	event.TimeZone = t.start.Location().String()
+}
+
+// parseTimestamp разбирает момент времени в RFC 3339 или дату без времени
+// (полночь в часовом поясе loc)
+func parseTimestamp(s string, loc *time.Location) (time.Time, error) {
+	if t, err := time.Parse(time.RFC3339, s); err == nil {
+		return t, nil
+	}
+	return time.ParseInLocation("2006-01-02", s, loc)
+}
+
+// parseOccurrence возвращает полуинтервал поиска экземпляра серии:
+// точный момент для RFC 3339 или весь день в часовом поясе loc для даты
+func parseOccurrence(s string, loc *time.Location) (time.Time, time.Time, error) {
+	if t, err := time.Parse(time.RFC3339, s); err == nil {
+		return t, t.Add(time.Nanosecond), nil
+	}
+
+	day, err := time.ParseInLocation("2006-01-02", s, loc)
+	if err != nil {
+		return time.Time{}, time.Time{}, apperrors.Field("occurrence_date", "invalid occurrence date format: %v", err)
+	}
+
+	return day, day.AddDate(0, 0, 1), nil
+}
+
+// startOfDay возвращает полночь того же дня
+func startOfDay(t time.Time) time.Time {
+	return time.Date(t.Year(), t.Month(), t.Day(), 0, 0, 0, 0, t.Location())
+}
+
+// loadLocation загружает часовой пояс IANA по имени
+func loadLocation(tz string) (*time.Location, error) {
+	loc, err := time.LoadLocation(tz)
+	if err != nil {
+		return nil, apperrors.Field("time_zone", "invalid time zone %q", tz)
+	}
+	return loc, nil
+}
+
+// eventLocation возвращает часовой пояс события (UTC, если он не задан или неизвестен)
+func eventLocation(event *models.Event) *time.Location {
+	if event.TimeZone == "" {
+		return time.UTC
+	}
+	loc, err := time.LoadLocation(event.TimeZone)
+	if err != nil {
+		return time.UTC
+	}
+	return loc
+}
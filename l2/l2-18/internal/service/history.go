@@ -0,0 +1,203 @@
+package service
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"l2-18/internal/apperrors"
+	"l2-18/internal/models"
+	"slices"
+	"time"
+)
+
+// untrackedFields поля события, которые меняются при каждом изменении
+// или выводятся из других и поэтому не попадают в историю
+var untrackedFields = []string{"id", "uid", "version", "created_at", "updated_at", "date"}
+
+// emptyValues значения полей в JSON, которые история считает пустыми
+var emptyValues = []string{`""`, `0`, `false`, `null`, `[]`, `"0001-01-01T00:00:00Z"`}
+
+// errHistory ошибка записи истории. Само изменение события к этому
+// моменту уже сохранено.
+var errHistory = errors.New("failed to record event history")
+
+// record добавляет изменение события в его историю
+func (s *EventService) record(rev models.Revision, event, before *models.Event) error {
+	if s.history == nil {
+		return nil
+	}
+
+	copied := *event
+	rev.EventID = event.ID
+	rev.Time = time.Now()
+	rev.Event = &copied
+	if rev.Action != models.ChangeDeleted && rev.Action != models.ChangeRestored {
+		rev.Changes = diffEvents(before, event)
+	}
+
+	if err := s.history.AppendRevision(&rev); err != nil {
+		return fmt.Errorf("%w of event %d: %w", errHistory, event.ID, err)
+	}
+	return nil
+}
+
+// GetHistory возвращает историю изменений события, доступного пользователю
+// для чтения; история удаленного события доступна его владельцу, пока
+// событие в корзине
+func (s *EventService) GetHistory(id, userID int) (*models.History, error) {
+	v := &apperrors.ValidationError{}
+	if id <= 0 {
+		v.Add("id", "invalid event ID")
+	}
+	if userID <= 0 {
+		v.Add("user_id", "invalid user ID")
+	}
+	if err := v.Err(); err != nil {
+		return nil, err
+	}
+
+	if _, err := s.authorize(id, userID, models.PermissionRead); err != nil {
+		if !errors.Is(err, apperrors.ErrNotFound) || !s.inTrash(id, userID) {
+			return nil, fmt.Errorf("failed to get event history: %w", err)
+		}
+	}
+
+	history := &models.History{EventID: id, Revisions: []models.Revision{}}
+	if s.history == nil {
+		return history, nil
+	}
+	revisions, err := s.history.GetHistory(id)
+	if err != nil {
+		return nil, fmt.Errorf("failed to get event history: %w", err)
+	}
+	if revisions != nil {
+		history.Revisions = revisions
+	}
+
+	return history, nil
+}
+
+// RevertEvent возвращает поля события к состоянию после записи req.Revision
+// его истории. Возврат — обычное изменение: он проверяется как обновление,
+// увеличивает версию и сам попадает в историю. Правило повторения
+// и исключения серии при возврате не меняются.
+func (s *EventService) RevertEvent(req *models.RevertEventRequest) (*models.Event, error) {
+	v := &apperrors.ValidationError{}
+	if req.ID <= 0 {
+		v.Add("id", "invalid event ID")
+	}
+	if req.UserID <= 0 {
+		v.Add("user_id", "invalid user ID")
+	}
+	if req.Revision <= 0 {
+		v.Add("revision", "invalid revision")
+	}
+	if err := v.Err(); err != nil {
+		return nil, err
+	}
+
+	current, err := s.authorize(req.ID, req.UserID, models.PermissionWrite)
+	if err != nil {
+		return nil, fmt.Errorf("failed to revert event: %w", err)
+	}
+	target, err := s.revision(req.ID, req.Revision)
+	if err != nil {
+		return nil, err
+	}
+
+	update := updateRequestFrom(target.Event)
+	update.ID = req.ID
+	update.UserID = req.UserID
+	update.RRule = current.RRule
+	update.Version = req.Version
+	if err := s.validateUpdateRequest(update); err != nil {
+		return nil, err
+	}
+	event, existing, err := s.replacement(update)
+	if err != nil {
+		return nil, err
+	}
+	if err := s.storage.Update(event); err != nil {
+		return nil, fmt.Errorf("failed to revert event: %w", err)
+	}
+	if err := s.publishRevision(models.Revision{Action: models.ChangeUpdated, ActorID: req.UserID, RevertedTo: req.Revision}, event, existing); err != nil {
+		return nil, fmt.Errorf("failed to revert event: %w", err)
+	}
+
+	return event, nil
+}
+
+// revision возвращает запись истории события
+func (s *EventService) revision(id, number int) (*models.Revision, error) {
+	if s.history != nil {
+		revisions, err := s.history.GetHistory(id)
+		if err != nil {
+			return nil, fmt.Errorf("failed to revert event: %w", err)
+		}
+		for i := range revisions {
+			if revisions[i].Revision == number && revisions[i].Event != nil {
+				return &revisions[i], nil
+			}
+		}
+	}
+	return nil, apperrors.Errorf(apperrors.ErrNotFound, "revision %d of event %d not found", number, id)
+}
+
+// inTrash сообщает, лежит ли событие в корзине пользователя
+func (s *EventService) inTrash(id, userID int) bool {
+	if s.trash == nil {
+		return false
+	}
+	trashed, err := s.trash.GetTrash(userID)
+	if err != nil {
+		return false
+	}
+	return slices.ContainsFunc(trashed, func(t models.TrashedEvent) bool { return t.Event.ID == id })
+}
+
+// diffEvents возвращает поля, различающиеся у событий before и after,
+// в порядке имен; before == nil — событие только что создано
+func diffEvents(before, after *models.Event) []models.FieldChange {
+	old, cur := eventFields(before), eventFields(after)
+
+	names := make([]string, 0, len(cur))
+	for name := range old {
+		names = append(names, name)
+	}
+	for name := range cur {
+		if _, ok := old[name]; !ok {
+			names = append(names, name)
+		}
+	}
+	slices.Sort(names)
+
+	var changes []models.FieldChange
+	for _, name := range names {
+		if !bytes.Equal(old[name], cur[name]) {
+			changes = append(changes, models.FieldChange{Field: name, Old: old[name], New: cur[name]})
+		}
+	}
+	return changes
+}
+
+// eventFields возвращает непустые отслеживаемые поля события в виде JSON
+func eventFields(event *models.Event) map[string]json.RawMessage {
+	fields := make(map[string]json.RawMessage)
+	if event == nil {
+		return fields
+	}
+	data, err := json.Marshal(event)
+	if err != nil {
+		return fields
+	}
+	if err := json.Unmarshal(data, &fields); err != nil {
+		return fields
+	}
+	for name, value := range fields {
+		if slices.Contains(untrackedFields, name) || slices.Contains(emptyValues, string(value)) {
+			delete(fields, name)
+		}
+	}
+	return fields
+}
@@ -0,0 +1,224 @@
+package service
+
+import (
+	"crypto/rand"
+	"encoding/hex"
+	"fmt"
+	"l2-18/internal/apperrors"
+	"l2-18/internal/models"
+	"slices"
+	"sort"
+	"strings"
+	"time"
+)
+
+// ExportEvents возвращает события пользователя, пересекающиеся с [from, to),
+// для выгрузки в iCalendar. Нулевой to означает отсутствие верхней границы.
+// Серии выгружаются целиком вместе с правилом повторения, если хотя бы
+// один их экземпляр попадает в диапазон.
+func (s *EventService) ExportEvents(userID int, from, to time.Time) ([]*models.Event, error) {
+	if userID <= 0 {
+		return nil, apperrors.Field("user_id", "invalid user ID")
+	}
+	if to.IsZero() {
+		to = maxDate
+	}
+	if !from.Before(to) {
+		return nil, apperrors.Errorf(apperrors.ErrValidation, "invalid date range")
+	}
+
+	events, err := s.storage.GetByDateRange(userID, from, to)
+	if err != nil {
+		return nil, err
+	}
+
+	var result []*models.Event
+	for _, event := range events {
+		if event.IsRecurring() {
+			found, err := hasOccurrence(event, from, to)
+			if err != nil {
+				return nil, err
+			}
+			if !found {
+				continue
+			}
+		}
+		result = append(result, event)
+	}
+
+	return result, nil
+}
+
+// ImportEvents сохраняет события, прочитанные из iCalendar, в календарь пользователя.
+// События сопоставляются с существующими по UID (а измененные экземпляры
+// серий — по UID и RECURRENCE-ID): найденные обновляются, остальные создаются.
+// Ошибки отдельных событий не прерывают импорт и возвращаются в результате.
+func (s *EventService) ImportEvents(userID int, events []*models.Event) (*models.ImportResult, error) {
+	if userID <= 0 {
+		return nil, apperrors.Field("user_id", "invalid user ID")
+	}
+
+	existing, err := s.userEvents(userID)
+	if err != nil {
+		return nil, fmt.Errorf("failed to import events: %w", err)
+	}
+	masters, exceptions := indexImported(existing)
+
+	result := &models.ImportResult{}
+	for _, event := range importOrder(events) {
+		created, err := s.importEvent(userID, event, masters, exceptions, nil)
+		switch {
+		case err != nil:
+			result.Errors = append(result.Errors, fmt.Sprintf("event %s: %v", event.UID, err))
+		case created:
+			result.Created++
+		default:
+			result.Updated++
+		}
+	}
+
+	return result, nil
+}
+
+// indexImported возвращает существующие основные события по UID
+// и измененные экземпляры серий по UID и RECURRENCE-ID
+func indexImported(existing []*models.Event) (masters, exceptions map[string]*models.Event) {
+	masters = make(map[string]*models.Event)
+	exceptions = make(map[string]*models.Event)
+	for _, event := range existing {
+		if event.RecurrenceID != nil && event.SeriesID != 0 {
+			exceptions[exceptionKey(event.UID, *event.RecurrenceID)] = event
+		} else {
+			masters[event.UID] = event
+		}
+	}
+	return masters, exceptions
+}
+
+// importOrder возвращает события в порядке импорта: сначала основные,
+// чтобы измененные экземпляры нашли свои серии
+func importOrder(events []*models.Event) []*models.Event {
+	ordered := slices.Clone(events)
+	sort.SliceStable(ordered, func(i, j int) bool {
+		return ordered[i].RecurrenceID == nil && ordered[j].RecurrenceID != nil
+	})
+	return ordered
+}
+
+// importEvent создает или обновляет импортированное событие. Существующее
+// событие обновляется, только если его версия совпадает с versions[ID]
+// (nil — без проверки). Возвращает true, если событие создано.
+func (s *EventService) importEvent(userID int, event *models.Event, masters, exceptions map[string]*models.Event, versions map[int]int) (bool, error) {
+	if event.RecurrenceID == nil {
+		return s.importMaster(userID, event, masters, versions)
+	}
+	return s.importException(userID, event, masters, exceptions, versions)
+}
+
+// importMaster создает или обновляет одиночное событие или серию
+func (s *EventService) importMaster(userID int, event *models.Event, masters map[string]*models.Event, versions map[int]int) (bool, error) {
+	if err := prepareImported(userID, event); err != nil {
+		return false, err
+	}
+
+	rrule, err := normalizeRRule(event.RRule)
+	if err != nil {
+		return false, err
+	}
+	event.RRule = rrule
+
+	old, exists := masters[event.UID]
+	if exists {
+		event.ID = old.ID
+		event.Version = versions[old.ID]
+		keepAttendees(event, old)
+		if err := s.update(event, old, userID); err != nil {
+			return false, err
+		}
+	} else if err := s.create(event, userID); err != nil {
+		return false, err
+	}
+
+	masters[event.UID] = event
+	return !exists, nil
+}
+
+// importException создает или обновляет измененный экземпляр серии
+// и исключает исходный экземпляр из серии
+func (s *EventService) importException(userID int, event *models.Event, masters, exceptions map[string]*models.Event, versions map[int]int) (bool, error) {
+	if err := prepareImported(userID, event); err != nil {
+		return false, err
+	}
+
+	series, ok := masters[event.UID]
+	if !ok || !series.IsRecurring() {
+		return false, apperrors.Errorf(apperrors.ErrValidation, "recurring series not found")
+	}
+	event.SeriesID = series.ID
+	event.RRule = ""
+	event.ExDates = nil
+
+	key := exceptionKey(event.UID, *event.RecurrenceID)
+	old, exists := exceptions[key]
+	if exists {
+		event.ID = old.ID
+		event.Version = versions[old.ID]
+		keepAttendees(event, old)
+		if err := s.update(event, old, userID); err != nil {
+			return false, err
+		}
+	} else if err := s.create(event, userID); err != nil {
+		return false, err
+	}
+	exceptions[key] = event
+
+	if !slices.ContainsFunc(series.ExDates, event.RecurrenceID.Equal) {
+		updated := *series
+		updated.ExDates = append(slices.Clone(series.ExDates), *event.RecurrenceID)
+		if err := s.update(&updated, series, userID); err != nil {
+			return false, err
+		}
+		masters[event.UID] = &updated
+	}
+
+	return !exists, nil
+}
+
+// prepareImported проверяет импортированное событие и привязывает его к пользователю
+func prepareImported(userID int, event *models.Event) error {
+	event.Title = strings.TrimSpace(event.Title)
+	event.Description = strings.TrimSpace(event.Description)
+	if event.Title == "" {
+		return apperrors.Field("title", "title is required")
+	}
+	reminders, err := normalizeReminders(event.Reminders)
+	if err != nil {
+		return err
+	}
+	event.Reminders = reminders
+	if event.Tags, err = normalizeTags(event.Tags); err != nil {
+		return err
+	}
+	event.UserID = userID
+	return nil
+}
+
+// keepAttendees сохраняет участников и их ответы при повторном импорте:
+// iCalendar-представление событий участников не содержит
+func keepAttendees(event, old *models.Event) {
+	if event.Attendees == nil {
+		event.Attendees = old.Attendees
+	}
+}
+
+// exceptionKey ключ измененного экземпляра серии
+func exceptionKey(uid string, recurrenceID time.Time) string {
+	return fmt.Sprintf("%s|%d", uid, recurrenceID.Unix())
+}
+
+// newUID генерирует глобально уникальный идентификатор события
+func newUID() string {
+	b := make([]byte, 16)
+	_, _ = rand.Read(b)
+	return hex.EncodeToString(b) + "@l2-18"
+}
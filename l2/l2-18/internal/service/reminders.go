@@ -0,0 +1,88 @@
+package service
+
+import (
+	"fmt"
+	"l2-18/internal/apperrors"
+	"l2-18/internal/models"
+	"slices"
+	"time"
+)
+
+// maxReminderMinutes наибольшее смещение напоминания — четыре недели
+const maxReminderMinutes = 4 * 7 * 24 * 60
+
+// DueReminders возвращает напоминания всех пользователей, срабатывающие
+// в полуинтервале [from, to), упорядоченные по времени срабатывания.
+// Серии разворачиваются, напоминание получает каждый экземпляр.
+func (s *EventService) DueReminders(from, to time.Time) ([]models.Reminder, error) {
+	if s.reminders == nil {
+		return nil, fmt.Errorf("reminders are not supported by storage")
+	}
+
+	// Событие может начаться позже to на величину наибольшего смещения
+	horizon := to.Add(maxReminderMinutes * time.Minute)
+	events, err := s.reminders.GetAllByDateRange(from, horizon)
+	if err != nil {
+		return nil, fmt.Errorf("failed to get events: %w", err)
+	}
+	events = slices.DeleteFunc(events, func(event *models.Event) bool {
+		return len(event.Reminders) == 0
+	})
+
+	events, err = expandOccurrences(events, from, horizon)
+	if err != nil {
+		return nil, err
+	}
+
+	var result []models.Reminder
+	for _, event := range events {
+		for _, minutes := range event.Reminders {
+			fireAt := event.Start.Add(-time.Duration(minutes) * time.Minute)
+			if fireAt.Before(from) || !fireAt.Before(to) {
+				continue
+			}
+			result = append(result, models.Reminder{
+				Key:     reminderKey(event, minutes),
+				EventID: event.ID,
+				UID:     event.UID,
+				UserID:  event.UserID,
+				Title:   event.Title,
+				Start:   event.Start,
+				End:     event.End,
+				AllDay:  event.AllDay,
+				Minutes: minutes,
+				FireAt:  fireAt,
+			})
+		}
+	}
+	slices.SortStableFunc(result, func(a, b models.Reminder) int {
+		return a.FireAt.Compare(b.FireAt)
+	})
+
+	return result, nil
+}
+
+// reminderKey ключ напоминания об экземпляре события. Измененный экземпляр
+// серии делит UID с серией, поэтому при неизменном времени начала
+// напоминание о нем не дублирует уже доставленное.
+func reminderKey(event *models.Event, minutes int) string {
+	return fmt.Sprintf("%s|%d|%d", event.UID, event.Start.Unix(), minutes)
+}
+
+// normalizeReminders проверяет смещения напоминаний, упорядочивает их
+// и убирает повторы
+func normalizeReminders(reminders []int) ([]int, error) {
+	if len(reminders) == 0 {
+		return nil, nil
+	}
+
+	for _, minutes := range reminders {
+		if minutes < 0 || minutes > maxReminderMinutes {
+			return nil, apperrors.Field("reminders", "reminder must be between 0 and %d minutes before start", maxReminderMinutes)
+		}
+	}
+
+	result := slices.Clone(reminders)
+	slices.Sort(result)
+	return slices.Compact(result), nil
+}
@@ -0,0 +1,228 @@
+package service
+
+import (
+	"fmt"
+	"l2-18/internal/apperrors"
+	"l2-18/internal/models"
+	"slices"
+	"sort"
+	"strings"
+	"time"
+)
+
+const (
+	// maxScheduleWindow наибольшее окно запроса занятости и поиска свободного времени
+	maxScheduleWindow = 366 * 24 * time.Hour
+	// conflictHorizon насколько вперед проверяются на пересечения экземпляры новой серии
+	conflictHorizon = 366 * 24 * time.Hour
+)
+
+// ScheduleConflictError событие пересекается с уже существующими событиями
+type ScheduleConflictError struct {
+	// Events пересекающиеся события (экземпляры для серий)
+	Events []*models.Event
+}
+
+// Error перечисляет пересекающиеся события
+func (e *ScheduleConflictError) Error() string {
+	parts := make([]string, 0, len(e.Events))
+	for _, event := range e.Events {
+		parts = append(parts, fmt.Sprintf("%q at %s", event.Title, event.Start.Format(time.RFC3339)))
+	}
+	return "event overlaps with " + strings.Join(parts, ", ")
+}
+
+// Unwrap относит ошибку к конфликтам
+func (e *ScheduleConflictError) Unwrap() error {
+	return apperrors.ErrConflict
+}
+
+// FreeBusy возвращает занятое время пользователей в окне [from, to).
+// Занятость раскрывает только интервалы без названий событий, поэтому
+// доступна любому пользователю.
+func (s *EventService) FreeBusy(userIDs []int, from, to time.Time) (*models.FreeBusyList, error) {
+	userIDs, err := validateSchedule(userIDs, from, to)
+	if err != nil {
+		return nil, err
+	}
+
+	result := &models.FreeBusyList{From: from, To: to}
+	for _, userID := range userIDs {
+		busy, err := s.busyIntervals(userID, from, to)
+		if err != nil {
+			return nil, fmt.Errorf("failed to get free/busy: %w", err)
+		}
+		if busy == nil {
+			busy = []models.Interval{}
+		}
+		result.Users = append(result.Users, models.FreeBusy{UserID: userID, Busy: busy})
+	}
+
+	return result, nil
+}
+
+// NextFreeSlot возвращает ближайший промежуток длительностью duration
+// в окне [from, to), свободный у всех пользователей
+func (s *EventService) NextFreeSlot(userIDs []int, duration time.Duration, from, to time.Time) (*models.Interval, error) {
+	userIDs, err := validateSchedule(userIDs, from, to)
+	if err != nil {
+		return nil, err
+	}
+	if duration <= 0 || duration > maxScheduleWindow {
+		return nil, apperrors.Field("minutes", "invalid slot duration")
+	}
+
+	var busy []models.Interval
+	for _, userID := range userIDs {
+		intervals, err := s.busyIntervals(userID, from, to)
+		if err != nil {
+			return nil, fmt.Errorf("failed to find free slot: %w", err)
+		}
+		busy = append(busy, intervals...)
+	}
+
+	cursor := from
+	for _, interval := range mergeIntervals(busy) {
+		if interval.Start.Sub(cursor) >= duration {
+			break
+		}
+		if interval.End.After(cursor) {
+			cursor = interval.End
+		}
+	}
+	if to.Sub(cursor) < duration {
+		return nil, apperrors.Errorf(apperrors.ErrNotFound, "no free slot of %s between %s and %s",
+			duration, from.Format(time.RFC3339), to.Format(time.RFC3339))
+	}
+
+	return &models.Interval{Start: cursor, End: cursor.Add(duration)}, nil
+}
+
+// checkConflicts возвращает ScheduleConflictError, если событие пересекается
+// с другими событиями своего календаря или неотклоненными приглашениями владельца.
+// Компоненты того же объекта (серия и ее экземпляры) не учитываются.
+func (s *EventService) checkConflicts(event *models.Event) error {
+	if event.Duration() <= 0 {
+		return nil
+	}
+
+	occurrences, err := expandOccurrences([]*models.Event{event}, event.Start, event.Start.Add(conflictHorizon))
+	if err != nil || len(occurrences) == 0 {
+		return err
+	}
+
+	busy, err := s.busyEvents(event.UserID, occurrences[0].Start, occurrences[len(occurrences)-1].End)
+	if err != nil {
+		return fmt.Errorf("failed to check conflicts: %w", err)
+	}
+
+	var conflicts []*models.Event
+	for _, other := range busy {
+		if other.UID == event.UID {
+			continue
+		}
+		if slices.ContainsFunc(occurrences, func(o *models.Event) bool { return other.Overlaps(o.Start, o.End) }) {
+			conflicts = append(conflicts, other)
+		}
+	}
+	if len(conflicts) > 0 {
+		return &ScheduleConflictError{Events: conflicts}
+	}
+
+	return nil
+}
+
+// busyEvents возвращает экземпляры событий, занимающих время пользователя
+// в [start, end): его события и приглашения, которые он не отклонил.
+// События без длительности время не занимают.
+func (s *EventService) busyEvents(userID int, start, end time.Time) ([]*models.Event, error) {
+	events, err := s.getEvents(userID, start, end)
+	if err != nil {
+		return nil, err
+	}
+
+	var result []*models.Event
+	for _, event := range events {
+		if event.Duration() <= 0 {
+			continue
+		}
+		if attendee := event.Attendee(userID); attendee != nil && attendee.Status == models.RSVPDeclined {
+			continue
+		}
+		result = append(result, event)
+	}
+
+	return result, nil
+}
+
+// busyIntervals возвращает занятое время пользователя в [start, end)
+// в виде непересекающихся интервалов, обрезанных по границам окна
+func (s *EventService) busyIntervals(userID int, start, end time.Time) ([]models.Interval, error) {
+	events, err := s.busyEvents(userID, start, end)
+	if err != nil {
+		return nil, err
+	}
+
+	intervals := make([]models.Interval, 0, len(events))
+	for _, event := range events {
+		interval := models.Interval{Start: event.Start, End: event.End}
+		if interval.Start.Before(start) {
+			interval.Start = start
+		}
+		if interval.End.After(end) {
+			interval.End = end
+		}
+		intervals = append(intervals, interval)
+	}
+
+	return mergeIntervals(intervals), nil
+}
+
+// mergeIntervals упорядочивает интервалы и объединяет пересекающиеся и смежные
+func mergeIntervals(intervals []models.Interval) []models.Interval {
+	sort.Slice(intervals, func(i, j int) bool { return intervals[i].Start.Before(intervals[j].Start) })
+
+	var result []models.Interval
+	for _, interval := range intervals {
+		if n := len(result); n > 0 && !interval.Start.After(result[n-1].End) {
+			if interval.End.After(result[n-1].End) {
+				result[n-1].End = interval.End
+			}
+			continue
+		}
+		result = append(result, interval)
+	}
+
+	return result
+}
+
+// validateSchedule проверяет пользователей и окно запроса занятости
+// и возвращает пользователей без повторов
+func validateSchedule(userIDs []int, from, to time.Time) ([]int, error) {
+	v := &apperrors.ValidationError{}
+	if len(userIDs) == 0 {
+		v.Add("users", "at least one user is required")
+	}
+	for _, userID := range userIDs {
+		if userID <= 0 {
+			v.Add("users", "invalid user ID %d", userID)
+			break
+		}
+	}
+	if !from.Before(to) {
+		v.Add("to", "invalid date range")
+	} else if to.Sub(from) > maxScheduleWindow {
+		v.Add("to", "date range must not exceed %d days", int(maxScheduleWindow.Hours()/24))
+	}
+	if err := v.Err(); err != nil {
+		return nil, err
+	}
+
+	var result []int
+	for _, userID := range userIDs {
+		if !slices.Contains(result, userID) {
+			result = append(result, userID)
+		}
+	}
+	return result, nil
+}
@@ -0,0 +1,196 @@
+package service
+
+import (
+	"cmp"
+	"encoding/base64"
+	"encoding/json"
+	"fmt"
+	"l2-18/internal/apperrors"
+	"l2-18/internal/models"
+	"l2-18/internal/search"
+	"l2-18/internal/storage"
+	"sort"
+	"strings"
+	"time"
+)
+
+const (
+	defaultSearchLimit = 50
+	maxSearchLimit     = 200
+
+	maxTags      = 20
+	maxTagLength = 64
+)
+
+// searchKeys сравнения событий по ключам сортировки выдачи
+var searchKeys = map[string]func(a, b *models.Event) int{
+	models.SortStart: func(a, b *models.Event) int { return a.Start.Compare(b.Start) },
+	models.SortTitle: func(a, b *models.Event) int {
+		return strings.Compare(search.Fold(a.Title), search.Fold(b.Title))
+	},
+	models.SortUpdated: func(a, b *models.Event) int { return a.UpdatedAt.Compare(b.UpdatedAt) },
+}
+
+// SearchEvents ищет события пользователя и приглашения по словам названия
+// и описания, меткам и участникам. Если задан диапазон дат (не длиннее
+// maxRange), серии разворачиваются в экземпляры из него. Выдача постраничная: курсор
+// следующей страницы указывает на последнее событие текущей, поэтому
+// страницы не сдвигаются при добавлении и удалении событий.
+func (s *EventService) SearchEvents(req *models.SearchRequest) (*models.SearchResult, error) {
+	v := &apperrors.ValidationError{}
+	if req.UserID <= 0 {
+		v.Add("user_id", "invalid user ID")
+	}
+	if req.Limit < 0 || req.Limit > maxSearchLimit {
+		v.Add("limit", "limit must be between 1 and %d", maxSearchLimit)
+	}
+	if req.From.IsZero() != req.To.IsZero() {
+		v.Add("to", "from and to must be given together")
+	} else if !req.From.IsZero() && !req.From.Before(req.To) {
+		v.Add("to", "invalid date range")
+	} else if req.To.Sub(req.From) > s.maxRange {
+		v.Add("to", "date range must not exceed %d days", int(s.maxRange.Hours()/24))
+	}
+	if err := v.Err(); err != nil {
+		return nil, err
+	}
+
+	limit := req.Limit
+	if limit == 0 {
+		limit = defaultSearchLimit
+	}
+
+	sortBy := req.Sort
+	if sortBy == "" {
+		sortBy = models.SortStart
+	}
+	order, err := searchOrder(sortBy)
+	if err != nil {
+		return nil, err
+	}
+
+	var after *models.Event
+	if req.Cursor != "" {
+		if after, err = decodeCursor(req.Cursor, sortBy); err != nil {
+			return nil, err
+		}
+	}
+
+	if s.searcher == nil {
+		return nil, fmt.Errorf("search is not supported by storage")
+	}
+	events, err := s.searcher.Search(req.UserID, storage.SearchFilter{
+		Terms:     search.Tokens(req.Query),
+		Tags:      req.Tags,
+		Attendees: req.Attendees,
+	})
+	if err != nil {
+		return nil, fmt.Errorf("failed to search events: %w", err)
+	}
+
+	if req.From.IsZero() {
+		for i, event := range events {
+			events[i] = localize([]*models.Event{event}, eventLocation(event))[0]
+		}
+	} else if events, err = expandEvents(events, req.From, req.To); err != nil {
+		return nil, err
+	}
+
+	sort.SliceStable(events, func(i, j int) bool { return order(events[i], events[j]) < 0 })
+
+	start := 0
+	if after != nil {
+		start = sort.Search(len(events), func(i int) bool { return order(after, events[i]) < 0 })
+	}
+	end := min(start+limit, len(events))
+
+	result := &models.SearchResult{Events: events[start:end]}
+	if end < len(events) {
+		result.NextCursor = encodeCursor(sortBy, events[end-1])
+	}
+
+	return result, nil
+}
+
+// searchOrder возвращает полный порядок выдачи: по ключу sort (с минусом —
+// в обратном порядке), при равенстве — по началу и ID события
+func searchOrder(sortBy string) (func(a, b *models.Event) int, error) {
+	desc := strings.HasPrefix(sortBy, "-")
+	compareKey, ok := searchKeys[strings.TrimPrefix(sortBy, "-")]
+	if !ok {
+		return nil, apperrors.Field("sort", "unknown sort order %q", sortBy)
+	}
+
+	return func(a, b *models.Event) int {
+		c := compareKey(a, b)
+		if c == 0 {
+			c = a.Start.Compare(b.Start)
+		}
+		if c == 0 {
+			c = cmp.Compare(a.ID, b.ID)
+		}
+		if desc {
+			return -c
+		}
+		return c
+	}, nil
+}
+
+// searchCursor позиция в выдаче: ключи сортировки последнего выданного события
+type searchCursor struct {
+	Sort    string    `json:"s"`
+	ID      int       `json:"i"`
+	Start   time.Time `json:"b"`
+	Title   string    `json:"t,omitempty"`
+	Updated time.Time `json:"u,omitzero"`
+}
+
+// encodeCursor кодирует позицию после события в непрозрачную строку
+func encodeCursor(sortBy string, event *models.Event) string {
+	data, _ := json.Marshal(searchCursor{
+		Sort:    sortBy,
+		ID:      event.ID,
+		Start:   event.Start,
+		Title:   event.Title,
+		Updated: event.UpdatedAt,
+	})
+	return base64.RawURLEncoding.EncodeToString(data)
+}
+
+// decodeCursor восстанавливает из курсора событие, после которого продолжается выдача
+func decodeCursor(cursor, sortBy string) (*models.Event, error) {
+	var c searchCursor
+	data, err := base64.RawURLEncoding.DecodeString(cursor)
+	if err == nil {
+		err = json.Unmarshal(data, &c)
+	}
+	if err != nil {
+		return nil, apperrors.Field("cursor", "invalid cursor")
+	}
+	if c.Sort != sortBy {
+		return nil, apperrors.Field("cursor", "cursor was issued for sort order %q", c.Sort)
+	}
+
+	return &models.Event{ID: c.ID, Start: c.Start, Title: c.Title, UpdatedAt: c.Updated}, nil
+}
+
+// normalizeTags убирает пробелы по краям, пустые метки и повторы без учета регистра
+func normalizeTags(tags []string) ([]string, error) {
+	var result []string
+	seen := make(map[string]bool, len(tags))
+	for _, tag := range tags {
+		tag = strings.TrimSpace(tag)
+		if tag == "" || seen[search.Fold(tag)] {
+			continue
+		}
+		if len([]rune(tag)) > maxTagLength {
+			return nil, apperrors.Field("tags", "tag must not exceed %d characters", maxTagLength)
+		}
+		seen[search.Fold(tag)] = true
+		result = append(result, tag)
+	}
+	if len(result) > maxTags {
+		return nil, apperrors.Field("tags", "at most %d tags are allowed", maxTags)
+	}
+	return result, nil
+}
@@ -0,0 +1,239 @@
+package service
+
+import (
+	"errors"
+	"fmt"
+	"l2-18/internal/apperrors"
+	"l2-18/internal/models"
+	"slices"
+	"time"
+)
+
+// Календарь пользователя — все события, которыми он владеет. Владелец
+// может открыть календарь другим пользователям на чтение или на запись,
+// а в отдельные события — пригласить участников. Участники видят
+// приглашения среди своих событий и отвечают на них, но менять событие
+// могут только владелец и пользователи с правом записи в его календарь.
+
+// ShareCalendar открывает пользователю доступ к календарю владельца
+// или меняет права уже открытого доступа
+func (s *EventService) ShareCalendar(req *models.ShareCalendarRequest) (*models.Share, error) {
+	v := &apperrors.ValidationError{}
+	if req.UserID <= 0 {
+		v.Add("user_id", "invalid user ID")
+	}
+	if req.ShareWith <= 0 || req.ShareWith == req.UserID {
+		v.Add("share_with", "invalid user to share with")
+	}
+	if req.Permission != models.PermissionRead && req.Permission != models.PermissionWrite {
+		v.Add("permission", "permission must be %s or %s", models.PermissionRead, models.PermissionWrite)
+	}
+	if err := v.Err(); err != nil {
+		return nil, err
+	}
+	if s.sharing == nil {
+		return nil, fmt.Errorf("calendar sharing is not supported by storage")
+	}
+
+	share := models.Share{OwnerID: req.UserID, UserID: req.ShareWith, Permission: req.Permission}
+	if err := s.sharing.SetShare(share); err != nil {
+		return nil, fmt.Errorf("failed to share calendar: %w", err)
+	}
+
+	return &share, nil
+}
+
+// UnshareCalendar закрывает пользователю доступ к календарю владельца
+func (s *EventService) UnshareCalendar(ownerID, userID int) error {
+	if ownerID <= 0 {
+		return apperrors.Field("user_id", "invalid user ID")
+	}
+	if userID <= 0 {
+		return apperrors.Field("share_with", "invalid user to share with")
+	}
+	if s.sharing == nil {
+		return fmt.Errorf("calendar sharing is not supported by storage")
+	}
+
+	if err := s.sharing.DeleteShare(ownerID, userID); err != nil {
+		return fmt.Errorf("failed to unshare calendar: %w", err)
+	}
+
+	return nil
+}
+
+// GetCalendarShares возвращает пользователей, которым открыт календарь владельца
+func (s *EventService) GetCalendarShares(ownerID int) ([]models.Share, error) {
+	if ownerID <= 0 {
+		return nil, apperrors.Field("user_id", "invalid user ID")
+	}
+	if s.sharing == nil {
+		return nil, nil
+	}
+
+	return s.sharing.GetShares(ownerID)
+}
+
+// GetSharedCalendars возвращает чужие календари, открытые пользователю
+func (s *EventService) GetSharedCalendars(userID int) ([]models.Share, error) {
+	if userID <= 0 {
+		return nil, apperrors.Field("user_id", "invalid user ID")
+	}
+	if s.sharing == nil {
+		return nil, nil
+	}
+
+	return s.sharing.GetSharedCalendars(userID)
+}
+
+// GetCalendarEvents возвращает события календаря ownerID, пересекающиеся
+// с [start, end), от имени пользователя userID. Свой календарь включает
+// приглашения, чужой доступен только при открытом доступе и без них.
+func (s *EventService) GetCalendarEvents(userID, ownerID int, start, end time.Time) ([]*models.Event, error) {
+	if ownerID == userID {
+		return s.GetEvents(userID, start, end)
+	}
+	if userID <= 0 {
+		return nil, apperrors.Field("user_id", "invalid user ID")
+	}
+	if err := s.checkRange(start, end); err != nil {
+		return nil, err
+	}
+
+	if err := s.checkCalendarAccess(ownerID, userID, models.PermissionRead); err != nil {
+		return nil, err
+	}
+
+	events, err := s.storage.GetByDateRange(ownerID, start, end)
+	if err != nil {
+		return nil, err
+	}
+
+	return expandEvents(events, start, end)
+}
+
+// RespondToEvent сохраняет ответ участника на приглашение
+func (s *EventService) RespondToEvent(req *models.RSVPRequest) (*models.Event, error) {
+	v := &apperrors.ValidationError{}
+	if req.ID <= 0 {
+		v.Add("id", "invalid event ID")
+	}
+	if req.UserID <= 0 {
+		v.Add("user_id", "invalid user ID")
+	}
+	switch req.Status {
+	case models.RSVPAccepted, models.RSVPDeclined, models.RSVPTentative:
+	default:
+		v.Add("status", "status must be %s, %s or %s", models.RSVPAccepted, models.RSVPDeclined, models.RSVPTentative)
+	}
+	if err := v.Err(); err != nil {
+		return nil, err
+	}
+
+	event, err := s.authorize(req.ID, req.UserID, models.PermissionRead)
+	if err != nil {
+		return nil, fmt.Errorf("failed to respond to event: %w", err)
+	}
+	if event.Attendee(req.UserID) == nil {
+		return nil, apperrors.Errorf(apperrors.ErrForbidden, "user %d is not invited to event %d", req.UserID, req.ID)
+	}
+
+	updated := *event
+	updated.Attendees = slices.Clone(event.Attendees)
+	updated.Attendee(req.UserID).Status = req.Status
+
+	if err := s.update(&updated, event, req.UserID); err != nil {
+		return nil, fmt.Errorf("failed to respond to event: %w", err)
+	}
+
+	return &updated, nil
+}
+
+// authorize возвращает событие, если у пользователя есть право permission
+// на него: владельцу доступно все, пользователю с доступом к календарю —
+// то, что разрешает доступ, а участнику — только чтение
+func (s *EventService) authorize(id, userID int, permission string) (*models.Event, error) {
+	event, err := s.storage.GetByID(id, userID)
+	if err == nil || s.sharing == nil || !errors.Is(err, apperrors.ErrForbidden) {
+		return event, err
+	}
+
+	event, err = s.sharing.Find(id)
+	if err != nil {
+		return nil, err
+	}
+
+	allowed, err := s.hasCalendarAccess(event.UserID, userID, permission)
+	if err != nil {
+		return nil, err
+	}
+	if allowed || (permission == models.PermissionRead && event.Attendee(userID) != nil) {
+		return event, nil
+	}
+
+	return nil, apperrors.Errorf(apperrors.ErrForbidden, "user %d has no %s access to event %d", userID, permission, id)
+}
+
+// checkCalendarAccess возвращает ErrForbidden, если у пользователя нет права
+// permission на календарь владельца ownerID
+func (s *EventService) checkCalendarAccess(ownerID, userID int, permission string) error {
+	allowed, err := s.hasCalendarAccess(ownerID, userID, permission)
+	if err != nil {
+		return err
+	}
+	if !allowed {
+		return apperrors.Errorf(apperrors.ErrForbidden, "user %d has no %s access to calendar %d", userID, permission, ownerID)
+	}
+	return nil
+}
+
+// hasCalendarAccess сообщает, есть ли у пользователя право permission
+// на календарь владельца ownerID. Право записи включает чтение.
+func (s *EventService) hasCalendarAccess(ownerID, userID int, permission string) (bool, error) {
+	if ownerID == userID {
+		return true, nil
+	}
+	if s.sharing == nil {
+		return false, nil
+	}
+
+	share, err := s.sharing.GetShare(ownerID, userID)
+	if err != nil {
+		return false, fmt.Errorf("failed to check calendar access: %w", err)
+	}
+	if share == nil {
+		return false, nil
+	}
+
+	return permission == models.PermissionRead || share.Permission == models.PermissionWrite, nil
+}
+
+// mergeAttendees строит список участников из ID пользователей, сохраняя
+// ответы уже приглашенных. Владелец события и повторы пропускаются.
+func mergeAttendees(existing []models.Attendee, userIDs []int, ownerID int) ([]models.Attendee, error) {
+	var result []models.Attendee
+	for _, userID := range userIDs {
+		if userID <= 0 {
+			return nil, apperrors.Field("attendees", "invalid attendee user ID %d", userID)
+		}
+		if userID == ownerID || slices.ContainsFunc(result, func(a models.Attendee) bool { return a.UserID == userID }) {
+			continue
+		}
+
+		status := models.RSVPNeedsAction
+		if i := slices.IndexFunc(existing, func(a models.Attendee) bool { return a.UserID == userID }); i >= 0 {
+			status = existing[i].Status
+		}
+		result = append(result, models.Attendee{UserID: userID, Status: status})
+	}
+	return result, nil
+}
+
+// attendeeIDs возвращает ID участников события
+func attendeeIDs(event *models.Event) []int {
+	var result []int
+	for _, attendee := range event.Attendees {
+		result = append(result, attendee.UserID)
+	}
+	return result
+}
@@ -0,0 +1,138 @@
+package service
+
+import (
+	"errors"
+	"fmt"
+	"l2-18/internal/apperrors"
+	"l2-18/internal/models"
+	"time"
+)
+
+// DefaultTrashRetention сколько удаленные события хранятся в корзине по умолчанию
+const DefaultTrashRetention = 30 * 24 * time.Hour
+
+// SetTrashRetention задает, сколько удаленные события хранятся в корзине
+// до окончательного удаления
+func (s *EventService) SetTrashRetention(retention time.Duration) {
+	s.trashRetention = retention
+}
+
+// GetTrash возвращает события в корзине пользователя, начиная с удаленных
+// последними, вместе со временем их окончательного удаления
+func (s *EventService) GetTrash(userID int) ([]models.TrashedEvent, error) {
+	if userID <= 0 {
+		return nil, apperrors.Field("user_id", "invalid user ID")
+	}
+	if s.trash == nil {
+		return nil, nil
+	}
+
+	trashed, err := s.trash.GetTrash(userID)
+	if err != nil {
+		return nil, fmt.Errorf("failed to get trash: %w", err)
+	}
+	for i := range trashed {
+		trashed[i].PurgeAt = trashed[i].DeletedAt.Add(s.trashRetention)
+	}
+
+	return trashed, nil
+}
+
+// RestoreEvent возвращает событие из корзины пользователя. Вместе с серией
+// восстанавливаются ее измененные экземпляры, удаленные вместе с ней;
+// экземпляр удаленной серии восстанавливается только после серии.
+func (s *EventService) RestoreEvent(req *models.RestoreEventRequest) (*models.Event, error) {
+	v := &apperrors.ValidationError{}
+	if req.ID <= 0 {
+		v.Add("id", "invalid event ID")
+	}
+	if req.UserID <= 0 {
+		v.Add("user_id", "invalid user ID")
+	}
+	if err := v.Err(); err != nil {
+		return nil, err
+	}
+	if s.trash == nil {
+		return nil, apperrors.Errorf(apperrors.ErrNotFound, "event with ID %d is not in the trash", req.ID)
+	}
+
+	trash, err := s.trash.GetTrash(req.UserID)
+	if err != nil {
+		return nil, fmt.Errorf("failed to restore event: %w", err)
+	}
+	var target *models.TrashedEvent
+	for i := range trash {
+		if trash[i].Event.ID == req.ID {
+			target = &trash[i]
+			break
+		}
+	}
+	if target == nil {
+		return nil, apperrors.Errorf(apperrors.ErrNotFound, "event with ID %d is not in the trash", req.ID)
+	}
+
+	if seriesID := target.Event.SeriesID; seriesID != 0 {
+		if _, err := s.storage.GetByID(seriesID, req.UserID); errors.Is(err, apperrors.ErrNotFound) {
+			return nil, apperrors.Errorf(apperrors.ErrConflict, "series %d of the event is deleted, restore the series first", seriesID)
+		} else if err != nil {
+			return nil, fmt.Errorf("failed to restore event: %w", err)
+		}
+	}
+
+	event, err := s.trash.RestoreEvent(req.ID, req.UserID)
+	if err != nil {
+		return nil, fmt.Errorf("failed to restore event: %w", err)
+	}
+	published := s.publish(models.ChangeRestored, req.UserID, event, nil)
+
+	// Экземпляры удаляются сразу после серии, а удаленные раньше
+	// остаются в корзине: их время и так исключено из серии
+	if event.IsRecurring() {
+		for _, trashed := range trash {
+			if trashed.Event.SeriesID != event.ID || trashed.DeletedAt.Before(target.DeletedAt) {
+				continue
+			}
+			exception, err := s.trash.RestoreEvent(trashed.Event.ID, req.UserID)
+			if err != nil {
+				return nil, fmt.Errorf("failed to restore occurrence of the series: %w", err)
+			}
+			published = errors.Join(published, s.publish(models.ChangeRestored, req.UserID, exception, nil))
+		}
+	}
+	if published != nil {
+		return nil, fmt.Errorf("failed to restore event: %w", published)
+	}
+
+	return event, nil
+}
+
+// PurgeEvent окончательно удаляет событие из корзины пользователя
+func (s *EventService) PurgeEvent(id, userID int) error {
+	if userID <= 0 {
+		return apperrors.Field("user_id", "invalid user ID")
+	}
+	if s.trash == nil {
+		return apperrors.Errorf(apperrors.ErrNotFound, "event with ID %d is not in the trash", id)
+	}
+
+	if err := s.trash.PurgeEvent(id, userID); err != nil {
+		return fmt.Errorf("failed to purge event: %w", err)
+	}
+
+	return nil
+}
+
+// PurgeTrash окончательно удаляет события, пролежавшие в корзине дольше
+// срока хранения к моменту now, и возвращает их число
+func (s *EventService) PurgeTrash(now time.Time) (int, error) {
+	if s.trash == nil {
+		return 0, nil
+	}
+
+	purged, err := s.trash.PurgeTrash(now.Add(-s.trashRetention))
+	if err != nil {
+		return purged, fmt.Errorf("failed to purge trash: %w", err)
+	}
+
+	return purged, nil
+}
@@ -0,0 +1,234 @@
+package service
+
+import (
+	"context"
+	"crypto/rand"
+	"encoding/hex"
+	"fmt"
+	"l2-18/internal/apperrors"
+	"l2-18/internal/models"
+	"net"
+	"net/netip"
+	"net/url"
+	"slices"
+	"strings"
+	"time"
+)
+
+// MaxWebhooks сколько webhook может зарегистрировать один пользователь
+const MaxWebhooks = 10
+
+// minSecretLength наименьшая длина секрета, заданного пользователем
+const minSecretLength = 16
+
+// resolveTimeout время ожидания разрешения имени хоста webhook при регистрации
+const resolveTimeout = 5 * time.Second
+
+// reservedPrefixes служебные диапазоны адресов, не покрытые проверками netip.Addr
+var reservedPrefixes = []netip.Prefix{
+	netip.MustParsePrefix("0.0.0.0/8"),
+	netip.MustParsePrefix("100.64.0.0/10"),
+	netip.MustParsePrefix("192.0.0.0/24"),
+	netip.MustParsePrefix("198.18.0.0/15"),
+	netip.MustParsePrefix("240.0.0.0/4"),
+	netip.MustParsePrefix("64:ff9b::/96"),
+}
+
+// PublicAddr сообщает, можно ли отправлять webhook на адрес. Loopback,
+// link-local, частные, служебные и multicast адреса запрещены, чтобы через
+// webhook нельзя было обратиться к внутренним сервисам.
+func PublicAddr(addr netip.Addr) bool {
+	addr = addr.Unmap()
+	if !addr.IsGlobalUnicast() || addr.IsPrivate() {
+		return false
+	}
+	for _, prefix := range reservedPrefixes {
+		if prefix.Contains(addr) {
+			return false
+		}
+	}
+	return true
+}
+
+// SetPrivateWebhooks разрешает регистрировать webhook на внутренние адреса,
+// например для локальной разработки
+func (s *EventService) SetPrivateWebhooks(allowed bool) {
+	s.privateWebhooks = allowed
+}
+
+// checkWebhookHost проверяет, что хост webhook не указывает на внутренний
+// адрес. Имя, которое сейчас не разрешается, допускается: адрес все равно
+// проверяется при каждой доставке.
+func (s *EventService) checkWebhookHost(host string) error {
+	if s.privateWebhooks {
+		return nil
+	}
+
+	if addr, err := netip.ParseAddr(host); err == nil {
+		if !PublicAddr(addr) {
+			return fmt.Errorf("address %s is not allowed", addr)
+		}
+		return nil
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), resolveTimeout)
+	defer cancel()
+	addrs, err := net.DefaultResolver.LookupNetIP(ctx, "ip", host)
+	if err != nil {
+		return nil
+	}
+	for _, addr := range addrs {
+		if !PublicAddr(addr) {
+			return fmt.Errorf("host %s resolves to address %s which is not allowed", host, addr.Unmap())
+		}
+	}
+	return nil
+}
+
+// RegisterWebhook регистрирует webhook для изменений событий пользователя.
+// Возвращенный webhook содержит секрет подписи; позже он не показывается.
+func (s *EventService) RegisterWebhook(req *models.CreateWebhookRequest) (*models.Webhook, error) {
+	v := &apperrors.ValidationError{}
+	if req.UserID <= 0 {
+		v.Add("user_id", "invalid user ID")
+	}
+	target, err := url.Parse(strings.TrimSpace(req.URL))
+	if err != nil || (target.Scheme != "http" && target.Scheme != "https") || target.Host == "" {
+		v.Add("url", "url must be an absolute http or https URL")
+	} else if err := s.checkWebhookHost(target.Hostname()); err != nil {
+		v.Add("url", "%v", err)
+	}
+	if req.Secret != "" && len(req.Secret) < minSecretLength {
+		v.Add("secret", "secret must be at least %d characters", minSecretLength)
+	}
+	var events []string
+	for _, event := range req.Events {
+		if !slices.Contains(models.ChangeTypes, event) {
+			v.Add("events", "unknown event type %q, must be one of %s", event, strings.Join(models.ChangeTypes, ", "))
+			continue
+		}
+		if !slices.Contains(events, event) {
+			events = append(events, event)
+		}
+	}
+	if err := v.Err(); err != nil {
+		return nil, err
+	}
+	if s.webhooks == nil {
+		return nil, fmt.Errorf("webhooks are not supported by storage")
+	}
+
+	existing, err := s.webhooks.GetWebhooks(req.UserID)
+	if err != nil {
+		return nil, fmt.Errorf("failed to register webhook: %w", err)
+	}
+	if len(existing) >= MaxWebhooks {
+		return nil, apperrors.Errorf(apperrors.ErrValidation, "user %d already has %d webhooks", req.UserID, MaxWebhooks)
+	}
+
+	secret := req.Secret
+	if secret == "" {
+		b := make([]byte, 32)
+		_, _ = rand.Read(b)
+		secret = hex.EncodeToString(b)
+	}
+
+	webhook := &models.Webhook{
+		UserID:    req.UserID,
+		URL:       target.String(),
+		Secret:    secret,
+		Events:    events,
+		CreatedAt: time.Now().UTC().Truncate(time.Second),
+	}
+	if err := s.webhooks.CreateWebhook(webhook); err != nil {
+		return nil, fmt.Errorf("failed to register webhook: %w", err)
+	}
+
+	return webhook, nil
+}
+
+// GetWebhooks возвращает webhook пользователя без секретов
+func (s *EventService) GetWebhooks(userID int) ([]models.Webhook, error) {
+	if userID <= 0 {
+		return nil, apperrors.Field("user_id", "invalid user ID")
+	}
+	if s.webhooks == nil {
+		return nil, nil
+	}
+
+	webhooks, err := s.webhooks.GetWebhooks(userID)
+	if err != nil {
+		return nil, fmt.Errorf("failed to get webhooks: %w", err)
+	}
+	for i := range webhooks {
+		webhooks[i].Secret = ""
+	}
+
+	return webhooks, nil
+}
+
+// DeleteWebhook удаляет webhook пользователя
+func (s *EventService) DeleteWebhook(userID, id int) error {
+	if userID <= 0 {
+		return apperrors.Field("user_id", "invalid user ID")
+	}
+	if s.webhooks == nil {
+		return fmt.Errorf("webhooks are not supported by storage")
+	}
+
+	if err := s.webhooks.DeleteWebhook(id, userID); err != nil {
+		return fmt.Errorf("failed to delete webhook: %w", err)
+	}
+
+	return nil
+}
+
+// GetDeadLetters возвращает изменения, которые не удалось доставить
+// на webhook пользователя
+func (s *EventService) GetDeadLetters(userID int) ([]models.DeadLetter, error) {
+	if userID <= 0 {
+		return nil, apperrors.Field("user_id", "invalid user ID")
+	}
+	if s.webhooks == nil {
+		return nil, nil
+	}
+
+	letters, err := s.webhooks.GetDeadLetters(userID)
+	if err != nil {
+		return nil, fmt.Errorf("failed to get dead letters: %w", err)
+	}
+
+	return letters, nil
+}
+
+// WebhookTargets возвращает webhook, на которые нужно доставить изменение:
+// webhook всех пользователей, которым оно видно, подписанные на его тип.
+// Webhook возвращаются с секретами для подписи.
+func (s *EventService) WebhookTargets(change models.Change) ([]models.Webhook, error) {
+	if s.webhooks == nil {
+		return nil, nil
+	}
+
+	var targets []models.Webhook
+	for _, userID := range change.Recipients {
+		webhooks, err := s.webhooks.GetWebhooks(userID)
+		if err != nil {
+			return nil, err
+		}
+		for _, webhook := range webhooks {
+			if webhook.Accepts(change.Type) {
+				targets = append(targets, webhook)
+			}
+		}
+	}
+
+	return targets, nil
+}
+
+// RecordDeadLetter сохраняет изменение, которое не удалось доставить на webhook
+func (s *EventService) RecordDeadLetter(letter *models.DeadLetter) error {
+	if s.webhooks == nil {
+		return fmt.Errorf("webhooks are not supported by storage")
+	}
+	return s.webhooks.AddDeadLetter(letter)
+}
@@ -0,0 +1,149 @@
+package storage
+
+import (
+	"fmt"
+	"l2-18/internal/apperrors"
+	"l2-18/internal/models"
+	"time"
+)
+
+// Виды операций пакета
+const (
+	BatchCreate = "create"
+	BatchUpdate = "update"
+	BatchDelete = "delete"
+	BatchTrash  = "trash"
+)
+
+// BatchOp операция пакета. Create и Update выполняются над Event так же,
+// как одноименные методы EventStorage; Delete удаляет событие ID
+// пользователя UserID, если оно не изменилось с версии Version, а Trash
+// так же переносит его в корзину (для хранилищ с TrashStorage).
+type BatchOp struct {
+	Kind    string
+	Event   *models.Event
+	ID      int
+	UserID  int
+	Version int
+}
+
+// BatchStorage интерфейс хранилища, выполняющего пакет изменений атомарно
+type BatchStorage interface {
+	// ApplyBatch выполняет все операции пакета или, если хотя бы одна
+	// из них невозможна, ни одной и возвращает *BatchError. Каждое событие
+	// может изменяться или удаляться в пакете только один раз.
+	ApplyBatch(ops []BatchOp) error
+}
+
+// BatchError операция пакета, из-за которой пакет не выполнен
+type BatchError struct {
+	Index int
+	Err   error
+}
+
+// Error возвращает номер операции и причину
+func (e *BatchError) Error() string {
+	return fmt.Sprintf("batch operation %d: %v", e.Index, e.Err)
+}
+
+// Unwrap возвращает причину для errors.Is
+func (e *BatchError) Unwrap() error {
+	return e.Err
+}
+
+// ApplyBatch выполняет пакет операций атомарно
+func (s *InMemoryEventStorage) ApplyBatch(ops []BatchOp) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	// Все операции проверяются до первого изменения. События меняются
+	// в пакете не более одного раза, поэтому проверка по текущему
+	// состоянию верна и для последних операций пакета.
+	changed := make(map[int]bool)
+	for i, op := range ops {
+		if err := s.checkBatchOp(op, changed); err != nil {
+			return &BatchError{Index: i, Err: err}
+		}
+	}
+
+	for _, op := range ops {
+		switch op.Kind {
+		case BatchCreate:
+			s.create(op.Event)
+		case BatchUpdate:
+			_ = s.update(op.Event)
+		case BatchDelete:
+			_ = s.delete(op.ID, op.UserID, op.Version)
+		case BatchTrash:
+			_ = s.trashEvent(op.ID, op.UserID, op.Version, time.Now())
+		}
+	}
+
+	return nil
+}
+
+// checkBatchOp проверяет, что операцию пакета можно выполнить;
+// changed — события, изменяемые предыдущими операциями
+func (s *InMemoryEventStorage) checkBatchOp(op BatchOp, changed map[int]bool) error {
+	id, userID, version := op.ID, op.UserID, op.Version
+	switch op.Kind {
+	case BatchCreate:
+		if op.Event == nil {
+			return fmt.Errorf("operation has no event")
+		}
+		return nil
+	case BatchUpdate:
+		if op.Event == nil {
+			return fmt.Errorf("operation has no event")
+		}
+		id, userID, version = op.Event.ID, op.Event.UserID, op.Event.Version
+	case BatchDelete, BatchTrash:
+	default:
+		return fmt.Errorf("unknown batch operation %q", op.Kind)
+	}
+
+	if changed[id] {
+		return apperrors.Errorf(apperrors.ErrConflict, "event %d is changed more than once in the batch", id)
+	}
+	changed[id] = true
+
+	_, err := s.writable(id, userID, version)
+	return err
+}
+
+// ApplyBatch выполняет пакет операций атомарно и сохраняет результат
+// в файл. Если сохранить не удалось, пакет отменяется и в памяти.
+func (s *FileEventStorage) ApplyBatch(ops []BatchOp) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if err := s.mem.ApplyBatch(ops); err != nil {
+		return err
+	}
+	return s.save()
+}
+
+// ApplyBatch выполняет пакет операций атомарно. Пакет записывается
+// в журнал одной записью, поэтому после сбоя он либо воспроизводится
+// целиком, либо отбрасывается.
+func (s *WALEventStorage) ApplyBatch(ops []BatchOp) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if err := s.mem.ApplyBatch(ops); err != nil {
+		return err
+	}
+
+	records := make([]walRecord, 0, len(ops))
+	for _, op := range ops {
+		switch op.Kind {
+		case BatchDelete:
+			records = append(records, walRecord{Op: opDelete, ID: op.ID})
+		case BatchTrash:
+			records = append(records, s.trashRecord(op.ID))
+		default:
+			records = append(records, walRecord{Op: opPut, Event: op.Event})
+		}
+	}
+	return s.append(walRecord{Op: opBatch, Batch: records})
+}
@@ -0,0 +1,123 @@
+package storage
+
+import (
+	"errors"
+	"l2-18/internal/apperrors"
+	"l2-18/internal/models"
+	"path/filepath"
+	"testing"
+	"time"
+)
+
+// batchBackend хранилище, выполняющее пакеты изменений
+type batchBackend interface {
+	EventStorage
+	BatchStorage
+}
+
+func TestBatchStorage_ApplyBatch(t *testing.T) {
+	backends := []struct {
+		name string
+		// open открывает хранилище; persistent — сохраняет ли оно данные по пути
+		open       func(path string) (batchBackend, error)
+		persistent bool
+	}{
+		{"memory", func(string) (batchBackend, error) { return NewInMemoryEventStorage(), nil }, false},
+		{"file", func(path string) (batchBackend, error) { return NewFileEventStorage(path) }, true},
+		// Журнал не сворачивается, пакет восстанавливается из его записи
+		{"wal", func(path string) (batchBackend, error) { return NewWALEventStorage(path, 100) }, true},
+	}
+
+	start := time.Date(2024, 1, 8, 9, 0, 0, 0, time.UTC)
+	newEvent := func(title string) *models.Event {
+		return &models.Event{UserID: 1, Title: title, Start: start, End: start.Add(time.Hour)}
+	}
+
+	for _, backend := range backends {
+		t.Run(backend.name, func(t *testing.T) {
+			path := filepath.Join(t.TempDir(), "events.json")
+			strg, err := backend.open(path)
+			if err != nil {
+				t.Fatal("open error:", err)
+			}
+
+			kept, removed := newEvent("Kept"), newEvent("Removed")
+			for _, event := range []*models.Event{kept, removed} {
+				if err := strg.Create(event); err != nil {
+					t.Fatal("Create() error:", err)
+				}
+			}
+			renamed := func() *models.Event {
+				event := *kept
+				event.Title = "Renamed"
+				return &event
+			}
+
+			failing := []struct {
+				name      string
+				ops       []BatchOp
+				wantIndex int
+				wantErr   error
+			}{
+				{"stale version", []BatchOp{
+					{Kind: BatchCreate, Event: newEvent("Added")},
+					{Kind: BatchUpdate, Event: renamed()},
+					{Kind: BatchDelete, ID: removed.ID, UserID: 1, Version: 5},
+				}, 2, apperrors.ErrConflict},
+				{"event changed twice", []BatchOp{
+					{Kind: BatchUpdate, Event: renamed()},
+					{Kind: BatchDelete, ID: kept.ID, UserID: 1},
+				}, 1, apperrors.ErrConflict},
+				{"missing event", []BatchOp{
+					{Kind: BatchCreate, Event: newEvent("Added")},
+					{Kind: BatchDelete, ID: 42, UserID: 1},
+				}, 1, apperrors.ErrNotFound},
+				{"other user", []BatchOp{
+					{Kind: BatchDelete, ID: removed.ID, UserID: 2},
+				}, 0, apperrors.ErrForbidden},
+			}
+			for _, tt := range failing {
+				err := strg.ApplyBatch(tt.ops)
+				var batchErr *BatchError
+				if !errors.As(err, &batchErr) || batchErr.Index != tt.wantIndex || !errors.Is(err, tt.wantErr) {
+					t.Errorf("%s: ApplyBatch() error = %v, want operation %d failing with %v", tt.name, err, tt.wantIndex, tt.wantErr)
+				}
+			}
+
+			// Ни одна операция неудачных пакетов не выполнена
+			events, _ := strg.GetByDateRange(1, start, start.AddDate(0, 0, 1))
+			if len(events) != 2 || kept.Version != 1 {
+				t.Fatalf("after failed batches got %d events, kept version %d; want 2 unchanged events", len(events), kept.Version)
+			}
+
+			added := newEvent("Added")
+			update := renamed()
+			err = strg.ApplyBatch([]BatchOp{
+				{Kind: BatchCreate, Event: added},
+				{Kind: BatchUpdate, Event: update},
+				{Kind: BatchDelete, ID: removed.ID, UserID: 1, Version: 1},
+			})
+			if err != nil {
+				t.Fatal("ApplyBatch() error:", err)
+			}
+			if added.ID != removed.ID+1 || added.Version != 1 || update.Version != 2 {
+				t.Errorf("added ID %d version %d, updated version %d; want ID %d, versions 1 and 2", added.ID, added.Version, update.Version, removed.ID+1)
+			}
+
+			if backend.persistent {
+				if strg, err = backend.open(path); err != nil {
+					t.Fatal("reopen error:", err)
+				}
+			}
+			if _, err := strg.GetByID(removed.ID, 1); !errors.Is(err, apperrors.ErrNotFound) {
+				t.Errorf("deleted event GetByID() error = %v, want ErrNotFound", err)
+			}
+			if got, err := strg.GetByID(kept.ID, 1); err != nil || got.Title != "Renamed" || got.Version != 2 {
+				t.Errorf("updated event = %+v, %v; want Renamed at version 2", got, err)
+			}
+			if got, err := strg.GetByID(added.ID, 1); err != nil || got.Title != "Added" {
+				t.Errorf("created event = %+v, %v; want Added", got, err)
+			}
+		})
+	}
+}
@@ -1,35 +1,70 @@
 package storage
 
 import (
-	"fmt"
+	"l2-18/internal/apperrors"
 	"l2-18/internal/models"
+	"l2-18/internal/search"
+	"sort"
 	"sync"
 	"time"
 )
 
-// EventStorage интерфейс для работы с событиями
+// EventStorage интерфейс для работы с событиями.
+// Update и Delete проверяют версию события атомарно с изменением:
+// если ожидаемая версия не 0 и не совпадает с текущей, возвращается ErrConflict.
 type EventStorage interface {
 	Create(event *models.Event) error
+	// Update заменяет событие; event.Version — ожидаемая версия, после
+	// обновления в нее записывается новая
 	Update(event *models.Event) error
-	Delete(id, userID int) error
+	// Delete удаляет событие; version — ожидаемая версия
+	Delete(id, userID, version int) error
 	GetByDateRange(userID int, start, end time.Time) ([]*models.Event, error)
 	GetByID(id, userID int) (*models.Event, error)
 }
 
 // InMemoryEventStorage реализация хранилища в памяти
 type InMemoryEventStorage struct {
-	events   map[int]*models.Event
-	nextID   int
-	userToID map[int][]int // userID -> []eventIDs
-	mu       sync.RWMutex
+	events    map[int]*models.Event
+	nextID    int
+	userToID  map[int][]int  // userID -> []eventIDs
+	timeZones map[int]string // userID -> часовой пояс
+	// timelines userID владельца -> индекс событий по времени
+	timelines map[int]*timeline
+	reminders reminderLog
+	// attendeeToID userID участника -> []eventIDs
+	attendeeToID map[int][]int
+	// shares ownerID -> userID -> право доступа к календарю
+	shares map[int]map[int]string
+	// webhooks webhook пользователей и недоставленные на них изменения
+	webhooks webhookLog
+	// trash удаленные события в корзинах владельцев по ID
+	trash map[int]models.TrashedEvent
+	// history eventID -> записи истории изменений события
+	history map[int][]models.Revision
+	// text и tags индексы слов названия и описания и меток событий
+	text *search.Index
+	tags *search.Index
+	mu   sync.RWMutex
 }
 
 // NewInMemoryEventStorage создает новое хранилище в памяти
 func NewInMemoryEventStorage() *InMemoryEventStorage {
 	return &InMemoryEventStorage{
-		events:   make(map[int]*models.Event),
-		nextID:   1,
-		userToID: make(map[int][]int),
+		events:    make(map[int]*models.Event),
+		nextID:    1,
+		userToID:  make(map[int][]int),
+		timeZones: make(map[int]string),
+		timelines: make(map[int]*timeline),
+		reminders: reminderLog{Sent: make(map[string]time.Time)},
+
+		attendeeToID: make(map[int][]int),
+		shares:       make(map[int]map[int]string),
+		webhooks:     newWebhookLog(),
+		trash:        make(map[int]models.TrashedEvent),
+		history:      make(map[int][]models.Revision),
+		text:         search.NewIndex(),
+		tags:         search.NewIndex(),
 	}
 }
 
@@ -38,14 +73,7 @@ func (s *InMemoryEventStorage) Create(event *models.Event) error {
 	s.mu.Lock()
 	defer s.mu.Unlock()
 
-	event.ID = s.nextID
-	s.nextID++
-	event.CreatedAt = time.Now()
-	event.UpdatedAt = time.Now()
-
-	s.events[event.ID] = event
-	s.userToID[event.UserID] = append(s.userToID[event.UserID], event.ID)
-
+	s.create(event)
 	return nil
 }
 
@@ -54,66 +82,100 @@ func (s *InMemoryEventStorage) Update(event *models.Event) error {
 	s.mu.Lock()
 	defer s.mu.Unlock()
 
-	existing, exists := s.events[event.ID]
-	if !exists {
-		return fmt.Errorf("event with ID %d not found", event.ID)
-	}
+	return s.update(event)
+}
+
+// Delete удаляет событие
+func (s *InMemoryEventStorage) Delete(id, userID, version int) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
 
-	if existing.UserID != event.UserID {
-		return fmt.Errorf("event does not belong to user")
+	return s.delete(id, userID, version)
+}
+
+// create сохраняет новое событие, присваивая ему ID и первую версию
+func (s *InMemoryEventStorage) create(event *models.Event) {
+	event.ID = s.nextID
+	s.nextID++
+	event.Version = 1
+	event.CreatedAt = time.Now()
+	event.UpdatedAt = time.Now()
+
+	s.events[event.ID] = event
+	s.userToID[event.UserID] = append(s.userToID[event.UserID], event.ID)
+	s.index(event)
+}
+
+// update заменяет событие, если его можно изменить
+func (s *InMemoryEventStorage) update(event *models.Event) error {
+	existing, err := s.writable(event.ID, event.UserID, event.Version)
+	if err != nil {
+		return err
 	}
 
+	event.Version = existing.Version + 1
 	event.CreatedAt = existing.CreatedAt
 	event.UpdatedAt = time.Now()
+	s.unindex(existing)
 	s.events[event.ID] = event
+	s.index(event)
 
 	return nil
 }
 
-// Delete удаляет событие
-func (s *InMemoryEventStorage) Delete(id, userID int) error {
-	s.mu.Lock()
-	defer s.mu.Unlock()
+// delete удаляет событие, если его можно изменить
+func (s *InMemoryEventStorage) delete(id, userID, version int) error {
+	event, err := s.writable(id, userID, version)
+	if err != nil {
+		return err
+	}
+
+	delete(s.events, id)
 
+	// Удаляем из индексов пользователя, времени, участников и поиска
+	s.userToID[userID] = removeID(s.userToID[userID], id)
+	s.unindex(event)
+
+	return nil
+}
+
+// writable возвращает событие, если оно существует, принадлежит
+// пользователю и не изменилось с ожидаемой версии
+func (s *InMemoryEventStorage) writable(id, userID, version int) (*models.Event, error) {
 	event, exists := s.events[id]
 	if !exists {
-		return fmt.Errorf("event with ID %d not found", id)
+		return nil, apperrors.Errorf(apperrors.ErrNotFound, "event with ID %d not found", id)
 	}
 
 	if event.UserID != userID {
-		return fmt.Errorf("event does not belong to user")
+		return nil, apperrors.Errorf(apperrors.ErrForbidden, "event does not belong to user")
 	}
 
-	delete(s.events, id)
-
-	// Удаляем из индекса пользователя
-	userEvents := s.userToID[userID]
-	for i, eventID := range userEvents {
-		if eventID == id {
-			s.userToID[userID] = append(userEvents[:i], userEvents[i+1:]...)
-			break
-		}
+	if err := checkVersion(event, version); err != nil {
+		return nil, err
 	}
 
-	return nil
+	return event, nil
 }
 
-// GetByDateRange возвращает события в указанном диапазоне дат
+// GetByDateRange возвращает события, пересекающиеся с полуинтервалом [start, end),
+// в хронологическом порядке. Повторяющиеся серии возвращаются целиком,
+// если начались раньше end: разворачивание экземпляров выполняет сервис.
 func (s *InMemoryEventStorage) GetByDateRange(userID int, start, end time.Time) ([]*models.Event, error) {
 	s.mu.RLock()
 	defer s.mu.RUnlock()
 
-	var result []*models.Event
-	eventIDs := s.userToID[userID]
-
-	for _, eventID := range eventIDs {
-		event := s.events[eventID]
-		if event != nil && isDateInRange(event.Date, start, end) {
-			result = append(result, event)
-		}
+	t, ok := s.timelines[userID]
+	if !ok {
+		return nil, nil
 	}
+	return t.between(start, end), nil
+}
 
-	return result, nil
+// inRange проверяет, может ли событие попасть в [start, end):
+// пересекается с ним или является серией, начавшейся раньше end
+func inRange(event *models.Event, start, end time.Time) bool {
+	return event.Overlaps(start, end) || (event.IsRecurring() && event.Start.Before(end))
 }
 
 // GetByID возвращает событие по ID
@@ -123,23 +185,191 @@ func (s *InMemoryEventStorage) GetByID(id, userID int) (*models.Event, error) {
 
 	event, exists := s.events[id]
 	if !exists {
-		return nil, fmt.Errorf("event with ID %d not found", id)
+		return nil, apperrors.Errorf(apperrors.ErrNotFound, "event with ID %d not found", id)
 	}
 
 	if event.UserID != userID {
-		return nil, fmt.Errorf("event does not belong to user")
+		return nil, apperrors.Errorf(apperrors.ErrForbidden, "event does not belong to user")
 	}
 
 	return event, nil
 }
 
-// isDateInRange проверяет, попадает ли дата в заданный диапазон
-func isDateInRange(date, start, end time.Time) bool {
-	// Сравниваем только даты, игнорируя время
-	dateOnly := time.Date(date.Year(), date.Month(), date.Day(), 0, 0, 0, 0, time.UTC)
-	startOnly := time.Date(start.Year(), start.Month(), start.Day(), 0, 0, 0, 0, time.UTC)
-	endOnly := time.Date(end.Year(), end.Month(), end.Day(), 23, 59, 59, 0, time.UTC)
+// index добавляет событие в индексы времени, участников и поиска
+func (s *InMemoryEventStorage) index(event *models.Event) {
+	t, ok := s.timelines[event.UserID]
+	if !ok {
+		t = &timeline{}
+		s.timelines[event.UserID] = t
+	}
+	t.add(event)
+	for _, attendee := range event.Attendees {
+		s.attendeeToID[attendee.UserID] = append(s.attendeeToID[attendee.UserID], event.ID)
+	}
+	s.text.Add(event.ID, textTerms(event))
+	s.tags.Add(event.ID, tagTerms(event))
+}
 
-	return (dateOnly.Equal(startOnly) || dateOnly.After(startOnly)) &&
-		(dateOnly.Equal(endOnly) || dateOnly.Before(endOnly))
+// unindex удаляет событие из индексов времени, участников и поиска
+func (s *InMemoryEventStorage) unindex(event *models.Event) {
+	if t, ok := s.timelines[event.UserID]; ok {
+		t.remove(event)
+		if t.empty() {
+			delete(s.timelines, event.UserID)
+		}
+	}
+	for _, attendee := range event.Attendees {
+		s.attendeeToID[attendee.UserID] = removeID(s.attendeeToID[attendee.UserID], event.ID)
+	}
+	s.text.Remove(event.ID, textTerms(event))
+	s.tags.Remove(event.ID, tagTerms(event))
+}
+
+// put сохраняет событие как есть, заменяя событие с тем же ID
+func (s *InMemoryEventStorage) put(event *models.Event) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if existing, ok := s.events[event.ID]; ok {
+		s.unindex(existing)
+	} else {
+		s.userToID[event.UserID] = append(s.userToID[event.UserID], event.ID)
+	}
+	s.events[event.ID] = event
+	s.index(event)
+	s.nextID = max(s.nextID, event.ID+1)
+}
+
+// remove удаляет событие по ID, если оно есть
+func (s *InMemoryEventStorage) remove(id int) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	event, ok := s.events[id]
+	if !ok {
+		return
+	}
+	delete(s.events, id)
+	s.userToID[event.UserID] = removeID(s.userToID[event.UserID], id)
+	s.unindex(event)
+}
+
+// removeID удаляет id из списка идентификаторов
+func removeID(ids []int, id int) []int {
+	for i, eventID := range ids {
+		if eventID == id {
+			return append(ids[:i], ids[i+1:]...)
+		}
+	}
+	return ids
+}
+
+// checkVersion сверяет ожидаемую версию события с текущей (0 — без проверки)
+func checkVersion(event *models.Event, version int) error {
+	if version != 0 && version != event.Version {
+		return apperrors.Errorf(apperrors.ErrConflict, "event %d has been modified: version %d is stale, current version is %d", event.ID, version, event.Version)
+	}
+	return nil
+}
+
+// state полное состояние хранилища, используется для сохранения на диск
+type state struct {
+	NextID    int              `json:"next_id"`
+	Events    []*models.Event  `json:"events"`
+	TimeZones map[int]string   `json:"time_zones,omitempty"`
+	Reminders reminderLog      `json:"reminders,omitzero"`
+	Shares    []models.Share   `json:"shares,omitempty"`
+	Webhooks  []models.Webhook `json:"webhooks,omitempty"`
+	// NextWebhookID и NextDeadLetterID следующие ID, чтобы ID удаленных
+	// webhook и вытесненных изменений не выдавались повторно
+	NextWebhookID    int `json:"next_webhook_id,omitempty"`
+	NextDeadLetterID int `json:"next_dead_letter_id,omitempty"`
+	// DeadLetters недоставленные изменения по возрастанию ID
+	DeadLetters []models.DeadLetter `json:"dead_letters,omitempty"`
+	// Trash события в корзине по возрастанию ID
+	Trash []models.TrashedEvent `json:"trash,omitempty"`
+	// History записи истории по возрастанию ID событий и номеров записей
+	History []models.Revision `json:"history,omitempty"`
+}
+
+// snapshot возвращает копию состояния хранилища (события по возрастанию ID)
+func (s *InMemoryEventStorage) snapshot() state {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	events := make([]*models.Event, 0, len(s.events))
+	for _, event := range s.events {
+		copied := *event
+		events = append(events, &copied)
+	}
+	sort.Slice(events, func(i, j int) bool { return events[i].ID < events[j].ID })
+
+	timeZones := make(map[int]string, len(s.timeZones))
+	for userID, tz := range s.timeZones {
+		timeZones[userID] = tz
+	}
+
+	return state{
+		NextID:           s.nextID,
+		Events:           events,
+		TimeZones:        timeZones,
+		Reminders:        s.reminders.clone(),
+		Shares:           s.shareList(),
+		Webhooks:         s.webhookList(),
+		DeadLetters:      s.deadLetterList(),
+		NextWebhookID:    s.webhooks.nextID,
+		NextDeadLetterID: s.webhooks.nextLetter,
+		Trash:            s.trashList(),
+		History:          s.historyList(),
+	}
+}
+
+// restore заменяет содержимое хранилища переданным состоянием
+func (s *InMemoryEventStorage) restore(st state) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	s.events = make(map[int]*models.Event, len(st.Events))
+	s.userToID = make(map[int][]int)
+	s.timeZones = make(map[int]string, len(st.TimeZones))
+	s.timelines = make(map[int]*timeline)
+	s.attendeeToID = make(map[int][]int)
+	s.shares = make(map[int]map[int]string)
+	s.text = search.NewIndex()
+	s.tags = search.NewIndex()
+	s.nextID = max(st.NextID, 1)
+
+	for _, event := range st.Events {
+		s.events[event.ID] = event
+		s.userToID[event.UserID] = append(s.userToID[event.UserID], event.ID)
+		s.index(event)
+		if event.ID >= s.nextID {
+			s.nextID = event.ID + 1
+		}
+	}
+	for userID, tz := range st.TimeZones {
+		s.timeZones[userID] = tz
+	}
+	s.reminders = st.Reminders.clone()
+	for _, share := range st.Shares {
+		s.setShare(share)
+	}
+	s.webhooks = newWebhookLog()
+	s.webhooks.nextID = max(st.NextWebhookID, 1)
+	s.webhooks.nextLetter = max(st.NextDeadLetterID, 1)
+	for _, webhook := range st.Webhooks {
+		s.putWebhook(webhook)
+	}
+	for _, letter := range st.DeadLetters {
+		s.addDeadLetter(letter)
+	}
+	s.trash = make(map[int]models.TrashedEvent, len(st.Trash))
+	for _, trashed := range st.Trash {
+		s.trash[trashed.Event.ID] = trashed
+		s.nextID = max(s.nextID, trashed.Event.ID+1)
+	}
+	s.history = make(map[int][]models.Revision)
+	for _, rev := range st.History {
+		s.putRevision(rev)
+	}
 }
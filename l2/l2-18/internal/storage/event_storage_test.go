@@ -0,0 +1,130 @@
+package storage
+
+import (
+	"errors"
+	"l2-18/internal/apperrors"
+	"l2-18/internal/models"
+	"testing"
+	"time"
+)
+
+func TestInMemoryEventStorage_Versions(t *testing.T) {
+	strg := NewInMemoryEventStorage()
+	start := time.Date(2024, 1, 8, 9, 0, 0, 0, time.UTC)
+
+	event := &models.Event{UserID: 1, Title: "Standup", Start: start, End: start}
+	if err := strg.Create(event); err != nil {
+		t.Fatal("Create() error:", err)
+	}
+	if event.Version != 1 {
+		t.Fatalf("Create() version = %d, want 1", event.Version)
+	}
+
+	// Два клиента прочитали версию 1, первый успевает сохранить изменения
+	first := *event
+	first.Title = "Daily standup"
+	if err := strg.Update(&first); err != nil {
+		t.Fatal("Update() error:", err)
+	}
+	if first.Version != 2 {
+		t.Errorf("Update() version = %d, want 2", first.Version)
+	}
+
+	second := *event
+	second.Title = "Weekly standup"
+	if err := strg.Update(&second); !errors.Is(err, apperrors.ErrConflict) {
+		t.Errorf("Update() with stale version error = %v, want conflict", err)
+	}
+	if err := strg.Delete(event.ID, 1, 1); !errors.Is(err, apperrors.ErrConflict) {
+		t.Errorf("Delete() with stale version error = %v, want conflict", err)
+	}
+
+	stored, _ := strg.GetByID(event.ID, 1)
+	if stored.Title != "Daily standup" {
+		t.Errorf("stored title = %q, stale update must not overwrite it", stored.Title)
+	}
+
+	if err := strg.Delete(event.ID, 1, 2); err != nil {
+		t.Errorf("Delete() with current version error = %v", err)
+	}
+}
+
+func TestInMemoryEventStorage_Invitations(t *testing.T) {
+	strg := NewInMemoryEventStorage()
+	start := time.Date(2024, 1, 8, 10, 0, 0, 0, time.UTC)
+	day := func(userID int) int {
+		events, _ := strg.GetInvitations(userID, start, start.AddDate(0, 0, 1))
+		return len(events)
+	}
+
+	event := &models.Event{
+		UserID:    1,
+		Title:     "Planning",
+		Start:     start,
+		End:       start.Add(time.Hour),
+		Attendees: []models.Attendee{{UserID: 2}, {UserID: 3}},
+	}
+	if err := strg.Create(event); err != nil {
+		t.Fatal("Create() error:", err)
+	}
+	if day(2) != 1 || day(3) != 1 || day(1) != 0 {
+		t.Fatalf("invitations after Create() = %d, %d, %d; want 1, 1, 0", day(2), day(3), day(1))
+	}
+
+	// Участник 3 убран из события и больше не видит приглашения
+	updated := *event
+	updated.Attendees = []models.Attendee{{UserID: 2}}
+	if err := strg.Update(&updated); err != nil {
+		t.Fatal("Update() error:", err)
+	}
+	if day(2) != 1 || day(3) != 0 {
+		t.Errorf("invitations after Update() = %d, %d; want 1, 0", day(2), day(3))
+	}
+
+	if err := strg.Delete(event.ID, 1, 0); err != nil {
+		t.Fatal("Delete() error:", err)
+	}
+	if day(2) != 0 {
+		t.Errorf("invitations after Delete() = %d, want 0", day(2))
+	}
+}
+
+func TestInMemoryEventStorage_Stats(t *testing.T) {
+	strg := NewInMemoryEventStorage()
+	start := time.Date(2024, 1, 8, 10, 0, 0, 0, time.UTC)
+
+	check := func(stage string, events, users int) {
+		t.Helper()
+		stats, err := strg.Stats()
+		if err != nil {
+			t.Fatal("Stats() error:", err)
+		}
+		if stats.Events != events || stats.Users != users {
+			t.Errorf("Stats() %s = %+v, want %d events and %d users", stage, stats, events, users)
+		}
+	}
+
+	check("empty", 0, 0)
+
+	event := &models.Event{UserID: 1, Title: "Planning", Start: start, End: start.Add(time.Hour), Attendees: []models.Attendee{{UserID: 2}}}
+	if err := strg.Create(event); err != nil {
+		t.Fatal("Create() error:", err)
+	}
+	if err := strg.Create(&models.Event{UserID: 1, Title: "Review", Start: start, End: start}); err != nil {
+		t.Fatal("Create() error:", err)
+	}
+	check("after Create()", 2, 2)
+
+	if err := strg.SetTimeZone(3, "Europe/Moscow"); err != nil {
+		t.Fatal("SetTimeZone() error:", err)
+	}
+	if err := strg.SetShare(models.Share{OwnerID: 1, UserID: 4, Permission: models.PermissionRead}); err != nil {
+		t.Fatal("SetShare() error:", err)
+	}
+	check("with settings and shares", 2, 4)
+
+	if err := strg.Delete(event.ID, 1, 0); err != nil {
+		t.Fatal("Delete() error:", err)
+	}
+	check("after Delete()", 1, 3) // участник 2 был только в удаленном событии
+}
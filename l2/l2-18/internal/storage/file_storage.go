@@ -0,0 +1,298 @@
+package storage
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"l2-18/internal/models"
+	"os"
+	"path/filepath"
+	"sync"
+	"time"
+)
+
+// schemaVersion текущая версия формата файла хранилища
+const schemaVersion = 4
+
+// migration переводит документ хранилища с версии N на версию N+1
+type migration func(doc map[string]interface{}) error
+
+// migrations список миграций, индекс соответствует исходной версии
+var migrations = []migration{
+	// 0 -> 1: новый файл, создаем пустую схему
+	func(doc map[string]interface{}) error {
+		doc["next_id"] = 1
+		doc["events"] = []interface{}{}
+		return nil
+	},
+	// 1 -> 2: у событий появились начало и конец, старые события становятся событиями на весь день
+	migrateAllDayEvents,
+	// 2 -> 3: у событий появился UID для обмена через iCalendar
+	migrateEventUIDs,
+	// 3 -> 4: у событий появилась версия для оптимистичных блокировок
+	migrateEventVersions,
+}
+
+// migrateAllDayEvents заполняет start, end и all_day по дате события
+func migrateAllDayEvents(doc map[string]interface{}) error {
+	events, _ := doc["events"].([]interface{})
+	for _, raw := range events {
+		event, ok := raw.(map[string]interface{})
+		if !ok {
+			return fmt.Errorf("invalid event record")
+		}
+		if _, ok := event["start"]; ok {
+			continue
+		}
+
+		dateStr, _ := event["date"].(string)
+		date, err := time.Parse(time.RFC3339Nano, dateStr)
+		if err != nil {
+			return fmt.Errorf("invalid event date %q", dateStr)
+		}
+
+		start := time.Date(date.Year(), date.Month(), date.Day(), 0, 0, 0, 0, date.Location())
+		event["start"] = start.Format(time.RFC3339Nano)
+		event["end"] = start.AddDate(0, 0, 1).Format(time.RFC3339Nano)
+		event["all_day"] = true
+	}
+
+	return nil
+}
+
+// migrateEventUIDs присваивает UID событиям, созданным до его появления.
+// Измененные экземпляры серий получают UID своей серии.
+func migrateEventUIDs(doc map[string]interface{}) error {
+	events, _ := doc["events"].([]interface{})
+	uidByID := make(map[float64]string, len(events))
+
+	for _, raw := range events {
+		event, ok := raw.(map[string]interface{})
+		if !ok {
+			return fmt.Errorf("invalid event record")
+		}
+		id, _ := event["id"].(float64)
+		if uid, _ := event["uid"].(string); uid == "" {
+			event["uid"] = fmt.Sprintf("event-%d@l2-18", int(id))
+		}
+		uidByID[id] = event["uid"].(string)
+	}
+
+	for _, raw := range events {
+		event := raw.(map[string]interface{})
+		if seriesID, _ := event["series_id"].(float64); seriesID != 0 {
+			event["uid"] = uidByID[seriesID]
+		}
+	}
+
+	return nil
+}
+
+// migrateEventVersions присваивает существующим событиям первую версию
+func migrateEventVersions(doc map[string]interface{}) error {
+	events, _ := doc["events"].([]interface{})
+	for _, raw := range events {
+		event, ok := raw.(map[string]interface{})
+		if !ok {
+			return fmt.Errorf("invalid event record")
+		}
+		if version, _ := event["version"].(float64); version == 0 {
+			event["version"] = 1
+		}
+	}
+
+	return nil
+}
+
+// fileSnapshot формат файла хранилища
+type fileSnapshot struct {
+	Version int `json:"version"`
+	state
+}
+
+// FileEventStorage хранилище событий с сохранением в JSON-файл на диске.
+// Все данные держатся в памяти, а после каждого изменения файл
+// атомарно перезаписывается целиком.
+type FileEventStorage struct {
+	mem  *InMemoryEventStorage
+	path string
+	err  error      // ошибка последней записи файла
+	mu   sync.Mutex // сериализует изменения и запись файла
+	// write записывает файл; подменяется в тестах
+	write func(path string, data []byte) error
+}
+
+// NewFileEventStorage открывает хранилище по указанному пути,
+// применяя миграции схемы при необходимости
+func NewFileEventStorage(path string) (*FileEventStorage, error) {
+	s := &FileEventStorage{
+		mem:   NewInMemoryEventStorage(),
+		path:  path,
+		write: writeFileAtomic,
+	}
+
+	snap, migrated, err := loadSnapshot(path)
+	if err != nil {
+		return nil, err
+	}
+	s.mem.restore(snap.state)
+
+	if migrated {
+		if err := s.save(); err != nil {
+			return nil, err
+		}
+	}
+
+	return s, nil
+}
+
+// Create создает новое событие
+func (s *FileEventStorage) Create(event *models.Event) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if err := s.mem.Create(event); err != nil {
+		return err
+	}
+	return s.save()
+}
+
+// Update обновляет существующее событие
+func (s *FileEventStorage) Update(event *models.Event) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if err := s.mem.Update(event); err != nil {
+		return err
+	}
+	return s.save()
+}
+
+// Delete удаляет событие
+func (s *FileEventStorage) Delete(id, userID, version int) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if err := s.mem.Delete(id, userID, version); err != nil {
+		return err
+	}
+	return s.save()
+}
+
+// GetByDateRange возвращает события, пересекающиеся с полуинтервалом [start, end)
+func (s *FileEventStorage) GetByDateRange(userID int, start, end time.Time) ([]*models.Event, error) {
+	return s.mem.GetByDateRange(userID, start, end)
+}
+
+// GetByID возвращает событие по ID
+func (s *FileEventStorage) GetByID(id, userID int) (*models.Event, error) {
+	return s.mem.GetByID(id, userID)
+}
+
+// save атомарно записывает текущее состояние в файл. Если записать
+// не удалось, состояние в памяти возвращается к сохраненному в файле:
+// иначе неудавшееся изменение попало бы на диск со следующей записью.
+func (s *FileEventStorage) save() (err error) {
+	defer func() { s.err = err }()
+
+	data, err := json.MarshalIndent(fileSnapshot{
+		Version: schemaVersion,
+		state:   s.mem.snapshot(),
+	}, "", "  ")
+	if err != nil {
+		return s.rollback(fmt.Errorf("failed to encode storage: %v", err))
+	}
+
+	if err := s.write(s.path, data); err != nil {
+		return s.rollback(fmt.Errorf("failed to persist storage: %v", err))
+	}
+
+	return nil
+}
+
+// rollback возвращает состояние в памяти к сохраненному в файле
+// и возвращает ошибку записи err
+func (s *FileEventStorage) rollback(err error) error {
+	snap, _, loadErr := loadSnapshot(s.path)
+	if loadErr != nil {
+		return fmt.Errorf("%v; failed to roll back: %v", err, loadErr)
+	}
+	s.mem.restore(snap.state)
+	return err
+}
+
+// loadSnapshot читает файл хранилища и приводит его к текущей версии схемы.
+// Второе значение сообщает, были ли применены миграции.
+func loadSnapshot(path string) (*fileSnapshot, bool, error) {
+	doc := map[string]interface{}{"version": float64(0)}
+
+	data, err := os.ReadFile(path)
+	switch {
+	case errors.Is(err, os.ErrNotExist):
+	case err != nil:
+		return nil, false, fmt.Errorf("failed to read storage file: %v", err)
+	default:
+		if err := json.Unmarshal(data, &doc); err != nil {
+			return nil, false, fmt.Errorf("failed to decode storage file: %v", err)
+		}
+	}
+
+	rawVersion, ok := doc["version"].(float64)
+	if !ok {
+		return nil, false, fmt.Errorf("storage file has no schema version")
+	}
+	version := int(rawVersion)
+	if version > schemaVersion {
+		return nil, false, fmt.Errorf("storage schema version %d is newer than supported %d", version, schemaVersion)
+	}
+
+	migrated := version < schemaVersion
+	for ; version < schemaVersion; version++ {
+		if err := migrations[version](doc); err != nil {
+			return nil, false, fmt.Errorf("failed to migrate storage from version %d: %v", version, err)
+		}
+		doc["version"] = version + 1
+	}
+
+	// Перекодируем документ в типизированную структуру
+	data, err = json.Marshal(doc)
+	if err != nil {
+		return nil, false, fmt.Errorf("failed to encode storage: %v", err)
+	}
+	var snap fileSnapshot
+	if err := json.Unmarshal(data, &snap); err != nil {
+		return nil, false, fmt.Errorf("failed to decode storage file: %v", err)
+	}
+
+	return &snap, migrated, nil
+}
+
+// writeFileAtomic записывает данные во временный файл и переименовывает его,
+// чтобы при сбое на диске не остался частично записанный файл
+func writeFileAtomic(path string, data []byte) error {
+	if dir := filepath.Dir(path); dir != "" {
+		if err := os.MkdirAll(dir, 0755); err != nil {
+			return err
+		}
+	}
+
+	tmp, err := os.CreateTemp(filepath.Dir(path), filepath.Base(path)+".tmp*")
+	if err != nil {
+		return err
+	}
+	defer os.Remove(tmp.Name())
+
+	if _, err := tmp.Write(data); err != nil {
+		tmp.Close()
+		return err
+	}
+	if err := tmp.Sync(); err != nil {
+		tmp.Close()
+		return err
+	}
+	if err := tmp.Close(); err != nil {
+		return err
+	}
+
+	return os.Rename(tmp.Name(), path)
+}
@@ -0,0 +1,218 @@
+package storage
+
+import (
+	"errors"
+	"l2-18/internal/models"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+)
+
+func TestFileEventStorage_Reopen(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "events.json")
+
+	strg, err := NewFileEventStorage(path)
+	if err != nil {
+		t.Fatal("NewFileEventStorage() error:", err)
+	}
+
+	date := time.Date(2023, 12, 31, 0, 0, 0, 0, time.UTC)
+	first := &models.Event{UserID: 1, Date: date, Title: "First"}
+	second := &models.Event{UserID: 1, Date: date, Title: "Second"}
+	for _, event := range []*models.Event{first, second} {
+		if err := strg.Create(event); err != nil {
+			t.Fatal("Create() error:", err)
+		}
+	}
+	if err := strg.Delete(first.ID, 1, 0); err != nil {
+		t.Fatal("Delete() error:", err)
+	}
+
+	reopened, err := NewFileEventStorage(path)
+	if err != nil {
+		t.Fatal("NewFileEventStorage() reopen error:", err)
+	}
+
+	if _, err := reopened.GetByID(first.ID, 1); err == nil {
+		t.Error("GetByID() deleted event survived reopen")
+	}
+	got, err := reopened.GetByID(second.ID, 1)
+	if err != nil {
+		t.Fatal("GetByID() error:", err)
+	}
+	if got.Title != "Second" || !got.Date.Equal(date) {
+		t.Errorf("GetByID() got %+v after reopen", got)
+	}
+
+	// Новые ID не должны повторять уже выданные
+	third := &models.Event{UserID: 1, Date: date, Title: "Third"}
+	if err := reopened.Create(third); err != nil {
+		t.Fatal("Create() error:", err)
+	}
+	if third.ID <= second.ID {
+		t.Errorf("Create() reused ID %d", third.ID)
+	}
+}
+
+func TestFileEventStorage_FailedSave(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "events.json")
+	strg, err := NewFileEventStorage(path)
+	if err != nil {
+		t.Fatal("NewFileEventStorage() error:", err)
+	}
+
+	start := time.Date(2024, 1, 8, 9, 0, 0, 0, time.UTC)
+	kept := &models.Event{UserID: 1, Title: "Kept", Start: start, End: start.Add(time.Hour)}
+	if err := strg.Create(kept); err != nil {
+		t.Fatal("Create() error:", err)
+	}
+
+	// Имитируем сбой диска
+	strg.write = func(string, []byte) error { return errors.New("disk is full") }
+
+	failing := []struct {
+		name  string
+		write func() error
+	}{
+		{"create", func() error {
+			return strg.Create(&models.Event{UserID: 1, Title: "Lost", Start: start, End: start.Add(time.Hour)})
+		}},
+		{"update", func() error {
+			renamed := *kept
+			renamed.Title = "Renamed"
+			return strg.Update(&renamed)
+		}},
+		{"delete", func() error { return strg.Delete(kept.ID, 1, 0) }},
+		{"trash", func() error { return strg.TrashEvent(kept.ID, 1, 0) }},
+		{"batch", func() error {
+			return strg.ApplyBatch([]BatchOp{
+				{Kind: BatchCreate, Event: &models.Event{UserID: 1, Title: "Lost", Start: start, End: start.Add(time.Hour)}},
+				{Kind: BatchDelete, ID: kept.ID, UserID: 1},
+			})
+		}},
+		{"share", func() error {
+			return strg.SetShare(models.Share{OwnerID: 1, UserID: 2, Permission: models.PermissionRead})
+		}},
+		{"webhook", func() error {
+			return strg.CreateWebhook(&models.Webhook{UserID: 1, URL: "https://example.com/hook"})
+		}},
+	}
+	for _, tt := range failing {
+		if err := tt.write(); err == nil {
+			t.Fatalf("%s: write with a failing disk succeeded", tt.name)
+		}
+
+		// Изменение, не попавшее в файл, не видно и в памяти
+		events, err := strg.GetByDateRange(1, start, start.AddDate(0, 0, 1))
+		if err != nil {
+			t.Fatal("GetByDateRange() error:", err)
+		}
+		if len(events) != 1 || events[0].ID != kept.ID || events[0].Title != "Kept" {
+			t.Errorf("%s: GetByDateRange() after failed save = %v, want only the kept event", tt.name, events)
+		}
+		if trash, _ := strg.GetTrash(1); len(trash) != 0 {
+			t.Errorf("%s: trash after failed save = %v, want empty", tt.name, trash)
+		}
+		if shares, _ := strg.GetShares(1); len(shares) != 0 {
+			t.Errorf("%s: shares after failed save = %v, want none", tt.name, shares)
+		}
+		if webhooks, _ := strg.GetWebhooks(1); len(webhooks) != 0 {
+			t.Errorf("%s: webhooks after failed save = %v, want none", tt.name, webhooks)
+		}
+	}
+
+	// Следующая удачная запись не сохраняет неудавшиеся изменения
+	strg.write = writeFileAtomic
+	saved := &models.Event{UserID: 1, Title: "Saved", Start: start, End: start.Add(time.Hour)}
+	if err := strg.Create(saved); err != nil {
+		t.Fatal("Create() error:", err)
+	}
+	reopened, err := NewFileEventStorage(path)
+	if err != nil {
+		t.Fatal("NewFileEventStorage() reopen error:", err)
+	}
+	events, _ := reopened.GetByDateRange(1, start, start.AddDate(0, 0, 1))
+	if len(events) != 2 || events[0].Title != "Kept" || events[1].Title != "Saved" {
+		t.Errorf("events after reopen = %v, want Kept and Saved", events)
+	}
+}
+
+func TestFileEventStorage_SchemaVersion(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "events.json")
+
+	if err := os.WriteFile(path, []byte(`{"version": 999, "next_id": 1, "events": []}`), 0644); err != nil {
+		t.Fatal(err)
+	}
+
+	if _, err := NewFileEventStorage(path); err == nil {
+		t.Error("NewFileEventStorage() should reject unknown schema version")
+	}
+}
+
+func TestFileEventStorage_MigrateDateOnlyEvents(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "events.json")
+
+	legacy := `{"version": 1, "next_id": 2, "events": [
+		{"id": 1, "user_id": 1, "date": "2023-12-31T00:00:00Z", "title": "Party"}
+	]}`
+	if err := os.WriteFile(path, []byte(legacy), 0644); err != nil {
+		t.Fatal(err)
+	}
+
+	strg, err := NewFileEventStorage(path)
+	if err != nil {
+		t.Fatal("NewFileEventStorage() error:", err)
+	}
+
+	event, err := strg.GetByID(1, 1)
+	if err != nil {
+		t.Fatal("GetByID() error:", err)
+	}
+
+	start := time.Date(2023, 12, 31, 0, 0, 0, 0, time.UTC)
+	if !event.AllDay || !event.Start.Equal(start) || !event.End.Equal(start.AddDate(0, 0, 1)) {
+		t.Errorf("migrated event = %+v, want all-day event on 2023-12-31", event)
+	}
+	if event.UID == "" || event.Version != 1 {
+		t.Errorf("migrated event UID = %q, version %d, want generated UID and version 1", event.UID, event.Version)
+	}
+}
+
+func TestFileEventStorage_ReopenSharing(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "events.json")
+
+	strg, err := NewFileEventStorage(path)
+	if err != nil {
+		t.Fatal("NewFileEventStorage() error:", err)
+	}
+
+	start := time.Date(2024, 1, 8, 10, 0, 0, 0, time.UTC)
+	event := &models.Event{
+		UserID:    1,
+		Title:     "Planning",
+		Start:     start,
+		End:       start.Add(time.Hour),
+		Attendees: []models.Attendee{{UserID: 2, Status: models.RSVPAccepted}},
+	}
+	if err := strg.Create(event); err != nil {
+		t.Fatal("Create() error:", err)
+	}
+	if err := strg.SetShare(models.Share{OwnerID: 1, UserID: 3, Permission: models.PermissionWrite}); err != nil {
+		t.Fatal("SetShare() error:", err)
+	}
+
+	reopened, err := NewFileEventStorage(path)
+	if err != nil {
+		t.Fatal("NewFileEventStorage() reopen error:", err)
+	}
+
+	invitations, err := reopened.GetInvitations(2, start, start.AddDate(0, 0, 1))
+	if err != nil || len(invitations) != 1 || invitations[0].Attendees[0].Status != models.RSVPAccepted {
+		t.Errorf("GetInvitations() after reopen = %+v, %v; want accepted invitation", invitations, err)
+	}
+	share, err := reopened.GetShare(1, 3)
+	if err != nil || share == nil || share.Permission != models.PermissionWrite {
+		t.Errorf("GetShare() after reopen = %+v, %v; want write access", share, err)
+	}
+}
@@ -0,0 +1,35 @@
+package storage
+
+import "fmt"
+
+// HealthChecker интерфейс хранилища, которое умеет сообщать о своей
+// работоспособности для проверки готовности сервера
+type HealthChecker interface {
+	// Ping возвращает ошибку, если хранилище не может сохранять изменения
+	Ping() error
+}
+
+// Ping хранилище в памяти всегда доступно
+func (s *InMemoryEventStorage) Ping() error {
+	return nil
+}
+
+// Ping возвращает ошибку последней записи файла, пока запись не удастся снова
+func (s *FileEventStorage) Ping() error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	return s.err
+}
+
+// Ping возвращает ошибку последней записи журнала, пока запись не удастся
+// снова, или ошибку закрытого хранилища
+func (s *WALEventStorage) Ping() error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if s.log == nil {
+		return fmt.Errorf("storage is closed")
+	}
+	return s.err
+}
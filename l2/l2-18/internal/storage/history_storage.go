@@ -0,0 +1,119 @@
+package storage
+
+import (
+	"cmp"
+	"l2-18/internal/models"
+	"slices"
+	"sort"
+)
+
+// HistoryStorage интерфейс хранилища истории изменений событий.
+// Записи истории только добавляются; история удаляется вместе
+// с событием, когда оно окончательно удаляется из корзины.
+type HistoryStorage interface {
+	// AppendRevision добавляет запись в историю события rev.EventID
+	// и присваивает ей следующий номер
+	AppendRevision(rev *models.Revision) error
+	// GetHistory возвращает историю события по возрастанию номеров
+	GetHistory(eventID int) ([]models.Revision, error)
+}
+
+// AppendRevision добавляет запись в историю события
+func (s *InMemoryEventStorage) AppendRevision(rev *models.Revision) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	rev.Revision = len(s.history[rev.EventID]) + 1
+	s.putRevision(*rev)
+
+	return nil
+}
+
+// GetHistory возвращает историю события
+func (s *InMemoryEventStorage) GetHistory(eventID int) ([]models.Revision, error) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	history := s.history[eventID]
+	result := make([]models.Revision, len(history))
+	for i, rev := range history {
+		result[i] = copyRevision(rev)
+	}
+
+	return result, nil
+}
+
+// putRevision сохраняет запись с заданным номером в историю без блокировки.
+// Запись с тем же номером заменяется, поэтому повторное воспроизведение
+// журнала поверх снимка не дублирует историю.
+func (s *InMemoryEventStorage) putRevision(rev models.Revision) {
+	rev = copyRevision(rev)
+	history := s.history[rev.EventID]
+	i, found := slices.BinarySearchFunc(history, rev.Revision, func(r models.Revision, n int) int {
+		return cmp.Compare(r.Revision, n)
+	})
+	if found {
+		history[i] = rev
+		return
+	}
+	s.history[rev.EventID] = slices.Insert(history, i, rev)
+}
+
+// historyList возвращает все записи истории по возрастанию ID событий
+// и номеров записей без блокировки
+func (s *InMemoryEventStorage) historyList() []models.Revision {
+	ids := make([]int, 0, len(s.history))
+	for id := range s.history {
+		ids = append(ids, id)
+	}
+	sort.Ints(ids)
+
+	var list []models.Revision
+	for _, id := range ids {
+		for _, rev := range s.history[id] {
+			list = append(list, copyRevision(rev))
+		}
+	}
+	return list
+}
+
+// copyRevision копирует запись истории вместе с событием
+func copyRevision(rev models.Revision) models.Revision {
+	if rev.Event != nil {
+		copied := *rev.Event
+		rev.Event = &copied
+	}
+	return rev
+}
+
+// AppendRevision добавляет запись в историю события и сохраняет ее в файл
+func (s *FileEventStorage) AppendRevision(rev *models.Revision) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if err := s.mem.AppendRevision(rev); err != nil {
+		return err
+	}
+	return s.save()
+}
+
+// GetHistory возвращает историю события
+func (s *FileEventStorage) GetHistory(eventID int) ([]models.Revision, error) {
+	return s.mem.GetHistory(eventID)
+}
+
+// AppendRevision добавляет запись в историю события и в журнал
+func (s *WALEventStorage) AppendRevision(rev *models.Revision) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if err := s.mem.AppendRevision(rev); err != nil {
+		return err
+	}
+	return s.append(walRecord{Op: opRevision, Revision: rev})
+}
+
+// GetHistory возвращает историю события
+func (s *WALEventStorage) GetHistory(eventID int) ([]models.Revision, error) {
+	return s.mem.GetHistory(eventID)
+}
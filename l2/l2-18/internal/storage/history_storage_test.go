@@ -0,0 +1,90 @@
+package storage
+
+import (
+	"l2-18/internal/models"
+	"path/filepath"
+	"testing"
+	"time"
+)
+
+// historyBackend хранилище с историей и корзиной событий
+type historyBackend interface {
+	EventStorage
+	TrashStorage
+	HistoryStorage
+}
+
+func TestHistoryStorage(t *testing.T) {
+	backends := []struct {
+		name       string
+		open       func(path string) (historyBackend, error)
+		persistent bool
+	}{
+		{"memory", func(string) (historyBackend, error) { return NewInMemoryEventStorage(), nil }, false},
+		{"file", func(path string) (historyBackend, error) { return NewFileEventStorage(path) }, true},
+		{"wal", func(path string) (historyBackend, error) { return NewWALEventStorage(path, 100) }, true},
+		{"wal snapshot", func(path string) (historyBackend, error) { return NewWALEventStorage(path, 1) }, true},
+	}
+
+	start := time.Date(2024, 1, 8, 9, 0, 0, 0, time.UTC)
+
+	for _, backend := range backends {
+		t.Run(backend.name, func(t *testing.T) {
+			path := filepath.Join(t.TempDir(), "events.json")
+			strg, err := backend.open(path)
+			if err != nil {
+				t.Fatal("open error:", err)
+			}
+			reopen := func() {
+				t.Helper()
+				if !backend.persistent {
+					return
+				}
+				if strg, err = backend.open(path); err != nil {
+					t.Fatal("reopen error:", err)
+				}
+			}
+
+			event := &models.Event{UserID: 1, Title: "Standup", Start: start, End: start.Add(time.Hour)}
+			if err := strg.Create(event); err != nil {
+				t.Fatal("Create() error:", err)
+			}
+			for _, action := range []string{models.ChangeCreated, models.ChangeUpdated} {
+				rev := &models.Revision{EventID: event.ID, Action: action, ActorID: 2, Time: start, Event: event}
+				if err := strg.AppendRevision(rev); err != nil {
+					t.Fatal("AppendRevision() error:", err)
+				}
+			}
+			other := &models.Revision{EventID: event.ID + 1, Action: models.ChangeCreated, ActorID: 1, Time: start, Event: event}
+			if err := strg.AppendRevision(other); err != nil || other.Revision != 1 {
+				t.Fatalf("AppendRevision() of other event = revision %d, %v; want 1", other.Revision, err)
+			}
+			reopen()
+
+			history, err := strg.GetHistory(event.ID)
+			if err != nil || len(history) != 2 {
+				t.Fatalf("GetHistory() = %+v, %v; want 2 revisions", history, err)
+			}
+			for i, rev := range history {
+				if rev.Revision != i+1 || rev.ActorID != 2 || rev.Event == nil || rev.Event.Title != "Standup" {
+					t.Errorf("revision %d = %+v, want revision %d by user 2 with the event", i, rev, i+1)
+				}
+			}
+
+			// История удаляется вместе с событием только при очистке корзины
+			if err := strg.TrashEvent(event.ID, 1, 0); err != nil {
+				t.Fatal("TrashEvent() error:", err)
+			}
+			if history, _ := strg.GetHistory(event.ID); len(history) != 2 {
+				t.Errorf("GetHistory() of trashed event = %d revisions, want 2", len(history))
+			}
+			if err := strg.PurgeEvent(event.ID, 1); err != nil {
+				t.Fatal("PurgeEvent() error:", err)
+			}
+			reopen()
+			if history, _ := strg.GetHistory(event.ID); len(history) != 0 {
+				t.Errorf("GetHistory() of purged event = %+v, want empty", history)
+			}
+		})
+	}
+}
@@ -0,0 +1,174 @@
+package storage
+
+import (
+	"l2-18/internal/models"
+	"maps"
+	"time"
+)
+
+// ReminderStorage интерфейс для поиска и учета доставки напоминаний.
+// Планировщик сдвигает отметку Checkpoint только после доставки всех
+// напоминаний до нее, а доставленные после отметки помнит по ключу,
+// поэтому после перезапуска напоминания не теряются и не дублируются.
+type ReminderStorage interface {
+	// GetAllByDateRange работает как GetByDateRange, но для всех пользователей
+	GetAllByDateRange(start, end time.Time) ([]*models.Event, error)
+	// ReminderCheckpoint возвращает момент, до которого все напоминания обработаны
+	ReminderCheckpoint() (time.Time, error)
+	// SetReminderCheckpoint сдвигает отметку и забывает напоминания, доставленные до нее
+	SetReminderCheckpoint(checkpoint time.Time) error
+	IsReminderSent(key string) (bool, error)
+	MarkReminderSent(key string, fireAt time.Time) error
+}
+
+// reminderLog журнал доставки напоминаний
+type reminderLog struct {
+	Checkpoint time.Time            `json:"checkpoint,omitzero"`
+	Sent       map[string]time.Time `json:"sent,omitempty"` // ключ -> время срабатывания
+}
+
+// clone возвращает независимую копию журнала
+func (l reminderLog) clone() reminderLog {
+	sent := maps.Clone(l.Sent)
+	if sent == nil {
+		sent = make(map[string]time.Time)
+	}
+	return reminderLog{Checkpoint: l.Checkpoint, Sent: sent}
+}
+
+// IsZero сообщает, что журнал пуст (для omitzero)
+func (l reminderLog) IsZero() bool {
+	return l.Checkpoint.IsZero() && len(l.Sent) == 0
+}
+
+// GetAllByDateRange возвращает события всех пользователей, пересекающиеся с [start, end)
+func (s *InMemoryEventStorage) GetAllByDateRange(start, end time.Time) ([]*models.Event, error) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	var result []*models.Event
+	for _, event := range s.events {
+		if inRange(event, start, end) {
+			result = append(result, event)
+		}
+	}
+	models.SortByStart(result)
+
+	return result, nil
+}
+
+// ReminderCheckpoint возвращает отметку обработанных напоминаний
+func (s *InMemoryEventStorage) ReminderCheckpoint() (time.Time, error) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	return s.reminders.Checkpoint, nil
+}
+
+// SetReminderCheckpoint сдвигает отметку обработанных напоминаний
+func (s *InMemoryEventStorage) SetReminderCheckpoint(checkpoint time.Time) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	s.reminders.Checkpoint = checkpoint
+	for key, fireAt := range s.reminders.Sent {
+		if fireAt.Before(checkpoint) {
+			delete(s.reminders.Sent, key)
+		}
+	}
+
+	return nil
+}
+
+// IsReminderSent сообщает, доставлено ли напоминание
+func (s *InMemoryEventStorage) IsReminderSent(key string) (bool, error) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	_, ok := s.reminders.Sent[key]
+	return ok, nil
+}
+
+// MarkReminderSent отмечает напоминание доставленным
+func (s *InMemoryEventStorage) MarkReminderSent(key string, fireAt time.Time) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	s.reminders.Sent[key] = fireAt
+
+	return nil
+}
+
+// GetAllByDateRange возвращает события всех пользователей, пересекающиеся с [start, end)
+func (s *FileEventStorage) GetAllByDateRange(start, end time.Time) ([]*models.Event, error) {
+	return s.mem.GetAllByDateRange(start, end)
+}
+
+// ReminderCheckpoint возвращает отметку обработанных напоминаний
+func (s *FileEventStorage) ReminderCheckpoint() (time.Time, error) {
+	return s.mem.ReminderCheckpoint()
+}
+
+// SetReminderCheckpoint сдвигает отметку обработанных напоминаний
+func (s *FileEventStorage) SetReminderCheckpoint(checkpoint time.Time) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if err := s.mem.SetReminderCheckpoint(checkpoint); err != nil {
+		return err
+	}
+	return s.save()
+}
+
+// IsReminderSent сообщает, доставлено ли напоминание
+func (s *FileEventStorage) IsReminderSent(key string) (bool, error) {
+	return s.mem.IsReminderSent(key)
+}
+
+// MarkReminderSent отмечает напоминание доставленным
+func (s *FileEventStorage) MarkReminderSent(key string, fireAt time.Time) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if err := s.mem.MarkReminderSent(key, fireAt); err != nil {
+		return err
+	}
+	return s.save()
+}
+
+// GetAllByDateRange возвращает события всех пользователей, пересекающиеся с [start, end)
+func (s *WALEventStorage) GetAllByDateRange(start, end time.Time) ([]*models.Event, error) {
+	return s.mem.GetAllByDateRange(start, end)
+}
+
+// ReminderCheckpoint возвращает отметку обработанных напоминаний
+func (s *WALEventStorage) ReminderCheckpoint() (time.Time, error) {
+	return s.mem.ReminderCheckpoint()
+}
+
+// SetReminderCheckpoint сдвигает отметку обработанных напоминаний
+func (s *WALEventStorage) SetReminderCheckpoint(checkpoint time.Time) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if err := s.mem.SetReminderCheckpoint(checkpoint); err != nil {
+		return err
+	}
+	return s.append(walRecord{Op: opCheckpoint, Time: checkpoint})
+}
+
+// IsReminderSent сообщает, доставлено ли напоминание
+func (s *WALEventStorage) IsReminderSent(key string) (bool, error) {
+	return s.mem.IsReminderSent(key)
+}
+
+// MarkReminderSent отмечает напоминание доставленным
+func (s *WALEventStorage) MarkReminderSent(key string, fireAt time.Time) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if err := s.mem.MarkReminderSent(key, fireAt); err != nil {
+		return err
+	}
+	return s.append(walRecord{Op: opReminderSent, Key: key, Time: fireAt})
+}
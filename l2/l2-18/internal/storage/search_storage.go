@@ -0,0 +1,97 @@
+package storage
+
+import (
+	"l2-18/internal/models"
+	"l2-18/internal/search"
+	"sort"
+)
+
+// SearchFilter условия поиска событий по индексу
+type SearchFilter struct {
+	// Terms слова запроса, приведенные через search.Fold; каждое
+	// совпадает с началом слова в названии или описании события
+	Terms []string
+	// Tags метки, которые должны быть у события (без учета регистра)
+	Tags []string
+	// Attendees пользователи, которые должны участвовать в событии или владеть им
+	Attendees []int
+}
+
+// SearchStorage интерфейс полнотекстового поиска событий
+type SearchStorage interface {
+	// Search возвращает события, которые видит пользователь (свои
+	// и приглашения) и которые подходят под все условия фильтра
+	Search(userID int, filter SearchFilter) ([]*models.Event, error)
+}
+
+// Search ищет события по индексам слов, меток и участников, начиная
+// пересечение с самого короткого списка
+func (s *InMemoryEventStorage) Search(userID int, filter SearchFilter) ([]*models.Event, error) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	sets := []map[int]struct{}{s.participantEvents(userID)}
+	for _, term := range filter.Terms {
+		sets = append(sets, s.text.LookupPrefix(term))
+	}
+	for _, tag := range filter.Tags {
+		sets = append(sets, s.tags.Lookup(search.Fold(tag)))
+	}
+	for _, attendee := range filter.Attendees {
+		sets = append(sets, s.participantEvents(attendee))
+	}
+	sort.Slice(sets, func(i, j int) bool { return len(sets[i]) < len(sets[j]) })
+
+	var result []*models.Event
+	for id := range sets[0] {
+		matched := true
+		for _, set := range sets[1:] {
+			if _, ok := set[id]; !ok {
+				matched = false
+				break
+			}
+		}
+		if event := s.events[id]; matched && event != nil {
+			result = append(result, event)
+		}
+	}
+	models.SortByStart(result)
+
+	return result, nil
+}
+
+// participantEvents возвращает ID событий, которыми пользователь владеет или в которых участвует
+func (s *InMemoryEventStorage) participantEvents(userID int) map[int]struct{} {
+	result := make(map[int]struct{}, len(s.userToID[userID])+len(s.attendeeToID[userID]))
+	for _, id := range s.userToID[userID] {
+		result[id] = struct{}{}
+	}
+	for _, id := range s.attendeeToID[userID] {
+		result[id] = struct{}{}
+	}
+	return result
+}
+
+// textTerms возвращает слова названия и описания события для индекса
+func textTerms(event *models.Event) []string {
+	return search.Tokens(event.Title + " " + event.Description)
+}
+
+// tagTerms возвращает метки события для индекса
+func tagTerms(event *models.Event) []string {
+	terms := make([]string, 0, len(event.Tags))
+	for _, tag := range event.Tags {
+		terms = append(terms, search.Fold(tag))
+	}
+	return terms
+}
+
+// Search ищет события по индексу
+func (s *FileEventStorage) Search(userID int, filter SearchFilter) ([]*models.Event, error) {
+	return s.mem.Search(userID, filter)
+}
+
+// Search ищет события по индексу
+func (s *WALEventStorage) Search(userID int, filter SearchFilter) ([]*models.Event, error) {
+	return s.mem.Search(userID, filter)
+}
@@ -0,0 +1,249 @@
+package storage
+
+import (
+	"l2-18/internal/apperrors"
+	"l2-18/internal/models"
+	"sort"
+	"time"
+)
+
+// SharingStorage интерфейс для совместной работы с календарями: поиск
+// событий, в которых пользователь участвует, и доступы к чужим календарям.
+// Права доступа проверяет сервис, хранилище только хранит их.
+type SharingStorage interface {
+	// Find возвращает событие по ID без проверки владельца
+	Find(id int) (*models.Event, error)
+	// GetInvitations работает как GetByDateRange, но возвращает события,
+	// в которых пользователь указан участником
+	GetInvitations(userID int, start, end time.Time) ([]*models.Event, error)
+	// GetShare возвращает доступ userID к календарю ownerID или nil, если его нет
+	GetShare(ownerID, userID int) (*models.Share, error)
+	SetShare(share models.Share) error
+	DeleteShare(ownerID, userID int) error
+	// GetShares возвращает доступы к календарю владельца ownerID
+	GetShares(ownerID int) ([]models.Share, error)
+	// GetSharedCalendars возвращает доступы пользователя userID к чужим календарям
+	GetSharedCalendars(userID int) ([]models.Share, error)
+}
+
+// Find возвращает событие по ID
+func (s *InMemoryEventStorage) Find(id int) (*models.Event, error) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	event, exists := s.events[id]
+	if !exists {
+		return nil, apperrors.Errorf(apperrors.ErrNotFound, "event with ID %d not found", id)
+	}
+
+	return event, nil
+}
+
+// GetInvitations возвращает события, в которых пользователь участвует,
+// пересекающиеся с полуинтервалом [start, end)
+func (s *InMemoryEventStorage) GetInvitations(userID int, start, end time.Time) ([]*models.Event, error) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	var result []*models.Event
+	for _, eventID := range s.attendeeToID[userID] {
+		event := s.events[eventID]
+		if event != nil && inRange(event, start, end) {
+			result = append(result, event)
+		}
+	}
+	models.SortByStart(result)
+
+	return result, nil
+}
+
+// GetShare возвращает доступ пользователя к календарю
+func (s *InMemoryEventStorage) GetShare(ownerID, userID int) (*models.Share, error) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	permission, ok := s.shares[ownerID][userID]
+	if !ok {
+		return nil, nil
+	}
+
+	return &models.Share{OwnerID: ownerID, UserID: userID, Permission: permission}, nil
+}
+
+// SetShare открывает доступ к календарю или меняет его права
+func (s *InMemoryEventStorage) SetShare(share models.Share) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	s.setShare(share)
+
+	return nil
+}
+
+// DeleteShare закрывает доступ к календарю
+func (s *InMemoryEventStorage) DeleteShare(ownerID, userID int) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if _, ok := s.shares[ownerID][userID]; !ok {
+		return apperrors.Errorf(apperrors.ErrNotFound, "calendar %d is not shared with user %d", ownerID, userID)
+	}
+	delete(s.shares[ownerID], userID)
+
+	return nil
+}
+
+// GetShares возвращает доступы к календарю по возрастанию ID пользователя
+func (s *InMemoryEventStorage) GetShares(ownerID int) ([]models.Share, error) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	var result []models.Share
+	for userID, permission := range s.shares[ownerID] {
+		result = append(result, models.Share{OwnerID: ownerID, UserID: userID, Permission: permission})
+	}
+	sortShares(result)
+
+	return result, nil
+}
+
+// GetSharedCalendars возвращает доступы пользователя к чужим календарям
+// по возрастанию ID владельца
+func (s *InMemoryEventStorage) GetSharedCalendars(userID int) ([]models.Share, error) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	var result []models.Share
+	for ownerID, users := range s.shares {
+		if permission, ok := users[userID]; ok {
+			result = append(result, models.Share{OwnerID: ownerID, UserID: userID, Permission: permission})
+		}
+	}
+	sortShares(result)
+
+	return result, nil
+}
+
+// setShare сохраняет доступ без блокировки
+func (s *InMemoryEventStorage) setShare(share models.Share) {
+	if s.shares[share.OwnerID] == nil {
+		s.shares[share.OwnerID] = make(map[int]string)
+	}
+	s.shares[share.OwnerID][share.UserID] = share.Permission
+}
+
+// shareList возвращает все доступы без блокировки
+func (s *InMemoryEventStorage) shareList() []models.Share {
+	var result []models.Share
+	for ownerID, users := range s.shares {
+		for userID, permission := range users {
+			result = append(result, models.Share{OwnerID: ownerID, UserID: userID, Permission: permission})
+		}
+	}
+	sortShares(result)
+	return result
+}
+
+// sortShares упорядочивает доступы по владельцу, затем по пользователю
+func sortShares(shares []models.Share) {
+	sort.Slice(shares, func(i, j int) bool {
+		if shares[i].OwnerID != shares[j].OwnerID {
+			return shares[i].OwnerID < shares[j].OwnerID
+		}
+		return shares[i].UserID < shares[j].UserID
+	})
+}
+
+// Find возвращает событие по ID
+func (s *FileEventStorage) Find(id int) (*models.Event, error) {
+	return s.mem.Find(id)
+}
+
+// GetInvitations возвращает события, в которых пользователь участвует
+func (s *FileEventStorage) GetInvitations(userID int, start, end time.Time) ([]*models.Event, error) {
+	return s.mem.GetInvitations(userID, start, end)
+}
+
+// GetShare возвращает доступ пользователя к календарю
+func (s *FileEventStorage) GetShare(ownerID, userID int) (*models.Share, error) {
+	return s.mem.GetShare(ownerID, userID)
+}
+
+// SetShare открывает доступ к календарю или меняет его права
+func (s *FileEventStorage) SetShare(share models.Share) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if err := s.mem.SetShare(share); err != nil {
+		return err
+	}
+	return s.save()
+}
+
+// DeleteShare закрывает доступ к календарю
+func (s *FileEventStorage) DeleteShare(ownerID, userID int) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if err := s.mem.DeleteShare(ownerID, userID); err != nil {
+		return err
+	}
+	return s.save()
+}
+
+// GetShares возвращает доступы к календарю
+func (s *FileEventStorage) GetShares(ownerID int) ([]models.Share, error) {
+	return s.mem.GetShares(ownerID)
+}
+
+// GetSharedCalendars возвращает доступы пользователя к чужим календарям
+func (s *FileEventStorage) GetSharedCalendars(userID int) ([]models.Share, error) {
+	return s.mem.GetSharedCalendars(userID)
+}
+
+// Find возвращает событие по ID
+func (s *WALEventStorage) Find(id int) (*models.Event, error) {
+	return s.mem.Find(id)
+}
+
+// GetInvitations возвращает события, в которых пользователь участвует
+func (s *WALEventStorage) GetInvitations(userID int, start, end time.Time) ([]*models.Event, error) {
+	return s.mem.GetInvitations(userID, start, end)
+}
+
+// GetShare возвращает доступ пользователя к календарю
+func (s *WALEventStorage) GetShare(ownerID, userID int) (*models.Share, error) {
+	return s.mem.GetShare(ownerID, userID)
+}
+
+// SetShare открывает доступ к календарю или меняет его права
+func (s *WALEventStorage) SetShare(share models.Share) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if err := s.mem.SetShare(share); err != nil {
+		return err
+	}
+	return s.append(walRecord{Op: opShare, Share: &share})
+}
+
+// DeleteShare закрывает доступ к календарю
+func (s *WALEventStorage) DeleteShare(ownerID, userID int) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if err := s.mem.DeleteShare(ownerID, userID); err != nil {
+		return err
+	}
+	return s.append(walRecord{Op: opUnshare, Share: &models.Share{OwnerID: ownerID, UserID: userID}})
+}
+
+// GetShares возвращает доступы к календарю
+func (s *WALEventStorage) GetShares(ownerID int) ([]models.Share, error) {
+	return s.mem.GetShares(ownerID)
+}
+
+// GetSharedCalendars возвращает доступы пользователя к чужим календарям
+func (s *WALEventStorage) GetSharedCalendars(userID int) ([]models.Share, error) {
+	return s.mem.GetSharedCalendars(userID)
+}
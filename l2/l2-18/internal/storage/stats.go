@@ -0,0 +1,54 @@
+package storage
+
+// Stats сведения о содержимом хранилища для метрик
+type Stats struct {
+	// Events число хранимых событий (серия считается одним событием)
+	Events int
+	// Users число пользователей, известных хранилищу: владельцев
+	// и участников событий, пользователей с настройками и доступами
+	Users int
+}
+
+// StatsStorage интерфейс хранилища, которое сообщает сведения о содержимом
+type StatsStorage interface {
+	Stats() (Stats, error)
+}
+
+// Stats возвращает число событий и пользователей
+func (s *InMemoryEventStorage) Stats() (Stats, error) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	users := make(map[int]struct{})
+	for userID, ids := range s.userToID {
+		if len(ids) > 0 {
+			users[userID] = struct{}{}
+		}
+	}
+	for userID, ids := range s.attendeeToID {
+		if len(ids) > 0 {
+			users[userID] = struct{}{}
+		}
+	}
+	for userID := range s.timeZones {
+		users[userID] = struct{}{}
+	}
+	for ownerID, shares := range s.shares {
+		users[ownerID] = struct{}{}
+		for userID := range shares {
+			users[userID] = struct{}{}
+		}
+	}
+
+	return Stats{Events: len(s.events), Users: len(users)}, nil
+}
+
+// Stats возвращает число событий и пользователей
+func (s *FileEventStorage) Stats() (Stats, error) {
+	return s.mem.Stats()
+}
+
+// Stats возвращает число событий и пользователей
+func (s *WALEventStorage) Stats() (Stats, error) {
+	return s.mem.Stats()
+}
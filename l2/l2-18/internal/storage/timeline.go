@@ -0,0 +1,207 @@
+package storage
+
+import (
+	"l2-18/internal/models"
+	"l2-18/internal/recurrence"
+	"math/rand/v2"
+	"time"
+)
+
+// farFuture конец серии без COUNT и UNTIL
+var farFuture = time.Date(9999, 12, 31, 0, 0, 0, 0, time.UTC)
+
+// timeline индекс событий пользователя по времени: декартово дерево
+// (дерамида), упорядоченное по началу события и ID. Каждый узел хранит
+// конец последнего экземпляра события и наибольший такой конец в своем
+// поддереве, поэтому выборка пропускает поддеревья, события которых
+// закончились до начала диапазона или начинаются после его конца.
+// Серии индексируются так же, как разовые события, — от начала до конца
+// последнего экземпляра. Добавление, удаление и поиск первого события
+// диапазона занимают O(log n) в среднем.
+type timeline struct {
+	root *timelineNode
+}
+
+// timelineNode узел дерева timeline
+type timelineNode struct {
+	event *models.Event
+	// until конец последнего экземпляра события
+	until time.Time
+	// maxUntil наибольший until в поддереве узла
+	maxUntil    time.Time
+	priority    uint64
+	left, right *timelineNode
+}
+
+// add добавляет событие в индекс
+func (t *timeline) add(event *models.Event) {
+	until := eventUntil(event)
+	t.root = insertNode(t.root, &timelineNode{
+		event:    event,
+		until:    until,
+		maxUntil: until,
+		priority: rand.Uint64(),
+	})
+}
+
+// remove удаляет событие из индекса; event — то же значение, что было добавлено
+func (t *timeline) remove(event *models.Event) {
+	t.root = removeNode(t.root, event)
+}
+
+// empty сообщает, что в индексе нет событий
+func (t *timeline) empty() bool {
+	return t.root == nil
+}
+
+// between возвращает события, которые могут попасть в [start, end),
+// в хронологическом порядке (правило то же, что у inRange, но серии,
+// закончившиеся до start, не возвращаются)
+func (t *timeline) between(start, end time.Time) []*models.Event {
+	return t.root.collect(start, end, nil)
+}
+
+// collect дописывает к result события поддерева, которые могут попасть
+// в [start, end), обходя его по порядку
+func (n *timelineNode) collect(start, end time.Time, result []*models.Event) []*models.Event {
+	if n == nil || n.maxUntil.Before(start) {
+		return result
+	}
+
+	result = n.left.collect(start, end, result)
+	// Узел и его правое поддерево начинаются не раньше end
+	if !n.event.Start.Before(end) {
+		return result
+	}
+	if !n.until.Before(start) && inRange(n.event, start, end) {
+		result = append(result, n.event)
+	}
+	return n.right.collect(start, end, result)
+}
+
+// update пересчитывает maxUntil узла по его детям
+func (n *timelineNode) update() {
+	n.maxUntil = n.until
+	for _, child := range []*timelineNode{n.left, n.right} {
+		if child != nil && child.maxUntil.After(n.maxUntil) {
+			n.maxUntil = child.maxUntil
+		}
+	}
+}
+
+// precedes сообщает, идет ли событие a в индексе раньше b (по началу и ID)
+func precedes(a, b *models.Event) bool {
+	if !a.Start.Equal(b.Start) {
+		return a.Start.Before(b.Start)
+	}
+	return a.ID < b.ID
+}
+
+// insertNode вставляет узел в поддерево и возвращает новый корень поддерева
+func insertNode(n, node *timelineNode) *timelineNode {
+	if n == nil {
+		return node
+	}
+	if node.priority > n.priority {
+		node.left, node.right = splitNodes(n, node.event)
+		node.update()
+		return node
+	}
+
+	if precedes(node.event, n.event) {
+		n.left = insertNode(n.left, node)
+	} else {
+		n.right = insertNode(n.right, node)
+	}
+	n.update()
+	return n
+}
+
+// removeNode удаляет событие из поддерева и возвращает новый корень поддерева
+func removeNode(n *timelineNode, event *models.Event) *timelineNode {
+	if n == nil {
+		return nil
+	}
+	if n.event.ID == event.ID {
+		return mergeNodes(n.left, n.right)
+	}
+
+	if precedes(event, n.event) {
+		n.left = removeNode(n.left, event)
+	} else {
+		n.right = removeNode(n.right, event)
+	}
+	n.update()
+	return n
+}
+
+// splitNodes делит поддерево на события, идущие раньше event, и остальные
+func splitNodes(n *timelineNode, event *models.Event) (left, right *timelineNode) {
+	if n == nil {
+		return nil, nil
+	}
+	if precedes(n.event, event) {
+		n.right, right = splitNodes(n.right, event)
+		n.update()
+		return n, right
+	}
+	left, n.left = splitNodes(n.left, event)
+	n.update()
+	return left, n
+}
+
+// mergeNodes объединяет поддеревья, все события left идут раньше событий right
+func mergeNodes(left, right *timelineNode) *timelineNode {
+	switch {
+	case left == nil:
+		return right
+	case right == nil:
+		return left
+	case left.priority > right.priority:
+		left.right = mergeNodes(left.right, right)
+		left.update()
+		return left
+	default:
+		right.left = mergeNodes(left, right.left)
+		right.update()
+		return right
+	}
+}
+
+// eventUntil возвращает конец последнего экземпляра события: для разового
+// события — его конец, для серии — конец экземпляра, начинающегося
+// не позже UNTIL или последнего по COUNT. Серия без ограничений,
+// как и серия с неразборчивым правилом, длится до farFuture.
+func eventUntil(event *models.Event) time.Time {
+	if !event.IsRecurring() {
+		return event.End
+	}
+
+	rule, err := recurrence.Parse(event.RRule)
+	if err != nil {
+		return farFuture
+	}
+	switch {
+	case !rule.Until.IsZero():
+		return rule.Until.Add(event.Duration())
+	case rule.Count > 0:
+		// Экземпляры вычисляются в часовом поясе события, как в сервисе
+		loc := time.UTC
+		if event.TimeZone != "" {
+			if zone, err := time.LoadLocation(event.TimeZone); err == nil {
+				loc = zone
+			}
+		}
+		// Серия с экземплярами сверх предела индексируется как бесконечная
+		occurrences, err := rule.Between(event.Start.In(loc), event.Start, farFuture, nil)
+		if err != nil {
+			return farFuture
+		}
+		if len(occurrences) == 0 {
+			return event.End
+		}
+		return occurrences[len(occurrences)-1].Add(event.Duration())
+	default:
+		return farFuture
+	}
+}
@@ -0,0 +1,137 @@
+package storage
+
+import (
+	"fmt"
+	"l2-18/internal/models"
+	"math/rand"
+	"slices"
+	"testing"
+	"time"
+)
+
+// scanByDateRange прежняя выборка диапазона перебором всех событий
+// пользователя: эталон для проверки индекса и сравнения в бенчмарках
+func scanByDateRange(s *InMemoryEventStorage, userID int, start, end time.Time) []*models.Event {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	var result []*models.Event
+	for _, eventID := range s.userToID[userID] {
+		if event := s.events[eventID]; event != nil && inRange(event, start, end) {
+			result = append(result, event)
+		}
+	}
+	models.SortByStart(result)
+	return result
+}
+
+// fillHistory создает пользователю 1 события за years лет: по нескольку
+// встреч в день, изредка длинные события, серии и события без длительности.
+// Серией становится каждое событие с номером, кратным recurring: ограниченной
+// COUNT или UNTIL либо бесконечной.
+func fillHistory(tb testing.TB, s *InMemoryEventStorage, years, recurring int) {
+	tb.Helper()
+	rng := rand.New(rand.NewSource(1))
+	base := time.Date(2020, 1, 1, 0, 0, 0, 0, time.UTC)
+
+	for day := 0; day < years*365; day++ {
+		for i := range 4 {
+			start := base.AddDate(0, 0, day).Add(time.Duration(rng.Intn(24*4)) * 15 * time.Minute)
+			event := &models.Event{UserID: 1, Start: start, End: start.Add(time.Duration(rng.Intn(5)) * 30 * time.Minute)}
+			switch {
+			case (day*4+i)%recurring == 0:
+				event.RRule = []string{
+					"FREQ=WEEKLY;COUNT=10",
+					"FREQ=DAILY;UNTIL=" + start.AddDate(0, 1, 0).Format("20060102T150405Z"),
+					"FREQ=MONTHLY",
+				}[rng.Intn(3)]
+			case rng.Intn(200) == 0:
+				event.End = start.AddDate(0, 0, 10+rng.Intn(30))
+			}
+			if err := s.Create(event); err != nil {
+				tb.Fatal("Create() error:", err)
+			}
+		}
+	}
+}
+
+func TestInMemoryEventStorage_GetByDateRangeIndex(t *testing.T) {
+	strg := NewInMemoryEventStorage()
+	fillHistory(t, strg, 2, 50)
+
+	// Изменения и удаления должны переносить события в индексе
+	for id := 1; id < 600; id += 3 {
+		event, err := strg.GetByID(id, 1)
+		if err != nil {
+			t.Fatal("GetByID() error:", err)
+		}
+		if id%2 == 0 {
+			if err := strg.Delete(id, 1, 0); err != nil {
+				t.Fatal("Delete() error:", err)
+			}
+			continue
+		}
+		moved := *event
+		moved.Start = event.Start.AddDate(0, 0, id%40)
+		moved.End = moved.Start.Add(time.Duration(id%3) * 24 * 5 * time.Hour)
+		if err := strg.Update(&moved); err != nil {
+			t.Fatal("Update() error:", err)
+		}
+	}
+
+	base := time.Date(2020, 1, 1, 0, 0, 0, 0, time.UTC)
+	tests := []struct {
+		name       string
+		start, end time.Time
+	}{
+		{"day", base.AddDate(0, 1, 3), base.AddDate(0, 1, 4)},
+		{"week", base.AddDate(0, 2, 0), base.AddDate(0, 2, 7)},
+		{"month", base.AddDate(1, 0, 0), base.AddDate(1, 1, 0)},
+		{"before history", base.AddDate(-1, 0, 0), base},
+		{"after history", base.AddDate(5, 0, 0), base.AddDate(5, 1, 0)},
+		{"exact start", base.Add(45 * time.Minute), base.Add(46 * time.Minute)},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := strg.GetByDateRange(1, tt.start, tt.end)
+			if err != nil {
+				t.Fatal("GetByDateRange() error:", err)
+			}
+			// Серии, закончившиеся до начала диапазона, индекс отбрасывает
+			want := slices.DeleteFunc(scanByDateRange(strg, 1, tt.start, tt.end), func(e *models.Event) bool {
+				return eventUntil(e).Before(tt.start)
+			})
+			if !slices.Equal(got, want) {
+				t.Errorf("GetByDateRange() returned %d events, want %d", len(got), len(want))
+			}
+		})
+	}
+
+	if events, _ := strg.GetByDateRange(2, base, base.AddDate(10, 0, 0)); len(events) != 0 {
+		t.Errorf("GetByDateRange() for user without events = %d events, want none", len(events))
+	}
+}
+
+func BenchmarkGetByDateRange(b *testing.B) {
+	week := time.Date(2022, 6, 6, 0, 0, 0, 0, time.UTC)
+
+	// Серия — каждое 200-е событие или каждое второе
+	for _, recurring := range []int{200, 2} {
+		for _, years := range []int{1, 5, 20} {
+			strg := NewInMemoryEventStorage()
+			fillHistory(b, strg, years, recurring)
+
+			b.Run(fmt.Sprintf("index/%dy/series-every-%d", years, recurring), func(b *testing.B) {
+				for b.Loop() {
+					_, _ = strg.GetByDateRange(1, week, week.AddDate(0, 0, 7))
+				}
+			})
+			b.Run(fmt.Sprintf("scan/%dy/series-every-%d", years, recurring), func(b *testing.B) {
+				for b.Loop() {
+					scanByDateRange(strg, 1, week, week.AddDate(0, 0, 7))
+				}
+			})
+		}
+	}
+}
@@ -0,0 +1,281 @@
+package storage
+
+import (
+	"l2-18/internal/apperrors"
+	"l2-18/internal/models"
+	"sort"
+	"time"
+)
+
+// TrashStorage интерфейс хранилища с корзиной удаленных событий.
+// Событие в корзине не возвращается обычными методами чтения,
+// но сохраняет свой ID и может быть восстановлено.
+type TrashStorage interface {
+	// TrashEvent переносит событие в корзину владельца, если оно
+	// не изменилось с версии version (0 — без проверки)
+	TrashEvent(id, userID, version int) error
+	// GetTrash возвращает события в корзине пользователя, начиная с удаленных последними
+	GetTrash(userID int) ([]models.TrashedEvent, error)
+	// RestoreEvent возвращает событие из корзины; версия события увеличивается
+	RestoreEvent(id, userID int) (*models.Event, error)
+	// PurgeEvent окончательно удаляет событие из корзины вместе с его историей
+	PurgeEvent(id, userID int) error
+	// PurgeTrash окончательно удаляет события, перенесенные в корзину
+	// раньше before, и возвращает их число
+	PurgeTrash(before time.Time) (int, error)
+}
+
+// TrashEvent переносит событие в корзину
+func (s *InMemoryEventStorage) TrashEvent(id, userID, version int) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	return s.trashEvent(id, userID, version, time.Now())
+}
+
+// GetTrash возвращает события в корзине пользователя
+func (s *InMemoryEventStorage) GetTrash(userID int) ([]models.TrashedEvent, error) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	var result []models.TrashedEvent
+	for _, trashed := range s.trash {
+		if trashed.Event.UserID == userID {
+			copied := *trashed.Event
+			trashed.Event = &copied
+			result = append(result, trashed)
+		}
+	}
+	sort.Slice(result, func(i, j int) bool {
+		if !result[i].DeletedAt.Equal(result[j].DeletedAt) {
+			return result[i].DeletedAt.After(result[j].DeletedAt)
+		}
+		return result[i].Event.ID > result[j].Event.ID
+	})
+
+	return result, nil
+}
+
+// RestoreEvent возвращает событие из корзины
+func (s *InMemoryEventStorage) RestoreEvent(id, userID int) (*models.Event, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	trashed, err := s.trashedEvent(id, userID)
+	if err != nil {
+		return nil, err
+	}
+	delete(s.trash, id)
+
+	event := *trashed.Event
+	event.Version++
+	event.UpdatedAt = time.Now()
+	s.events[id] = &event
+	s.userToID[event.UserID] = append(s.userToID[event.UserID], id)
+	s.index(&event)
+
+	return &event, nil
+}
+
+// PurgeEvent окончательно удаляет событие из корзины
+func (s *InMemoryEventStorage) PurgeEvent(id, userID int) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if _, err := s.trashedEvent(id, userID); err != nil {
+		return err
+	}
+	delete(s.trash, id)
+	delete(s.history, id)
+
+	return nil
+}
+
+// PurgeTrash окончательно удаляет события, перенесенные в корзину раньше before
+func (s *InMemoryEventStorage) PurgeTrash(before time.Time) (int, error) {
+	return len(s.purgeTrash(before)), nil
+}
+
+// trashEvent переносит событие в корзину с временем удаления deletedAt
+func (s *InMemoryEventStorage) trashEvent(id, userID, version int, deletedAt time.Time) error {
+	event, err := s.writable(id, userID, version)
+	if err != nil {
+		return err
+	}
+
+	_ = s.delete(id, userID, 0)
+	s.trash[id] = models.TrashedEvent{Event: event, DeletedAt: deletedAt}
+
+	return nil
+}
+
+// trashedEvent возвращает событие пользователя из корзины
+func (s *InMemoryEventStorage) trashedEvent(id, userID int) (models.TrashedEvent, error) {
+	trashed, ok := s.trash[id]
+	if !ok || trashed.Event.UserID != userID {
+		return models.TrashedEvent{}, apperrors.Errorf(apperrors.ErrNotFound, "event with ID %d is not in the trash", id)
+	}
+	return trashed, nil
+}
+
+// trashed возвращает событие из корзины без проверки владельца
+func (s *InMemoryEventStorage) trashed(id int) (models.TrashedEvent, bool) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	trashed, ok := s.trash[id]
+	return trashed, ok
+}
+
+// purgeTrash удаляет из корзины события, перенесенные в нее раньше before,
+// и возвращает их ID
+func (s *InMemoryEventStorage) purgeTrash(before time.Time) []int {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	var purged []int
+	for id, trashed := range s.trash {
+		if trashed.DeletedAt.Before(before) {
+			delete(s.trash, id)
+			delete(s.history, id)
+			purged = append(purged, id)
+		}
+	}
+	sort.Ints(purged)
+
+	return purged
+}
+
+// putTrashed кладет событие в корзину как есть, убирая его из событий
+func (s *InMemoryEventStorage) putTrashed(trashed models.TrashedEvent) {
+	s.remove(trashed.Event.ID)
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.trash[trashed.Event.ID] = trashed
+	s.nextID = max(s.nextID, trashed.Event.ID+1)
+}
+
+// trashList возвращает события в корзине по возрастанию ID
+func (s *InMemoryEventStorage) trashList() []models.TrashedEvent {
+	list := make([]models.TrashedEvent, 0, len(s.trash))
+	for _, trashed := range s.trash {
+		copied := *trashed.Event
+		trashed.Event = &copied
+		list = append(list, trashed)
+	}
+	sort.Slice(list, func(i, j int) bool { return list[i].Event.ID < list[j].Event.ID })
+	return list
+}
+
+// TrashEvent переносит событие в корзину
+func (s *FileEventStorage) TrashEvent(id, userID, version int) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if err := s.mem.TrashEvent(id, userID, version); err != nil {
+		return err
+	}
+	return s.save()
+}
+
+// GetTrash возвращает события в корзине пользователя
+func (s *FileEventStorage) GetTrash(userID int) ([]models.TrashedEvent, error) {
+	return s.mem.GetTrash(userID)
+}
+
+// RestoreEvent возвращает событие из корзины
+func (s *FileEventStorage) RestoreEvent(id, userID int) (*models.Event, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	event, err := s.mem.RestoreEvent(id, userID)
+	if err != nil {
+		return nil, err
+	}
+	return event, s.save()
+}
+
+// PurgeEvent окончательно удаляет событие из корзины
+func (s *FileEventStorage) PurgeEvent(id, userID int) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if err := s.mem.PurgeEvent(id, userID); err != nil {
+		return err
+	}
+	return s.save()
+}
+
+// PurgeTrash окончательно удаляет события, перенесенные в корзину раньше before
+func (s *FileEventStorage) PurgeTrash(before time.Time) (int, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	purged := s.mem.purgeTrash(before)
+	if len(purged) == 0 {
+		return 0, nil
+	}
+	return len(purged), s.save()
+}
+
+// TrashEvent переносит событие в корзину
+func (s *WALEventStorage) TrashEvent(id, userID, version int) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if err := s.mem.TrashEvent(id, userID, version); err != nil {
+		return err
+	}
+	return s.append(s.trashRecord(id))
+}
+
+// GetTrash возвращает события в корзине пользователя
+func (s *WALEventStorage) GetTrash(userID int) ([]models.TrashedEvent, error) {
+	return s.mem.GetTrash(userID)
+}
+
+// RestoreEvent возвращает событие из корзины
+func (s *WALEventStorage) RestoreEvent(id, userID int) (*models.Event, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	event, err := s.mem.RestoreEvent(id, userID)
+	if err != nil {
+		return nil, err
+	}
+	return event, s.append(walRecord{Op: opRestore, Event: event})
+}
+
+// PurgeEvent окончательно удаляет событие из корзины
+func (s *WALEventStorage) PurgeEvent(id, userID int) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if err := s.mem.PurgeEvent(id, userID); err != nil {
+		return err
+	}
+	return s.append(walRecord{Op: opPurge, ID: id})
+}
+
+// PurgeTrash окончательно удаляет события, перенесенные в корзину раньше before
+func (s *WALEventStorage) PurgeTrash(before time.Time) (int, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	purged := s.mem.purgeTrash(before)
+	if len(purged) == 0 {
+		return 0, nil
+	}
+	records := make([]walRecord, 0, len(purged))
+	for _, id := range purged {
+		records = append(records, walRecord{Op: opPurge, ID: id})
+	}
+	return len(purged), s.append(walRecord{Op: opBatch, Batch: records})
+}
+
+// trashRecord возвращает запись журнала о событии, перенесенном в корзину
+func (s *WALEventStorage) trashRecord(id int) walRecord {
+	trashed, _ := s.mem.trashed(id)
+	return walRecord{Op: opTrash, Event: trashed.Event, Time: trashed.DeletedAt}
+}
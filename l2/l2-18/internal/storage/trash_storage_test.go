@@ -0,0 +1,145 @@
+package storage
+
+import (
+	"errors"
+	"l2-18/internal/apperrors"
+	"l2-18/internal/models"
+	"path/filepath"
+	"slices"
+	"testing"
+	"time"
+)
+
+// trashBackend хранилище с корзиной удаленных событий
+type trashBackend interface {
+	EventStorage
+	TrashStorage
+}
+
+func TestTrashStorage(t *testing.T) {
+	backends := []struct {
+		name       string
+		open       func(path string) (trashBackend, error)
+		persistent bool
+	}{
+		{"memory", func(string) (trashBackend, error) { return NewInMemoryEventStorage(), nil }, false},
+		{"file", func(path string) (trashBackend, error) { return NewFileEventStorage(path) }, true},
+		// Журнал не сворачивается, корзина восстанавливается из его записей
+		{"wal", func(path string) (trashBackend, error) { return NewWALEventStorage(path, 100) }, true},
+		// Журнал сворачивается после каждой записи, корзина хранится в снимке
+		{"wal snapshot", func(path string) (trashBackend, error) { return NewWALEventStorage(path, 1) }, true},
+	}
+
+	start := time.Date(2024, 1, 8, 9, 0, 0, 0, time.UTC)
+
+	for _, backend := range backends {
+		t.Run(backend.name, func(t *testing.T) {
+			path := filepath.Join(t.TempDir(), "events.json")
+			strg, err := backend.open(path)
+			if err != nil {
+				t.Fatal("open error:", err)
+			}
+			reopen := func() {
+				t.Helper()
+				if !backend.persistent {
+					return
+				}
+				if strg, err = backend.open(path); err != nil {
+					t.Fatal("reopen error:", err)
+				}
+			}
+			trashIDs := func(userID int) []int {
+				t.Helper()
+				trashed, err := strg.GetTrash(userID)
+				if err != nil {
+					t.Fatal("GetTrash() error:", err)
+				}
+				ids := make([]int, len(trashed))
+				for i, entry := range trashed {
+					ids[i] = entry.Event.ID
+				}
+				return ids
+			}
+
+			var events []*models.Event
+			for _, title := range []string{"Standup", "Review", "Retro"} {
+				event := &models.Event{UserID: 1, Title: title, Start: start, End: start.Add(time.Hour)}
+				if err := strg.Create(event); err != nil {
+					t.Fatal("Create() error:", err)
+				}
+				events = append(events, event)
+			}
+			standup, review, retro := events[0], events[1], events[2]
+
+			failing := []struct {
+				name    string
+				id      int
+				userID  int
+				version int
+				wantErr error
+			}{
+				{"missing event", 42, 1, 0, apperrors.ErrNotFound},
+				{"other user", standup.ID, 2, 0, apperrors.ErrForbidden},
+				{"stale version", standup.ID, 1, 5, apperrors.ErrConflict},
+			}
+			for _, tt := range failing {
+				if err := strg.TrashEvent(tt.id, tt.userID, tt.version); !errors.Is(err, tt.wantErr) {
+					t.Errorf("%s: TrashEvent() error = %v, want %v", tt.name, err, tt.wantErr)
+				}
+			}
+
+			for _, event := range []*models.Event{standup, review, retro} {
+				if err := strg.TrashEvent(event.ID, 1, event.Version); err != nil {
+					t.Fatal("TrashEvent() error:", err)
+				}
+			}
+			reopen()
+
+			if _, err := strg.GetByID(standup.ID, 1); !errors.Is(err, apperrors.ErrNotFound) {
+				t.Errorf("trashed event GetByID() error = %v, want ErrNotFound", err)
+			}
+			if got, want := trashIDs(1), []int{retro.ID, review.ID, standup.ID}; !slices.Equal(got, want) {
+				t.Errorf("GetTrash() = %v, want %v", got, want)
+			}
+			if got := trashIDs(2); len(got) != 0 {
+				t.Errorf("GetTrash() of other user = %v, want empty", got)
+			}
+
+			if _, err := strg.RestoreEvent(standup.ID, 2); !errors.Is(err, apperrors.ErrNotFound) {
+				t.Errorf("RestoreEvent() of other user error = %v, want ErrNotFound", err)
+			}
+			restored, err := strg.RestoreEvent(standup.ID, 1)
+			if err != nil || restored.Version != 2 {
+				t.Fatalf("RestoreEvent() = %+v, %v; want version 2", restored, err)
+			}
+			if err := strg.PurgeEvent(review.ID, 1); err != nil {
+				t.Fatal("PurgeEvent() error:", err)
+			}
+			reopen()
+
+			if got, err := strg.GetByID(standup.ID, 1); err != nil || got.Title != "Standup" || got.Version != 2 {
+				t.Errorf("restored event = %+v, %v; want Standup at version 2", got, err)
+			}
+			if got, want := trashIDs(1), []int{retro.ID}; !slices.Equal(got, want) {
+				t.Errorf("GetTrash() after restore and purge = %v, want %v", got, want)
+			}
+
+			if n, err := strg.PurgeTrash(time.Now().Add(-time.Hour)); err != nil || n != 0 {
+				t.Errorf("PurgeTrash() of recent events = %d, %v; want 0", n, err)
+			}
+			if n, err := strg.PurgeTrash(time.Now().Add(time.Minute)); err != nil || n != 1 {
+				t.Errorf("PurgeTrash() = %d, %v; want 1", n, err)
+			}
+			reopen()
+
+			if got := trashIDs(1); len(got) != 0 {
+				t.Errorf("GetTrash() after PurgeTrash() = %v, want empty", got)
+			}
+			// ID окончательно удаленных событий не выдаются повторно
+			added := &models.Event{UserID: 1, Title: "Planning", Start: start, End: start.Add(time.Hour)}
+			if err := strg.Create(added); err != nil || added.ID != retro.ID+1 {
+				t.Errorf("Create() after purge gave ID %d, %v; want %d", added.ID, err, retro.ID+1)
+			}
+		})
+	}
+}
@@ -0,0 +1,58 @@
+package storage
+
+// UserStorage интерфейс для работы с настройками пользователей
+type UserStorage interface {
+	// GetTimeZone возвращает часовой пояс пользователя или пустую строку, если он не задан
+	GetTimeZone(userID int) (string, error)
+	SetTimeZone(userID int, tz string) error
+}
+
+// GetTimeZone возвращает часовой пояс пользователя
+func (s *InMemoryEventStorage) GetTimeZone(userID int) (string, error) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	return s.timeZones[userID], nil
+}
+
+// SetTimeZone сохраняет часовой пояс пользователя
+func (s *InMemoryEventStorage) SetTimeZone(userID int, tz string) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	s.timeZones[userID] = tz
+
+	return nil
+}
+
+// GetTimeZone возвращает часовой пояс пользователя
+func (s *FileEventStorage) GetTimeZone(userID int) (string, error) {
+	return s.mem.GetTimeZone(userID)
+}
+
+// SetTimeZone сохраняет часовой пояс пользователя
+func (s *FileEventStorage) SetTimeZone(userID int, tz string) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if err := s.mem.SetTimeZone(userID, tz); err != nil {
+		return err
+	}
+	return s.save()
+}
+
+// GetTimeZone возвращает часовой пояс пользователя
+func (s *WALEventStorage) GetTimeZone(userID int) (string, error) {
+	return s.mem.GetTimeZone(userID)
+}
+
+// SetTimeZone сохраняет часовой пояс пользователя
+func (s *WALEventStorage) SetTimeZone(userID int, tz string) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if err := s.mem.SetTimeZone(userID, tz); err != nil {
+		return err
+	}
+	return s.append(walRecord{Op: opTimeZone, UserID: userID, TimeZone: tz})
+}
@@ -0,0 +1,396 @@
+package storage
+
+import (
+	"bufio"
+	"bytes"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"l2-18/internal/apperrors"
+	"l2-18/internal/models"
+	"os"
+	"sync"
+	"time"
+)
+
+// Операции журнала предзаписи. Каждая запись задает итоговое значение,
+// а не приращение, поэтому повторное воспроизведение записей поверх
+// более нового снимка не меняет состояние.
+const (
+	opPut           = "put"
+	opDelete        = "delete"
+	opTimeZone      = "time_zone"
+	opShare         = "share"
+	opUnshare       = "unshare"
+	opCheckpoint    = "checkpoint"
+	opReminderSent  = "reminder_sent"
+	opWebhook       = "webhook"
+	opDeleteWebhook = "delete_webhook"
+	opDeadLetter    = "dead_letter"
+	opBatch         = "batch"
+	opTrash         = "trash"
+	opRestore       = "restore"
+	opPurge         = "purge"
+	opRevision      = "revision"
+)
+
+// DefaultCompactEvery число записей журнала, после которого он сворачивается в снимок
+const DefaultCompactEvery = 1000
+
+// walHeader первая строка журнала: версия схемы событий в записях
+type walHeader struct {
+	Version int `json:"version"`
+}
+
+// walRecord запись журнала об одном изменении
+type walRecord struct {
+	Op         string             `json:"op"`
+	Event      *models.Event      `json:"event,omitempty"`
+	ID         int                `json:"id,omitempty"`
+	UserID     int                `json:"user_id,omitempty"`
+	TimeZone   string             `json:"time_zone,omitempty"`
+	Share      *models.Share      `json:"share,omitempty"`
+	Key        string             `json:"key,omitempty"`
+	Time       time.Time          `json:"time,omitzero"`
+	Webhook    *models.Webhook    `json:"webhook,omitempty"`
+	DeadLetter *models.DeadLetter `json:"dead_letter,omitempty"`
+	Revision   *models.Revision   `json:"revision,omitempty"`
+	// Batch записи пакета изменений, применяемые вместе
+	Batch []walRecord `json:"batch,omitempty"`
+}
+
+// WALEventStorage хранилище событий в памяти с журналом предзаписи.
+// Каждое изменение дописывается в журнал path+".wal" и сбрасывается
+// на диск до ответа, а каждые compactEvery записей состояние сохраняется
+// снимком в path (в формате FileEventStorage) и журнал начинается заново.
+// При открытии снимок загружается, журнал воспроизводится поверх него
+// и сразу сворачивается. Оборванная при сбое последняя запись отбрасывается.
+// Изменение, которое не удалось записать в журнал, откатывается и в памяти.
+type WALEventStorage struct {
+	mem          *InMemoryEventStorage
+	path         string
+	log          *os.File
+	records      int
+	compactEvery int
+	err          error      // ошибка последней записи журнала или снимка
+	mu           sync.Mutex // сериализует изменения и запись журнала
+}
+
+// NewWALEventStorage открывает хранилище со снимком по пути path и журналом
+// рядом с ним. compactEvery <= 0 означает DefaultCompactEvery.
+func NewWALEventStorage(path string, compactEvery int) (*WALEventStorage, error) {
+	if compactEvery <= 0 {
+		compactEvery = DefaultCompactEvery
+	}
+	s := &WALEventStorage{
+		mem:          NewInMemoryEventStorage(),
+		path:         path,
+		compactEvery: compactEvery,
+	}
+
+	snap, _, err := loadSnapshot(path)
+	if err != nil {
+		return nil, err
+	}
+	s.mem.restore(snap.state)
+
+	if err := s.replay(s.mem); err != nil {
+		return nil, err
+	}
+	if err := s.compact(); err != nil {
+		return nil, err
+	}
+
+	return s, nil
+}
+
+// Close сворачивает журнал в снимок и закрывает его
+func (s *WALEventStorage) Close() error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if s.log == nil {
+		return nil
+	}
+	err := s.compact()
+	if closeErr := s.log.Close(); err == nil {
+		err = closeErr
+	}
+	s.log = nil
+	return err
+}
+
+// Create создает новое событие
+func (s *WALEventStorage) Create(event *models.Event) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if err := s.mem.Create(event); err != nil {
+		return err
+	}
+	return s.append(walRecord{Op: opPut, Event: event})
+}
+
+// Update обновляет существующее событие
+func (s *WALEventStorage) Update(event *models.Event) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if err := s.mem.Update(event); err != nil {
+		return err
+	}
+	return s.append(walRecord{Op: opPut, Event: event})
+}
+
+// Delete удаляет событие
+func (s *WALEventStorage) Delete(id, userID, version int) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if err := s.mem.Delete(id, userID, version); err != nil {
+		return err
+	}
+	return s.append(walRecord{Op: opDelete, ID: id})
+}
+
+// GetByDateRange возвращает события, пересекающиеся с полуинтервалом [start, end)
+func (s *WALEventStorage) GetByDateRange(userID int, start, end time.Time) ([]*models.Event, error) {
+	return s.mem.GetByDateRange(userID, start, end)
+}
+
+// GetByID возвращает событие по ID
+func (s *WALEventStorage) GetByID(id, userID int) (*models.Event, error) {
+	return s.mem.GetByID(id, userID)
+}
+
+// walPath возвращает путь к файлу журнала
+func (s *WALEventStorage) walPath() string {
+	return s.path + ".wal"
+}
+
+// append дописывает в журнал запись об изменении, уже примененном
+// в памяти, и сворачивает журнал, когда он достиг compactEvery записей.
+// Если запись не удалась, изменение откатывается: состояние в памяти
+// заново читается с диска, чтобы оно не расходилось с журналом.
+// Сбой свертки изменение не отменяет, ведь запись уже в журнале: ошибка
+// сообщается через Ping, а свертка повторяется при следующей записи.
+func (s *WALEventStorage) append(rec walRecord) error {
+	if err := s.write(rec); err != nil {
+		if rollbackErr := s.rollback(); rollbackErr != nil {
+			err = fmt.Errorf("%v; failed to roll back: %v", err, rollbackErr)
+		}
+		s.err = err
+		return err
+	}
+
+	s.records++
+	s.err = nil
+	if s.records >= s.compactEvery {
+		s.err = s.compact()
+	}
+	return nil
+}
+
+// write дописывает запись в журнал и сбрасывает ее на диск. Если запись
+// не удалась, журнал обрезается до прежнего размера, чтобы следующая
+// запись не продолжила оборванную строку.
+func (s *WALEventStorage) write(rec walRecord) error {
+	if s.log == nil {
+		return fmt.Errorf("storage is closed")
+	}
+
+	data, err := json.Marshal(rec)
+	if err != nil {
+		return fmt.Errorf("failed to encode log record: %v", err)
+	}
+	info, err := s.log.Stat()
+	if err != nil {
+		return fmt.Errorf("failed to write log: %v", err)
+	}
+	if _, err := s.log.Write(append(data, '\n')); err != nil {
+		_ = s.log.Truncate(info.Size())
+		return fmt.Errorf("failed to write log: %v", err)
+	}
+	if err := s.log.Sync(); err != nil {
+		_ = s.log.Truncate(info.Size())
+		return fmt.Errorf("failed to sync log: %v", err)
+	}
+	return nil
+}
+
+// rollback возвращает состояние в памяти к сохраненному на диске:
+// снимку и воспроизведенному поверх него журналу
+func (s *WALEventStorage) rollback() error {
+	durable := NewInMemoryEventStorage()
+	snap, _, err := loadSnapshot(s.path)
+	if err != nil {
+		return err
+	}
+	durable.restore(snap.state)
+	if err := s.replay(durable); err != nil {
+		return err
+	}
+
+	s.mem.restore(durable.snapshot())
+	return nil
+}
+
+// compact сохраняет снимок состояния и начинает журнал заново. Если сбой
+// случится между записью снимка и заменой журнала, старый журнал
+// воспроизведется поверх нового снимка без последствий.
+func (s *WALEventStorage) compact() error {
+	data, err := json.MarshalIndent(fileSnapshot{
+		Version: schemaVersion,
+		state:   s.mem.snapshot(),
+	}, "", "  ")
+	if err != nil {
+		return fmt.Errorf("failed to encode storage: %v", err)
+	}
+	if err := writeFileAtomic(s.path, data); err != nil {
+		return fmt.Errorf("failed to persist storage: %v", err)
+	}
+
+	header, _ := json.Marshal(walHeader{Version: schemaVersion})
+	if err := writeFileAtomic(s.walPath(), append(header, '\n')); err != nil {
+		return fmt.Errorf("failed to reset log: %v", err)
+	}
+
+	log, err := os.OpenFile(s.walPath(), os.O_WRONLY|os.O_APPEND, 0)
+	if s.log != nil {
+		s.log.Close()
+	}
+	// Прежний журнал уже заменен, и записи в него были бы потеряны
+	s.log = log
+	if err != nil {
+		return fmt.Errorf("failed to open log: %v", err)
+	}
+	s.records = 0
+
+	return nil
+}
+
+// replay применяет записи журнала к состоянию в памяти mem
+func (s *WALEventStorage) replay(mem *InMemoryEventStorage) error {
+	data, err := os.ReadFile(s.walPath())
+	if errors.Is(err, os.ErrNotExist) {
+		return nil
+	}
+	if err != nil {
+		return fmt.Errorf("failed to read log: %v", err)
+	}
+
+	reader := bufio.NewReader(bytes.NewReader(data))
+	for n := 0; ; n++ {
+		line, err := reader.ReadBytes('\n')
+		if err == io.EOF {
+			// Запись без перевода строки оборвана сбоем во время записи
+			return nil
+		}
+		if err != nil {
+			return fmt.Errorf("failed to read log: %v", err)
+		}
+
+		if n == 0 {
+			var header walHeader
+			if err := json.Unmarshal(line, &header); err != nil {
+				return fmt.Errorf("failed to decode log header: %v", err)
+			}
+			if header.Version != schemaVersion {
+				return fmt.Errorf("log schema version %d differs from supported %d", header.Version, schemaVersion)
+			}
+			continue
+		}
+
+		var rec walRecord
+		if err := json.Unmarshal(line, &rec); err != nil {
+			return fmt.Errorf("failed to decode log record %d: %v", n, err)
+		}
+		if err := mem.apply(rec); err != nil {
+			return fmt.Errorf("failed to replay log record %d: %v", n, err)
+		}
+	}
+}
+
+// apply применяет запись журнала без проверок владельца и версий
+func (s *InMemoryEventStorage) apply(rec walRecord) error {
+	switch rec.Op {
+	case opPut:
+		if rec.Event == nil {
+			return fmt.Errorf("record has no event")
+		}
+		s.put(rec.Event)
+	case opDelete:
+		s.remove(rec.ID)
+	case opTimeZone:
+		return s.SetTimeZone(rec.UserID, rec.TimeZone)
+	case opShare:
+		if rec.Share == nil {
+			return fmt.Errorf("record has no share")
+		}
+		return s.SetShare(*rec.Share)
+	case opUnshare:
+		if rec.Share == nil {
+			return fmt.Errorf("record has no share")
+		}
+		if err := s.DeleteShare(rec.Share.OwnerID, rec.Share.UserID); err != nil && !errors.Is(err, apperrors.ErrNotFound) {
+			return err
+		}
+	case opCheckpoint:
+		return s.SetReminderCheckpoint(rec.Time)
+	case opReminderSent:
+		return s.MarkReminderSent(rec.Key, rec.Time)
+	case opWebhook:
+		if rec.Webhook == nil {
+			return fmt.Errorf("record has no webhook")
+		}
+		s.mu.Lock()
+		s.putWebhook(*rec.Webhook)
+		s.mu.Unlock()
+	case opDeleteWebhook:
+		s.mu.Lock()
+		delete(s.webhooks.webhooks, rec.ID)
+		s.mu.Unlock()
+	case opDeadLetter:
+		if rec.DeadLetter == nil {
+			return fmt.Errorf("record has no dead letter")
+		}
+		s.mu.Lock()
+		s.addDeadLetter(*rec.DeadLetter)
+		s.mu.Unlock()
+	case opTrash:
+		if rec.Event == nil {
+			return fmt.Errorf("record has no event")
+		}
+		s.putTrashed(models.TrashedEvent{Event: rec.Event, DeletedAt: rec.Time})
+	case opRestore:
+		if rec.Event == nil {
+			return fmt.Errorf("record has no event")
+		}
+		s.mu.Lock()
+		delete(s.trash, rec.Event.ID)
+		s.mu.Unlock()
+		s.put(rec.Event)
+	case opPurge:
+		s.mu.Lock()
+		delete(s.trash, rec.ID)
+		delete(s.history, rec.ID)
+		s.mu.Unlock()
+	case opRevision:
+		if rec.Revision == nil {
+			return fmt.Errorf("record has no revision")
+		}
+		s.mu.Lock()
+		s.putRevision(*rec.Revision)
+		s.mu.Unlock()
+	case opBatch:
+		for _, batched := range rec.Batch {
+			if err := s.apply(batched); err != nil {
+				return err
+			}
+		}
+	default:
+		return fmt.Errorf("unknown operation %q", rec.Op)
+	}
+	return nil
+}
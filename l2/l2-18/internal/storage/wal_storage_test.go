@@ -0,0 +1,341 @@
+package storage
+
+import (
+	"l2-18/internal/models"
+	"os"
+	"path/filepath"
+	"slices"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestWALEventStorage_Replay(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "events.json")
+
+	// Журнал не сворачивается, состояние восстанавливается только из него
+	strg, err := NewWALEventStorage(path, 100)
+	if err != nil {
+		t.Fatal("NewWALEventStorage() error:", err)
+	}
+
+	start := time.Date(2024, 1, 8, 9, 0, 0, 0, time.UTC)
+	first := &models.Event{UserID: 1, Title: "First", Start: start, End: start.Add(time.Hour)}
+	second := &models.Event{UserID: 1, Title: "Second", Start: start, End: start.Add(time.Hour)}
+	for _, event := range []*models.Event{first, second} {
+		if err := strg.Create(event); err != nil {
+			t.Fatal("Create() error:", err)
+		}
+	}
+	moved := *second
+	moved.Start, moved.End = start.AddDate(0, 0, 1), start.AddDate(0, 0, 1).Add(time.Hour)
+	if err := strg.Update(&moved); err != nil {
+		t.Fatal("Update() error:", err)
+	}
+	if err := strg.Delete(first.ID, 1, 0); err != nil {
+		t.Fatal("Delete() error:", err)
+	}
+	if err := strg.SetTimeZone(1, "Europe/Moscow"); err != nil {
+		t.Fatal("SetTimeZone() error:", err)
+	}
+	if err := strg.SetShare(models.Share{OwnerID: 1, UserID: 2, Permission: models.PermissionRead}); err != nil {
+		t.Fatal("SetShare() error:", err)
+	}
+	if err := strg.MarkReminderSent("2:10", start); err != nil {
+		t.Fatal("MarkReminderSent() error:", err)
+	}
+
+	// Имитируем сбой: хранилище не закрывается, в журнал дописана оборванная запись
+	log, err := os.OpenFile(path+".wal", os.O_WRONLY|os.O_APPEND, 0)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err := log.WriteString(`{"op":"delete","id":`); err != nil {
+		t.Fatal(err)
+	}
+	log.Close()
+
+	reopened, err := NewWALEventStorage(path, 100)
+	if err != nil {
+		t.Fatal("NewWALEventStorage() reopen error:", err)
+	}
+	defer reopened.Close()
+
+	if _, err := reopened.GetByID(first.ID, 1); err == nil {
+		t.Error("GetByID() deleted event survived replay")
+	}
+	got, err := reopened.GetByID(second.ID, 1)
+	if err != nil {
+		t.Fatal("GetByID() error:", err)
+	}
+	if !got.Start.Equal(moved.Start) || got.Version != 2 {
+		t.Errorf("replayed event = %v version %d, want %v version 2", got.Start, got.Version, moved.Start)
+	}
+	if events, _ := reopened.GetByDateRange(1, moved.Start, moved.End); len(events) != 1 {
+		t.Errorf("GetByDateRange() after replay = %d events, want 1", len(events))
+	}
+	if tz, _ := reopened.GetTimeZone(1); tz != "Europe/Moscow" {
+		t.Errorf("GetTimeZone() = %q, want Europe/Moscow", tz)
+	}
+	if share, _ := reopened.GetShare(1, 2); share == nil {
+		t.Error("GetShare() share lost in replay")
+	}
+	if sent, _ := reopened.IsReminderSent("2:10"); !sent {
+		t.Error("IsReminderSent() reminder lost in replay")
+	}
+
+	third := &models.Event{UserID: 1, Title: "Third", Start: start, End: start}
+	if err := reopened.Create(third); err != nil {
+		t.Fatal("Create() error:", err)
+	}
+	if third.ID != second.ID+1 {
+		t.Errorf("Create() after replay ID = %d, want %d", third.ID, second.ID+1)
+	}
+}
+
+func TestWALEventStorage_Compact(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "events.json")
+	strg, err := NewWALEventStorage(path, 2)
+	if err != nil {
+		t.Fatal("NewWALEventStorage() error:", err)
+	}
+
+	start := time.Date(2024, 1, 8, 9, 0, 0, 0, time.UTC)
+	for _, title := range []string{"First", "Second", "Third"} {
+		if err := strg.Create(&models.Event{UserID: 1, Title: title, Start: start, End: start}); err != nil {
+			t.Fatal("Create() error:", err)
+		}
+	}
+
+	// Две записи свернуты в снимок, в журнале осталась третья
+	data, err := os.ReadFile(path + ".wal")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if lines := strings.Count(string(data), "\n"); lines != 2 {
+		t.Errorf("log has %d lines, want header and one record", lines)
+	}
+	snap, _, err := loadSnapshot(path)
+	if err != nil {
+		t.Fatal("loadSnapshot() error:", err)
+	}
+	if len(snap.Events) != 2 {
+		t.Errorf("snapshot has %d events, want 2", len(snap.Events))
+	}
+
+	// Закрытие сворачивает остаток журнала
+	if err := strg.Close(); err != nil {
+		t.Fatal("Close() error:", err)
+	}
+	if snap, _, _ = loadSnapshot(path); len(snap.Events) != 3 {
+		t.Errorf("snapshot after Close() has %d events, want 3", len(snap.Events))
+	}
+	if err := strg.Create(&models.Event{UserID: 1, Start: start, End: start}); err == nil {
+		t.Error("Create() after Close() succeeded")
+	}
+}
+
+func TestWALEventStorage_FailedWrite(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "events.json")
+	strg, err := NewWALEventStorage(path, 100)
+	if err != nil {
+		t.Fatal("NewWALEventStorage() error:", err)
+	}
+	defer strg.Close()
+
+	start := time.Date(2024, 1, 8, 9, 0, 0, 0, time.UTC)
+	kept := &models.Event{UserID: 1, Title: "Kept", Start: start, End: start.Add(time.Hour)}
+	if err := strg.Create(kept); err != nil {
+		t.Fatal("Create() error:", err)
+	}
+
+	// Имитируем сбой диска: журнал открыт только на чтение
+	readOnly, err := os.Open(path + ".wal")
+	if err != nil {
+		t.Fatal(err)
+	}
+	writable := strg.log
+	strg.log = readOnly
+
+	failing := []struct {
+		name  string
+		write func() error
+	}{
+		{"create", func() error {
+			return strg.Create(&models.Event{UserID: 1, Title: "Lost", Start: start, End: start.Add(time.Hour)})
+		}},
+		{"update", func() error {
+			renamed := *kept
+			renamed.Title = "Renamed"
+			return strg.Update(&renamed)
+		}},
+		{"delete", func() error { return strg.Delete(kept.ID, 1, 0) }},
+		{"batch", func() error {
+			return strg.ApplyBatch([]BatchOp{
+				{Kind: BatchCreate, Event: &models.Event{UserID: 1, Title: "Lost", Start: start, End: start.Add(time.Hour)}},
+				{Kind: BatchDelete, ID: kept.ID, UserID: 1},
+			})
+		}},
+	}
+	for _, tt := range failing {
+		if err := tt.write(); err == nil {
+			t.Fatalf("%s: write to a read-only log succeeded", tt.name)
+		}
+
+		// Изменение, не попавшее в журнал, не видно и в памяти
+		events, err := strg.GetByDateRange(1, start, start.AddDate(0, 0, 1))
+		if err != nil {
+			t.Fatal("GetByDateRange() error:", err)
+		}
+		if len(events) != 1 || events[0].ID != kept.ID || events[0].Title != "Kept" {
+			t.Errorf("%s: GetByDateRange() after failed write = %v, want only the kept event", tt.name, events)
+		}
+	}
+
+	// После восстановления журнала запись продолжается
+	readOnly.Close()
+	strg.log = writable
+	if err := strg.Create(&models.Event{UserID: 1, Title: "Saved", Start: start, End: start.Add(time.Hour)}); err != nil {
+		t.Fatal("Create() error:", err)
+	}
+}
+
+func TestWALEventStorage_FailedCompact(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "events.json")
+	strg, err := NewWALEventStorage(path, 1)
+	if err != nil {
+		t.Fatal("NewWALEventStorage() error:", err)
+	}
+	defer strg.Close()
+
+	// Имитируем сбой диска при сохранении снимка: каталога снимка нет,
+	// а журнал остается открытым
+	strg.path = filepath.Join(path, "missing", "events.json")
+	start := time.Date(2024, 1, 8, 9, 0, 0, 0, time.UTC)
+	if err := strg.Create(&models.Event{UserID: 1, Title: "Logged", Start: start, End: start.Add(time.Hour)}); err != nil {
+		t.Fatal("Create() error after a failed compaction:", err)
+	}
+	if err := strg.Ping(); err == nil {
+		t.Error("Ping() after a failed compaction = nil, want error")
+	}
+
+	// Свертка повторяется при следующей записи
+	strg.path = path
+	if err := strg.Create(&models.Event{UserID: 1, Title: "Compacted", Start: start, End: start.Add(time.Hour)}); err != nil {
+		t.Fatal("Create() error:", err)
+	}
+	if err := strg.Ping(); err != nil {
+		t.Errorf("Ping() after a retried compaction = %v, want nil", err)
+	}
+	snap, _, err := loadSnapshot(path)
+	if err != nil {
+		t.Fatal("loadSnapshot() error:", err)
+	}
+	if len(snap.Events) != 2 {
+		t.Errorf("snapshot has %d events, want both", len(snap.Events))
+	}
+}
+
+// reopenAfterCompaction закрывает хранилище, сворачивая журнал в снимок,
+// и возвращает прежний журнал на место, как при сбое между записью снимка
+// и заменой журнала. Затем хранилище открывается дважды: журнал
+// воспроизводится поверх снимка, в который уже вошли его записи.
+func reopenAfterCompaction(t *testing.T, strg *WALEventStorage, path string) *WALEventStorage {
+	t.Helper()
+
+	log, err := os.ReadFile(path + ".wal")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := strg.Close(); err != nil {
+		t.Fatal("Close() error:", err)
+	}
+
+	for i := range 2 {
+		if err := os.WriteFile(path+".wal", log, 0644); err != nil {
+			t.Fatal(err)
+		}
+		if strg, err = NewWALEventStorage(path, 100); err != nil {
+			t.Fatal("NewWALEventStorage() reopen error:", err)
+		}
+		if i == 0 {
+			strg.Close()
+		}
+	}
+	t.Cleanup(func() { strg.Close() })
+	return strg
+}
+
+func TestWALEventStorage_ReplayDeadLetters(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "events.json")
+	strg, err := NewWALEventStorage(path, 1000)
+	if err != nil {
+		t.Fatal("NewWALEventStorage() error:", err)
+	}
+
+	// Первое изменение пользователя 1 вытесняется лимитом
+	for range MaxDeadLetters + 1 {
+		if err := strg.AddDeadLetter(&models.DeadLetter{UserID: 1, Error: "timeout"}); err != nil {
+			t.Fatal("AddDeadLetter() error:", err)
+		}
+	}
+	if err := strg.AddDeadLetter(&models.DeadLetter{UserID: 2, Error: "timeout"}); err != nil {
+		t.Fatal("AddDeadLetter() error:", err)
+	}
+
+	reopened := reopenAfterCompaction(t, strg, path)
+	letters, _ := reopened.GetDeadLetters(1)
+	if len(letters) != MaxDeadLetters || letters[0].ID != 2 || letters[len(letters)-1].ID != MaxDeadLetters+1 {
+		t.Errorf("user 1 has %d letters after replay, want letters 2..%d", len(letters), MaxDeadLetters+1)
+	}
+	if letters, _ := reopened.GetDeadLetters(2); len(letters) != 1 {
+		t.Errorf("user 2 has %d letters after replay, want 1", len(letters))
+	}
+}
+
+func TestWALEventStorage_ReplayHistory(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "events.json")
+	strg, err := NewWALEventStorage(path, 1000)
+	if err != nil {
+		t.Fatal("NewWALEventStorage() error:", err)
+	}
+
+	for _, action := range []string{models.ChangeCreated, models.ChangeUpdated, models.ChangeUpdated} {
+		if err := strg.AppendRevision(&models.Revision{EventID: 1, Action: action, ActorID: 1}); err != nil {
+			t.Fatal("AppendRevision() error:", err)
+		}
+	}
+	if err := strg.AppendRevision(&models.Revision{EventID: 2, Action: models.ChangeCreated, ActorID: 1}); err != nil {
+		t.Fatal("AppendRevision() error:", err)
+	}
+
+	reopened := reopenAfterCompaction(t, strg, path)
+	history, _ := reopened.GetHistory(1)
+	var numbers []int
+	for _, rev := range history {
+		numbers = append(numbers, rev.Revision)
+	}
+	if !slices.Equal(numbers, []int{1, 2, 3}) {
+		t.Errorf("event 1 revisions after replay = %v, want [1 2 3]", numbers)
+	}
+	if history, _ := reopened.GetHistory(2); len(history) != 1 {
+		t.Errorf("event 2 has %d revisions after replay, want 1", len(history))
+	}
+
+	// Нумерация продолжается после восстановленной истории
+	rev := &models.Revision{EventID: 1, Action: models.ChangeDeleted, ActorID: 1}
+	if err := reopened.AppendRevision(rev); err != nil || rev.Revision != 4 {
+		t.Errorf("AppendRevision() after replay = revision %d, %v; want 4", rev.Revision, err)
+	}
+}
+
+func TestWALEventStorage_SchemaVersion(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "events.json")
+	if err := os.WriteFile(path+".wal", []byte(`{"version":99}`+"\n"), 0644); err != nil {
+		t.Fatal(err)
+	}
+
+	if _, err := NewWALEventStorage(path, 0); err == nil {
+		t.Error("NewWALEventStorage() accepted log with unsupported schema version")
+	}
+}
@@ -0,0 +1,244 @@
+package storage
+
+import (
+	"l2-18/internal/apperrors"
+	"l2-18/internal/models"
+	"sort"
+)
+
+// MaxDeadLetters сколько последних недоставленных изменений хранится
+// для каждого пользователя; более старые вытесняются
+const MaxDeadLetters = 100
+
+// WebhookStorage интерфейс хранилища webhook пользователей
+// и изменений, которые не удалось на них доставить
+type WebhookStorage interface {
+	// CreateWebhook сохраняет webhook и присваивает ему ID
+	CreateWebhook(webhook *models.Webhook) error
+	// DeleteWebhook удаляет webhook пользователя userID
+	DeleteWebhook(id, userID int) error
+	// GetWebhooks возвращает webhook пользователя по возрастанию ID
+	GetWebhooks(userID int) ([]models.Webhook, error)
+	// AddDeadLetter сохраняет недоставленное изменение и присваивает ему ID
+	AddDeadLetter(letter *models.DeadLetter) error
+	// GetDeadLetters возвращает недоставленные изменения пользователя по возрастанию ID
+	GetDeadLetters(userID int) ([]models.DeadLetter, error)
+}
+
+// webhookLog webhook и недоставленные изменения в хранилище в памяти
+type webhookLog struct {
+	webhooks    map[int]models.Webhook
+	deadLetters map[int]models.DeadLetter
+	nextID      int
+	nextLetter  int
+}
+
+// newWebhookLog создает пустой набор webhook
+func newWebhookLog() webhookLog {
+	return webhookLog{
+		webhooks:    make(map[int]models.Webhook),
+		deadLetters: make(map[int]models.DeadLetter),
+		nextID:      1,
+		nextLetter:  1,
+	}
+}
+
+// CreateWebhook сохраняет webhook и присваивает ему ID
+func (s *InMemoryEventStorage) CreateWebhook(webhook *models.Webhook) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	webhook.ID = s.webhooks.nextID
+	s.putWebhook(*webhook)
+
+	return nil
+}
+
+// DeleteWebhook удаляет webhook пользователя
+func (s *InMemoryEventStorage) DeleteWebhook(id, userID int) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	webhook, ok := s.webhooks.webhooks[id]
+	if !ok || webhook.UserID != userID {
+		return apperrors.Errorf(apperrors.ErrNotFound, "webhook with ID %d not found", id)
+	}
+	delete(s.webhooks.webhooks, id)
+
+	return nil
+}
+
+// GetWebhooks возвращает webhook пользователя по возрастанию ID
+func (s *InMemoryEventStorage) GetWebhooks(userID int) ([]models.Webhook, error) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	var result []models.Webhook
+	for _, webhook := range s.webhooks.webhooks {
+		if webhook.UserID == userID {
+			result = append(result, webhook)
+		}
+	}
+	sort.Slice(result, func(i, j int) bool { return result[i].ID < result[j].ID })
+
+	return result, nil
+}
+
+// AddDeadLetter сохраняет недоставленное изменение и присваивает ему ID
+func (s *InMemoryEventStorage) AddDeadLetter(letter *models.DeadLetter) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	letter.ID = s.webhooks.nextLetter
+	s.addDeadLetter(*letter)
+
+	return nil
+}
+
+// GetDeadLetters возвращает недоставленные изменения пользователя по возрастанию ID
+func (s *InMemoryEventStorage) GetDeadLetters(userID int) ([]models.DeadLetter, error) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	var result []models.DeadLetter
+	for _, letter := range s.webhooks.deadLetters {
+		if letter.UserID == userID {
+			result = append(result, letter)
+		}
+	}
+	sort.Slice(result, func(i, j int) bool { return result[i].ID < result[j].ID })
+
+	return result, nil
+}
+
+// putWebhook сохраняет webhook с заданным ID без блокировки
+func (s *InMemoryEventStorage) putWebhook(webhook models.Webhook) {
+	s.webhooks.webhooks[webhook.ID] = webhook
+	s.webhooks.nextID = max(s.webhooks.nextID, webhook.ID+1)
+}
+
+// addDeadLetter сохраняет недоставленное изменение с заданным ID без блокировки
+// и вытесняет изменение пользователя с наименьшим ID сверх MaxDeadLetters.
+// Повторное добавление того же изменения, в том числе уже вытесненного,
+// состояние не меняет, поэтому журнал можно воспроизводить повторно.
+func (s *InMemoryEventStorage) addDeadLetter(letter models.DeadLetter) {
+	s.webhooks.deadLetters[letter.ID] = letter
+	s.webhooks.nextLetter = max(s.webhooks.nextLetter, letter.ID+1)
+
+	count, oldest := 0, letter.ID
+	for id, l := range s.webhooks.deadLetters {
+		if l.UserID == letter.UserID {
+			count++
+			oldest = min(oldest, id)
+		}
+	}
+	if count > MaxDeadLetters {
+		delete(s.webhooks.deadLetters, oldest)
+	}
+}
+
+// deadLetterList возвращает все недоставленные изменения по возрастанию ID без блокировки
+func (s *InMemoryEventStorage) deadLetterList() []models.DeadLetter {
+	result := make([]models.DeadLetter, 0, len(s.webhooks.deadLetters))
+	for _, letter := range s.webhooks.deadLetters {
+		result = append(result, letter)
+	}
+	sort.Slice(result, func(i, j int) bool { return result[i].ID < result[j].ID })
+	return result
+}
+
+// webhookList возвращает все webhook по возрастанию ID без блокировки
+func (s *InMemoryEventStorage) webhookList() []models.Webhook {
+	result := make([]models.Webhook, 0, len(s.webhooks.webhooks))
+	for _, webhook := range s.webhooks.webhooks {
+		result = append(result, webhook)
+	}
+	sort.Slice(result, func(i, j int) bool { return result[i].ID < result[j].ID })
+	return result
+}
+
+// CreateWebhook сохраняет webhook и присваивает ему ID
+func (s *FileEventStorage) CreateWebhook(webhook *models.Webhook) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if err := s.mem.CreateWebhook(webhook); err != nil {
+		return err
+	}
+	return s.save()
+}
+
+// DeleteWebhook удаляет webhook пользователя
+func (s *FileEventStorage) DeleteWebhook(id, userID int) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if err := s.mem.DeleteWebhook(id, userID); err != nil {
+		return err
+	}
+	return s.save()
+}
+
+// GetWebhooks возвращает webhook пользователя
+func (s *FileEventStorage) GetWebhooks(userID int) ([]models.Webhook, error) {
+	return s.mem.GetWebhooks(userID)
+}
+
+// AddDeadLetter сохраняет недоставленное изменение
+func (s *FileEventStorage) AddDeadLetter(letter *models.DeadLetter) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if err := s.mem.AddDeadLetter(letter); err != nil {
+		return err
+	}
+	return s.save()
+}
+
+// GetDeadLetters возвращает недоставленные изменения пользователя
+func (s *FileEventStorage) GetDeadLetters(userID int) ([]models.DeadLetter, error) {
+	return s.mem.GetDeadLetters(userID)
+}
+
+// CreateWebhook сохраняет webhook и присваивает ему ID
+func (s *WALEventStorage) CreateWebhook(webhook *models.Webhook) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if err := s.mem.CreateWebhook(webhook); err != nil {
+		return err
+	}
+	return s.append(walRecord{Op: opWebhook, Webhook: webhook})
+}
+
+// DeleteWebhook удаляет webhook пользователя
+func (s *WALEventStorage) DeleteWebhook(id, userID int) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if err := s.mem.DeleteWebhook(id, userID); err != nil {
+		return err
+	}
+	return s.append(walRecord{Op: opDeleteWebhook, ID: id})
+}
+
+// GetWebhooks возвращает webhook пользователя
+func (s *WALEventStorage) GetWebhooks(userID int) ([]models.Webhook, error) {
+	return s.mem.GetWebhooks(userID)
+}
+
+// AddDeadLetter сохраняет недоставленное изменение
+func (s *WALEventStorage) AddDeadLetter(letter *models.DeadLetter) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if err := s.mem.AddDeadLetter(letter); err != nil {
+		return err
+	}
+	return s.append(walRecord{Op: opDeadLetter, DeadLetter: letter})
+}
+
+// GetDeadLetters возвращает недоставленные изменения пользователя
+func (s *WALEventStorage) GetDeadLetters(userID int) ([]models.DeadLetter, error) {
+	return s.mem.GetDeadLetters(userID)
+}
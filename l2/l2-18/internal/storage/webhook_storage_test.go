@@ -0,0 +1,88 @@
+package storage
+
+import (
+	"l2-18/internal/models"
+	"path/filepath"
+	"testing"
+)
+
+func TestWebhookStorage_Persistence(t *testing.T) {
+	backends := []struct {
+		name string
+		open func(path string) (WebhookStorage, error)
+	}{
+		{"file", func(path string) (WebhookStorage, error) { return NewFileEventStorage(path) }},
+		// Журнал не сворачивается, webhook восстанавливаются из его записей
+		{"wal", func(path string) (WebhookStorage, error) { return NewWALEventStorage(path, 100) }},
+	}
+
+	for _, backend := range backends {
+		t.Run(backend.name, func(t *testing.T) {
+			path := filepath.Join(t.TempDir(), "events.json")
+			strg, err := backend.open(path)
+			if err != nil {
+				t.Fatal("open error:", err)
+			}
+
+			kept := &models.Webhook{UserID: 1, URL: "https://example.com/kept", Secret: "secret"}
+			removed := &models.Webhook{UserID: 1, URL: "https://example.com/removed", Secret: "secret"}
+			for _, webhook := range []*models.Webhook{kept, removed} {
+				if err := strg.CreateWebhook(webhook); err != nil {
+					t.Fatal("CreateWebhook() error:", err)
+				}
+			}
+			if err := strg.DeleteWebhook(removed.ID, 1); err != nil {
+				t.Fatal("DeleteWebhook() error:", err)
+			}
+			letter := &models.DeadLetter{WebhookID: kept.ID, UserID: 1, Attempts: 3, Error: "timeout"}
+			if err := strg.AddDeadLetter(letter); err != nil {
+				t.Fatal("AddDeadLetter() error:", err)
+			}
+
+			reopened, err := backend.open(path)
+			if err != nil {
+				t.Fatal("reopen error:", err)
+			}
+			webhooks, _ := reopened.GetWebhooks(1)
+			if len(webhooks) != 1 || webhooks[0].ID != kept.ID || webhooks[0].Secret != "secret" {
+				t.Errorf("webhooks after reopen = %+v, want webhook %d with secret", webhooks, kept.ID)
+			}
+			letters, _ := reopened.GetDeadLetters(1)
+			if len(letters) != 1 || letters[0].ID != letter.ID || letters[0].Error != "timeout" {
+				t.Errorf("dead letters after reopen = %+v, want letter %d", letters, letter.ID)
+			}
+
+			// Новые ID не повторяют выданные до перезапуска
+			next := &models.Webhook{UserID: 1, URL: "https://example.com/next"}
+			if err := reopened.CreateWebhook(next); err != nil {
+				t.Fatal("CreateWebhook() error:", err)
+			}
+			if next.ID <= removed.ID {
+				t.Errorf("new webhook ID = %d, want greater than %d", next.ID, removed.ID)
+			}
+		})
+	}
+}
+
+func TestInMemoryEventStorage_DeadLetterLimit(t *testing.T) {
+	strg := NewInMemoryEventStorage()
+	for i := 0; i < MaxDeadLetters+5; i++ {
+		for _, userID := range []int{1, 2} {
+			if userID == 2 && i > 0 {
+				continue
+			}
+			if err := strg.AddDeadLetter(&models.DeadLetter{UserID: userID}); err != nil {
+				t.Fatal("AddDeadLetter() error:", err)
+			}
+		}
+	}
+
+	letters, _ := strg.GetDeadLetters(1)
+	if len(letters) != MaxDeadLetters || letters[0].ID != 7 {
+		t.Errorf("user 1 has %d letters starting at %d, want the last %d", len(letters), letters[0].ID, MaxDeadLetters)
+	}
+	// Вытеснение у одного пользователя не трогает другого
+	if letters, _ := strg.GetDeadLetters(2); len(letters) != 1 {
+		t.Errorf("user 2 has %d letters, want 1", len(letters))
+	}
+}
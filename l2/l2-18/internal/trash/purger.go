@@ -0,0 +1,54 @@
+package trash
+
+import (
+	"context"
+	"l2-18/internal/service"
+	"log/slog"
+	"time"
+)
+
+// Purger периодически окончательно удаляет события, срок хранения
+// которых в корзине истек
+type Purger struct {
+	service  *service.EventService
+	interval time.Duration
+	now      func() time.Time
+}
+
+// NewPurger создает очистку корзины с периодом interval
+func NewPurger(service *service.EventService, interval time.Duration) *Purger {
+	return &Purger{
+		service:  service,
+		interval: interval,
+		now:      time.Now,
+	}
+}
+
+// Run очищает корзину до отмены контекста
+func (p *Purger) Run(ctx context.Context) {
+	ticker := time.NewTicker(p.interval)
+	defer ticker.Stop()
+
+	for {
+		p.purge(ctx)
+
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+		}
+	}
+}
+
+// purge удаляет события с истекшим сроком хранения к текущему моменту
+// и возвращает их число
+func (p *Purger) purge(ctx context.Context) int {
+	purged, err := p.service.PurgeTrash(p.now())
+	switch {
+	case err != nil:
+		slog.ErrorContext(ctx, "trash purge failed", "error", err)
+	case purged > 0:
+		slog.InfoContext(ctx, "purged expired events from trash", "count", purged)
+	}
+	return purged
+}
@@ -0,0 +1,95 @@
+package trash
+
+import (
+	"context"
+	"errors"
+	"l2-18/internal/apperrors"
+	"l2-18/internal/models"
+	"l2-18/internal/service"
+	"l2-18/internal/storage"
+	"path/filepath"
+	"slices"
+	"testing"
+	"time"
+)
+
+func TestPurger_Retention(t *testing.T) {
+	const retention = 24 * time.Hour
+
+	path := filepath.Join(t.TempDir(), "events.json")
+	open := func() (*service.EventService, *storage.FileEventStorage) {
+		strg, err := storage.NewFileEventStorage(path)
+		if err != nil {
+			t.Fatal("Failed to open file storage:", err)
+		}
+		svc := service.NewEventService(strg)
+		svc.SetTrashRetention(retention)
+		return svc, strg
+	}
+
+	svc, _ := open()
+	var ids []int
+	for _, title := range []string{"Planning", "Review", "Retro"} {
+		event, err := svc.CreateEvent(&models.CreateEventRequest{UserID: 1, Start: "2024-01-08T10:00:00Z", Duration: "1h", Title: title})
+		if err != nil {
+			t.Fatal("CreateEvent() error:", err)
+		}
+		ids = append(ids, event.ID)
+	}
+	// Planning и Review удаляются в разные моменты, Retro остается
+	for _, id := range ids[:2] {
+		if err := svc.DeleteEvent(&models.DeleteEventRequest{ID: id, UserID: 1}); err != nil {
+			t.Fatal("DeleteEvent() error:", err)
+		}
+		time.Sleep(time.Millisecond)
+	}
+	trashed, err := svc.GetTrash(1)
+	if err != nil || len(trashed) != 2 {
+		t.Fatalf("GetTrash() = %+v, %v; want 2 events", trashed, err)
+	}
+	deletedAt := map[int]time.Time{}
+	for _, entry := range trashed {
+		deletedAt[entry.Event.ID] = entry.DeletedAt
+	}
+
+	purger := NewPurger(svc, time.Hour)
+	tests := []struct {
+		name      string
+		now       time.Time
+		want      int
+		wantTrash []int
+	}{
+		{"before retention", deletedAt[ids[0]].Add(retention - time.Minute), 0, []int{ids[1], ids[0]}},
+		{"first expired", deletedAt[ids[1]].Add(retention), 1, []int{ids[1]}},
+		{"all expired", deletedAt[ids[1]].Add(retention + time.Minute), 1, nil},
+		{"nothing left", deletedAt[ids[1]].Add(2 * retention), 0, nil},
+	}
+	for _, tt := range tests {
+		purger.now = func() time.Time { return tt.now }
+		if got := purger.purge(context.Background()); got != tt.want {
+			t.Errorf("%s: purge() = %d, want %d", tt.name, got, tt.want)
+		}
+
+		// Состояние проверяется по заново открытому хранилищу
+		reopened, strg := open()
+		trashed, err := reopened.GetTrash(1)
+		if err != nil {
+			t.Fatalf("%s: GetTrash() error: %v", tt.name, err)
+		}
+		var got []int
+		for _, entry := range trashed {
+			got = append(got, entry.Event.ID)
+		}
+		if !slices.Equal(got, tt.wantTrash) {
+			t.Errorf("%s: trash = %v, want %v", tt.name, got, tt.wantTrash)
+		}
+		if _, err := strg.GetByID(ids[2], 1); err != nil {
+			t.Errorf("%s: kept event GetByID() error: %v", tt.name, err)
+		}
+		for _, id := range ids[:2] {
+			if _, err := strg.GetByID(id, 1); !errors.Is(err, apperrors.ErrNotFound) {
+				t.Errorf("%s: deleted event %d GetByID() error = %v, want ErrNotFound", tt.name, id, err)
+			}
+		}
+	}
+}
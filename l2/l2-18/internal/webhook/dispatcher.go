@@ -0,0 +1,279 @@
+// Package webhook доставляет изменения событий на webhook пользователей
+package webhook
+
+import (
+	"bytes"
+	"context"
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"l2-18/internal/models"
+	"l2-18/internal/service"
+	"log/slog"
+	"math/rand/v2"
+	"net"
+	"net/http"
+	"net/netip"
+	"strconv"
+	"sync"
+	"syscall"
+	"time"
+)
+
+// Заголовки запроса с изменением
+const (
+	// SignatureHeader подпись тела в виде t=<unix-время>,v1=<hex HMAC-SHA256>
+	SignatureHeader = "X-Calendar-Signature"
+	// EventHeader тип изменения
+	EventHeader = "X-Calendar-Event"
+	// DeliveryHeader ключ доставки, одинаковый во всех попытках
+	DeliveryHeader = "X-Calendar-Delivery"
+)
+
+// subscriptionBuffer размер очереди изменений диспетчера
+const subscriptionBuffer = 1024
+
+// Options настройки доставки
+type Options struct {
+	// Timeout время ожидания ответа на одну попытку
+	Timeout time.Duration
+	// MaxAttempts число попыток, после которого изменение попадает
+	// в список недоставленных
+	MaxAttempts int
+	// Backoff пауза перед второй попыткой; каждая следующая вдвое длиннее
+	Backoff time.Duration
+	// MaxBackoff наибольшая пауза между попытками
+	MaxBackoff time.Duration
+	// Workers сколько запросов отправляется одновременно
+	Workers int
+	// AllowPrivate разрешает доставку на внутренние адреса (loopback,
+	// частные сети и т.п.), например для локальной разработки
+	AllowPrivate bool
+}
+
+// errAddressNotAllowed адрес webhook запрещен политикой доставки
+var errAddressNotAllowed = errors.New("address is not allowed")
+
+// Dispatcher подписывается на изменения событий и доставляет их на webhook
+// пользователей, которым они видны. Ответ 2xx — доставлено; сетевые ошибки,
+// 408, 429 и 5xx повторяются с растущей паузой, остальные ответы и
+// исчерпанные попытки записываются в список недоставленных. Перенаправления
+// не выполняются, а внутренние адреса проверяются при каждом соединении,
+// после разрешения имени. Порядок доставки не гарантируется; попытки,
+// не завершенные к остановке, теряются.
+type Dispatcher struct {
+	service *service.EventService
+	client  *http.Client
+	options Options
+	slots   chan struct{}
+	now     func() time.Time
+}
+
+// NewDispatcher создает диспетчер доставки
+func NewDispatcher(service *service.EventService, options Options) *Dispatcher {
+	client := &http.Client{
+		Timeout: options.Timeout,
+		// Перенаправление могло бы увести запрос на внутренний адрес
+		CheckRedirect: func(*http.Request, []*http.Request) error {
+			return http.ErrUseLastResponse
+		},
+	}
+	if !options.AllowPrivate {
+		client.Transport = publicTransport()
+	}
+
+	return &Dispatcher{
+		service: service,
+		client:  client,
+		options: options,
+		slots:   make(chan struct{}, max(options.Workers, 1)),
+		now:     time.Now,
+	}
+}
+
+// publicTransport возвращает транспорт, который соединяется только
+// с публичными адресами. Адрес проверяется после разрешения имени,
+// поэтому подмена DNS-записи после регистрации webhook не помогает.
+// Прокси не используется: иначе проверялся бы адрес прокси.
+func publicTransport() *http.Transport {
+	dialer := &net.Dialer{
+		Timeout:   30 * time.Second,
+		KeepAlive: 30 * time.Second,
+		Control: func(network, address string, _ syscall.RawConn) error {
+			addrPort, err := netip.ParseAddrPort(address)
+			if err != nil {
+				return err
+			}
+			if !service.PublicAddr(addrPort.Addr()) {
+				return fmt.Errorf("%w: %s", errAddressNotAllowed, addrPort.Addr().Unmap())
+			}
+			return nil
+		},
+	}
+
+	transport := http.DefaultTransport.(*http.Transport).Clone()
+	transport.Proxy = nil
+	transport.DialContext = dialer.DialContext
+	return transport
+}
+
+// Run доставляет изменения до отмены контекста или закрытия шины
+// и ждет завершения начатых попыток
+func (d *Dispatcher) Run(ctx context.Context) {
+	var deliveries sync.WaitGroup
+	defer deliveries.Wait()
+
+	// Шина хранит последние изменения, поэтому опубликованные до подписки не теряются
+	var after int64
+	for {
+		sub, err := d.service.Changes().Subscribe(after, nil, subscriptionBuffer)
+		if err != nil {
+			return
+		}
+
+	receive:
+		for {
+			select {
+			case <-ctx.Done():
+				sub.Close()
+				return
+			case change, ok := <-sub.C:
+				if !ok {
+					break receive
+				}
+				after = change.Seq
+				d.dispatch(ctx, change, &deliveries)
+			}
+		}
+
+		if !sub.Dropped() {
+			return
+		}
+		slog.WarnContext(ctx, "webhook dispatcher fell behind the change feed, resubscribing", "after", after)
+	}
+}
+
+// dispatch запускает доставку изменения на все подходящие webhook
+func (d *Dispatcher) dispatch(ctx context.Context, change models.Change, deliveries *sync.WaitGroup) {
+	targets, err := d.service.WebhookTargets(change)
+	if err != nil {
+		slog.ErrorContext(ctx, "failed to find webhooks for change", "seq", change.Seq, "error", err)
+		return
+	}
+
+	for _, target := range targets {
+		deliveries.Add(1)
+		go func() {
+			defer deliveries.Done()
+			d.deliver(ctx, target, change)
+		}()
+	}
+}
+
+// deliver отправляет изменение на webhook с повторами и при неудаче
+// записывает его в список недоставленных
+func (d *Dispatcher) deliver(ctx context.Context, webhook models.Webhook, change models.Change) {
+	var err error
+	attempt := 1
+	for ; ; attempt++ {
+		var retry bool
+		if retry, err = d.send(ctx, webhook, change); err == nil {
+			return
+		}
+		if ctx.Err() != nil {
+			break
+		}
+		if !retry || attempt >= d.options.MaxAttempts {
+			break
+		}
+
+		select {
+		case <-ctx.Done():
+		case <-time.After(d.backoff(attempt)):
+		}
+	}
+	if ctx.Err() != nil {
+		slog.WarnContext(ctx, "webhook delivery abandoned on shutdown", "webhook_id", webhook.ID, "seq", change.Seq)
+		return
+	}
+
+	slog.WarnContext(ctx, "webhook delivery failed", "webhook_id", webhook.ID, "seq", change.Seq, "attempts", attempt, "error", err)
+	letter := &models.DeadLetter{
+		WebhookID: webhook.ID,
+		UserID:    webhook.UserID,
+		URL:       webhook.URL,
+		Change:    change,
+		Attempts:  attempt,
+		Error:     err.Error(),
+		FailedAt:  d.now().UTC(),
+	}
+	if err := d.service.RecordDeadLetter(letter); err != nil {
+		slog.ErrorContext(ctx, "failed to record dead letter", "webhook_id", webhook.ID, "seq", change.Seq, "error", err)
+	}
+}
+
+// send делает одну попытку доставки и сообщает, имеет ли смысл повторить ее
+func (d *Dispatcher) send(ctx context.Context, webhook models.Webhook, change models.Change) (retry bool, err error) {
+	body, err := json.Marshal(change)
+	if err != nil {
+		return false, fmt.Errorf("failed to encode change: %v", err)
+	}
+
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, webhook.URL, bytes.NewReader(body))
+	if err != nil {
+		return false, err
+	}
+	req.Header.Set("Content-Type", "application/json")
+	req.Header.Set(EventHeader, change.Type)
+	req.Header.Set(DeliveryHeader, fmt.Sprintf("%d-%d", change.Seq, webhook.ID))
+	req.Header.Set(SignatureHeader, Sign(webhook.Secret, d.now().Unix(), body))
+
+	select {
+	case d.slots <- struct{}{}:
+	case <-ctx.Done():
+		return false, ctx.Err()
+	}
+	resp, err := d.client.Do(req)
+	<-d.slots
+	if err != nil {
+		return !errors.Is(err, errAddressNotAllowed), fmt.Errorf("failed to send webhook: %w", err)
+	}
+	defer resp.Body.Close()
+
+	switch {
+	case resp.StatusCode >= 200 && resp.StatusCode <= 299:
+		return false, nil
+	case resp.StatusCode == http.StatusRequestTimeout,
+		resp.StatusCode == http.StatusTooManyRequests,
+		resp.StatusCode >= 500:
+		return true, fmt.Errorf("webhook responded with status %d", resp.StatusCode)
+	default:
+		return false, fmt.Errorf("webhook responded with status %d", resp.StatusCode)
+	}
+}
+
+// backoff возвращает паузу после неудачной попытки attempt: Backoff,
+// удваиваемый с каждой попыткой до MaxBackoff, плюс до 20% случайно,
+// чтобы повторы разных доставок не совпадали
+func (d *Dispatcher) backoff(attempt int) time.Duration {
+	delay := d.options.Backoff
+	for i := 1; i < attempt && delay < d.options.MaxBackoff; i++ {
+		delay *= 2
+	}
+	delay = min(delay, d.options.MaxBackoff)
+	return delay + time.Duration(rand.Int64N(int64(delay)/5+1))
+}
+
+// Sign возвращает значение заголовка подписи тела body секретом secret.
+// Подписывается строка "<timestamp>.<body>", чтобы получатель мог
+// отклонить повтор старого запроса.
+func Sign(secret string, timestamp int64, body []byte) string {
+	mac := hmac.New(sha256.New, []byte(secret))
+	mac.Write([]byte(strconv.FormatInt(timestamp, 10)))
+	mac.Write([]byte("."))
+	mac.Write(body)
+	return fmt.Sprintf("t=%d,v1=%s", timestamp, hex.EncodeToString(mac.Sum(nil)))
+}
@@ -0,0 +1,203 @@
+package webhook
+
+import (
+	"context"
+	"encoding/json"
+	"io"
+	"l2-18/internal/models"
+	"l2-18/internal/service"
+	"l2-18/internal/storage"
+	"net/http"
+	"net/http/httptest"
+	"strconv"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+)
+
+// delivery запрос, полученный тестовым webhook
+type delivery struct {
+	header http.Header
+	body   []byte
+}
+
+func TestDispatcher(t *testing.T) {
+	var mu sync.Mutex
+	received := make(map[string][]delivery)
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		body, _ := io.ReadAll(r.Body)
+		mu.Lock()
+		received[r.URL.Path] = append(received[r.URL.Path], delivery{header: r.Header.Clone(), body: body})
+		attempt := len(received[r.URL.Path])
+		mu.Unlock()
+
+		switch {
+		case r.URL.Path == "/flaky" && attempt < 3:
+			w.WriteHeader(http.StatusInternalServerError)
+		case r.URL.Path == "/rejecting":
+			w.WriteHeader(http.StatusBadRequest)
+		case r.URL.Path == "/down":
+			w.WriteHeader(http.StatusServiceUnavailable)
+		case r.URL.Path == "/redirect":
+			http.Redirect(w, r, "/ok", http.StatusTemporaryRedirect)
+		}
+	}))
+	defer server.Close()
+
+	// Тестовый сервер слушает loopback
+	svc := service.NewEventService(storage.NewInMemoryEventStorage())
+	svc.SetPrivateWebhooks(true)
+	webhooks := make(map[string]*models.Webhook)
+	for _, path := range []string{"/ok", "/flaky", "/rejecting", "/down", "/redirect"} {
+		webhook, err := svc.RegisterWebhook(&models.CreateWebhookRequest{UserID: 2, URL: server.URL + path})
+		if err != nil {
+			t.Fatal("RegisterWebhook() error:", err)
+		}
+		webhooks[path] = webhook
+	}
+
+	dispatcher := NewDispatcher(svc, Options{
+		Timeout:      time.Second,
+		MaxAttempts:  3,
+		Backoff:      time.Millisecond,
+		MaxBackoff:   4 * time.Millisecond,
+		Workers:      2,
+		AllowPrivate: true,
+	})
+	ctx, cancel := context.WithCancel(context.Background())
+	done := make(chan struct{})
+	go func() {
+		dispatcher.Run(ctx)
+		close(done)
+	}()
+
+	// Изменение события пользователя 1 доставляется приглашенному пользователю 2
+	event, err := svc.CreateEvent(&models.CreateEventRequest{
+		UserID: 1, Start: "2024-01-08T10:00:00Z", Duration: "1h", Title: "Planning", Attendees: []int{2},
+	})
+	if err != nil {
+		t.Fatal("Failed to create event:", err)
+	}
+
+	deadline := time.Now().Add(5 * time.Second)
+	for {
+		letters, _ := svc.GetDeadLetters(2)
+		if len(letters) == 3 {
+			break
+		}
+		if time.Now().After(deadline) {
+			t.Fatalf("dead letters = %+v, want 3", letters)
+		}
+		time.Sleep(5 * time.Millisecond)
+	}
+	cancel()
+	<-done
+
+	mu.Lock()
+	defer mu.Unlock()
+	// Перенаправление не выполняется
+	for path, want := range map[string]int{"/ok": 1, "/flaky": 3, "/rejecting": 1, "/down": 3, "/redirect": 1} {
+		if got := len(received[path]); got != want {
+			t.Errorf("%s got %d requests, want %d", path, got, want)
+		}
+	}
+
+	ok := received["/ok"][0]
+	var change models.Change
+	if err := json.Unmarshal(ok.body, &change); err != nil {
+		t.Fatal("invalid delivery body:", err)
+	}
+	if change.Type != models.ChangeCreated || change.Event == nil || change.Event.ID != event.ID {
+		t.Errorf("delivered change = %+v, want creation of event %d", change, event.ID)
+	}
+	if ok.header.Get(EventHeader) != models.ChangeCreated {
+		t.Errorf("%s = %q, want %q", EventHeader, ok.header.Get(EventHeader), models.ChangeCreated)
+	}
+
+	signature := ok.header.Get(SignatureHeader)
+	timestamp, err := strconv.ParseInt(strings.TrimPrefix(strings.Split(signature, ",")[0], "t="), 10, 64)
+	if err != nil {
+		t.Fatalf("invalid signature header %q", signature)
+	}
+	if want := Sign(webhooks["/ok"].Secret, timestamp, ok.body); signature != want {
+		t.Errorf("signature = %q, want %q", signature, want)
+	}
+	if Sign("other secret", timestamp, ok.body) == signature {
+		t.Error("signature does not depend on the secret")
+	}
+
+	flaky := received["/flaky"]
+	if flaky[0].header.Get(DeliveryHeader) == "" || flaky[0].header.Get(DeliveryHeader) != flaky[2].header.Get(DeliveryHeader) {
+		t.Errorf("delivery keys of retries = %q and %q, want the same", flaky[0].header.Get(DeliveryHeader), flaky[2].header.Get(DeliveryHeader))
+	}
+
+	letters, _ := svc.GetDeadLetters(2)
+	attempts := map[int]int{}
+	for _, letter := range letters {
+		attempts[letter.WebhookID] = letter.Attempts
+		if letter.Change.Seq != change.Seq || letter.Error == "" {
+			t.Errorf("dead letter = %+v, want change %d with error", letter, change.Seq)
+		}
+	}
+	if attempts[webhooks["/rejecting"].ID] != 1 || attempts[webhooks["/down"].ID] != 3 || attempts[webhooks["/redirect"].ID] != 1 {
+		t.Errorf("dead letter attempts = %v, want 1 for a rejected or redirecting and 3 for an unavailable webhook", attempts)
+	}
+}
+
+func TestDispatcher_PrivateAddress(t *testing.T) {
+	var requests atomic.Int32
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		requests.Add(1)
+	}))
+	defer server.Close()
+
+	// Webhook зарегистрирован, а его адрес стал внутренним уже после
+	// регистрации, например после смены DNS-записи
+	svc := service.NewEventService(storage.NewInMemoryEventStorage())
+	svc.SetPrivateWebhooks(true)
+	if _, err := svc.RegisterWebhook(&models.CreateWebhookRequest{UserID: 1, URL: server.URL + "/hook"}); err != nil {
+		t.Fatal("RegisterWebhook() error:", err)
+	}
+
+	dispatcher := NewDispatcher(svc, Options{
+		Timeout:     time.Second,
+		MaxAttempts: 3,
+		Backoff:     time.Millisecond,
+		MaxBackoff:  time.Millisecond,
+		Workers:     1,
+	})
+	ctx, cancel := context.WithCancel(context.Background())
+	done := make(chan struct{})
+	go func() {
+		dispatcher.Run(ctx)
+		close(done)
+	}()
+	defer func() {
+		cancel()
+		<-done
+	}()
+
+	if _, err := svc.CreateEvent(&models.CreateEventRequest{UserID: 1, Start: "2024-01-08T10:00:00Z", Duration: "1h", Title: "Planning"}); err != nil {
+		t.Fatal("Failed to create event:", err)
+	}
+
+	deadline := time.Now().Add(5 * time.Second)
+	for {
+		letters, _ := svc.GetDeadLetters(1)
+		if len(letters) == 1 {
+			if letters[0].Attempts != 1 || !strings.Contains(letters[0].Error, "not allowed") {
+				t.Errorf("dead letter = %+v, want one attempt refused by address policy", letters[0])
+			}
+			break
+		}
+		if time.Now().After(deadline) {
+			t.Fatalf("dead letters = %+v, want 1", letters)
+		}
+		time.Sleep(5 * time.Millisecond)
+	}
+	if n := requests.Load(); n != 0 {
+		t.Errorf("server got %d requests, want none", n)
+	}
+}
@@ -1,23 +1,72 @@
 package main
 
 import (
+	"context"
+	"errors"
+	"flag"
 	"fmt"
+	"io"
 	"l2-18/config"
+	"l2-18/internal/auth"
+	"l2-18/internal/caldav"
 	"l2-18/internal/handler"
+	"l2-18/internal/logging"
+	"l2-18/internal/metrics"
 	"l2-18/internal/middleware"
+	"l2-18/internal/reminder"
 	"l2-18/internal/service"
 	"l2-18/internal/storage"
-	"log"
+	"l2-18/internal/trash"
+	"l2-18/internal/webhook"
+	"log/slog"
 	"net/http"
+	"os"
+	"os/signal"
+	"sync"
+	"syscall"
+	"time"
+
+	// Встроенная база часовых поясов на случай, если в системе ее нет
+	_ "time/tzdata"
 )
 
 func main() {
 	// Загружаем конфигурацию
-	cfg := config.Load()
+	cfg, err := config.Load(os.Args[1:])
+	if errors.Is(err, flag.ErrHelp) {
+		return
+	}
+	if err != nil {
+		fmt.Fprintln(os.Stderr, err)
+		os.Exit(1)
+	}
+	logger := newLogger(cfg)
+	slog.SetDefault(logger)
+
+	authenticator, err := newAuthenticator(cfg)
+	if err != nil {
+		fatal("failed to initialize authentication", err)
+	}
+
+	// Выпуск токена для пользователя без запуска сервера
+	if cfg.Auth.IssueTokenFor != 0 {
+		token, err := authenticator.IssueToken(cfg.Auth.IssueTokenFor, cfg.Auth.TokenTTL)
+		if err != nil {
+			fatal("failed to issue token", err)
+		}
+		fmt.Println(token)
+		return
+	}
 
 	// Создаем слои приложения
-	eventStorage := storage.NewInMemoryEventStorage()
+	eventStorage, err := newEventStorage(cfg)
+	if err != nil {
+		fatal("failed to initialize storage", err)
+	}
 	eventService := service.NewEventService(eventStorage)
+	eventService.SetTrashRetention(cfg.Trash.Retention)
+	eventService.SetPrivateWebhooks(cfg.Webhooks.AllowPrivate)
+	eventService.SetMaxRange(cfg.Limits.MaxRange)
 	eventHandler := handler.NewEventHandler(eventService)
 
 	// Настраиваем роуты
@@ -27,18 +76,266 @@ func main() {
 	mux.HandleFunc("/create_event", eventHandler.CreateEvent)
 	mux.HandleFunc("/update_event", eventHandler.UpdateEvent)
 	mux.HandleFunc("/delete_event", eventHandler.DeleteEvent)
+	mux.HandleFunc("/trash", eventHandler.Trash)
+	mux.HandleFunc("/restore_event", eventHandler.RestoreEvent)
+	mux.HandleFunc("/events/{id}/history", eventHandler.EventHistory)
+	mux.HandleFunc("/revert_event", eventHandler.RevertEvent)
 	mux.HandleFunc("/events_for_day", eventHandler.GetEventsForDay)
 	mux.HandleFunc("/events_for_week", eventHandler.GetEventsForWeek)
 	mux.HandleFunc("/events_for_month", eventHandler.GetEventsForMonth)
 
-	// Применяем middleware
-	handler := middleware.Logging(mux)
+	// Поиск событий
+	mux.HandleFunc("/search_events", eventHandler.SearchEvents)
+
+	// Лента изменений событий
+	mux.HandleFunc("/events/stream", eventHandler.StreamEvents)
+
+	// Приглашения и совместные календари
+	mux.HandleFunc("/rsvp_event", eventHandler.RespondToEvent)
+	mux.HandleFunc("/share_calendar", eventHandler.ShareCalendar)
+	mux.HandleFunc("/unshare_calendar", eventHandler.UnshareCalendar)
+
+	// Планирование встреч
+	mux.HandleFunc("/free_busy", eventHandler.FreeBusy)
+	mux.HandleFunc("/next_free_slot", eventHandler.NextFreeSlot)
+
+	// REST API v2
+	handler.NewV2Handler(eventService).Register(mux)
+
+	// Обмен с другими календарями
+	mux.HandleFunc("/export.ics", eventHandler.ExportICS)
+	mux.HandleFunc("/import", eventHandler.ImportICS)
+
+	// Настройки пользователя
+	mux.HandleFunc("/set_user_timezone", eventHandler.SetUserTimeZone)
+
+	// Синхронизация с календарными клиентами по CalDAV
+	if cfg.CalDAV {
+		mux.Handle("/caldav/", caldav.NewHandler(eventService, "/caldav/"))
+		mux.Handle("/.well-known/caldav", http.RedirectHandler("/caldav/", http.StatusMovedPermanently))
+	}
+
+	// SIGINT и SIGTERM останавливают сервер и фоновые задачи
+	ctx, stop := signal.NotifyContext(context.Background(), os.Interrupt, syscall.SIGTERM)
+	defer stop()
+
+	// Фоновая доставка напоминаний
+	var background sync.WaitGroup
+	if cfg.Reminders.Enabled {
+		scheduler, err := newReminderScheduler(cfg, eventService, eventStorage)
+		if err != nil {
+			fatal("failed to initialize reminders", err)
+		}
+		background.Add(1)
+		go func() {
+			defer background.Done()
+			scheduler.Run(ctx)
+		}()
+	}
+
+	// Доставка изменений событий на webhook пользователей
+	if cfg.Webhooks.Enabled {
+		dispatcher := webhook.NewDispatcher(eventService, webhook.Options{
+			Timeout:      cfg.Webhooks.Timeout,
+			MaxAttempts:  cfg.Webhooks.MaxAttempts,
+			Backoff:      cfg.Webhooks.Backoff,
+			MaxBackoff:   cfg.Webhooks.MaxBackoff,
+			Workers:      cfg.Webhooks.Workers,
+			AllowPrivate: cfg.Webhooks.AllowPrivate,
+		})
+		background.Add(1)
+		go func() {
+			defer background.Done()
+			dispatcher.Run(ctx)
+		}()
+	}
+
+	// Очистка корзины от событий с истекшим сроком хранения
+	purger := trash.NewPurger(eventService, cfg.Trash.PurgeInterval)
+	background.Add(1)
+	go func() {
+		defer background.Done()
+		purger.Run(ctx)
+	}()
+
+	// Применяем middleware. Частота ограничивается после аутентификации,
+	// чтобы считать запросы каждого пользователя отдельно, и до нее —
+	// по адресу, чтобы перебор токенов не доходил до проверки подписи.
+	var api http.Handler = middleware.BodyLimit(int64(cfg.Limits.MaxBodyBytes), mux)
+	if cfg.Limits.Requests > 0 {
+		api = middleware.RateLimit(middleware.RateLimitOptions{
+			Requests:       cfg.Limits.Requests,
+			Interval:       cfg.Limits.Interval,
+			Burst:          cfg.Limits.Burst,
+			TrustedProxies: cfg.Limits.TrustedProxies,
+		}, api)
+	}
+	if cfg.Auth.Enabled {
+		api = middleware.Auth(authenticator, api)
+	} else {
+		slog.Warn("authentication is disabled: user_id is taken from requests")
+	}
+	if cfg.Limits.AddressRequests > 0 {
+		api = middleware.RateLimit(middleware.RateLimitOptions{
+			Requests:       cfg.Limits.AddressRequests,
+			Interval:       cfg.Limits.Interval,
+			Burst:          cfg.Limits.AddressBurst,
+			PerAddress:     true,
+			TrustedProxies: cfg.Limits.TrustedProxies,
+		}, api)
+	}
+
+	// Проверки для оркестратора доступны без аутентификации
+	health := handler.NewHealthHandler(eventService)
+	root := http.NewServeMux()
+	health.Register(root)
+	root.Handle("/", api)
+
+	// Метрики для Prometheus тоже доступны без аутентификации
+	registry := metrics.NewRegistry()
+	registerStorageMetrics(registry, eventService)
+	root.Handle("GET /metrics", registry)
+
+	cors := middleware.CORSOptions{
+		AllowedOrigins: cfg.CORS.AllowedOrigins,
+		AllowedMethods: cfg.CORS.AllowedMethods,
+		AllowedHeaders: cfg.CORS.AllowedHeaders,
+		MaxAge:         cfg.CORS.MaxAge,
+	}
+
+	var app http.Handler = middleware.CORS(cors, root)
+	app = middleware.Metrics(registry, middleware.MuxRoute("/", root, mux), app)
+	app = middleware.Logging(logger, app)
+
+	server := &http.Server{
+		Addr:              fmt.Sprintf(":%d", cfg.Port),
+		Handler:           app,
+		ReadTimeout:       cfg.Server.ReadTimeout,
+		ReadHeaderTimeout: cfg.Server.ReadHeaderTimeout,
+		WriteTimeout:      cfg.Server.WriteTimeout,
+		IdleTimeout:       cfg.Server.IdleTimeout,
+	}
+	// Открытые ленты изменений закрываются, иначе остановка ждала бы их до таймаута
+	server.RegisterOnShutdown(eventService.Changes().Close)
 
 	// Запускаем сервер
-	addr := fmt.Sprintf(":%d", cfg.Port)
-	log.Printf("Starting server on %s", addr)
+	slog.Info("starting server", "addr", server.Addr)
+	if err := serve(ctx, server, health, cfg.Server.ShutdownTimeout); err != nil {
+		slog.Error("server error", "error", err)
+	}
+
+	background.Wait()
+	if closer, ok := eventStorage.(io.Closer); ok {
+		if err := closer.Close(); err != nil {
+			slog.Error("failed to close storage", "error", err)
+		}
+	}
+	slog.Info("server stopped")
+}
+
+// newLogger создает журнал приложения согласно конфигурации. Записи
+// внутри запроса получают его идентификатор в поле request_id.
+func newLogger(cfg *config.Config) *slog.Logger {
+	options := &slog.HandlerOptions{Level: cfg.LogLevel()}
+	var h slog.Handler = slog.NewJSONHandler(os.Stderr, options)
+	if cfg.Log.Format == config.LogFormatText {
+		h = slog.NewTextHandler(os.Stderr, options)
+	}
+	return slog.New(logging.NewHandler(h))
+}
+
+// registerStorageMetrics добавляет метрики числа событий и пользователей,
+// если хранилище сообщает эти сведения
+func registerStorageMetrics(registry *metrics.Registry, eventService *service.EventService) {
+	if _, ok, _ := eventService.Stats(); !ok {
+		return
+	}
+	stat := func(value func(storage.Stats) int) func() (float64, error) {
+		return func() (float64, error) {
+			stats, _, err := eventService.Stats()
+			return float64(value(stats)), err
+		}
+	}
+	registry.GaugeFunc("calendar_events", "Number of stored events.", stat(func(s storage.Stats) int { return s.Events }))
+	registry.GaugeFunc("calendar_users", "Number of users known to the storage.", stat(func(s storage.Stats) int { return s.Users }))
+}
+
+// fatal записывает ошибку запуска в журнал и завершает приложение
+func fatal(msg string, err error) {
+	slog.Error(msg, "error", err)
+	os.Exit(1)
+}
+
+// serve обслуживает запросы до отмены ctx, после чего снимает готовность
+// и ждет завершения текущих запросов не дольше shutdownTimeout
+func serve(ctx context.Context, server *http.Server, health *handler.HealthHandler, shutdownTimeout time.Duration) error {
+	errCh := make(chan error, 1)
+	go func() {
+		errCh <- server.ListenAndServe()
+	}()
+
+	select {
+	case err := <-errCh:
+		return err
+	case <-ctx.Done():
+	}
+
+	slog.Info("shutting down, waiting for in-flight requests", "timeout", shutdownTimeout)
+	health.Drain()
+
+	shutdownCtx, cancel := context.WithTimeout(context.Background(), shutdownTimeout)
+	defer cancel()
+	if err := server.Shutdown(shutdownCtx); err != nil {
+		return fmt.Errorf("graceful shutdown failed: %w", err)
+	}
+	if err := <-errCh; !errors.Is(err, http.ErrServerClosed) {
+		return err
+	}
+	return nil
+}
+
+// newEventStorage создает хранилище событий согласно конфигурации
+func newEventStorage(cfg *config.Config) (storage.EventStorage, error) {
+	switch cfg.Storage.Type {
+	case config.StorageMemory:
+		return storage.NewInMemoryEventStorage(), nil
+	case config.StorageFile:
+		return storage.NewFileEventStorage(cfg.Storage.Path)
+	case config.StorageWAL:
+		return storage.NewWALEventStorage(cfg.Storage.Path, cfg.Storage.WALCompactEvery)
+	default:
+		return nil, fmt.Errorf("unknown storage type %q", cfg.Storage.Type)
+	}
+}
+
+// newAuthenticator создает аутентификатор согласно конфигурации
+func newAuthenticator(cfg *config.Config) (*auth.Authenticator, error) {
+	apiKeys, err := auth.ParseAPIKeys(cfg.Auth.APIKeys)
+	if err != nil {
+		return nil, err
+	}
+
+	return auth.NewAuthenticator(cfg.Auth.Secret, apiKeys), nil
+}
+
+// newReminderScheduler создает планировщик напоминаний согласно конфигурации
+func newReminderScheduler(cfg *config.Config, eventService *service.EventService, eventStorage storage.EventStorage) (*reminder.Scheduler, error) {
+	tracker, ok := eventStorage.(storage.ReminderStorage)
+	if !ok {
+		return nil, fmt.Errorf("storage %q does not support reminders", cfg.Storage.Type)
+	}
 
-	if err := http.ListenAndServe(addr, handler); err != nil {
-		log.Fatal("Server failed to start:", err)
+	var notifier reminder.Notifier
+	switch cfg.Reminders.Notifier {
+	case config.NotifierLog:
+		notifier = reminder.LogNotifier{}
+	case config.NotifierWebhook:
+		notifier = reminder.NewWebhookNotifier(cfg.Reminders.WebhookURL)
+	case config.NotifierSMTP:
+		notifier = reminder.NewSMTPNotifier(cfg.Reminders.SMTPAddr, cfg.Reminders.SMTPFrom, cfg.Reminders.SMTPTo)
+	default:
+		return nil, fmt.Errorf("unknown reminder notifier %q", cfg.Reminders.Notifier)
 	}
+
+	return reminder.NewScheduler(eventService, tracker, notifier, cfg.Reminders.Interval, cfg.Reminders.MaxDelay), nil
 }